@@ -10,6 +10,7 @@ var defaultTTLs = map[string]time.Duration{
 	TypeOrderCancel:         10 * time.Minute,
 	TypeOrderRedirect:       10 * time.Minute,
 	TypeOrderStorageWaybill: 10 * time.Minute,
+	TypeOrderRelease:        10 * time.Minute,
 
 	TypeOrderAck:    10 * time.Minute,
 	TypeOrderUpdate: 10 * time.Minute,
@@ -34,6 +35,8 @@ var subjectTTLs = map[string]time.Duration{
 	SubjectEdgeStale:           5 * time.Minute,
 	SubjectNodeListRequest:     5 * time.Minute,
 	SubjectNodeListResponse:    5 * time.Minute,
+	SubjectZoneLock:            5 * time.Minute,
+	SubjectZoneLockReply:       5 * time.Minute,
 }
 
 // FallbackTTL is used when no specific TTL is configured.
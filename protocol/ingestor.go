@@ -20,6 +20,7 @@ type MessageHandler interface {
 	HandleOrderReceipt(env *Envelope, p *OrderReceipt)
 	HandleOrderRedirect(env *Envelope, p *OrderRedirect)
 	HandleOrderStorageWaybill(env *Envelope, p *OrderStorageWaybill)
+	HandleOrderRelease(env *Envelope, p *OrderRelease)
 
 	// Core -> Edge
 	HandleOrderAck(env *Envelope, p *OrderAck)
@@ -99,6 +100,8 @@ func (ing *Ingestor) HandleRaw(data []byte) {
 		decodeAndCall(ing.handler.HandleOrderRedirect, &env, ing.dbg)
 	case TypeOrderStorageWaybill:
 		decodeAndCall(ing.handler.HandleOrderStorageWaybill, &env, ing.dbg)
+	case TypeOrderRelease:
+		decodeAndCall(ing.handler.HandleOrderRelease, &env, ing.dbg)
 	case TypeOrderAck:
 		decodeAndCall(ing.handler.HandleOrderAck, &env, ing.dbg)
 	case TypeOrderWaybill:
@@ -10,6 +10,7 @@ func (NoOpHandler) HandleOrderCancel(*Envelope, *OrderCancel)                 {}
 func (NoOpHandler) HandleOrderReceipt(*Envelope, *OrderReceipt)               {}
 func (NoOpHandler) HandleOrderRedirect(*Envelope, *OrderRedirect)             {}
 func (NoOpHandler) HandleOrderStorageWaybill(*Envelope, *OrderStorageWaybill) {}
+func (NoOpHandler) HandleOrderRelease(*Envelope, *OrderRelease)               {}
 func (NoOpHandler) HandleOrderAck(*Envelope, *OrderAck)                       {}
 func (NoOpHandler) HandleOrderWaybill(*Envelope, *OrderWaybill)               {}
 func (NoOpHandler) HandleOrderUpdate(*Envelope, *OrderUpdate)                 {}
@@ -56,6 +56,7 @@ type OrderRequest struct {
 	LoadType        string  `json:"load_type,omitempty"`
 	Priority        int     `json:"priority,omitempty"`
 	RetrieveEmpty   bool    `json:"retrieve_empty,omitempty"`
+	MaxWaitSec      int     `json:"max_wait_sec,omitempty"` // how long core may hold the order waiting for stock; 0 uses the core default
 }
 
 // OrderCancel cancels an existing order.
@@ -86,6 +87,13 @@ type OrderStorageWaybill struct {
 	FinalCount  float64 `json:"final_count"`
 }
 
+// OrderRelease lets a staged order continue from its staging node to the
+// delivery node.
+type OrderRelease struct {
+	OrderUUID string `json:"order_uuid"`
+	Reason    string `json:"reason,omitempty"`
+}
+
 // --- Order payloads: Core -> Edge ---
 
 // OrderAck confirms order acceptance.
@@ -166,6 +174,28 @@ type ProductionReportAck struct {
 	Accepted  int    `json:"accepted"`
 }
 
+// ZoneLock asks core to lock or unlock an exclusive zone, e.g. a press area
+// for the length of a die changeover. Orders to or from a locked zone are
+// held in core until it is unlocked.
+type ZoneLock struct {
+	StationID string `json:"station_id"`
+	Zone      string `json:"zone"`
+	Locked    bool   `json:"locked"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// ZoneLockReply tells an edge whether its zone lock or unlock took effect.
+// Only the party holding a lock can release it, so a lock is refused while an
+// operator or another edge holds the zone.
+type ZoneLockReply struct {
+	StationID string `json:"station_id"`
+	Zone      string `json:"zone"`
+	Locked    bool   `json:"locked"` // what the edge asked for
+	Granted   bool   `json:"granted"`
+	HeldBy    string `json:"held_by,omitempty"` // owner of the lock, when refused
+	Detail    string `json:"detail,omitempty"`
+}
+
 // EdgeStale is sent by core to notify an edge that it has been marked stale.
 type EdgeStale struct {
 	StationID string `json:"station_id"`
@@ -11,6 +11,7 @@ const (
 	TypeOrderReceipt       = "order.receipt"
 	TypeOrderRedirect      = "order.redirect"
 	TypeOrderStorageWaybill = "order.storage_waybill"
+	TypeOrderRelease       = "order.release"
 
 	// Core -> Edge (published on dispatch topic)
 	TypeOrderAck        = "order.ack"
@@ -35,6 +36,9 @@ const (
 
 	SubjectNodeListRequest  = "node.list_request"
 	SubjectNodeListResponse = "node.list_response"
+
+	SubjectZoneLock      = "zone.lock"
+	SubjectZoneLockReply = "zone.lock_reply"
 )
 
 // Roles for Address.Role.
@@ -49,14 +53,25 @@ const StationBroadcast = "*"
 // Protocol version.
 const Version = 1
 
+// Canonical order type constants shared by core and edge.
+const (
+	OrderTypeRetrieve = "retrieve"
+	OrderTypeMove     = "move"
+	OrderTypeStore    = "store"
+	OrderTypeSwap     = "swap" // deliver a full payload and take the line's empty back in one trip
+)
+
 // Canonical order status constants shared by core and edge.
 const (
 	StatusPending      = "pending"
+	StatusQueued       = "queued" // held in core by an in-flight cap
 	StatusSourcing     = "sourcing"
+	StatusRetrying     = "retrying" // waiting in core to retry a failed fleet order
 	StatusSubmitted    = "submitted"
 	StatusDispatched   = "dispatched"
 	StatusAcknowledged = "acknowledged"
 	StatusInTransit    = "in_transit"
+	StatusStaged       = "staged"
 	StatusDelivered    = "delivered"
 	StatusConfirmed    = "confirmed"
 	StatusFailed       = "failed"
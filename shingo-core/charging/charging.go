@@ -0,0 +1,465 @@
+// Package charging sends robots to charge when their battery runs low or
+// while they sit idle, takes them out of dispatch while they charge and
+// returns them once charged, keeping a minimum number of robots available
+// during each shift.
+package charging
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shingocore/dispatch"
+	"shingocore/fleet"
+)
+
+// NodeType is the node type of charging stations.
+const NodeType = "charging"
+
+// orderPrefix marks vendor orders created to send a robot to charge.
+const orderPrefix = "chg-"
+
+// orderSettle is how long a robot may take to start charging once its charge
+// order has ended, before it is returned to service.
+const orderSettle = time.Minute
+
+// IsChargeOrder reports whether a vendor order was created by the charging
+// manager rather than for a ShinGo order.
+func IsChargeOrder(vendorOrderID string) bool {
+	return strings.HasPrefix(vendorOrderID, orderPrefix)
+}
+
+// Policy holds the charging thresholds, in battery percent.
+type Policy struct {
+	ChargeBelow      float64       // send a robot to charge below this level
+	ReleaseAbove     float64       // return a charging robot to service at this level
+	OpportunityBelow float64       // idle robots below this level charge opportunistically; 0 disables
+	OpportunityIdle  time.Duration // how long a robot must sit idle before an opportunity charge
+	ChargeTimeout    time.Duration // return a robot that has not charged for this long to service; 0 disables
+	Shifts           []Shift
+}
+
+// Shift is a window in which at least MinAvailable robots are kept in
+// service. Opportunity charges wait while the floor would be broken, and
+// charging robots above ChargeBelow are returned early to restore it.
+type Shift struct {
+	Window       dispatch.DowntimeWindow
+	MinAvailable int
+}
+
+// Station is a charging node.
+type Station struct {
+	Node           string
+	VendorLocation string
+}
+
+// Action reports a robot sent to charge or returned to service.
+type Action struct {
+	VehicleID string    `json:"vehicle_id"`
+	Action    string    `json:"action"` // "charge" or "release"
+	Reason    string    `json:"reason"`
+	Station   string    `json:"station,omitempty"`
+	Battery   float64   `json:"battery"`
+	At        time.Time `json:"at"`
+}
+
+// View is a robot under the manager's control, for the web UI.
+type View struct {
+	VehicleID string    `json:"vehicle_id"`
+	Reason    string    `json:"reason"`
+	Station   string    `json:"station"`
+	OrderID   string    `json:"order_id,omitempty"`
+	Battery   float64   `json:"battery"`
+	Since     time.Time `json:"since"`
+}
+
+// Store keeps the robots under the manager's control across restarts. The
+// manager is the only record of why a robot is out of dispatch, so without
+// a store a restart leaves robots that are not on a charger out for good.
+type Store interface {
+	SaveCharge(v View) error
+	DeleteCharge(vehicleID string) error
+	LoadCharges() ([]View, error)
+}
+
+type charge struct {
+	reason  string
+	station string
+	orderID string // charge order sent to the fleet, "" when charging in place
+	battery float64
+	since   time.Time
+	active  time.Time // last seen charging, or when taken out of dispatch
+	ended   time.Time // when the charge order was seen ended, zero until then
+}
+
+func (c *charge) view(vehicleID string) View {
+	return View{VehicleID: vehicleID, Reason: c.reason, Station: c.station, OrderID: c.orderID, Battery: c.battery, Since: c.since}
+}
+
+// Manager decides which robots charge. It is driven by the engine calling
+// Update with every robot status refresh.
+type Manager struct {
+	robots   fleet.RobotLister
+	charger  fleet.RobotCharger
+	policy   Policy
+	Stations func() ([]Station, error)
+	Action   func(Action)
+	Store    Store // nil keeps managed robots in memory only
+	DebugLog func(string, ...any)
+
+	// OrderEnded reports whether a charge order has ended in the robot's
+	// fleet, or is unknown to it. Without it, robots whose charge order
+	// fails are only returned to service after ChargeTimeout.
+	OrderEnded func(fleetName, vendorOrderID string) (bool, error)
+
+	mu        sync.Mutex
+	charging  map[string]*charge   // vehicle -> charge in progress
+	idleSince map[string]time.Time // vehicle -> when it was last seen idle and in service
+}
+
+// NewManager creates a charging manager. charger may be nil when the fleet
+// cannot send robots anywhere, in which case low robots are only taken out
+// of dispatch and are expected to charge where they stand.
+func NewManager(robots fleet.RobotLister, charger fleet.RobotCharger, policy Policy) *Manager {
+	return &Manager{
+		robots:    robots,
+		charger:   charger,
+		policy:    policy,
+		charging:  make(map[string]*charge),
+		idleSince: make(map[string]time.Time),
+	}
+}
+
+func (m *Manager) dbg(format string, args ...any) {
+	if fn := m.DebugLog; fn != nil {
+		fn(format, args...)
+	}
+}
+
+// Load takes back under control the robots saved in the store, e.g. before
+// a restart. Call it once before the first Update.
+func (m *Manager) Load() error {
+	if m.Store == nil {
+		return nil
+	}
+	saved, err := m.Store.LoadCharges()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range saved {
+		m.charging[v.VehicleID] = &charge{reason: v.Reason, station: v.Station, orderID: v.OrderID, since: v.Since}
+	}
+	return nil
+}
+
+func (m *Manager) save(vehicleID string, c *charge) {
+	if m.Store == nil {
+		return
+	}
+	m.mu.Lock()
+	v := c.view(vehicleID)
+	m.mu.Unlock()
+	if err := m.Store.SaveCharge(v); err != nil {
+		log.Printf("charging: save %s: %v", vehicleID, err)
+	}
+}
+
+// minAvailable returns the shift floor in force at now.
+func (m *Manager) minAvailable(now time.Time) int {
+	n := 0
+	for _, s := range m.policy.Shifts {
+		if s.Window.Contains(now) && s.MinAvailable > n {
+			n = s.MinAvailable
+		}
+	}
+	return n
+}
+
+// inService reports whether a robot can take work.
+func inService(r fleet.RobotStatus) bool {
+	return r.Connected && r.Available && !r.IsError && !r.Emergency
+}
+
+// Update acts on a robot status refresh: it returns charged robots to
+// service, sends low and idle robots to charge, and releases robots early
+// when the shift floor is not met.
+func (m *Manager) Update(robots []fleet.RobotStatus, now time.Time) {
+	var stations []Station
+	if m.Stations != nil {
+		var err error
+		if stations, err = m.Stations(); err != nil {
+			log.Printf("charging: list stations: %v", err)
+		}
+	}
+	stationLocs := make(map[string]string, len(stations))
+	for _, s := range stations {
+		stationLocs[s.VendorLocation] = s.Node
+	}
+
+	m.mu.Lock()
+	found := make(map[string]*charge)
+	available := 0
+	for _, r := range robots {
+		c := m.charging[r.VehicleID]
+		// A robot left out of dispatch on a charger, e.g. by a build without
+		// a store, is taken back under control.
+		if c == nil && !r.Available && r.Charging && stationLocs[r.CurrentStation] != "" {
+			c = &charge{reason: "found charging", station: stationLocs[r.CurrentStation], since: now}
+			m.charging[r.VehicleID] = c
+			found[r.VehicleID] = c
+		}
+		if c != nil {
+			c.battery = r.BatteryLevel
+			if r.Charging || c.active.IsZero() {
+				c.active = now
+			}
+			continue
+		}
+		if inService(r) {
+			available++
+			if r.Busy {
+				delete(m.idleSince, r.VehicleID)
+			} else if _, ok := m.idleSince[r.VehicleID]; !ok {
+				m.idleSince[r.VehicleID] = now
+			}
+		} else {
+			delete(m.idleSince, r.VehicleID)
+		}
+	}
+	// Robots missing from the refresh, e.g. while their fleet cannot be
+	// reached, stay under control.
+	m.mu.Unlock()
+	for id, c := range found {
+		m.save(id, c)
+	}
+
+	floor := m.minAvailable(now)
+	available += m.releaseCharged(robots, now)
+	available += m.releaseStalled(robots, now)
+	if available < floor {
+		available += m.releaseEarly(robots, floor-available, now)
+	}
+	m.sendLow(robots, stations, available, floor, now)
+}
+
+// releaseCharged returns robots that have reached ReleaseAbove to service.
+func (m *Manager) releaseCharged(robots []fleet.RobotStatus, now time.Time) int {
+	n := 0
+	for _, r := range robots {
+		m.mu.Lock()
+		c := m.charging[r.VehicleID]
+		m.mu.Unlock()
+		if c == nil || r.BatteryLevel < m.policy.ReleaseAbove {
+			continue
+		}
+		if m.release(r, "charged", now) {
+			n++
+		}
+	}
+	return n
+}
+
+// releaseStalled returns robots to service that stopped short of charging:
+// their charge order ended without them charging, or they have not charged
+// for ChargeTimeout. Robots still low are sent again on a later refresh.
+func (m *Manager) releaseStalled(robots []fleet.RobotStatus, now time.Time) int {
+	n := 0
+	for _, r := range robots {
+		if r.Charging {
+			continue
+		}
+		m.mu.Lock()
+		c := m.charging[r.VehicleID]
+		var orderID string
+		var active, ended time.Time
+		if c != nil {
+			orderID, active, ended = c.orderID, c.active, c.ended
+		}
+		m.mu.Unlock()
+		if c == nil {
+			continue
+		}
+
+		reason := ""
+		switch {
+		case m.policy.ChargeTimeout > 0 && now.Sub(active) >= m.policy.ChargeTimeout:
+			reason = fmt.Sprintf("not charging for %s", m.policy.ChargeTimeout)
+		case !ended.IsZero():
+			if now.Sub(ended) >= orderSettle {
+				reason = "charge order ended without charging"
+			}
+		case orderID != "" && m.OrderEnded != nil:
+			done, err := m.OrderEnded(r.Fleet, orderID)
+			if err != nil {
+				log.Printf("charging: check charge order %s: %v", orderID, err)
+			} else if done {
+				m.dbg("charging: charge order %s of %s ended", orderID, r.VehicleID)
+				m.mu.Lock()
+				c.ended = now
+				m.mu.Unlock()
+			}
+		}
+		if reason != "" && m.release(r, reason, now) {
+			n++
+		}
+	}
+	return n
+}
+
+// releaseEarly returns up to want charging robots above ChargeBelow to
+// service, fullest first, to restore the shift floor.
+func (m *Manager) releaseEarly(robots []fleet.RobotStatus, want int, now time.Time) int {
+	var candidates []fleet.RobotStatus
+	m.mu.Lock()
+	for _, r := range robots {
+		if m.charging[r.VehicleID] != nil && r.Connected && !r.IsError && r.BatteryLevel >= m.policy.ChargeBelow {
+			candidates = append(candidates, r)
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].BatteryLevel > candidates[j].BatteryLevel })
+
+	n := 0
+	for _, r := range candidates {
+		if n == want {
+			break
+		}
+		if m.release(r, "shift minimum", now) {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Manager) release(r fleet.RobotStatus, reason string, now time.Time) bool {
+	if err := m.robots.SetAvailability(r.VehicleID, true); err != nil {
+		log.Printf("charging: return %s to service: %v", r.VehicleID, err)
+		return false
+	}
+	m.mu.Lock()
+	c := m.charging[r.VehicleID]
+	delete(m.charging, r.VehicleID)
+	m.mu.Unlock()
+	if m.Store != nil {
+		if err := m.Store.DeleteCharge(r.VehicleID); err != nil {
+			log.Printf("charging: forget %s: %v", r.VehicleID, err)
+		}
+	}
+	station := ""
+	if c != nil {
+		station = c.station
+	}
+	m.emit(Action{VehicleID: r.VehicleID, Action: "release", Reason: reason, Station: station, Battery: r.BatteryLevel, At: now})
+	return true
+}
+
+// sendLow sends idle robots below ChargeBelow to charge, then opportunity
+// charges idle robots as far as the shift floor allows, lowest battery first.
+func (m *Manager) sendLow(robots []fleet.RobotStatus, stations []Station, available, floor int, now time.Time) {
+	type candidate struct {
+		robot  fleet.RobotStatus
+		reason string
+		low    bool
+	}
+	var candidates []candidate
+	m.mu.Lock()
+	for _, r := range robots {
+		if m.charging[r.VehicleID] != nil || !inService(r) || r.Busy {
+			continue
+		}
+		switch {
+		case r.BatteryLevel < m.policy.ChargeBelow:
+			candidates = append(candidates, candidate{r, fmt.Sprintf("battery %.0f%% below %.0f%%", r.BatteryLevel, m.policy.ChargeBelow), true})
+		case m.policy.OpportunityBelow > 0 && r.BatteryLevel < m.policy.OpportunityBelow &&
+			now.Sub(m.idleSince[r.VehicleID]) >= m.policy.OpportunityIdle:
+			candidates = append(candidates, candidate{r, fmt.Sprintf("opportunity charge at %.0f%%", r.BatteryLevel), false})
+		}
+	}
+	m.mu.Unlock()
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].low != candidates[j].low {
+			return candidates[i].low
+		}
+		return candidates[i].robot.BatteryLevel < candidates[j].robot.BatteryLevel
+	})
+
+	for _, c := range candidates {
+		// Low robots charge regardless of the floor; they would stop anyway.
+		if !c.low && available-1 < floor {
+			m.dbg("charging: %s waits to opportunity charge: %d available, shift minimum %d", c.robot.VehicleID, available, floor)
+			continue
+		}
+		if m.send(c.robot, c.reason, stations, now) {
+			available--
+		}
+	}
+}
+
+func (m *Manager) send(r fleet.RobotStatus, reason string, stations []Station, now time.Time) bool {
+	station, orderID := "", ""
+	if m.charger != nil {
+		s, ok := m.freeStation(stations)
+		if !ok {
+			m.dbg("charging: no free charging station for %s", r.VehicleID)
+			return false
+		}
+		orderID = fmt.Sprintf("%s%s-%d", orderPrefix, r.VehicleID, now.Unix())
+		if err := m.charger.SendToCharge(orderID, r.VehicleID, s.VendorLocation); err != nil {
+			log.Printf("charging: send %s to %s: %v", r.VehicleID, s.Node, err)
+			return false
+		}
+		station = s.Node
+	}
+	if err := m.robots.SetAvailability(r.VehicleID, false); err != nil {
+		log.Printf("charging: take %s out of dispatch: %v", r.VehicleID, err)
+	}
+	c := &charge{reason: reason, station: station, orderID: orderID, battery: r.BatteryLevel, since: now, active: now}
+	m.mu.Lock()
+	m.charging[r.VehicleID] = c
+	delete(m.idleSince, r.VehicleID)
+	m.mu.Unlock()
+	m.save(r.VehicleID, c)
+	m.emit(Action{VehicleID: r.VehicleID, Action: "charge", Reason: reason, Station: station, Battery: r.BatteryLevel, At: now})
+	return true
+}
+
+// freeStation returns a charging station no managed robot is using.
+func (m *Manager) freeStation(stations []Station) (Station, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	used := make(map[string]bool, len(m.charging))
+	for _, c := range m.charging {
+		used[c.station] = true
+	}
+	for _, s := range stations {
+		if !used[s.Node] {
+			return s, true
+		}
+	}
+	return Station{}, false
+}
+
+func (m *Manager) emit(a Action) {
+	log.Printf("charging: %s %s: %s (battery %.0f%%)", a.Action, a.VehicleID, a.Reason, a.Battery)
+	if m.Action != nil {
+		m.Action(a)
+	}
+}
+
+// Charging returns the robots currently charging under the manager's
+// control.
+func (m *Manager) Charging() []View {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]View, 0, len(m.charging))
+	for id, c := range m.charging {
+		out = append(out, c.view(id))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].VehicleID < out[j].VehicleID })
+	return out
+}
@@ -0,0 +1,194 @@
+package charging
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"shingocore/dispatch"
+	"shingocore/fleet"
+)
+
+type fakeFleet struct {
+	calls []string
+}
+
+func (f *fakeFleet) GetRobotsStatus() ([]fleet.RobotStatus, error) { return nil, nil }
+func (f *fakeFleet) RetryFailed(string) error                      { return nil }
+func (f *fakeFleet) ForceComplete(string) error                    { return nil }
+
+func (f *fakeFleet) SetAvailability(vehicleID string, available bool) error {
+	f.calls = append(f.calls, fmt.Sprintf("available %s %v", vehicleID, available))
+	return nil
+}
+
+func (f *fakeFleet) SendToCharge(_, vehicleID, loc string) error {
+	f.calls = append(f.calls, fmt.Sprintf("charge %s %s", vehicleID, loc))
+	return nil
+}
+
+func robot(id string, battery float64) fleet.RobotStatus {
+	return fleet.RobotStatus{VehicleID: id, Connected: true, Available: true, BatteryLevel: battery}
+}
+
+func stations(locs ...string) func() ([]Station, error) {
+	return func() ([]Station, error) {
+		var out []Station
+		for _, l := range locs {
+			out = append(out, Station{Node: "CHG-" + l, VendorLocation: l})
+		}
+		return out, nil
+	}
+}
+
+func TestLowRobotsChargeAndReturn(t *testing.T) {
+	f := &fakeFleet{}
+	m := NewManager(f, f, Policy{ChargeBelow: 20, ReleaseAbove: 90})
+	m.Stations = stations("CP1")
+	var actions []string
+	m.Action = func(a Action) { actions = append(actions, a.Action+" "+a.VehicleID) }
+
+	now := time.Now()
+	busy := robot("AMB-03", 10)
+	busy.Busy = true
+	m.Update([]fleet.RobotStatus{robot("AMB-01", 15), robot("AMB-02", 12), busy}, now)
+
+	// The lower robot takes the only station; the busy one finishes its order first
+	want := []string{"charge AMB-02 CP1", "available AMB-02 false"}
+	if fmt.Sprint(f.calls) != fmt.Sprint(want) {
+		t.Fatalf("calls = %v, want %v", f.calls, want)
+	}
+	if v := m.Charging(); len(v) != 1 || v[0].VehicleID != "AMB-02" || v[0].Station != "CHG-CP1" {
+		t.Fatalf("charging = %+v", v)
+	}
+
+	f.calls = nil
+	charged := robot("AMB-02", 92)
+	charged.Available = false
+	charged.Charging = true
+	m.Update([]fleet.RobotStatus{robot("AMB-01", 15), charged}, now.Add(time.Hour))
+	want = []string{"available AMB-02 true", "charge AMB-01 CP1", "available AMB-01 false"}
+	if fmt.Sprint(f.calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", f.calls, want)
+	}
+	if fmt.Sprint(actions) != "[charge AMB-02 release AMB-02 charge AMB-01]" {
+		t.Errorf("actions = %v", actions)
+	}
+}
+
+func TestShiftMinimum(t *testing.T) {
+	always, _ := dispatch.ParseDowntimeWindow(nil, "00:00", "23:59")
+	f := &fakeFleet{}
+	m := NewManager(f, f, Policy{
+		ChargeBelow:      20,
+		ReleaseAbove:     90,
+		OpportunityBelow: 60,
+		OpportunityIdle:  time.Minute,
+		Shifts:           []Shift{{Window: always, MinAvailable: 2}},
+	})
+	m.Stations = stations("CP1", "CP2")
+
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local)
+	fleetNow := []fleet.RobotStatus{robot("AMB-01", 40), robot("AMB-02", 50), robot("AMB-03", 80)}
+	m.Update(fleetNow, now)
+	if len(f.calls) != 0 {
+		t.Fatalf("calls before idle time = %v, want none", f.calls)
+	}
+
+	// Idle long enough: only one may go without breaking the floor of 2
+	m.Update(fleetNow, now.Add(2*time.Minute))
+	want := []string{"charge AMB-01 CP1", "available AMB-01 false"}
+	if fmt.Sprint(f.calls) != fmt.Sprint(want) {
+		t.Fatalf("calls = %v, want %v", f.calls, want)
+	}
+
+	// AMB-03 drops out; AMB-01 is charged enough to come back early
+	f.calls = nil
+	offline := robot("AMB-03", 80)
+	offline.Connected = false
+	charging := robot("AMB-01", 45)
+	charging.Available = false
+	charging.Charging = true
+	m.Update([]fleet.RobotStatus{charging, robot("AMB-02", 50), offline}, now.Add(3*time.Minute))
+	want = []string{"available AMB-01 true"}
+	if fmt.Sprint(f.calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", f.calls, want)
+	}
+	if len(m.Charging()) != 0 {
+		t.Errorf("charging = %+v, want none", m.Charging())
+	}
+}
+
+type memStore map[string]View
+
+func (s memStore) SaveCharge(v View) error            { s[v.VehicleID] = v; return nil }
+func (s memStore) DeleteCharge(vehicleID string) error { delete(s, vehicleID); return nil }
+
+func (s memStore) LoadCharges() ([]View, error) {
+	var out []View
+	for _, v := range s {
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func TestRestoredRobotReleasedWhenOrderEnds(t *testing.T) {
+	f := &fakeFleet{}
+	st := memStore{"AMB-01": {VehicleID: "AMB-01", Reason: "battery low", Station: "CHG-CP1", OrderID: "chg-AMB-01-1"}}
+	m := NewManager(f, f, Policy{ChargeBelow: 20, ReleaseAbove: 90})
+	m.Stations = stations("CP1")
+	m.Store = st
+	ended := false
+	m.OrderEnded = func(_, id string) (bool, error) { return ended && id == "chg-AMB-01-1", nil }
+	if err := m.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// Still driving to the charger after the restart: kept out of dispatch.
+	now := time.Now()
+	driving := robot("AMB-01", 15)
+	driving.Available = false
+	driving.Busy = true
+	m.Update([]fleet.RobotStatus{driving}, now)
+	if len(f.calls) != 0 || len(m.Charging()) != 1 {
+		t.Fatalf("calls = %v, charging = %+v", f.calls, m.Charging())
+	}
+
+	// The order fails short of the charger; the robot is released once it
+	// has had time to start charging and did not.
+	ended = true
+	stuck := driving
+	stuck.Busy = false
+	m.Update([]fleet.RobotStatus{stuck}, now.Add(time.Minute))
+	if len(f.calls) != 0 {
+		t.Fatalf("released before settling: %v", f.calls)
+	}
+	m.Update([]fleet.RobotStatus{stuck}, now.Add(2*time.Minute+time.Second))
+	if fmt.Sprint(f.calls) != "[available AMB-01 true]" {
+		t.Errorf("calls = %v, want AMB-01 returned to service", f.calls)
+	}
+	if len(st) != 0 {
+		t.Errorf("store still holds %+v", st)
+	}
+}
+
+func TestChargeTimeout(t *testing.T) {
+	f := &fakeFleet{}
+	st := memStore{}
+	m := NewManager(f, nil, Policy{ChargeBelow: 20, ReleaseAbove: 90, ChargeTimeout: 10 * time.Minute})
+	m.Store = st
+
+	now := time.Now()
+	m.Update([]fleet.RobotStatus{robot("AMB-01", 15)}, now)
+	if v, ok := st["AMB-01"]; !ok || v.OrderID != "" {
+		t.Fatalf("store = %+v, want AMB-01 charging in place", st)
+	}
+
+	out := robot("AMB-01", 15)
+	out.Available = false
+	f.calls = nil
+	m.Update([]fleet.RobotStatus{out}, now.Add(11*time.Minute))
+	if fmt.Sprint(f.calls) != "[available AMB-01 true]" {
+		t.Errorf("calls = %v, want AMB-01 returned after the timeout", f.calls)
+	}
+}
@@ -18,9 +18,13 @@ import (
 	"shingocore/config"
 	"shingocore/debuglog"
 	"shingocore/engine"
+	"shingocore/fleet"
 	"shingocore/fleet/seerrds"
+	"shingocore/fleet/sim"
+	"shingocore/fleet/vda5050"
 	"shingocore/messaging"
 	"shingocore/nodestate"
+	"shingocore/rds"
 	"shingocore/store"
 	"shingocore/www"
 )
@@ -28,6 +32,11 @@ import (
 var Version = "dev"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	// Strip --log-debug / -log-debug from os.Args before flag.Parse,
 	// because flag.String always requires a value argument but we want
 	// bare --log-debug (no value) to mean "all subsystems".
@@ -54,6 +63,7 @@ func main() {
 
 	if *showHelp {
 		fmt.Println("Usage: shingocore [options]")
+		fmt.Println("       shingocore migrate [--config PATH] status|up|down")
 		fmt.Println()
 		fmt.Println("Options:")
 		fmt.Println("  --config PATH         config file path (default: shingocore.yaml)")
@@ -64,6 +74,8 @@ func main() {
 		fmt.Println()
 		fmt.Println("Debug subsystems:")
 		fmt.Println("  rds           Fleet manager (Seer RDS) HTTP requests/responses")
+		fmt.Println("  sim           Simulated fleet: order assignment, transitions")
+		fmt.Println("  vda5050       VDA 5050 adapter: vehicles, assignment, order state")
 		fmt.Println("  kafka         Kafka connect, publish, subscribe, receive")
 		fmt.Println("  dispatch      Order lifecycle: request routing, fleet dispatch")
 		fmt.Println("  protocol      Protocol envelope decode/encode")
@@ -76,6 +88,7 @@ func main() {
 		fmt.Println("  shingocore --log-debug              # all subsystems to file")
 		fmt.Println("  shingocore --log-debug=rds           # only RDS to file")
 		fmt.Println("  shingocore --log-debug=rds,dispatch  # RDS + dispatch to file")
+		fmt.Println("  shingocore migrate status            # schema version and pending migrations")
 		os.Exit(0)
 	}
 
@@ -134,13 +147,11 @@ func main() {
 		log.Printf("shingocore: redis sync from SQL: %v", err)
 	}
 
-	// Fleet backend (Seer RDS adapter)
-	fleetAdapter := seerrds.New(seerrds.Config{
-		BaseURL:      cfg.RDS.BaseURL,
-		Timeout:      cfg.RDS.Timeout,
-		PollInterval: cfg.RDS.PollInterval,
-		DebugLog:     dbg.Func("rds"),
-	})
+	// Fleet backend
+	fleetAdapter, err := newFleetBackend(cfg, db, dbg)
+	if err != nil {
+		log.Fatalf("fleet config: %v", err)
+	}
 	if err := fleetAdapter.Ping(); err == nil {
 		log.Printf("shingocore: fleet backend connected (%s)", fleetAdapter.Name())
 	} else {
@@ -226,3 +237,159 @@ func main() {
 
 	log.Printf("shingocore: stopped")
 }
+
+// newFleetBackend builds the fleet backend selected by fleet.backend, or a
+// registry of the fleets listed under fleet.fleets.
+func newFleetBackend(cfg *config.Config, db *store.DB, dbg *debuglog.Logger) (fleet.Backend, error) {
+	if len(cfg.Fleet.Fleets) == 0 {
+		return newBackend(cfg.Fleet.Backend, cfg.Fleet.Backend, cfg.RDS, cfg, db, dbg)
+	}
+	reg := fleet.NewRegistry()
+	for _, fc := range cfg.Fleet.Fleets {
+		if fc.Name == "" {
+			return nil, fmt.Errorf("every entry under fleet.fleets needs a name")
+		}
+		if reg.Get(fc.Name) != nil {
+			return nil, fmt.Errorf("fleet %q is listed twice", fc.Name)
+		}
+		var rc config.RDSConfig
+		if fc.Backend == "" || fc.Backend == "rds" {
+			var err error
+			if rc, err = fc.RDSSettings(); err != nil {
+				return nil, err
+			}
+		}
+		b, err := newBackend(fc.Name, fc.Backend, rc, cfg, db, dbg)
+		if err != nil {
+			return nil, fmt.Errorf("fleet %q: %w", fc.Name, err)
+		}
+		reg.Add(fc.Name, b)
+	}
+	// Bind once every fleet is added so a binding to a misspelled fleet
+	// fails here instead of routing its nodes to the default fleet.
+	for _, fc := range cfg.Fleet.Fleets {
+		for _, z := range fc.Zones {
+			if err := reg.BindZone(z, fc.Name); err != nil {
+				return nil, err
+			}
+		}
+		for _, n := range fc.Nodes {
+			if err := reg.BindNode(n, fc.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, h := range cfg.Fleet.Handoffs {
+		if len(h.Fleets) != 2 {
+			return nil, fmt.Errorf("fleet handoff at %s must name two fleets", h.Node)
+		}
+		if err := reg.SetHandoff(h.Fleets[0], h.Fleets[1], h.Node); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("shingocore: using %d fleets: %s", len(cfg.Fleet.Fleets), reg.Name())
+	return reg, nil
+}
+
+// newBackend builds one fleet backend: the Seer RDS adapter (the default,
+// using rc), the in-process simulator, or the VDA 5050 adapter, which saves
+// its orders in db under the fleet's name.
+func newBackend(name, kind string, rc config.RDSConfig, cfg *config.Config, db *store.DB, dbg *debuglog.Logger) (fleet.Backend, error) {
+	switch kind {
+	case "", "rds":
+	case "sim":
+		return newSimBackend(cfg, dbg), nil
+	case "vda5050":
+		return newVDA5050Backend(cfg, fleetOrders{db: db, fleet: name}, dbg), nil
+	default:
+		return nil, fmt.Errorf("unknown fleet backend %q (want rds, sim or vda5050)", kind)
+	}
+	return seerrds.New(seerrds.Config{
+		BaseURL:      rc.BaseURL,
+		Timeout:      rc.Timeout,
+		PollInterval: rc.PollInterval,
+		PollIntervals: rds.PollIntervals{
+			Waiting:    rc.PollWaiting,
+			Delivering: rc.PollDelivering,
+		},
+		PageSize: rc.PollPageSize,
+		DebugLog: dbg.Func("rds"),
+	}), nil
+}
+
+func newSimBackend(cfg *config.Config, dbg *debuglog.Logger) fleet.Backend {
+	sc := cfg.Fleet.Sim
+	locs := make([]sim.Location, len(sc.Locations))
+	for i, l := range sc.Locations {
+		locs[i] = sim.Location{Name: l.Name, Group: l.Group, X: l.X, Y: l.Y}
+	}
+	if len(locs) == 0 {
+		locs = sim.DefaultLocations()
+	}
+	log.Printf("shingocore: using simulated fleet (%d robots, %d locations)", sc.Robots, len(locs))
+	return sim.New(sim.Config{
+		Robots:       sc.Robots,
+		Speed:        sc.Speed,
+		HandlingTime: sc.HandlingTime,
+		TickInterval: sc.TickInterval,
+		FailRate:     sc.FailRate,
+		RejectRate:   sc.RejectRate,
+		RecoverTime:  sc.RecoverTime,
+		History:      sc.History,
+		Locations:    locs,
+		DebugLog:     dbg.Func("sim"),
+	})
+}
+
+// newVDA5050Backend connects to the MQTT broker. A broker that is down at
+// startup is not fatal: the client keeps retrying and orders fail with
+// fleet_failed until it connects.
+func newVDA5050Backend(cfg *config.Config, orders vda5050.OrderStore, dbg *debuglog.Logger) fleet.Backend {
+	vc := cfg.Fleet.VDA5050
+	transport, err := vda5050.DialMQTT(vda5050.MQTTConfig{
+		Broker:   vc.Broker,
+		ClientID: vc.ClientID,
+		Username: vc.Username,
+		Password: vc.Password,
+	})
+	if err != nil {
+		log.Printf("shingocore: vda5050: %v (retrying in background)", err)
+	}
+	locs := make(map[string]vda5050.NodePosition, len(vc.Locations))
+	for _, l := range vc.Locations {
+		locs[l.Name] = vda5050.NodePosition{X: l.X, Y: l.Y, Theta: l.Theta, MapID: vc.MapID}
+	}
+	a, err := vda5050.New(transport, vda5050.Config{
+		InterfaceName: vc.InterfaceName,
+		Manufacturer:  vc.Manufacturer,
+		MapID:         vc.MapID,
+		Locations:     locs,
+		PickAction:    vc.PickAction,
+		DropAction:    vc.DropAction,
+		Orders:        orders,
+		DebugLog:      dbg.Func("vda5050"),
+	})
+	if err != nil {
+		log.Fatalf("vda5050: %v", err)
+	}
+	log.Printf("shingocore: using VDA 5050 fleet via %s", vc.Broker)
+	return a
+}
+
+// fleetOrders keeps one fleet's VDA 5050 orders in the database.
+type fleetOrders struct {
+	db    *store.DB
+	fleet string
+}
+
+func (f fleetOrders) SaveOrder(id string, data []byte) error {
+	return f.db.SaveFleetOrder(f.fleet, id, data)
+}
+
+func (f fleetOrders) DeleteOrder(id string) error {
+	return f.db.DeleteFleetOrder(f.fleet, id)
+}
+
+func (f fleetOrders) LoadOrders() ([][]byte, error) {
+	return f.db.ListFleetOrders(f.fleet)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"shingocore/config"
+	"shingocore/store"
+)
+
+// runMigrate handles "shingocore migrate status|up|down". The server applies
+// pending migrations on start; this lets an operator inspect or step the
+// schema without starting it.
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPath := fs.String("config", "shingocore.yaml", "path to config file")
+	fs.Usage = func() {
+		fmt.Println("Usage: shingocore migrate [--config PATH] status|up|down")
+		fmt.Println()
+		fmt.Println("  status  list migrations and when each was applied")
+		fmt.Println("  up      apply all pending migrations")
+		fmt.Println("  down    revert the most recently applied migration")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	db, err := store.Connect(&cfg.Database)
+	if err != nil {
+		log.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	switch fs.Arg(0) {
+	case "status":
+		status, err := db.Migrations()
+		if err != nil {
+			log.Fatalf("migration status: %v", err)
+		}
+		for _, m := range status {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-24s %s\n", m.Version, m.Name, applied)
+		}
+	case "up":
+		n, err := db.Migrate()
+		if err != nil {
+			log.Fatalf("migrate up: %v", err)
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		m, err := db.MigrateDown()
+		if err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+		fmt.Printf("reverted migration %d (%s)\n", m.Version, m.Name)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"sync"
 	"time"
@@ -11,11 +12,19 @@ import (
 type Config struct {
 	mu sync.RWMutex `yaml:"-"`
 
-	Database  DatabaseConfig `yaml:"database"`
-	Redis     RedisConfig    `yaml:"redis"`
-	RDS       RDSConfig      `yaml:"rds"`
-	Web       WebConfig      `yaml:"web"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Redis     RedisConfig     `yaml:"redis"`
+	RDS       RDSConfig       `yaml:"rds"`
+	Fleet     FleetConfig     `yaml:"fleet"`
+	Web       WebConfig       `yaml:"web"`
 	Messaging MessagingConfig `yaml:"messaging"`
+	Dispatch  DispatchConfig  `yaml:"dispatch"`
+	Devices   DevicesConfig   `yaml:"devices"`
+	Charging  ChargingConfig  `yaml:"charging"`
+	Telemetry TelemetryConfig `yaml:"telemetry"`
+	Goods     GoodsConfig     `yaml:"goods"`
+	ETA       ETAConfig       `yaml:"eta"`
+	Scenarios ScenarioConfig  `yaml:"scenarios"`
 }
 
 type DatabaseConfig struct {
@@ -47,6 +56,102 @@ type RDSConfig struct {
 	BaseURL      string        `yaml:"base_url"`
 	PollInterval time.Duration `yaml:"poll_interval"`
 	Timeout      time.Duration `yaml:"timeout"`
+
+	// Orders still queued in RDS are polled every PollWaiting and orders on
+	// their final drop every PollDelivering. Zero polls every PollInterval.
+	PollWaiting    time.Duration `yaml:"poll_waiting"`
+	PollDelivering time.Duration `yaml:"poll_delivering"`
+	// PollPageSize is the order list page size used when polling in bulk.
+	PollPageSize int `yaml:"poll_page_size"`
+}
+
+// FleetConfig selects the fleet backend.
+type FleetConfig struct {
+	Backend  string               `yaml:"backend"`  // "rds" (default), "sim" or "vda5050"
+	Fleets   []FleetEntryConfig   `yaml:"fleets"`   // several fleets side by side; overrides backend
+	Handoffs []FleetHandoffConfig `yaml:"handoffs"` // where payloads pass between fleets
+	Sim      SimConfig            `yaml:"sim"`
+	VDA5050  VDA5050Config        `yaml:"vda5050"`
+}
+
+// FleetEntryConfig is one of several fleets and the nodes and zones it
+// serves. The first fleet also serves every unbound node.
+type FleetEntryConfig struct {
+	Name    string     `yaml:"name"`
+	Backend string     `yaml:"backend"` // "rds", "sim" or "vda5050", set up from its section
+	RDS     *RDSConfig `yaml:"rds"`     // this fleet's RDS server, required for backend rds
+	Zones   []string   `yaml:"zones"`
+	Nodes   []string   `yaml:"nodes"`
+}
+
+// RDSSettings returns the RDS settings of an rds fleet. Timings and page
+// size left unset take the built-in defaults, never the top-level rds
+// section, which belongs to the single-backend setup.
+func (f FleetEntryConfig) RDSSettings() (RDSConfig, error) {
+	if f.RDS == nil || f.RDS.BaseURL == "" {
+		return RDSConfig{}, fmt.Errorf("fleet %q: backend rds needs rds.base_url", f.Name)
+	}
+	rc := *f.RDS
+	def := Defaults().RDS
+	if rc.PollInterval <= 0 {
+		rc.PollInterval = def.PollInterval
+	}
+	if rc.Timeout <= 0 {
+		rc.Timeout = def.Timeout
+	}
+	if rc.PollPageSize <= 0 {
+		rc.PollPageSize = def.PollPageSize
+	}
+	return rc, nil
+}
+
+// FleetHandoffConfig names the node where payloads pass between two fleets.
+// Both fleets must be able to reach it.
+type FleetHandoffConfig struct {
+	Fleets []string `yaml:"fleets"` // the two fleet names
+	Node   string   `yaml:"node"`
+}
+
+// SimConfig configures the in-process simulated fleet.
+type SimConfig struct {
+	Robots       int                 `yaml:"robots"`
+	Speed        float64             `yaml:"speed"`         // metres per second
+	HandlingTime time.Duration       `yaml:"handling_time"` // time spent at each pick or drop
+	TickInterval time.Duration       `yaml:"tick_interval"`
+	FailRate     float64             `yaml:"fail_rate"`    // chance a stop fails, failing its order
+	RejectRate   float64             `yaml:"reject_rate"`  // chance order creation is rejected
+	RecoverTime  time.Duration       `yaml:"recover_time"` // how long a robot stays in error after a failure
+	History      int                 `yaml:"history"`      // ended orders kept for listing, 0 for the default
+	Locations    []SimLocationConfig `yaml:"locations"`    // empty uses a small built-in layout
+}
+
+type SimLocationConfig struct {
+	Name  string  `yaml:"name"`
+	Group string  `yaml:"group"`
+	X     float64 `yaml:"x"`
+	Y     float64 `yaml:"y"`
+}
+
+// VDA5050Config configures the VDA 5050 backend, which drives vehicles
+// directly over an MQTT broker.
+type VDA5050Config struct {
+	Broker        string                  `yaml:"broker"` // e.g. tcp://localhost:1883
+	ClientID      string                  `yaml:"client_id"`
+	Username      string                  `yaml:"username"`
+	Password      string                  `yaml:"password"`
+	InterfaceName string                  `yaml:"interface_name"` // topic prefix
+	Manufacturer  string                  `yaml:"manufacturer"`   // empty controls every manufacturer's vehicles
+	MapID         string                  `yaml:"map_id"`
+	PickAction    string                  `yaml:"pick_action"`
+	DropAction    string                  `yaml:"drop_action"`
+	Locations     []VDA5050LocationConfig `yaml:"locations"` // optional node positions sent with orders
+}
+
+type VDA5050LocationConfig struct {
+	Name  string  `yaml:"name"`
+	X     float64 `yaml:"x"`
+	Y     float64 `yaml:"y"`
+	Theta float64 `yaml:"theta"`
 }
 
 type WebConfig struct {
@@ -63,6 +168,142 @@ type MessagingConfig struct {
 	StationID           string        `yaml:"station_id"`
 }
 
+type DispatchConfig struct {
+	SourcingRetryInterval time.Duration    `yaml:"sourcing_retry_interval"` // how often the sourcing backlog is re-evaluated
+	MaxSourcingWait       time.Duration    `yaml:"max_sourcing_wait"`       // default time an unsourceable order may wait; 0 fails immediately
+	Escalation            EscalationConfig `yaml:"escalation"`
+	InFlight              InFlightConfig   `yaml:"in_flight"`
+	Schedules             ScheduleConfig   `yaml:"schedules"`
+	CreateRetry           RetryConfig      `yaml:"create_retry"` // retries when the fleet rejects or errors on order creation
+	VendorRetry           RetryConfig      `yaml:"vendor_retry"` // retries when the fleet reports a vendor order failed
+}
+
+// RetryConfig is a retry policy for fleet orders. Each retry is dispatched as
+// a new vendor order; the wait doubles from backoff up to max_backoff.
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"` // 0 fails the order on the first error
+	Backoff     time.Duration `yaml:"backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+}
+
+// ScheduleConfig controls scheduled and recurring orders.
+type ScheduleConfig struct {
+	Interval time.Duration          `yaml:"interval"` // how often due schedules are checked; 0 disables scheduled orders
+	Downtime []DowntimeWindowConfig `yaml:"downtime"` // windows in which schedules do not fire
+}
+
+// DowntimeWindowConfig is a daily window of local time, e.g. a shift break.
+type DowntimeWindowConfig struct {
+	Days  []string `yaml:"days"`  // mon..sun the window starts on; empty means every day
+	Start string   `yaml:"start"` // HH:MM
+	End   string   `yaml:"end"`   // HH:MM; before start means the window spans midnight
+}
+
+// InFlightConfig caps how many orders may be in flight at once. Orders over a
+// cap are queued in core. A cap of 0 means unlimited.
+type InFlightConfig struct {
+	Station      int            `yaml:"station"`       // default cap per station
+	Stations     map[string]int `yaml:"stations"`      // per-station caps, overriding station
+	Zone         int            `yaml:"zone"`          // default cap per destination zone
+	Zones        map[string]int `yaml:"zones"`         // per-zone caps, overriding zone
+	PayloadType  int            `yaml:"payload_type"`  // default cap per payload type
+	PayloadTypes map[string]int `yaml:"payload_types"` // per-payload-type caps, overriding payload_type
+}
+
+// EscalationConfig controls priority aging of orders waiting for a robot.
+type EscalationConfig struct {
+	Interval      time.Duration            `yaml:"interval"`        // how often waiting orders are re-prioritised; 0 disables escalation
+	AgeStep       time.Duration            `yaml:"age_step"`        // each full step waited adds one priority level
+	DefaultSLA    time.Duration            `yaml:"default_sla"`     // wait allowed before an order breaches its SLA; 0 disables SLAs
+	StationSLA    map[string]time.Duration `yaml:"station_sla"`     // per-station SLA, overriding default_sla
+	LoadTypeBoost map[string]int           `yaml:"load_type_boost"` // fixed priority boost per load type, e.g. hot: 10
+	BreachBoost   int                      `yaml:"breach_boost"`    // added once an order breaches its SLA
+	MaxPriority   int                      `yaml:"max_priority"`    // ceiling for escalated priority; 0 means none
+}
+
+// DevicesConfig maps automatic doors and lifts to the node routes that pass
+// through them. Core opens or calls them for orders on those routes.
+type DevicesConfig struct {
+	Interval time.Duration  `yaml:"interval"` // how often device status and maintenance windows are checked
+	Devices  []DeviceConfig `yaml:"devices"`
+}
+
+// DeviceConfig is one door or lift.
+type DeviceConfig struct {
+	Name        string                 `yaml:"name"` // the fleet's device name
+	Kind        string                 `yaml:"kind"` // "door" or "lift"
+	Routes      []DeviceRouteConfig    `yaml:"routes"`
+	Maintenance []DowntimeWindowConfig `yaml:"maintenance"` // windows in which the device is disabled
+}
+
+// DeviceRouteConfig is a pair of nodes or zones whose path crosses the
+// device, in either direction.
+type DeviceRouteConfig struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	Area string `yaml:"area"` // lifts: area to call the lift to
+}
+
+// ChargingConfig sets when robots are sent to the charging nodes. Battery
+// levels are percentages.
+type ChargingConfig struct {
+	Enabled          bool                  `yaml:"enabled"`
+	ChargeBelow      float64               `yaml:"charge_below"`      // send to charge below this level
+	ReleaseAbove     float64               `yaml:"release_above"`     // return to service at this level
+	OpportunityBelow float64               `yaml:"opportunity_below"` // idle robots below this level charge; 0 disables
+	OpportunityIdle  time.Duration         `yaml:"opportunity_idle"`  // idle time before an opportunity charge
+	ChargeTimeout    time.Duration         `yaml:"charge_timeout"`    // return a robot that has not charged for this long; 0 disables
+	Shifts           []ChargingShiftConfig `yaml:"shifts"`
+}
+
+// ChargingShiftConfig keeps at least MinAvailable robots in service during
+// a daily window.
+type ChargingShiftConfig struct {
+	Days         []string `yaml:"days"`  // mon..sun the shift starts on; empty means every day
+	Start        string   `yaml:"start"` // HH:MM
+	End          string   `yaml:"end"`   // HH:MM; before start means the shift spans midnight
+	MinAvailable int      `yaml:"min_available"`
+}
+
+// TelemetryConfig sets how robot status history is kept and which shifts
+// utilization is reported for.
+type TelemetryConfig struct {
+	Enabled        bool                   `yaml:"enabled"`
+	SampleInterval time.Duration          `yaml:"sample_interval"` // snapshot each robot at most this often; state changes are always kept
+	Retention      time.Duration          `yaml:"retention"`       // delete history older than this
+	Shifts         []TelemetryShiftConfig `yaml:"shifts"`          // empty reports whole days
+}
+
+// TelemetryShiftConfig is a named daily window utilization is reported for.
+type TelemetryShiftConfig struct {
+	Name  string   `yaml:"name"`
+	Days  []string `yaml:"days"`  // mon..sun the shift starts on; empty means every day
+	Start string   `yaml:"start"` // HH:MM
+	End   string   `yaml:"end"`   // HH:MM; before start means the shift spans midnight
+}
+
+// GoodsConfig binds the payloads of retrieve and store orders to the robot's
+// container on the fleet.
+type GoodsConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Container string `yaml:"container"` // robot container to bind to; empty for the default container
+}
+
+// ETAConfig sets how delivery ETAs are estimated and sent to ShinGo Edge.
+type ETAConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Speed   float64       `yaml:"speed"`   // average robot speed in m/s, for legs without history
+	Refresh time.Duration `yaml:"refresh"` // resend an unchanged ETA this often
+	Change  time.Duration `yaml:"change"`  // resend sooner when the ETA moves this much
+}
+
+// ScenarioConfig enables scripted rehearsals against simulated robots.
+// Scenarios inject real orders, so they are off unless enabled.
+type ScenarioConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"` // directory of *.yaml scripts offered in the web UI
+}
+
 type KafkaConfig struct {
 	Brokers []string `yaml:"brokers"`
 	GroupID string   `yaml:"group_id"`
@@ -91,6 +332,24 @@ func Defaults() *Config {
 			BaseURL:      "http://192.168.1.100:8088",
 			PollInterval: 5 * time.Second,
 			Timeout:      10 * time.Second,
+			PollPageSize: 100,
+		},
+		Fleet: FleetConfig{
+			Backend: "rds",
+			Sim: SimConfig{
+				Robots:       3,
+				Speed:        1.0,
+				HandlingTime: 5 * time.Second,
+				TickInterval: 500 * time.Millisecond,
+				RecoverTime:  30 * time.Second,
+			},
+			VDA5050: VDA5050Config{
+				Broker:        "tcp://localhost:1883",
+				ClientID:      "shingo-core",
+				InterfaceName: "uagv",
+				PickAction:    "pick",
+				DropAction:    "drop",
+			},
 		},
 		Web: WebConfig{
 			Host:          "0.0.0.0",
@@ -107,6 +366,47 @@ func Defaults() *Config {
 			OutboxDrainInterval: 5 * time.Second,
 			StationID:           "core",
 		},
+		Dispatch: DispatchConfig{
+			SourcingRetryInterval: 30 * time.Second,
+			MaxSourcingWait:       15 * time.Minute,
+			Escalation: EscalationConfig{
+				Interval:      30 * time.Second,
+				AgeStep:       5 * time.Minute,
+				DefaultSLA:    20 * time.Minute,
+				LoadTypeBoost: map[string]int{"hot": 10},
+				BreachBoost:   10,
+				MaxPriority:   100,
+			},
+			Schedules: ScheduleConfig{
+				Interval: 30 * time.Second,
+			},
+			CreateRetry: RetryConfig{
+				MaxAttempts: 3,
+				Backoff:     10 * time.Second,
+				MaxBackoff:  2 * time.Minute,
+			},
+		},
+		Devices: DevicesConfig{
+			Interval: 10 * time.Second,
+		},
+		Charging: ChargingConfig{
+			ChargeBelow:      20,
+			ReleaseAbove:     90,
+			OpportunityBelow: 60,
+			OpportunityIdle:  5 * time.Minute,
+			ChargeTimeout:    30 * time.Minute,
+		},
+		Telemetry: TelemetryConfig{
+			Enabled:        true,
+			SampleInterval: time.Minute,
+			Retention:      30 * 24 * time.Hour,
+		},
+		ETA: ETAConfig{
+			Enabled: true,
+			Speed:   1.0,
+			Refresh: time.Minute,
+			Change:  30 * time.Second,
+		},
 	}
 }
 
@@ -0,0 +1,500 @@
+// Package devices opens doors and calls lifts as robots on transport orders
+// pass through them, takes devices out of service during maintenance
+// windows and raises alerts when a faulted device blocks an active order.
+package devices
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"shingocore/dispatch"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// Device is a door or lift and the node routes that pass through it.
+type Device struct {
+	Name        string
+	Kind        string // fleet.DeviceDoor or fleet.DeviceLift
+	Routes      []Route
+	Maintenance []dispatch.DowntimeWindow
+}
+
+// Route is a pair of endpoints, each a node name or zone. A route is crossed
+// in either direction.
+type Route struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Area string `json:"area,omitempty"` // lifts: the area to call the lift to when the route is used
+}
+
+// Endpoint is one end of a transport leg.
+type Endpoint struct {
+	Node, Zone string
+}
+
+// at reports whether an endpoint is on the side of a route named side.
+func (e Endpoint) at(side string) bool {
+	return side == e.Node || side == e.Zone
+}
+
+func (r Route) matches(from, to Endpoint) bool {
+	return from.at(r.From) && to.at(r.To) || to.at(r.From) && from.at(r.To)
+}
+
+// Alert reports a device that blocks, or is likely to block, an active order.
+type Alert struct {
+	Device  string    `json:"device"`
+	Kind    string    `json:"kind"`
+	OrderID int64     `json:"order_id"`
+	Reason  string    `json:"reason"`
+	At      time.Time `json:"at"`
+}
+
+// View is a device's configuration and last known state, for the web UI.
+type View struct {
+	Name          string              `json:"name"`
+	Kind          string              `json:"kind"`
+	Routes        []Route             `json:"routes"`
+	Status        *fleet.DeviceStatus `json:"status,omitempty"`
+	InMaintenance bool                `json:"in_maintenance"`
+	Orders        []int64             `json:"orders"`  // orders whose leg crosses the device
+	Passing       []int64             `json:"passing"` // orders whose robot the device is open for
+}
+
+// Manager tracks which active orders need which devices. It is driven by the
+// engine: Reserve when an order is dispatched, Advance with every robot
+// status refresh, Release when the order leaves flight, and Check on a timer.
+// A device is only opened while the order's robot passes through it: from
+// the robot reaching the near side of the device's route until it reports a
+// position on the far side. Reservations are kept in the database so a
+// restart resumes them.
+type Manager struct {
+	db       *store.DB
+	ctl      fleet.DeviceController
+	devices  []Device
+	Alert    func(Alert)
+	DebugLog func(string, ...any)
+
+	mu          sync.Mutex
+	reserved    map[string]map[int64]*store.DeviceReservation // device -> order ID -> reservation
+	maintenance map[string]bool                               // devices disabled for maintenance
+	status      map[string]fleet.DeviceStatus
+	alerted     map[string]bool // device/order pairs already alerted
+	alerts      []Alert
+}
+
+// maxAlerts bounds the recent alerts kept for the web UI.
+const maxAlerts = 50
+
+// NewManager creates a device manager and resumes the reservations of
+// orders still active. ctl may be nil when the fleet has no device control,
+// in which case reservations are tracked but nothing is commanded.
+func NewManager(db *store.DB, ctl fleet.DeviceController, devices []Device) *Manager {
+	m := &Manager{
+		db:          db,
+		ctl:         ctl,
+		devices:     devices,
+		reserved:    make(map[string]map[int64]*store.DeviceReservation),
+		maintenance: make(map[string]bool),
+		status:      make(map[string]fleet.DeviceStatus),
+		alerted:     make(map[string]bool),
+	}
+	m.restore()
+	return m
+}
+
+// restore loads saved reservations, dropping those of orders that ended
+// while core was down.
+func (m *Manager) restore() {
+	saved, err := m.db.ListDeviceReservations()
+	if err != nil {
+		log.Printf("devices: list reservations: %v", err)
+		return
+	}
+	if len(saved) == 0 {
+		return
+	}
+	orders, err := m.db.ListActiveOrders()
+	if err != nil {
+		log.Printf("devices: list active orders: %v", err)
+		return
+	}
+	active := make(map[int64]bool, len(orders))
+	for _, o := range orders {
+		active[o.ID] = true
+	}
+	for _, r := range saved {
+		if !active[r.OrderID] {
+			m.db.DeleteDeviceReservations(r.OrderID)
+			continue
+		}
+		m.reserveLocked(r)
+	}
+	m.dbg("devices: resumed %d reservations", len(saved))
+}
+
+func (m *Manager) reserveLocked(r *store.DeviceReservation) {
+	if m.reserved[r.Device] == nil {
+		m.reserved[r.Device] = make(map[int64]*store.DeviceReservation)
+	}
+	m.reserved[r.Device][r.OrderID] = r
+}
+
+// openLocked reports whether a device is open for any order's robot.
+func (m *Manager) openLocked(name string) bool {
+	for _, r := range m.reserved[name] {
+		if r.Open {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) dbg(format string, args ...any) {
+	if fn := m.DebugLog; fn != nil {
+		fn(format, args...)
+	}
+}
+
+// Crossed returns the devices on the route between two endpoints, with the
+// matching route for each.
+func (m *Manager) Crossed(from, to Endpoint) ([]Device, []Route) {
+	var devs []Device
+	var routes []Route
+	for _, d := range m.devices {
+		for _, r := range d.Routes {
+			if r.matches(from, to) {
+				devs = append(devs, d)
+				routes = append(routes, r)
+				break
+			}
+		}
+	}
+	return devs, routes
+}
+
+// Reserve records the doors and lifts an order's leg crosses. Nothing is
+// opened yet; Advance does that when the robot gets there. Devices under
+// maintenance or in fault raise an alert instead of failing the order; the
+// fleet decides whether the robot can wait.
+func (m *Manager) Reserve(orderID int64, from, to Endpoint) {
+	devs, routes := m.Crossed(from, to)
+	if len(devs) == 0 {
+		return
+	}
+	var alerts []Alert
+	for i, d := range devs {
+		r := &store.DeviceReservation{Device: d.Name, OrderID: orderID, Near: routes[i].From, Far: routes[i].To, Area: routes[i].Area}
+		if !from.at(r.Near) || !to.at(r.Far) {
+			r.Near, r.Far = r.Far, r.Near
+		}
+		if err := m.db.SaveDeviceReservation(r); err != nil {
+			log.Printf("devices: save %s reservation for order %d: %v", d.Name, orderID, err)
+		}
+		m.dbg("devices: order %d crosses %s %s from %s to %s", orderID, d.Kind, d.Name, r.Near, r.Far)
+		m.mu.Lock()
+		m.reserveLocked(r)
+		if reason := m.blockedLocked(d.Name); reason != "" {
+			alerts = append(alerts, m.alertLocked(d, orderID, reason))
+		}
+		m.mu.Unlock()
+	}
+	m.emit(alerts)
+}
+
+// Advance follows the robots running reserved orders. A device is opened
+// when the order's robot reports a position on the near side of its route,
+// and released once the robot reports one on the far side.
+func (m *Manager) Advance(robots []fleet.RobotStatus) {
+	at := make(map[string]string, len(robots)) // vehicle -> station
+	for _, r := range robots {
+		if st := r.CurrentStation; st != "" {
+			at[r.VehicleID] = st
+		} else if r.LastStation != "" {
+			at[r.VehicleID] = r.LastStation
+		}
+	}
+
+	type pending struct {
+		d Device
+		r store.DeviceReservation
+	}
+	var todo []pending
+	m.mu.Lock()
+	for _, d := range m.devices {
+		n := len(todo)
+		for _, r := range m.reserved[d.Name] {
+			todo = append(todo, pending{d, *r})
+		}
+		mine := todo[n:]
+		sort.Slice(mine, func(a, b int) bool { return mine[a].r.OrderID < mine[b].r.OrderID })
+	}
+	m.mu.Unlock()
+
+	robotOf := make(map[int64]string)
+	for _, p := range todo {
+		vehicle, ok := robotOf[p.r.OrderID]
+		if !ok {
+			if o, err := m.db.GetOrder(p.r.OrderID); err == nil {
+				vehicle = o.RobotID
+			}
+			robotOf[p.r.OrderID] = vehicle
+		}
+		station := at[vehicle]
+		if station == "" {
+			continue
+		}
+		pos := m.locate(station)
+		switch {
+		case !p.r.Open && pos.at(p.r.Near):
+			m.open(p.d, p.r, vehicle)
+		case p.r.Open && pos.at(p.r.Far):
+			m.passed(p.d, p.r.OrderID, vehicle)
+		}
+	}
+}
+
+// locate maps a robot's reported station to a node and its zone.
+func (m *Manager) locate(station string) Endpoint {
+	n, err := m.db.GetNodeByVendorLocation(station)
+	if err != nil {
+		if n, err = m.db.GetNodeByName(station); err != nil {
+			return Endpoint{Node: station}
+		}
+	}
+	return Endpoint{Node: n.Name, Zone: n.Zone}
+}
+
+func (m *Manager) open(d Device, r store.DeviceReservation, vehicle string) {
+	m.mu.Lock()
+	if cur := m.reserved[d.Name][r.OrderID]; cur != nil {
+		cur.Open = true
+	}
+	m.mu.Unlock()
+	if err := m.db.SetDeviceReservationOpen(d.Name, r.OrderID, true); err != nil {
+		log.Printf("devices: save %s opened for order %d: %v", d.Name, r.OrderID, err)
+	}
+	m.dbg("devices: %s reached %s, opening %s %s for order %d", vehicle, r.Near, d.Kind, d.Name, r.OrderID)
+	if err := m.activate(d, r.Area); err != nil {
+		log.Printf("devices: %s %s for order %d: %v", d.Kind, d.Name, r.OrderID, err)
+	}
+}
+
+// passed releases a device the order's robot has gone through.
+func (m *Manager) passed(d Device, orderID int64, vehicle string) {
+	m.mu.Lock()
+	delete(m.reserved[d.Name], orderID)
+	delete(m.alerted, alertKey(d.Name, orderID))
+	closing := d.Kind == fleet.DeviceDoor && !m.openLocked(d.Name) && !m.maintenance[d.Name]
+	m.mu.Unlock()
+	if err := m.db.DeleteDeviceReservation(d.Name, orderID); err != nil {
+		log.Printf("devices: delete %s reservation for order %d: %v", d.Name, orderID, err)
+	}
+	m.dbg("devices: %s passed %s %s for order %d", vehicle, d.Kind, d.Name, orderID)
+	if closing {
+		m.close(d)
+	}
+}
+
+// Release frees the devices still reserved for an order. Doors it opened
+// that no other robot is passing through are closed again.
+func (m *Manager) Release(orderID int64) {
+	var closing []Device
+	found := false
+	m.mu.Lock()
+	for _, d := range m.devices {
+		r := m.reserved[d.Name][orderID]
+		if r == nil {
+			continue
+		}
+		found = true
+		delete(m.reserved[d.Name], orderID)
+		delete(m.alerted, alertKey(d.Name, orderID))
+		if r.Open && d.Kind == fleet.DeviceDoor && !m.openLocked(d.Name) && !m.maintenance[d.Name] {
+			closing = append(closing, d)
+		}
+	}
+	m.mu.Unlock()
+	if !found {
+		return
+	}
+	if err := m.db.DeleteDeviceReservations(orderID); err != nil {
+		log.Printf("devices: delete reservations of order %d: %v", orderID, err)
+	}
+	for _, d := range closing {
+		m.dbg("devices: closing door %s after order %d", d.Name, orderID)
+		m.close(d)
+	}
+}
+
+func (m *Manager) close(d Device) {
+	if m.ctl == nil {
+		return
+	}
+	if err := m.ctl.SetDoor(d.Name, false); err != nil {
+		log.Printf("devices: close door %s: %v", d.Name, err)
+	}
+}
+
+func (m *Manager) activate(d Device, area string) error {
+	if m.ctl == nil {
+		return nil
+	}
+	switch d.Kind {
+	case fleet.DeviceDoor:
+		return m.ctl.SetDoor(d.Name, true)
+	case fleet.DeviceLift:
+		if area == "" {
+			return nil
+		}
+		return m.ctl.CallLift(d.Name, area)
+	}
+	return fmt.Errorf("unknown device kind %q", d.Kind)
+}
+
+// Check enters and leaves maintenance windows, refreshes device status from
+// the fleet and alerts on faulted devices held by active orders.
+func (m *Manager) Check(now time.Time) {
+	for _, d := range m.devices {
+		in := false
+		for _, w := range d.Maintenance {
+			if w.Contains(now) {
+				in = true
+				break
+			}
+		}
+		m.mu.Lock()
+		changed := m.maintenance[d.Name] != in
+		m.maintenance[d.Name] = in
+		inUse := m.openLocked(d.Name)
+		m.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if in {
+			log.Printf("devices: %s %s entering maintenance", d.Kind, d.Name)
+		} else {
+			log.Printf("devices: %s %s leaving maintenance", d.Kind, d.Name)
+		}
+		if m.ctl == nil {
+			continue
+		}
+		if err := m.ctl.DisableDevice(d.Kind, d.Name, in); err != nil {
+			log.Printf("devices: set %s %s disabled=%v: %v", d.Kind, d.Name, in, err)
+		}
+		// Robots that waited out the window still need their door.
+		if !in && inUse && d.Kind == fleet.DeviceDoor {
+			if err := m.ctl.SetDoor(d.Name, true); err != nil {
+				log.Printf("devices: open door %s: %v", d.Name, err)
+			}
+		}
+	}
+
+	if m.ctl != nil {
+		statuses, err := m.ctl.GetDevicesStatus()
+		if err != nil {
+			m.dbg("devices: status: %v", err)
+		} else {
+			m.mu.Lock()
+			for _, s := range statuses {
+				m.status[s.Name] = s
+			}
+			m.mu.Unlock()
+		}
+	}
+
+	var alerts []Alert
+	m.mu.Lock()
+	for _, d := range m.devices {
+		reason := m.blockedLocked(d.Name)
+		if reason == "" {
+			continue
+		}
+		for orderID := range m.reserved[d.Name] {
+			if !m.alerted[alertKey(d.Name, orderID)] {
+				alerts = append(alerts, m.alertLocked(d, orderID, reason))
+			}
+		}
+	}
+	m.mu.Unlock()
+	m.emit(alerts)
+}
+
+// blockedLocked returns why a device cannot serve orders, or "" if it can.
+func (m *Manager) blockedLocked(name string) string {
+	if m.maintenance[name] {
+		return "in maintenance window"
+	}
+	s, ok := m.status[name]
+	if !ok {
+		return ""
+	}
+	if s.Fault != "" {
+		return "fault: " + s.Fault
+	}
+	if s.Disabled {
+		return "disabled"
+	}
+	return ""
+}
+
+func (m *Manager) alertLocked(d Device, orderID int64, reason string) Alert {
+	a := Alert{Device: d.Name, Kind: d.Kind, OrderID: orderID, Reason: reason, At: time.Now()}
+	m.alerted[alertKey(d.Name, orderID)] = true
+	m.alerts = append(m.alerts, a)
+	if len(m.alerts) > maxAlerts {
+		m.alerts = m.alerts[len(m.alerts)-maxAlerts:]
+	}
+	return a
+}
+
+func (m *Manager) emit(alerts []Alert) {
+	for _, a := range alerts {
+		log.Printf("devices: %s %s blocks order %d: %s", a.Kind, a.Device, a.OrderID, a.Reason)
+		if m.Alert != nil {
+			m.Alert(a)
+		}
+	}
+}
+
+func alertKey(device string, orderID int64) string {
+	return fmt.Sprintf("%s/%d", device, orderID)
+}
+
+// Devices returns every configured device with its last known state.
+func (m *Manager) Devices() []View {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]View, len(m.devices))
+	for i, d := range m.devices {
+		v := View{Name: d.Name, Kind: d.Kind, Routes: d.Routes, InMaintenance: m.maintenance[d.Name]}
+		if s, ok := m.status[d.Name]; ok {
+			v.Status = &s
+		}
+		for id, r := range m.reserved[d.Name] {
+			v.Orders = append(v.Orders, id)
+			if r.Open {
+				v.Passing = append(v.Passing, id)
+			}
+		}
+		sort.Slice(v.Orders, func(a, b int) bool { return v.Orders[a] < v.Orders[b] })
+		sort.Slice(v.Passing, func(a, b int) bool { return v.Passing[a] < v.Passing[b] })
+		out[i] = v
+	}
+	return out
+}
+
+// Alerts returns recent alerts, newest first.
+func (m *Manager) Alerts() []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Alert, len(m.alerts))
+	for i, a := range m.alerts {
+		out[len(out)-1-i] = a
+	}
+	return out
+}
@@ -0,0 +1,259 @@
+package devices
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"shingocore/config"
+	"shingocore/dispatch"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+func testDB(t *testing.T) *store.DB {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := store.Open(&config.DatabaseConfig{
+		Driver: "sqlite",
+		SQLite: config.SQLiteConfig{Path: dbPath},
+	})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		os.Remove(dbPath)
+	})
+	return db
+}
+
+// testSite creates the nodes the test routes run between, keyed by the
+// station the fleet reports for them.
+func testSite(t *testing.T, db *store.DB) {
+	t.Helper()
+	for _, n := range []*store.Node{
+		{Name: "S-01", VendorLocation: "LM1", Zone: "WAREHOUSE"},
+		{Name: "S-02", VendorLocation: "LM2", Zone: "WAREHOUSE"},
+		{Name: "LINE-1", VendorLocation: "LM10"},
+		{Name: "M-01", VendorLocation: "LM20", Zone: "MEZZ"},
+	} {
+		n.NodeType = "storage"
+		n.Capacity = 1
+		n.Enabled = true
+		if err := db.CreateNode(n); err != nil {
+			t.Fatalf("create node %s: %v", n.Name, err)
+		}
+	}
+}
+
+// runningOrder creates an in-flight order assigned to a robot.
+func runningOrder(t *testing.T, db *store.DB, robot string) int64 {
+	t.Helper()
+	o := &store.Order{EdgeUUID: "uuid-" + robot, StationID: "line-1", OrderType: "move", Status: "in_transit"}
+	if err := db.CreateOrder(o); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	if err := db.UpdateOrderVendor(o.ID, "v-"+robot, "RUNNING", robot); err != nil {
+		t.Fatalf("assign robot: %v", err)
+	}
+	return o.ID
+}
+
+func robotAt(vehicle, station string) fleet.RobotStatus {
+	return fleet.RobotStatus{VehicleID: vehicle, CurrentStation: station}
+}
+
+type fakeController struct {
+	mu       sync.Mutex
+	calls    []string
+	statuses []fleet.DeviceStatus
+}
+
+func (f *fakeController) record(format string, args ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeController) GetDevicesStatus() ([]fleet.DeviceStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.statuses, nil
+}
+
+func (f *fakeController) SetDoor(name string, open bool) error {
+	f.record("door %s open=%v", name, open)
+	return nil
+}
+
+func (f *fakeController) CallLift(name, targetArea string) error {
+	f.record("lift %s to %s", name, targetArea)
+	return nil
+}
+
+func (f *fakeController) DisableDevice(kind, name string, disabled bool) error {
+	f.record("%s %s disabled=%v", kind, name, disabled)
+	return nil
+}
+
+func (f *fakeController) take() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := f.calls
+	f.calls = nil
+	return calls
+}
+
+func testDevices() []Device {
+	return []Device{
+		{Name: "D1", Kind: fleet.DeviceDoor, Routes: []Route{{From: "WAREHOUSE", To: "LINE-1"}}},
+		{Name: "L1", Kind: fleet.DeviceLift, Routes: []Route{{From: "WAREHOUSE", To: "MEZZ", Area: "floor-1"}}},
+	}
+}
+
+func TestManagerOpensDoorForRobotPass(t *testing.T) {
+	db := testDB(t)
+	testSite(t, db)
+	ctl := &fakeController{}
+	m := NewManager(db, ctl, testDevices())
+
+	o1 := runningOrder(t, db, "AMB-01")
+	o2 := runningOrder(t, db, "AMB-02")
+	o3 := runningOrder(t, db, "AMB-03")
+	o4 := runningOrder(t, db, "AMB-04")
+
+	// Route endpoints match by node or zone, in either direction.
+	m.Reserve(o1, Endpoint{Node: "LINE-1"}, Endpoint{Node: "S-01", Zone: "WAREHOUSE"})
+	m.Reserve(o2, Endpoint{Node: "S-02", Zone: "WAREHOUSE"}, Endpoint{Node: "LINE-1"})
+	m.Reserve(o3, Endpoint{Node: "S-01", Zone: "WAREHOUSE"}, Endpoint{Node: "M-01", Zone: "MEZZ"})
+	m.Reserve(o4, Endpoint{Node: "S-01", Zone: "WAREHOUSE"}, Endpoint{Node: "S-02", Zone: "WAREHOUSE"})
+	if got := ctl.take(); len(got) != 0 {
+		t.Fatalf("calls at dispatch = %v, want none before a robot gets there", got)
+	}
+
+	// Robots on the near side get their devices; the one that already left
+	// the door behind does not reopen it.
+	m.Advance([]fleet.RobotStatus{robotAt("AMB-01", "LM10"), robotAt("AMB-03", "LM1"), robotAt("AMB-04", "LM1")})
+	want := []string{"door D1 open=true", "lift L1 to floor-1"}
+	if got := ctl.take(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+
+	m.Advance([]fleet.RobotStatus{robotAt("AMB-01", "LM10"), robotAt("AMB-02", "LM2")})
+	if got := ctl.take(); fmt.Sprint(got) != "[door D1 open=true]" {
+		t.Errorf("calls when a second robot arrives = %v, want the door held open", got)
+	}
+
+	m.Advance([]fleet.RobotStatus{robotAt("AMB-01", "LM1"), robotAt("AMB-02", "LM2")})
+	if got := ctl.take(); len(got) != 0 {
+		t.Errorf("calls after the first robot passed = %v, want none while AMB-02 still needs the door", got)
+	}
+	m.Advance([]fleet.RobotStatus{{VehicleID: "AMB-02", LastStation: "LINE-1"}})
+	if got := ctl.take(); fmt.Sprint(got) != "[door D1 open=false]" {
+		t.Errorf("calls after the last robot passed = %v, want the door closed", got)
+	}
+
+	views := m.Devices()
+	if len(views[0].Orders) != 0 || fmt.Sprint(views[1].Passing) != fmt.Sprint([]int64{o3}) {
+		t.Errorf("views = %+v, want the door free and the lift passing order %d", views, o3)
+	}
+}
+
+func TestManagerResumesReservations(t *testing.T) {
+	db := testDB(t)
+	testSite(t, db)
+	ctl := &fakeController{}
+	m := NewManager(db, ctl, testDevices())
+
+	o1 := runningOrder(t, db, "AMB-01")
+	o2 := runningOrder(t, db, "AMB-02")
+	m.Reserve(o1, Endpoint{Node: "LINE-1"}, Endpoint{Node: "S-01", Zone: "WAREHOUSE"})
+	m.Reserve(o2, Endpoint{Node: "S-02", Zone: "WAREHOUSE"}, Endpoint{Node: "LINE-1"})
+	m.Advance([]fleet.RobotStatus{robotAt("AMB-01", "LM10")})
+	ctl.take()
+
+	// Order 2 ends while core is down; order 1 is mid-pass.
+	if err := db.UpdateOrderStatus(o2, "cancelled", "test"); err != nil {
+		t.Fatalf("cancel order: %v", err)
+	}
+	m = NewManager(db, ctl, testDevices())
+	views := m.Devices()
+	if fmt.Sprint(views[0].Orders) != fmt.Sprint([]int64{o1}) || fmt.Sprint(views[0].Passing) != fmt.Sprint([]int64{o1}) {
+		t.Fatalf("door view after restart = %+v, want order %d passing", views[0], o1)
+	}
+
+	m.Advance([]fleet.RobotStatus{robotAt("AMB-01", "LM1")})
+	if got := ctl.take(); fmt.Sprint(got) != "[door D1 open=false]" {
+		t.Errorf("calls after the resumed pass = %v, want the door closed", got)
+	}
+	saved, err := db.ListDeviceReservations()
+	if err != nil || len(saved) != 0 {
+		t.Errorf("saved reservations = %v (%v), want none", saved, err)
+	}
+}
+
+func TestManagerMaintenanceWindow(t *testing.T) {
+	ctl := &fakeController{}
+	devs := testDevices()
+	w, err := dispatch.ParseDowntimeWindow(nil, "02:00", "03:00")
+	if err != nil {
+		t.Fatalf("parse window: %v", err)
+	}
+	devs[0].Maintenance = []dispatch.DowntimeWindow{w}
+	db := testDB(t)
+	testSite(t, db)
+	var alerts []Alert
+	m := NewManager(db, ctl, devs)
+	m.Alert = func(a Alert) { alerts = append(alerts, a) }
+
+	day := time.Now()
+	at := func(h, min int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), h, min, 0, 0, time.Local)
+	}
+
+	m.Check(at(2, 30))
+	if got := ctl.take(); fmt.Sprint(got) != "[door D1 disabled=true]" {
+		t.Errorf("calls entering maintenance = %v", got)
+	}
+
+	o := runningOrder(t, db, "AMB-07")
+	m.Reserve(o, Endpoint{Zone: "WAREHOUSE"}, Endpoint{Node: "LINE-1"})
+	if len(alerts) != 1 || alerts[0].OrderID != o || alerts[0].Reason != "in maintenance window" {
+		t.Errorf("alerts = %+v, want one maintenance alert for order %d", alerts, o)
+	}
+	m.Advance([]fleet.RobotStatus{robotAt("AMB-07", "LM1")})
+	ctl.take()
+
+	m.Check(at(3, 5))
+	if got := ctl.take(); fmt.Sprint(got) != "[door D1 disabled=false door D1 open=true]" {
+		t.Errorf("calls leaving maintenance = %v, want re-enabled and reopened for the waiting robot", got)
+	}
+}
+
+func TestManagerAlertsOnFault(t *testing.T) {
+	db := testDB(t)
+	ctl := &fakeController{}
+	var alerts []Alert
+	m := NewManager(db, ctl, testDevices())
+	m.Alert = func(a Alert) { alerts = append(alerts, a) }
+
+	m.Reserve(9, Endpoint{Zone: "WAREHOUSE"}, Endpoint{Zone: "MEZZ"})
+	ctl.statuses = []fleet.DeviceStatus{{Name: "L1", Kind: fleet.DeviceLift, Fault: "door sensor"}}
+	m.Check(time.Now())
+	m.Check(time.Now())
+
+	if len(alerts) != 1 || alerts[0].Device != "L1" || alerts[0].Reason != "fault: door sensor" {
+		t.Fatalf("alerts = %+v, want a single fault alert for L1", alerts)
+	}
+	views := m.Devices()
+	if views[1].Status == nil || views[1].Status.Fault != "door sensor" || len(views[1].Orders) != 1 {
+		t.Errorf("view = %+v, want fault status and order 9", views[1])
+	}
+	if len(m.Alerts()) != 1 {
+		t.Errorf("recent alerts = %d, want 1", len(m.Alerts()))
+	}
+}
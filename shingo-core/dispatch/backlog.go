@@ -0,0 +1,228 @@
+package dispatch
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"shingo/protocol"
+	"shingocore/store"
+)
+
+// waitingOrder is a retrieve, swap or store order held in sourcing until stock or
+// storage frees up.
+type waitingOrder struct {
+	orderID   int64
+	env       *protocol.Envelope
+	errorCode string
+	since     time.Time
+	maxWait   time.Duration
+}
+
+// maxWaitFor returns the sourcing wait allowed for an order: its own
+// max_wait_sec if set, otherwise the dispatcher default.
+func (d *Dispatcher) maxWaitFor(order *store.Order) time.Duration {
+	if order.MaxWaitSec > 0 {
+		return time.Duration(order.MaxWaitSec) * time.Second
+	}
+	return d.MaxSourcingWait
+}
+
+// holdOrFail queues an unsourceable order in the backlog, or fails it when no
+// wait is allowed. Orders already waiting are left as they are.
+func (d *Dispatcher) holdOrFail(order *store.Order, env *protocol.Envelope, errorCode, detail string) {
+	d.mu.Lock()
+	_, already := d.waiting[order.ID]
+	d.mu.Unlock()
+	if already {
+		return
+	}
+
+	maxWait := d.maxWaitFor(order)
+	if maxWait <= 0 {
+		d.failOrder(order, env, errorCode, detail)
+		return
+	}
+
+	d.mu.Lock()
+	d.waiting[order.ID] = &waitingOrder{
+		orderID:   order.ID,
+		env:       env,
+		errorCode: errorCode,
+		since:     time.Now(),
+		maxWait:   maxWait,
+	}
+	d.mu.Unlock()
+
+	msg := fmt.Sprintf("waiting for stock: %s", detail)
+	d.db.UpdateOrderStatus(order.ID, StatusSourcing, msg)
+	d.dbg("backlog: order %d queued (%s, max wait %s)", order.ID, errorCode, maxWait)
+	d.sendUpdate(env, order.EdgeUUID, StatusSourcing, msg)
+}
+
+// dropWaiting removes an order from the backlog.
+func (d *Dispatcher) dropWaiting(orderID int64) {
+	d.mu.Lock()
+	delete(d.waiting, orderID)
+	d.mu.Unlock()
+}
+
+// WaitingCount returns the number of orders held in the backlog.
+func (d *Dispatcher) WaitingCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.waiting)
+}
+
+// RestoreWaiting reloads orders left in sourcing (e.g. across a restart) into
+// the backlog. Their wait restarts from now.
+func (d *Dispatcher) RestoreWaiting() int {
+	orders, err := d.db.ListSourcingOrders()
+	if err != nil {
+		log.Printf("dispatch: restore backlog: %v", err)
+		return 0
+	}
+	n := 0
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, o := range orders {
+		if o.OrderType != OrderTypeRetrieve && o.OrderType != OrderTypeStore && o.OrderType != OrderTypeSwap {
+			continue
+		}
+		if _, ok := d.waiting[o.ID]; ok {
+			continue
+		}
+		errorCode := "no_source"
+		if o.OrderType == OrderTypeStore {
+			errorCode = "no_storage"
+		}
+		d.waiting[o.ID] = &waitingOrder{
+			orderID:   o.ID,
+			env:       edgeEnvelope(o.StationID),
+			errorCode: errorCode,
+			since:     time.Now(),
+			maxWait:   d.maxWaitFor(o),
+		}
+		n++
+	}
+	return n
+}
+
+// RetryWaiting re-evaluates every order in the backlog, highest priority first.
+// Orders that can now be sourced are dispatched; orders past their max wait fail.
+func (d *Dispatcher) RetryWaiting() {
+	d.mu.Lock()
+	entries := make([]*waitingOrder, 0, len(d.waiting))
+	for _, w := range d.waiting {
+		entries = append(entries, w)
+	}
+	d.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	orders := make(map[int64]*store.Order, len(entries))
+	for _, w := range entries {
+		order, err := d.db.GetOrder(w.orderID)
+		if err != nil || order.Status != StatusSourcing {
+			d.dropWaiting(w.orderID)
+			continue
+		}
+		orders[w.orderID] = order
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		oi, oj := orders[entries[i].orderID], orders[entries[j].orderID]
+		if oi == nil || oj == nil {
+			return oi != nil
+		}
+		if oi.Priority != oj.Priority {
+			return oi.Priority > oj.Priority
+		}
+		return oi.ID < oj.ID
+	})
+
+	for _, w := range entries {
+		if orders[w.orderID] != nil {
+			d.retryWaitingOrder(w)
+		}
+	}
+}
+
+// retryWaitingOrder re-sources one backlog order under its per-order lock,
+// so a cancel or receipt handled meanwhile is not overwritten by a dispatch.
+func (d *Dispatcher) retryWaitingOrder(w *waitingOrder) {
+	unlock := d.lockOrder(w.orderID)
+	defer unlock()
+
+	order, err := d.db.GetOrder(w.orderID)
+	if err != nil || order.Status != StatusSourcing {
+		d.dropWaiting(w.orderID)
+		return
+	}
+	if time.Since(w.since) > w.maxWait {
+		d.dbg("backlog: order %d exceeded max wait %s", order.ID, w.maxWait)
+		d.failOrder(order, w.env, w.errorCode, fmt.Sprintf("no stock after waiting %s", w.maxWait))
+		return
+	}
+
+	code := d.payloadTypeCode(order)
+	switch order.OrderType {
+	case OrderTypeRetrieve:
+		d.sourceRetrieve(order, w.env, code)
+	case OrderTypeSwap:
+		d.sourceSwap(order, w.env, code)
+	case OrderTypeStore:
+		d.sourceStore(order, w.env)
+	default:
+		d.dropWaiting(order.ID)
+		return
+	}
+
+	if got, err := d.db.GetOrder(order.ID); err != nil || got.Status != StatusSourcing {
+		d.dropWaiting(order.ID)
+		d.dbg("backlog: order %d left backlog after %s", order.ID, time.Since(w.since).Round(time.Second))
+	}
+}
+
+// payloadTypeCode returns the name of an order's payload type, or "" if it has none.
+func (d *Dispatcher) payloadTypeCode(order *store.Order) string {
+	if order.PayloadTypeID == nil {
+		return ""
+	}
+	pt, err := d.db.GetPayloadType(*order.PayloadTypeID)
+	if err != nil {
+		return ""
+	}
+	return pt.Name
+}
+
+// edgeEnvelope builds a stand-in request envelope for replies to orders that
+// are no longer tied to the original inbound message.
+func edgeEnvelope(stationID string) *protocol.Envelope {
+	return &protocol.Envelope{
+		Src: protocol.Address{Role: protocol.RoleEdge, Station: stationID},
+		Dst: protocol.Address{Role: protocol.RoleCore},
+	}
+}
+
+func (d *Dispatcher) sendUpdate(env *protocol.Envelope, orderUUID, status, detail string) {
+	stationID := env.Src.Station
+	edgeAddr := protocol.Address{Role: protocol.RoleEdge, Station: stationID}
+	reply, err := protocol.NewReply(protocol.TypeOrderUpdate, d.coreAddress(), edgeAddr, env.ID, &protocol.OrderUpdate{
+		OrderUUID: orderUUID,
+		Status:    status,
+		Detail:    detail,
+	})
+	if err != nil {
+		log.Printf("dispatch: build update reply: %v", err)
+		return
+	}
+	data, err := reply.Encode()
+	if err != nil {
+		log.Printf("dispatch: encode update reply: %v", err)
+		return
+	}
+	d.dbg("sendUpdate: uuid=%s status=%s detail=%s", orderUUID, status, detail)
+	d.db.EnqueueOutbox(d.dispatchTopic, data, "order.update", stationID)
+}
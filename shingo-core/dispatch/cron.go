@@ -0,0 +1,142 @@
+package dispatch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronRule is a parsed five-field cron expression: minute, hour, day of month,
+// month and day of week (0 or 7 = Sunday). Fields accept *, single values,
+// ranges (a-b), steps (*/n, a-b/n) and comma lists. "@every <duration>"
+// repeats at a fixed interval from the previous run instead.
+type CronRule struct {
+	minute, hour, dom, month, dow uint64 // bit sets of allowed values
+	domAny, dowAny                bool
+	every                         time.Duration
+}
+
+// ParseCron parses a cron expression.
+func ParseCron(expr string) (*CronRule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("cron %q: interval must be at least 1m", expr)
+		}
+		return &CronRule{every: d}, nil
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: want 5 fields, got %d", expr, len(fields))
+	}
+	var r CronRule
+	var err error
+	if r.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("cron %q minute: %w", expr, err)
+	}
+	if r.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("cron %q hour: %w", expr, err)
+	}
+	if r.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("cron %q day of month: %w", expr, err)
+	}
+	if r.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("cron %q month: %w", expr, err)
+	}
+	if r.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("cron %q day of week: %w", expr, err)
+	}
+	if r.dow&(1<<7) != 0 {
+		r.dow |= 1 // 7 is Sunday too
+	}
+	// As in standard cron, a day field starting with * (e.g. */2) counts as
+	// unrestricted, so the two day fields are ANDed rather than ORed.
+	r.domAny = strings.HasPrefix(fields[2], "*")
+	r.dowAny = strings.HasPrefix(fields[4], "*")
+	return &r, nil
+}
+
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if base, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step %q", s)
+			}
+			part, step = base, n
+		}
+		start, end := lo, hi
+		if part != "*" {
+			a, b, isRange := strings.Cut(part, "-")
+			var err error
+			if start, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("bad value %q", a)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("bad value %q", b)
+				}
+			} else if step > 1 {
+				end = hi // "a/n" runs from a to the end of the range
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first time after t the rule fires. For "@every" rules t is
+// the previous run.
+func (r *CronRule) Next(t time.Time) time.Time {
+	if r.every > 0 {
+		return t.Add(r.every)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every valid rule fires within a few years (29 Feb at worst).
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if r.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !r.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if r.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if r.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's day rule: when both day of month and day of week
+// are restricted, either may match; otherwise both must.
+func (r *CronRule) dayMatches(t time.Time) bool {
+	domOK := r.dom&(1<<uint(t.Day())) != 0
+	dowOK := r.dow&(1<<uint(t.Weekday())) != 0
+	if r.domAny || r.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
@@ -1,8 +1,11 @@
 package dispatch
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -18,6 +21,33 @@ type Dispatcher struct {
 	stationID     string
 	dispatchTopic string
 	DebugLog      func(string, ...any)
+
+	// MaxSourcingWait is how long a retrieve or store order that cannot be
+	// sourced is held in the backlog before it fails. Zero fails such orders
+	// immediately unless the order carries its own max wait.
+	MaxSourcingWait time.Duration
+
+	// Limits caps in-flight orders; new orders over a cap are queued in core.
+	Limits InFlightLimits
+
+	// CreateRetry and VendorRetry retry orders whose vendor order could not
+	// be created or was failed by the fleet, respectively.
+	CreateRetry RetryPolicy
+	VendorRetry RetryPolicy
+
+	mu         sync.Mutex
+	waiting    map[int64]*waitingOrder
+	orderLocks map[int64]*orderLock
+	capMu      sync.Mutex        // guards reserved
+	reserved   map[int64]capSlot // cap slots of orders still starting
+	releaseMu  sync.Mutex
+}
+
+// orderLock serializes work on one order between the edge message handlers
+// and the background loops that re-dispatch it.
+type orderLock struct {
+	mu   sync.Mutex
+	refs int
 }
 
 func NewDispatcher(db *store.DB, backend fleet.Backend, emitter Emitter, stationID, dispatchTopic string) *Dispatcher {
@@ -27,9 +57,50 @@ func NewDispatcher(db *store.DB, backend fleet.Backend, emitter Emitter, station
 		emitter:       emitter,
 		stationID:     stationID,
 		dispatchTopic: dispatchTopic,
+		waiting:       make(map[int64]*waitingOrder),
+		orderLocks:    make(map[int64]*orderLock),
+	}
+}
+
+// lockOrder takes the per-order lock and returns its release func. Entry
+// points take it before reading the order, so each sees the other's writes.
+func (d *Dispatcher) lockOrder(orderID int64) func() {
+	d.mu.Lock()
+	l := d.orderLocks[orderID]
+	if l == nil {
+		l = &orderLock{}
+		d.orderLocks[orderID] = l
+	}
+	l.refs++
+	d.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		d.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(d.orderLocks, orderID)
+		}
+		d.mu.Unlock()
 	}
 }
 
+// lockOrderByUUID looks up an order by edge UUID and returns it under its
+// per-order lock, re-read once the lock is held.
+func (d *Dispatcher) lockOrderByUUID(uuid string) (*store.Order, func(), error) {
+	order, err := d.db.GetOrderByUUID(uuid)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock := d.lockOrder(order.ID)
+	order, err = d.db.GetOrder(order.ID)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return order, unlock, nil
+}
+
 func (d *Dispatcher) dbg(format string, args ...any) {
 	if fn := d.DebugLog; fn != nil {
 		fn(format, args...)
@@ -57,6 +128,9 @@ func (d *Dispatcher) HandleOrderRequest(env *protocol.Envelope, p *protocol.Orde
 		DeliveryNode: p.DeliveryNode,
 		Priority:     p.Priority,
 		PayloadDesc:  p.PayloadDesc,
+		MaxWaitSec:   p.MaxWaitSec,
+		StagingNode:  p.StagingNode,
+		LoadType:     p.LoadType,
 	}
 
 	// Resolve payload type
@@ -80,63 +154,117 @@ func (d *Dispatcher) HandleOrderRequest(env *protocol.Envelope, p *protocol.Orde
 		}
 	}
 
-	if err := d.db.CreateOrder(order); err != nil {
+	// Validate staging node exists
+	if p.StagingNode != "" {
+		_, err := d.db.GetNodeByName(p.StagingNode)
+		if err != nil {
+			log.Printf("dispatch: staging node %q not found: %v", p.StagingNode, err)
+			d.dbg("error: staging node %q not found: %v", p.StagingNode, err)
+			d.sendError(env, p.OrderUUID, "invalid_node", fmt.Sprintf("staging node %q not found", p.StagingNode))
+			return
+		}
+	}
+
+	if err := d.db.ReceiveOrder(order, "order received"); err != nil {
 		log.Printf("dispatch: create order: %v", err)
 		d.sendError(env, p.OrderUUID, "internal_error", err.Error())
 		return
 	}
-	d.db.UpdateOrderStatus(order.ID, StatusPending, "order received")
 
 	d.emitter.EmitOrderReceived(order.ID, order.EdgeUUID, stationID, p.OrderType, p.PayloadTypeCode, p.DeliveryNode)
 
-	switch p.OrderType {
-	case OrderTypeRetrieve:
-		d.handleRetrieve(order, env, p.PayloadTypeCode)
-	case OrderTypeMove:
-		d.handleMove(order, env, p.PayloadTypeCode)
-	case OrderTypeStore:
-		d.handleStore(order, env)
-	default:
-		log.Printf("dispatch: unknown order type %q", p.OrderType)
-		d.failOrder(order, env, "unknown_type", fmt.Sprintf("unknown order type: %s", p.OrderType))
-	}
+	d.admitOrder(order, env)
 }
 
 func (d *Dispatcher) handleRetrieve(order *store.Order, env *protocol.Envelope, payloadTypeCode string) {
 	d.db.UpdateOrderStatus(order.ID, StatusSourcing, "finding source")
+	d.sourceRetrieve(order, env, payloadTypeCode)
+}
+
+// sourceRetrieve selects and claims a source payload, then dispatches. Orders
+// with no available stock are held in the backlog when a wait is configured.
+func (d *Dispatcher) sourceRetrieve(order *store.Order, env *protocol.Envelope, payloadTypeCode string) {
+	// Destination is only a ranking hint here; it is validated before dispatch.
+	destNode, _ := d.db.GetNodeByName(order.DeliveryNode)
 
-	// FIFO source selection for payloads
-	source, err := d.db.FindSourcePayloadFIFO(payloadTypeCode)
+	source, sourceNode, err := d.selectSource(order, payloadTypeCode, destNode)
 	if err != nil {
-		d.dbg("retrieve: no source payload for type %s", payloadTypeCode)
-		d.failOrder(order, env, "no_source", fmt.Sprintf("no source payload found for type %s", payloadTypeCode))
+		d.dbg("retrieve: no source payload for type %s: %v", payloadTypeCode, err)
+		d.holdOrFail(order, env, "no_source", fmt.Sprintf("no source payload found for type %s", payloadTypeCode))
 		return
 	}
-	d.dbg("retrieve: FIFO source payload=%d type=%s node=%v", source.ID, payloadTypeCode, source.NodeID)
+	d.dbg("retrieve: claimed payload=%d at %s for order %d", source.ID, sourceNode.Name, order.ID)
 
-	// Claim the payload to prevent double-dispatch
-	if err := d.db.ClaimPayload(source.ID, order.ID); err != nil {
-		d.failOrder(order, env, "claim_failed", err.Error())
+	order.PickupNode = sourceNode.Name
+
+	if destNode == nil {
+		d.failOrder(order, env, "node_error", fmt.Sprintf("delivery node %q not found", order.DeliveryNode))
 		return
 	}
 
-	// Get node details for vendor locations
-	sourceNode, err := d.db.GetNode(*source.NodeID)
-	if err != nil {
-		d.failOrder(order, env, "node_error", err.Error())
-		return
+	// Staged retrieves run their first leg to the staging node only.
+	if IsStagingLeg(order) {
+		stagingNode, err := d.db.GetNodeByName(order.StagingNode)
+		if err != nil {
+			d.failOrder(order, env, "node_error", fmt.Sprintf("staging node %q not found", order.StagingNode))
+			return
+		}
+		d.dbg("retrieve: order %d staging at %s before %s", order.ID, stagingNode.Name, destNode.Name)
+		destNode = stagingNode
 	}
 
-	order.PickupNode = sourceNode.Name
-	d.db.UpdateOrderPickupNode(order.ID, sourceNode.Name)
+	d.dispatchToFleet(order, env, sourceNode, destNode)
+}
 
-	destNode, err := d.db.GetNodeByName(order.DeliveryNode)
+// selectSource ranks the available payloads of a type using the payload type's
+// source strategy and claims the first one it can, making its node the
+// order's pickup node.
+func (d *Dispatcher) selectSource(order *store.Order, payloadTypeCode string, destNode *store.Node) (*store.Payload, *store.Node, error) {
+	strategy := StrategyFIFO
+	if pt, err := d.db.GetPayloadTypeByName(payloadTypeCode); err == nil {
+		strategy = pt.SourceStrategy
+	}
+	selector := SelectorFor(strategy)
+
+	ranked, err := d.rankSources(selector, payloadTypeCode, destNode)
 	if err != nil {
-		d.failOrder(order, env, "node_error", err.Error())
-		return
+		return nil, nil, err
 	}
+	d.dbg("retrieve: %s selection over %d candidates for type %s", selector.Name(), len(ranked), payloadTypeCode)
 
-	d.dispatchToFleet(order, env, sourceNode, destNode)
+	// Claim the payload to prevent double-dispatch
+	for _, c := range ranked {
+		if err := d.db.ClaimPayloadForPickup(c.Payload.ID, order.ID, c.Node.Name); err != nil {
+			d.dbg("retrieve: claim payload=%d failed: %v", c.Payload.ID, err)
+			continue
+		}
+		return c.Payload, c.Node, nil
+	}
+	return nil, nil, fmt.Errorf("could not claim any of %d candidates", len(ranked))
+}
+
+// rankSources orders the payloads of a type that can be sourced, best first,
+// under the given selector. Payloads not at a node are skipped.
+func (d *Dispatcher) rankSources(selector SourceSelector, payloadTypeCode string, destNode *store.Node) ([]SourceCandidate, error) {
+	payloads, err := d.db.ListSourcePayloadCandidates(payloadTypeCode)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]SourceCandidate, 0, len(payloads))
+	for _, p := range payloads {
+		if p.NodeID == nil {
+			continue
+		}
+		node, err := d.db.GetNode(*p.NodeID)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, SourceCandidate{Payload: p, Node: node})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no candidates")
+	}
+	return selector.Rank(d.db, candidates, destNode), nil
 }
 
 func (d *Dispatcher) handleMove(order *store.Order, env *protocol.Envelope, payloadTypeCode string) {
@@ -159,9 +287,9 @@ func (d *Dispatcher) handleMove(order *store.Order, env *protocol.Envelope, payl
 		found := false
 		for _, p := range payloads {
 			if p.PayloadTypeName == payloadTypeCode && p.ClaimedBy == nil {
-				found = true
-				if err := d.db.ClaimPayload(p.ID, order.ID); err == nil {
+				if err := d.db.ClaimPayloadForPickup(p.ID, order.ID, pickupNode.Name); err == nil {
 					d.dbg("move: claimed payload=%d type=%s at %s", p.ID, payloadTypeCode, order.PickupNode)
+					found = true
 					break
 				}
 			}
@@ -186,7 +314,12 @@ func (d *Dispatcher) handleMove(order *store.Order, env *protocol.Envelope, payl
 
 func (d *Dispatcher) handleStore(order *store.Order, env *protocol.Envelope) {
 	d.db.UpdateOrderStatus(order.ID, StatusSourcing, "finding storage destination")
+	d.sourceStore(order, env)
+}
 
+// sourceStore picks a storage destination and dispatches. Orders with no free
+// storage are held in the backlog when a wait is configured.
+func (d *Dispatcher) sourceStore(order *store.Order, env *protocol.Envelope) {
 	var payloadTypeID int64
 	if order.PayloadTypeID != nil {
 		payloadTypeID = *order.PayloadTypeID
@@ -195,7 +328,7 @@ func (d *Dispatcher) handleStore(order *store.Order, env *protocol.Envelope) {
 	destNode, err := d.db.FindStorageDestinationForPayload(payloadTypeID)
 	if err != nil {
 		d.dbg("store: no available storage node")
-		d.failOrder(order, env, "no_storage", "no available storage node found")
+		d.holdOrFail(order, env, "no_storage", "no available storage node found")
 		return
 	}
 	d.dbg("store: selected destination=%s for order %d", destNode.Name, order.ID)
@@ -231,7 +364,14 @@ func (d *Dispatcher) handleStore(order *store.Order, env *protocol.Envelope) {
 }
 
 func (d *Dispatcher) dispatchToFleet(order *store.Order, env *protocol.Envelope, sourceNode, destNode *store.Node) {
-	vendorOrderID := fmt.Sprintf("sg-%d-%s", order.ID, uuid.New().String()[:8])
+	if d.holdForZone(order, env, sourceNode, destNode) {
+		return
+	}
+	fleetName, destNode, ok := d.routeFleet(order, env, sourceNode, destNode)
+	if !ok {
+		return
+	}
+	vendorOrderID := newVendorOrderID(order)
 
 	req := fleet.TransportOrderRequest{
 		OrderID:    vendorOrderID,
@@ -244,23 +384,54 @@ func (d *Dispatcher) dispatchToFleet(order *store.Order, env *protocol.Envelope,
 	d.dbg("fleet dispatch: order=%d vendor_id=%s from=%s(%s) to=%s(%s) priority=%d",
 		order.ID, vendorOrderID, sourceNode.Name, sourceNode.VendorLocation, destNode.Name, destNode.VendorLocation, order.Priority)
 
-	if _, err := d.backend.CreateTransportOrder(req); err != nil {
+	intent, ok := d.recordIntent(order, env, vendorOrderID, req)
+	if !ok {
+		return
+	}
+
+	if err := d.createTransportOrder(order, fleetName, req); err != nil {
 		log.Printf("dispatch: fleet create order failed: %v", err)
 		d.dbg("fleet dispatch failed: %v", err)
-		d.failOrder(order, env, "fleet_failed", err.Error())
+		d.db.AbandonFleetIntent(intent.ID)
+		d.retryOrFail(order, env, false, err.Error())
 		return
 	}
 
 	log.Printf("dispatch: order %d dispatched as %s (%s -> %s)", order.ID, vendorOrderID, sourceNode.Name, destNode.Name)
-	d.dbg("fleet dispatch ok: order=%d vendor_id=%s", order.ID, vendorOrderID)
+	d.recordDispatch(order, env, intent, sourceNode.Name, destNode.Name)
+}
+
+func newVendorOrderID(order *store.Order) string {
+	return fmt.Sprintf("sg-%d-%s", order.ID, uuid.New().String()[:8])
+}
 
-	d.db.UpdateOrderVendor(order.ID, vendorOrderID, "CREATED", "")
-	d.db.UpdateOrderStatus(order.ID, StatusDispatched, fmt.Sprintf("vendor order %s created", vendorOrderID))
+// recordIntent writes the fleet intent for a vendor order before it is sent,
+// so a crash during the fleet call can be reconciled on restart.
+func (d *Dispatcher) recordIntent(order *store.Order, env *protocol.Envelope, vendorOrderID string, req any) (*store.FleetIntent, bool) {
+	data, _ := json.Marshal(req)
+	intent, err := d.db.CreateFleetIntent(order.ID, vendorOrderID, string(data))
+	if err != nil {
+		log.Printf("dispatch: record fleet intent for order %d: %v", order.ID, err)
+		d.failOrder(order, env, "internal_error", fmt.Sprintf("record fleet intent: %v", err))
+		return nil, false
+	}
+	return intent, true
+}
 
-	d.emitter.EmitOrderDispatched(order.ID, vendorOrderID, sourceNode.Name, destNode.Name)
+// recordDispatch commits the fleet intent for a newly created vendor order,
+// announces it and acks ShinGo Edge.
+func (d *Dispatcher) recordDispatch(order *store.Order, env *protocol.Envelope, intent *store.FleetIntent, sourceName, destName string) {
+	d.dbg("fleet dispatch ok: order=%d vendor_id=%s", order.ID, intent.VendorOrderID)
+
+	if err := d.db.CommitFleetIntent(intent, StatusDispatched, fmt.Sprintf("vendor order %s created", intent.VendorOrderID)); err != nil {
+		// The vendor order exists; the reconciler commits the intent on restart.
+		log.Printf("dispatch: commit fleet intent for order %d: %v", order.ID, err)
+	}
+
+	d.emitter.EmitOrderDispatched(order.ID, intent.VendorOrderID, sourceName, destName)
 
 	// Send ack to ShinGo Edge
-	d.sendAck(env, order.EdgeUUID, order.ID, sourceNode.Name)
+	d.sendAck(env, order.EdgeUUID, order.ID, sourceName)
 }
 
 // HandleOrderCancel processes a cancellation request from ShinGo Edge.
@@ -268,11 +439,12 @@ func (d *Dispatcher) HandleOrderCancel(env *protocol.Envelope, p *protocol.Order
 	stationID := env.Src.Station
 	d.dbg("cancel request: station=%s uuid=%s reason=%s", stationID, p.OrderUUID, p.Reason)
 
-	order, err := d.db.GetOrderByUUID(p.OrderUUID)
+	order, unlock, err := d.lockOrderByUUID(p.OrderUUID)
 	if err != nil {
 		log.Printf("dispatch: cancel order %s not found: %v", p.OrderUUID, err)
 		return
 	}
+	defer unlock()
 
 	// If dispatched to fleet, cancel
 	if order.VendorOrderID != "" && order.Status != StatusConfirmed && order.Status != StatusFailed && order.Status != StatusCancelled {
@@ -284,10 +456,9 @@ func (d *Dispatcher) HandleOrderCancel(env *protocol.Envelope, p *protocol.Order
 		}
 	}
 
-	// Unclaim inventory if applicable
-	d.unclaimOrderPayloads(order.ID)
-
-	d.db.UpdateOrderStatus(order.ID, StatusCancelled, p.Reason)
+	// Cancel and unclaim inventory together
+	d.db.CloseOrder(order.ID, StatusCancelled, p.Reason)
+	d.dropWaiting(order.ID)
 
 	d.emitter.EmitOrderCancelled(order.ID, order.EdgeUUID, stationID, p.Reason)
 
@@ -314,11 +485,12 @@ func (d *Dispatcher) HandleOrderReceipt(env *protocol.Envelope, p *protocol.Orde
 	stationID := env.Src.Station
 	d.dbg("delivery receipt: station=%s uuid=%s type=%s count=%.1f", stationID, p.OrderUUID, p.ReceiptType, p.FinalCount)
 
-	order, err := d.db.GetOrderByUUID(p.OrderUUID)
+	order, unlock, err := d.lockOrderByUUID(p.OrderUUID)
 	if err != nil {
 		log.Printf("dispatch: delivery receipt order %s not found: %v", p.OrderUUID, err)
 		return
 	}
+	defer unlock()
 
 	d.db.UpdateOrderStatus(order.ID, StatusConfirmed, fmt.Sprintf("receipt: %s, count: %.1f", p.ReceiptType, p.FinalCount))
 
@@ -331,11 +503,12 @@ func (d *Dispatcher) HandleOrderReceipt(env *protocol.Envelope, p *protocol.Orde
 func (d *Dispatcher) HandleOrderRedirect(env *protocol.Envelope, p *protocol.OrderRedirect) {
 	d.dbg("redirect: uuid=%s new_dest=%s", p.OrderUUID, p.NewDeliveryNode)
 
-	order, err := d.db.GetOrderByUUID(p.OrderUUID)
+	order, unlock, err := d.lockOrderByUUID(p.OrderUUID)
 	if err != nil {
 		log.Printf("dispatch: redirect order %s not found: %v", p.OrderUUID, err)
 		return
 	}
+	defer unlock()
 
 	// Cancel existing vendor order
 	if order.VendorOrderID != "" {
@@ -383,44 +556,25 @@ func (d *Dispatcher) HandleOrderStorageWaybill(env *protocol.Envelope, p *protoc
 		PayloadDesc: p.PayloadDesc,
 	}
 
-	if err := d.db.CreateOrder(order); err != nil {
+	if err := d.db.ReceiveOrder(order, "store order received"); err != nil {
 		log.Printf("dispatch: create store order: %v", err)
 		d.sendError(env, p.OrderUUID, "internal_error", err.Error())
 		return
 	}
-	d.db.UpdateOrderStatus(order.ID, StatusPending, "store order received")
 
 	d.emitter.EmitOrderReceived(order.ID, order.EdgeUUID, stationID, p.OrderType, "", p.PickupNode)
 
-	d.handleStore(order, env)
+	d.admitOrder(order, env)
 }
 
 func (d *Dispatcher) failOrder(order *store.Order, env *protocol.Envelope, errorCode, detail string) {
 	stationID := env.Src.Station
-	d.db.UpdateOrderStatus(order.ID, StatusFailed, detail)
-	d.unclaimOrderPayloads(order.ID)
+	d.db.CloseOrder(order.ID, StatusFailed, detail)
+	d.dropWaiting(order.ID)
 	d.emitter.EmitOrderFailed(order.ID, order.EdgeUUID, stationID, errorCode, detail)
 	d.sendError(env, order.EdgeUUID, errorCode, detail)
 }
 
-func (d *Dispatcher) unclaimOrderPayloads(orderID int64) {
-	// Collect IDs first, then unclaim — avoids holding rows cursor during Exec (SQLite deadlock)
-	rows, err := d.db.Query(d.db.Q(`SELECT id FROM payloads WHERE claimed_by=?`), orderID)
-	if err != nil {
-		return
-	}
-	var ids []int64
-	for rows.Next() {
-		var id int64
-		rows.Scan(&id)
-		ids = append(ids, id)
-	}
-	rows.Close()
-	for _, id := range ids {
-		d.db.UnclaimPayload(id)
-	}
-}
-
 func (d *Dispatcher) sendAck(env *protocol.Envelope, orderUUID string, shingoOrderID int64, sourceNode string) {
 	stationID := env.Src.Station
 	edgeAddr := protocol.Address{Role: protocol.RoleEdge, Station: stationID}
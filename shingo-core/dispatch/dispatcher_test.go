@@ -1,10 +1,14 @@
 package dispatch
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"shingo/protocol"
 	"shingocore/config"
@@ -20,6 +24,8 @@ type mockEmitter struct {
 	failed     []emitFailed
 	cancelled  []emitCancelled
 	completed  []emitCompleted
+	breached   []int64
+	zoneLocks  []string
 }
 
 type emitReceived struct {
@@ -57,6 +63,12 @@ func (m *mockEmitter) EmitOrderCancelled(orderID int64, _, _, reason string) {
 func (m *mockEmitter) EmitOrderCompleted(orderID int64, _, _ string) {
 	m.completed = append(m.completed, emitCompleted{orderID})
 }
+func (m *mockEmitter) EmitSLABreached(orderID int64, _, _ string, _, _ time.Duration) {
+	m.breached = append(m.breached, orderID)
+}
+func (m *mockEmitter) EmitZoneLock(_ int64, zone string, locked bool, _, _ string) {
+	m.zoneLocks = append(m.zoneLocks, fmt.Sprintf("%s:%v", zone, locked))
+}
 
 // --- Mock fleet backend ---
 
@@ -365,33 +377,1614 @@ func TestFIFOPayloadSourceSelection(t *testing.T) {
 	}
 }
 
-func TestStatusConstants(t *testing.T) {
-	// Verify all plan-defined statuses exist
-	statuses := []string{
-		StatusPending, StatusSourcing, StatusSubmitted, StatusDispatched,
-		StatusAcknowledged, StatusInTransit, StatusDelivered, StatusConfirmed,
-		StatusFailed, StatusCancelled,
+func sourceCandidates(t *testing.T, db *store.DB) []SourceCandidate {
+	t.Helper()
+	payloads, err := db.ListSourcePayloadCandidates("PART-A")
+	if err != nil {
+		t.Fatalf("ListSourcePayloadCandidates: %v", err)
 	}
-	expected := []string{
-		"pending", "sourcing", "submitted", "dispatched",
-		"acknowledged", "in_transit", "delivered", "confirmed",
-		"failed", "cancelled",
+	var out []SourceCandidate
+	for _, p := range payloads {
+		n, err := db.GetNode(*p.NodeID)
+		if err != nil {
+			t.Fatalf("get node: %v", err)
+		}
+		out = append(out, SourceCandidate{Payload: p, Node: n})
 	}
-	for i, s := range statuses {
-		if s != expected[i] {
-			t.Errorf("status[%d] = %q, want %q", i, s, expected[i])
+	return out
+}
+
+func TestSourceSelectors(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	lineNode.Zone = "B"
+	db.UpdateNode(lineNode)
+
+	s2 := &store.Node{Name: "STORAGE-B1", VendorLocation: "Loc-02", NodeType: "storage", Zone: "B", Capacity: 10, Enabled: true}
+	db.CreateNode(s2)
+
+	p1 := &store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"}
+	db.CreatePayload(p1)
+	p2 := &store.Payload{PayloadTypeID: pt.ID, NodeID: &s2.ID, Status: "available"}
+	db.CreatePayload(p2)
+
+	// p2 was produced earlier than p1 even though it was delivered later
+	db.CreateManifestItem(&store.ManifestItem{PayloadID: p1.ID, PartNumber: "X", Quantity: 1, ProductionDate: "2024-03-01"})
+	db.CreateManifestItem(&store.ManifestItem{PayloadID: p2.ID, PartNumber: "X", Quantity: 1, ProductionDate: "2024-01-15"})
+
+	// STORAGE-B1 sits next to the line, STORAGE-A1 far away
+	db.UpsertScenePoint(&store.ScenePoint{AreaName: "A", InstanceName: "Loc-01", ClassName: "GeneralLocation", PosX: 100, PosY: 0})
+	db.UpsertScenePoint(&store.ScenePoint{AreaName: "A", InstanceName: "Loc-02", ClassName: "GeneralLocation", PosX: 1, PosY: 1})
+	db.UpsertScenePoint(&store.ScenePoint{AreaName: "A", InstanceName: "Loc-10", ClassName: "GeneralLocation", PosX: 0, PosY: 0})
+
+	candidates := sourceCandidates(t, db)
+	if len(candidates) != 2 {
+		t.Fatalf("candidates = %d, want 2", len(candidates))
+	}
+
+	tests := []struct {
+		strategy string
+		want     int64
+	}{
+		{StrategyFIFO, p1.ID},
+		{StrategyLIFO, p2.ID},
+		{StrategyFEFO, p2.ID},
+		{StrategyNearest, p2.ID},
+		{StrategyZone, p2.ID},
+		{"bogus", p1.ID},
+	}
+	for _, tt := range tests {
+		ranked := SelectorFor(tt.strategy).Rank(db, candidates, lineNode)
+		if ranked[0].Payload.ID != tt.want {
+			t.Errorf("%s: first = %d, want %d", tt.strategy, ranked[0].Payload.ID, tt.want)
 		}
 	}
 }
 
-func TestOrderTypeConstants(t *testing.T) {
-	if OrderTypeRetrieve != "retrieve" {
-		t.Errorf("OrderTypeRetrieve = %q", OrderTypeRetrieve)
+func TestSourceStrategyPersisted(t *testing.T) {
+	db := testDB(t)
+	_, _, pt := setupTestData(t, db)
+
+	got, _ := db.GetPayloadType(pt.ID)
+	if got.SourceStrategy != StrategyFIFO {
+		t.Errorf("default strategy = %q, want %q", got.SourceStrategy, StrategyFIFO)
 	}
-	if OrderTypeMove != "move" {
-		t.Errorf("OrderTypeMove = %q", OrderTypeMove)
+
+	got.SourceStrategy = StrategyFEFO
+	db.UpdatePayloadType(got)
+	got, _ = db.GetPayloadType(pt.ID)
+	if got.SourceStrategy != StrategyFEFO {
+		t.Errorf("strategy = %q, want %q", got.SourceStrategy, StrategyFEFO)
 	}
-	if OrderTypeStore != "store" {
-		t.Errorf("OrderTypeStore = %q", OrderTypeStore)
+}
+
+func TestRetrieve_NoSource_QueuedInBacklog(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+
+	d, emitter := newTestDispatcher(t, db, &mockBackend{})
+	d.MaxSourcingWait = time.Hour
+
+	env := testEnvelope()
+	d.HandleOrderRequest(env, &protocol.OrderRequest{
+		OrderUUID:       "uuid-wait",
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    lineNode.Name,
+		Quantity:        1.0,
+	})
+
+	if len(emitter.failed) != 0 {
+		t.Fatalf("failed events = %d, want 0 (order should wait)", len(emitter.failed))
+	}
+	if d.WaitingCount() != 1 {
+		t.Fatalf("waiting = %d, want 1", d.WaitingCount())
+	}
+	order, _ := db.GetOrderByUUID("uuid-wait")
+	if order.Status != StatusSourcing {
+		t.Errorf("status = %q, want %q", order.Status, StatusSourcing)
+	}
+
+	msgs, _ := db.ListPendingOutbox(10)
+	found := false
+	for _, m := range msgs {
+		if m.MsgType == "order.update" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected an order.update waiting-for-stock message in outbox")
+	}
+
+	// Stock arrives; the retry sources it and hands it to the (failing) fleet
+	p := &store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"}
+	db.CreatePayload(p)
+	d.RetryWaiting()
+
+	if d.WaitingCount() != 0 {
+		t.Errorf("waiting = %d, want 0", d.WaitingCount())
+	}
+	if len(emitter.failed) != 1 || emitter.failed[0].errorCode != "fleet_failed" {
+		t.Fatalf("failed = %+v, want one fleet_failed", emitter.failed)
+	}
+	order, _ = db.GetOrder(order.ID)
+	if order.PickupNode != storageNode.Name {
+		t.Errorf("pickup = %q, want %q", order.PickupNode, storageNode.Name)
+	}
+}
+
+func TestRetrieve_Backlog_MaxWaitExpires(t *testing.T) {
+	db := testDB(t)
+	_, lineNode, _ := setupTestData(t, db)
+
+	d, emitter := newTestDispatcher(t, db, &mockBackend{})
+
+	env := testEnvelope()
+	d.HandleOrderRequest(env, &protocol.OrderRequest{
+		OrderUUID:       "uuid-expire",
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    lineNode.Name,
+		MaxWaitSec:      1,
+	})
+	if d.WaitingCount() != 1 {
+		t.Fatalf("waiting = %d, want 1 (per-order max wait)", d.WaitingCount())
+	}
+
+	d.mu.Lock()
+	for _, w := range d.waiting {
+		w.since = time.Now().Add(-2 * time.Second)
+	}
+	d.mu.Unlock()
+	d.RetryWaiting()
+
+	if len(emitter.failed) != 1 || emitter.failed[0].errorCode != "no_source" {
+		t.Fatalf("failed = %+v, want one no_source", emitter.failed)
+	}
+	if d.WaitingCount() != 0 {
+		t.Errorf("waiting = %d, want 0", d.WaitingCount())
+	}
+}
+
+func TestHandleOrderCancel_RemovesFromBacklog(t *testing.T) {
+	db := testDB(t)
+	_, lineNode, _ := setupTestData(t, db)
+
+	d, _ := newTestDispatcher(t, db, &mockBackend{})
+	d.MaxSourcingWait = time.Hour
+
+	env := testEnvelope()
+	d.HandleOrderRequest(env, &protocol.OrderRequest{
+		OrderUUID:       "uuid-wait-cancel",
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    lineNode.Name,
+	})
+	d.HandleOrderCancel(env, &protocol.OrderCancel{OrderUUID: "uuid-wait-cancel", Reason: "not needed"})
+
+	if d.WaitingCount() != 0 {
+		t.Errorf("waiting = %d, want 0", d.WaitingCount())
+	}
+}
+
+// blockingBackend holds each transport order in the fleet call until released.
+type blockingBackend struct {
+	recordingBackend
+	entered   chan struct{}
+	release   chan struct{}
+	cancelled []string
+}
+
+func (m *blockingBackend) CreateTransportOrder(req fleet.TransportOrderRequest) (fleet.TransportOrderResult, error) {
+	m.entered <- struct{}{}
+	<-m.release
+	return m.recordingBackend.CreateTransportOrder(req)
+}
+
+func (m *blockingBackend) CancelOrder(vendorOrderID string) error {
+	m.cancelled = append(m.cancelled, vendorOrderID)
+	return nil
+}
+
+func TestHandleOrderCancel_DuringBacklogRetry(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+
+	backend := &blockingBackend{entered: make(chan struct{}), release: make(chan struct{})}
+	d, _ := newTestDispatcher(t, db, backend)
+	d.MaxSourcingWait = time.Hour
+
+	env := testEnvelope()
+	d.HandleOrderRequest(env, &protocol.OrderRequest{
+		OrderUUID:       "uuid-race",
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    lineNode.Name,
+	})
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+
+	retried := make(chan struct{})
+	go func() {
+		d.RetryWaiting()
+		close(retried)
+	}()
+	<-backend.entered
+
+	cancelled := make(chan struct{})
+	go func() {
+		d.HandleOrderCancel(env, &protocol.OrderCancel{OrderUUID: "uuid-race", Reason: "not needed"})
+		close(cancelled)
+	}()
+	select {
+	case <-cancelled:
+		t.Fatal("cancel finished while the retry was still dispatching")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(backend.release)
+	<-retried
+	<-cancelled
+
+	order, _ := db.GetOrderByUUID("uuid-race")
+	if order.Status != StatusCancelled {
+		t.Errorf("status = %q, want %q", order.Status, StatusCancelled)
+	}
+	if len(backend.cancelled) != 1 || backend.cancelled[0] != order.VendorOrderID {
+		t.Errorf("cancelled vendor orders = %v, want [%s]", backend.cancelled, order.VendorOrderID)
+	}
+}
+
+// recordingBackend accepts every transport order and keeps the requests.
+type recordingBackend struct {
+	mockBackend
+	reqs []fleet.TransportOrderRequest
+}
+
+func (m *recordingBackend) CreateTransportOrder(req fleet.TransportOrderRequest) (fleet.TransportOrderResult, error) {
+	m.reqs = append(m.reqs, req)
+	return fleet.TransportOrderResult{VendorOrderID: req.OrderID}, nil
+}
+
+// multiStopBackend also accepts multi-stop orders.
+type multiStopBackend struct {
+	recordingBackend
+	multi []fleet.MultiStopOrderRequest
+}
+
+func (m *multiStopBackend) CreateMultiStopOrder(req fleet.MultiStopOrderRequest) (fleet.TransportOrderResult, error) {
+	m.multi = append(m.multi, req)
+	return fleet.TransportOrderResult{VendorOrderID: req.OrderID}, nil
+}
+
+func swapRequest() *protocol.OrderRequest {
+	return &protocol.OrderRequest{
+		OrderUUID:       "uuid-swap",
+		OrderType:       OrderTypeSwap,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    "LINE1-IN",
+		Quantity:        1,
+	}
+}
+
+func TestSwap_SingleMultiStopOrder(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	full := &store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"}
+	db.CreatePayload(full)
+	empty := &store.Payload{PayloadTypeID: pt.ID, NodeID: &lineNode.ID, Status: "empty"}
+	db.CreatePayload(empty)
+
+	backend := &multiStopBackend{}
+	d, emitter := newTestDispatcher(t, db, backend)
+	d.HandleOrderRequest(testEnvelope(), swapRequest())
+
+	if len(backend.reqs) != 0 {
+		t.Errorf("single-leg requests = %d, want 0", len(backend.reqs))
+	}
+	if len(backend.multi) != 1 {
+		t.Fatalf("multi-stop requests = %d, want 1", len(backend.multi))
+	}
+	want := []fleet.TransportStop{
+		{Loc: "Loc-01", Action: fleet.StopPick},
+		{Loc: "Loc-10", Action: fleet.StopDrop},
+		{Loc: "Loc-10", Action: fleet.StopPick},
+		{Loc: "Loc-01", Action: fleet.StopDrop},
+	}
+	stops := backend.multi[0].Stops
+	if len(stops) != len(want) {
+		t.Fatalf("stops = %d, want %d", len(stops), len(want))
+	}
+	for i := range want {
+		if stops[i] != want[i] {
+			t.Errorf("stop[%d] = %+v, want %+v", i, stops[i], want[i])
+		}
+	}
+	if len(emitter.dispatched) != 1 {
+		t.Errorf("dispatched events = %d, want 1", len(emitter.dispatched))
+	}
+
+	order, _ := db.GetOrderByUUID("uuid-swap")
+	if order.ReturnNode != "STORAGE-A1" {
+		t.Errorf("return node = %q, want %q", order.ReturnNode, "STORAGE-A1")
+	}
+	claimed, _ := db.ListPayloadsByClaimedOrder(order.ID)
+	if len(claimed) != 2 {
+		t.Errorf("claimed payloads = %d, want 2", len(claimed))
+	}
+}
+
+func TestSwap_NoEmptyAtLine_DeliversOnly(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+	// Full stock still on the line is not an empty and must stay put.
+	onLine := &store.Payload{PayloadTypeID: pt.ID, NodeID: &lineNode.ID, Status: "available"}
+	db.CreatePayload(onLine)
+
+	backend := &multiStopBackend{}
+	d, _ := newTestDispatcher(t, db, backend)
+	d.HandleOrderRequest(testEnvelope(), swapRequest())
+
+	if len(backend.multi) != 0 {
+		t.Errorf("multi-stop requests = %d, want 0", len(backend.multi))
+	}
+	if len(backend.reqs) != 1 || backend.reqs[0].ToLoc != "Loc-10" {
+		t.Fatalf("expected one delivery to Loc-10, got %+v", backend.reqs)
+	}
+	order, _ := db.GetOrderByUUID("uuid-swap")
+	if order.ReturnNode != "" {
+		t.Errorf("return node = %q, want empty", order.ReturnNode)
+	}
+	if p, _ := db.GetPayload(onLine.ID); p.ClaimedBy != nil {
+		t.Errorf("full payload on the line was claimed by order %d", *p.ClaimedBy)
+	}
+}
+
+func TestSwap_BackendWithoutMultiStop_Fails(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &lineNode.ID, Status: "empty"})
+
+	d, emitter := newTestDispatcher(t, db, &recordingBackend{})
+	d.HandleOrderRequest(testEnvelope(), swapRequest())
+
+	if len(emitter.failed) != 1 || emitter.failed[0].errorCode != "fleet_failed" {
+		t.Fatalf("failed events = %+v, want one fleet_failed", emitter.failed)
+	}
+	order, _ := db.GetOrderByUUID("uuid-swap")
+	claimed, _ := db.ListPayloadsByClaimedOrder(order.ID)
+	if len(claimed) != 0 {
+		t.Errorf("claimed payloads = %d, want 0 after failure", len(claimed))
+	}
+}
+
+func setupStagingRetrieve(t *testing.T) (*store.DB, *Dispatcher, *recordingBackend, *store.Order) {
+	t.Helper()
+	db := testDB(t)
+	storageNode, _, pt := setupTestData(t, db)
+	staging := &store.Node{Name: "LINE1-STG", VendorLocation: "Loc-20", NodeType: "staging", Capacity: 2, Enabled: true}
+	if err := db.CreateNode(staging); err != nil {
+		t.Fatalf("create staging node: %v", err)
+	}
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+
+	backend := &recordingBackend{}
+	d, _ := newTestDispatcher(t, db, backend)
+	d.HandleOrderRequest(testEnvelope(), &protocol.OrderRequest{
+		OrderUUID:       "uuid-staged",
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    "LINE1-IN",
+		StagingNode:     "LINE1-STG",
+		Quantity:        1,
+	})
+
+	order, err := db.GetOrderByUUID("uuid-staged")
+	if err != nil {
+		t.Fatalf("get order: %v", err)
+	}
+	return db, d, backend, order
+}
+
+func TestRetrieve_Staged_FirstLegToStagingNode(t *testing.T) {
+	_, _, backend, order := setupStagingRetrieve(t)
+
+	if len(backend.reqs) != 1 {
+		t.Fatalf("fleet requests = %d, want 1", len(backend.reqs))
+	}
+	if backend.reqs[0].FromLoc != "Loc-01" || backend.reqs[0].ToLoc != "Loc-20" {
+		t.Errorf("leg 1 = %s -> %s, want Loc-01 -> Loc-20", backend.reqs[0].FromLoc, backend.reqs[0].ToLoc)
+	}
+	if order.StagingNode != "LINE1-STG" {
+		t.Errorf("staging node = %q, want %q", order.StagingNode, "LINE1-STG")
+	}
+	if order.Status != StatusDispatched {
+		t.Errorf("status = %q, want %q", order.Status, StatusDispatched)
+	}
+	if !IsStagingLeg(order) {
+		t.Error("first leg should be a staging leg")
+	}
+}
+
+func TestHandleOrderRelease_DispatchesSecondLeg(t *testing.T) {
+	db, d, backend, order := setupStagingRetrieve(t)
+	firstVendorID := order.VendorOrderID
+	db.UpdateOrderStatus(order.ID, StatusStaged, "payload held at LINE1-STG awaiting release")
+
+	d.HandleOrderRelease(testEnvelope(), &protocol.OrderRelease{OrderUUID: "uuid-staged", Reason: "line empty"})
+
+	if len(backend.reqs) != 2 {
+		t.Fatalf("fleet requests = %d, want 2", len(backend.reqs))
+	}
+	if backend.reqs[1].FromLoc != "Loc-20" || backend.reqs[1].ToLoc != "Loc-10" {
+		t.Errorf("leg 2 = %s -> %s, want Loc-20 -> Loc-10", backend.reqs[1].FromLoc, backend.reqs[1].ToLoc)
+	}
+
+	got, _ := db.GetOrder(order.ID)
+	if got.VendorOrderID == firstVendorID {
+		t.Errorf("leg 2 reused vendor order %s", firstVendorID)
+	}
+	if got.PickupNode != "LINE1-STG" {
+		t.Errorf("pickup node = %q, want %q", got.PickupNode, "LINE1-STG")
+	}
+	if got.Status != StatusDispatched {
+		t.Errorf("status = %q, want %q", got.Status, StatusDispatched)
+	}
+	if IsStagingLeg(got) {
+		t.Error("second leg should not be a staging leg")
+	}
+
+	history, _ := db.ListOrderHistory(order.ID)
+	dispatched := 0
+	for _, h := range history {
+		if h.Status == StatusDispatched {
+			dispatched++
+		}
+	}
+	if dispatched != 2 {
+		t.Errorf("dispatched history entries = %d, want 2", dispatched)
+	}
+}
+
+func TestHandleOrderRelease_IgnoredUnlessStaged(t *testing.T) {
+	db, d, backend, order := setupStagingRetrieve(t)
+
+	d.HandleOrderRelease(testEnvelope(), &protocol.OrderRelease{OrderUUID: "uuid-staged"})
+
+	if len(backend.reqs) != 1 {
+		t.Errorf("fleet requests = %d, want 1", len(backend.reqs))
+	}
+	got, _ := db.GetOrder(order.ID)
+	if got.VendorOrderID != order.VendorOrderID {
+		t.Errorf("vendor order changed to %s", got.VendorOrderID)
+	}
+}
+
+// lookupBackend also answers order lookups for the vendor orders it knows.
+type lookupBackend struct {
+	recordingBackend
+	known map[string]string
+}
+
+func (m *lookupBackend) GetOrderState(vendorOrderID string) (string, error) {
+	if state, ok := m.known[vendorOrderID]; ok {
+		return state, nil
+	}
+	return "", fleet.ErrOrderNotFound
+}
+
+// setupStuckRetrieve leaves a retrieve order as a crash would after claiming
+// its source payload: in sourcing, with the payload claimed.
+func setupStuckRetrieve(t *testing.T) (*store.DB, *store.Order, *store.Payload) {
+	t.Helper()
+	db := testDB(t)
+	storageNode, _, pt := setupTestData(t, db)
+	p := &store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"}
+	db.CreatePayload(p)
+
+	order := &store.Order{
+		EdgeUUID:      "uuid-stuck",
+		StationID:     "line-1",
+		OrderType:     OrderTypeRetrieve,
+		Status:        StatusSourcing,
+		DeliveryNode:  "LINE1-IN",
+		PayloadTypeID: &pt.ID,
+	}
+	if err := db.ReceiveOrder(order, "finding source"); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	if err := db.ClaimPayloadForPickup(p.ID, order.ID, storageNode.Name); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	return db, order, p
+}
+
+func TestReconcile_SourcingOrder_Resumes(t *testing.T) {
+	db, order, _ := setupStuckRetrieve(t)
+	backend := &lookupBackend{}
+	d, emitter := newTestDispatcher(t, db, backend)
+
+	res := d.ReconcileStuckOrders()
+	if res.Resumed != 1 || res.Unclaimed != 1 || res.Failed != 0 {
+		t.Errorf("result = %+v, want 1 resumed, 1 unclaimed", res)
+	}
+	if len(backend.reqs) != 1 {
+		t.Fatalf("fleet requests = %d, want 1", len(backend.reqs))
+	}
+	got, _ := db.GetOrder(order.ID)
+	if got.Status != StatusDispatched {
+		t.Errorf("status = %q, want %q", got.Status, StatusDispatched)
+	}
+	if len(emitter.dispatched) != 1 {
+		t.Errorf("dispatched events = %d, want 1", len(emitter.dispatched))
+	}
+}
+
+func TestReconcile_PendingIntent_FoundCommits(t *testing.T) {
+	db, order, p := setupStuckRetrieve(t)
+	db.CreateFleetIntent(order.ID, "sg-1-live", "")
+	backend := &lookupBackend{known: map[string]string{"sg-1-live": "RUNNING"}}
+	d, emitter := newTestDispatcher(t, db, backend)
+
+	res := d.ReconcileStuckOrders()
+	if res.Resumed != 1 || res.Unclaimed != 0 {
+		t.Errorf("result = %+v, want 1 resumed, 0 unclaimed", res)
+	}
+	if len(backend.reqs) != 0 {
+		t.Errorf("fleet requests = %d, want 0 (order already exists)", len(backend.reqs))
+	}
+	got, _ := db.GetOrder(order.ID)
+	if got.Status != StatusDispatched || got.VendorOrderID != "sg-1-live" {
+		t.Errorf("order = %s/%s, want dispatched/sg-1-live", got.Status, got.VendorOrderID)
+	}
+	gotP, _ := db.GetPayload(p.ID)
+	if gotP.ClaimedBy == nil || *gotP.ClaimedBy != order.ID {
+		t.Error("payload should stay claimed by the committed order")
+	}
+	if len(emitter.dispatched) != 1 {
+		t.Errorf("dispatched events = %d, want 1", len(emitter.dispatched))
+	}
+	if pending, _ := db.ListPendingFleetIntents(); len(pending) != 0 {
+		t.Errorf("pending intents = %d, want 0", len(pending))
+	}
+}
+
+func TestReconcile_PendingIntent_NotFoundRedispatches(t *testing.T) {
+	db, order, _ := setupStuckRetrieve(t)
+	db.CreateFleetIntent(order.ID, "sg-1-lost", "")
+	backend := &lookupBackend{}
+	d, _ := newTestDispatcher(t, db, backend)
+
+	res := d.ReconcileStuckOrders()
+	if res.Resumed != 1 || res.Unclaimed != 1 {
+		t.Errorf("result = %+v, want 1 resumed, 1 unclaimed", res)
+	}
+	if len(backend.reqs) != 1 {
+		t.Fatalf("fleet requests = %d, want 1", len(backend.reqs))
+	}
+	got, _ := db.GetOrder(order.ID)
+	if got.Status != StatusDispatched || got.VendorOrderID == "sg-1-lost" {
+		t.Errorf("order = %s/%s, want dispatched under a new vendor order", got.Status, got.VendorOrderID)
+	}
+}
+
+func TestReconcile_PendingIntent_NoLookupFails(t *testing.T) {
+	db, order, p := setupStuckRetrieve(t)
+	db.CreateFleetIntent(order.ID, "sg-1-unknown", "")
+	d, emitter := newTestDispatcher(t, db, &recordingBackend{})
+
+	res := d.ReconcileStuckOrders()
+	if res.Failed != 1 {
+		t.Errorf("result = %+v, want 1 failed", res)
+	}
+	got, _ := db.GetOrder(order.ID)
+	if got.Status != StatusFailed {
+		t.Errorf("status = %q, want %q", got.Status, StatusFailed)
+	}
+	gotP, _ := db.GetPayload(p.ID)
+	if gotP.ClaimedBy != nil {
+		t.Error("payload should be unclaimed")
+	}
+	if len(emitter.failed) != 1 || emitter.failed[0].errorCode != "dispatch_interrupted" {
+		t.Errorf("failed events = %+v, want dispatch_interrupted", emitter.failed)
+	}
+}
+
+func TestReconcile_DispatchedOrderLost_Fails(t *testing.T) {
+	db, order, p := setupStuckRetrieve(t)
+	fi, _ := db.CreateFleetIntent(order.ID, "sg-1-gone", "")
+	db.CommitFleetIntent(fi, StatusDispatched, "vendor order sg-1-gone created")
+	d, emitter := newTestDispatcher(t, db, &lookupBackend{})
+
+	res := d.ReconcileStuckOrders()
+	if res.Failed != 1 {
+		t.Errorf("result = %+v, want 1 failed", res)
+	}
+	gotP, _ := db.GetPayload(p.ID)
+	if gotP.ClaimedBy != nil {
+		t.Error("payload should be unclaimed")
+	}
+	if len(emitter.failed) != 1 || emitter.failed[0].errorCode != "fleet_order_lost" {
+		t.Errorf("failed events = %+v, want fleet_order_lost", emitter.failed)
+	}
+}
+
+// priorityBackend records fleet priority changes.
+type priorityBackend struct {
+	recordingBackend
+	priorities map[string]int
+}
+
+func (m *priorityBackend) SetOrderPriority(vendorOrderID string, priority int) error {
+	m.priorities[vendorOrderID] = priority
+	return nil
+}
+
+func TestEscalationPolicy_TargetPriority(t *testing.T) {
+	p := EscalationPolicy{
+		AgeStep:       5 * time.Minute,
+		LoadTypeBoost: map[string]int{"hot": 10},
+		BreachBoost:   20,
+		MaxPriority:   40,
+	}
+	tests := []struct {
+		name     string
+		order    store.Order
+		waited   time.Duration
+		breached bool
+		want     int
+	}{
+		{"fresh standard", store.Order{BasePriority: 1}, time.Minute, false, 1},
+		{"aged standard", store.Order{BasePriority: 1}, 16 * time.Minute, false, 4},
+		{"fresh hot", store.Order{BasePriority: 1, LoadType: "hot"}, 0, false, 11},
+		{"breached", store.Order{BasePriority: 1}, 10 * time.Minute, true, 23},
+		{"capped", store.Order{BasePriority: 1, LoadType: "hot"}, time.Hour, true, 40},
+	}
+	for _, tt := range tests {
+		if got := p.TargetPriority(&tt.order, tt.waited, tt.breached); got != tt.want {
+			t.Errorf("%s: target = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEscalatePriorities(t *testing.T) {
+	db := testDB(t)
+	setupTestData(t, db)
+	backend := &priorityBackend{priorities: map[string]int{}}
+	d, emitter := newTestDispatcher(t, db, backend)
+
+	sourcing := &store.Order{EdgeUUID: "uuid-src", StationID: "line-1", OrderType: OrderTypeRetrieve, Status: StatusSourcing, Priority: 1}
+	db.ReceiveOrder(sourcing, "finding source")
+	queued := &store.Order{EdgeUUID: "uuid-q", StationID: "line-2", OrderType: OrderTypeRetrieve, Status: StatusDispatched, LoadType: "hot"}
+	db.ReceiveOrder(queued, "order received")
+	db.UpdateOrderVendor(queued.ID, "sg-2-q", "CREATED", "")
+	manual := &store.Order{EdgeUUID: "uuid-m", StationID: "line-1", OrderType: OrderTypeRetrieve, Status: StatusSourcing}
+	db.ReceiveOrder(manual, "finding source")
+	db.UpdateOrderPriority(manual.ID, 90)
+
+	policy := EscalationPolicy{
+		AgeStep:       5 * time.Minute,
+		DefaultSLA:    20 * time.Minute,
+		StationSLA:    map[string]time.Duration{"line-2": time.Hour},
+		LoadTypeBoost: map[string]int{"hot": 10},
+		BreachBoost:   10,
+	}
+	later := time.Now().Add(25 * time.Minute)
+
+	if n := d.EscalatePriorities(policy, later); n != 2 {
+		t.Errorf("escalated = %d, want 2", n)
+	}
+	got, _ := db.GetOrder(sourcing.ID)
+	if got.Priority != 16 {
+		t.Errorf("sourcing priority = %d, want 16 (1 + 5 steps + breach)", got.Priority)
+	}
+	if got.SLABreachedAt == nil {
+		t.Error("sourcing order should be marked SLA breached")
+	}
+	if backend.priorities["sg-2-q"] != 15 {
+		t.Errorf("fleet priority = %d, want 15 (hot + 5 steps)", backend.priorities["sg-2-q"])
+	}
+	gotM, _ := db.GetOrder(manual.ID)
+	if gotM.Priority != 90 {
+		t.Errorf("manual priority = %d, want 90 (never lowered)", gotM.Priority)
+	}
+	if len(emitter.breached) != 2 || emitter.breached[0] != sourcing.ID {
+		t.Errorf("breaches = %v, want line-1 orders only", emitter.breached)
+	}
+
+	history, _ := db.ListOrderHistory(sourcing.ID)
+	last := history[len(history)-1]
+	if last.Status != StatusSourcing {
+		t.Errorf("escalation history status = %q, want %q", last.Status, StatusSourcing)
+	}
+
+	// A second pass at the same time changes nothing and reports no new breach
+	if n := d.EscalatePriorities(policy, later); n != 0 {
+		t.Errorf("second pass escalated = %d, want 0", n)
+	}
+	if len(emitter.breached) != 2 {
+		t.Errorf("breaches after second pass = %d, want 2", len(emitter.breached))
+	}
+}
+
+func TestInFlightCap_QueuesAndReleasesByPriority(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	for i := 0; i < 3; i++ {
+		db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+	}
+
+	backend := &recordingBackend{}
+	d, _ := newTestDispatcher(t, db, backend)
+	d.Limits = InFlightLimits{Station: 1}
+
+	env := testEnvelope()
+	for _, r := range []struct {
+		uuid     string
+		priority int
+	}{{"uuid-first", 0}, {"uuid-low", 1}, {"uuid-high", 5}} {
+		d.HandleOrderRequest(env, &protocol.OrderRequest{
+			OrderUUID:       r.uuid,
+			OrderType:       OrderTypeRetrieve,
+			PayloadTypeCode: "PART-A",
+			DeliveryNode:    lineNode.Name,
+			Quantity:        1,
+			Priority:        r.priority,
+		})
+	}
+
+	if len(backend.reqs) != 1 {
+		t.Fatalf("transport orders = %d, want 1", len(backend.reqs))
+	}
+	for _, uuid := range []string{"uuid-low", "uuid-high"} {
+		if o, _ := db.GetOrderByUUID(uuid); o.Status != StatusQueued {
+			t.Errorf("%s status = %q, want %q", uuid, o.Status, StatusQueued)
+		}
+	}
+
+	// Nothing leaves flight, nothing is released
+	if n := d.ReleaseQueued(); n != 0 {
+		t.Fatalf("released = %d with station at cap, want 0", n)
+	}
+
+	first, _ := db.GetOrderByUUID("uuid-first")
+	db.UpdateOrderStatus(first.ID, StatusDelivered, "payload delivered")
+	if n := d.ReleaseQueued(); n != 1 {
+		t.Fatalf("released = %d, want 1", n)
+	}
+	if high, _ := db.GetOrderByUUID("uuid-high"); high.Status != StatusDispatched {
+		t.Errorf("high priority status = %q, want %q", high.Status, StatusDispatched)
+	}
+	if low, _ := db.GetOrderByUUID("uuid-low"); low.Status != StatusQueued {
+		t.Errorf("low priority status = %q, want %q", low.Status, StatusQueued)
+	}
+
+	usage, err := d.InFlightUsage()
+	if err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	want := map[string]InFlightUsage{
+		"station":      {Scope: "station", Key: "line-1", Count: 1, Cap: 1},
+		"payload_type": {Scope: "payload_type", Key: "PART-A", Count: 1},
+	}
+	if len(usage) != len(want) {
+		t.Fatalf("usage = %+v, want %d rows", usage, len(want))
+	}
+	for _, u := range usage {
+		if u != want[u.Scope] {
+			t.Errorf("usage %s = %+v, want %+v", u.Scope, u, want[u.Scope])
+		}
+	}
+}
+
+func TestInFlightCap_ZoneAndPayloadType(t *testing.T) {
+	l := InFlightLimits{Zones: map[string]int{"A": 2}, PayloadType: 3, PayloadTypes: map[string]int{"PART-B": 0}}
+	f := &InFlight{
+		Stations:     map[string]int{"line-1": 9},
+		Zones:        map[string]int{"A": 2, "B": 5},
+		PayloadTypes: map[string]int{"PART-A": 3, "PART-B": 7},
+	}
+	tests := []struct {
+		zone, payloadType string
+		blocked           bool
+	}{
+		{"A", "", true},
+		{"B", "", false},
+		{"B", "PART-A", true},
+		{"B", "PART-B", false},
+		{"", "PART-C", false},
+	}
+	for _, tt := range tests {
+		if got := l.blockedBy(f, "line-1", tt.zone, tt.payloadType) != ""; got != tt.blocked {
+			t.Errorf("blockedBy(zone=%q, type=%q) = %v, want %v", tt.zone, tt.payloadType, got, tt.blocked)
+		}
+	}
+}
+
+func TestInFlightCap_FleetCallDoesNotBlockAdmission(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	for i := 0; i < 2; i++ {
+		db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+	}
+
+	backend := &blockingBackend{entered: make(chan struct{}), release: make(chan struct{})}
+	d, _ := newTestDispatcher(t, db, backend)
+	d.Limits = InFlightLimits{Station: 1}
+
+	request := func(station, uuid string) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			env := testEnvelope()
+			env.Src.Station = station
+			d.HandleOrderRequest(env, &protocol.OrderRequest{
+				OrderUUID:       uuid,
+				OrderType:       OrderTypeRetrieve,
+				PayloadTypeCode: "PART-A",
+				DeliveryNode:    lineNode.Name,
+			})
+			close(done)
+		}()
+		return done
+	}
+
+	first := request("line-1", "uuid-first")
+	<-backend.entered
+
+	// Same station while the first is still at the fleet: its slot is taken
+	<-request("line-1", "uuid-second")
+	if o, _ := db.GetOrderByUUID("uuid-second"); o.Status != StatusQueued {
+		t.Errorf("second status = %q, want %q", o.Status, StatusQueued)
+	}
+
+	// Another station reaches the fleet without waiting for the first call
+	other := request("line-2", "uuid-other")
+	select {
+	case <-backend.entered:
+	case <-time.After(time.Second):
+		t.Fatal("admission blocked behind an in-progress fleet call")
+	}
+
+	backend.release <- struct{}{}
+	select {
+	case <-first:
+	case <-other:
+	}
+	backend.release <- struct{}{}
+	<-first
+	<-other
+
+	for _, uuid := range []string{"uuid-first", "uuid-other"} {
+		if o, _ := db.GetOrderByUUID(uuid); o.Status != StatusDispatched {
+			t.Errorf("%s status = %q, want %q", uuid, o.Status, StatusDispatched)
+		}
+	}
+	if len(d.reserved) != 0 {
+		t.Errorf("reservations left = %v, want none", d.reserved)
+	}
+}
+
+func TestParseCron_Next(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		expr, after, want string
+	}{
+		{"*/30 * * * *", "2026-03-02 06:10", "2026-03-02 06:30"},
+		{"*/30 * * * *", "2026-03-02 06:30", "2026-03-02 07:00"},
+		{"45 6 * * *", "2026-03-02 07:00", "2026-03-03 06:45"},
+		{"0 8-16/4 * * 1-5", "2026-03-06 17:00", "2026-03-09 08:00"}, // Friday evening -> Monday
+		{"0 0 1 * 0", "2026-03-02 00:00", "2026-03-08 00:00"},        // day of month or Sunday
+		{"0 6 */2 * 1-5", "2026-03-03 07:00", "2026-03-05 06:00"},    // odd weekdays: */2 counts as unrestricted
+		{"@every 45m", "2026-03-02 06:10", "2026-03-02 06:55"},
+	}
+	for _, tt := range tests {
+		rule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tt.expr, err)
+		}
+		if got := rule.Next(at(tt.after)); !got.Equal(at(tt.want)) {
+			t.Errorf("%q after %s = %s, want %s", tt.expr, tt.after, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every 10s"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Errorf("ParseCron(%q) = nil error", bad)
+		}
+	}
+}
+
+func TestDowntimeWindow_Contains(t *testing.T) {
+	night, err := ParseDowntimeWindow([]string{"fri"}, "22:00", "06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2026-03-06 23:30", true},  // Friday night
+		{"2026-03-07 05:59", true},  // carried into Saturday
+		{"2026-03-07 06:00", false}, // window over
+		{"2026-03-05 23:30", false}, // Thursday
+	}
+	for _, tt := range tests {
+		tm, _ := time.ParseInLocation("2006-01-02 15:04", tt.at, time.Local)
+		if got := night.Contains(tm); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+	if _, err := ParseDowntimeWindow([]string{"someday"}, "10:00", "11:00"); err == nil {
+		t.Error("unknown day accepted")
+	}
+}
+
+func TestRunSchedules(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	for i := 0; i < 2; i++ {
+		db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+	}
+	backend := &recordingBackend{}
+	d, emitter := newTestDispatcher(t, db, backend)
+
+	now := time.Date(2026, 3, 2, 6, 45, 0, 0, time.Local)
+	once := &store.OrderSchedule{Name: "morning", OrderType: OrderTypeRetrieve, PayloadTypeCode: "PART-A",
+		DeliveryNode: lineNode.Name, Quantity: 1, RunAt: &now, Enabled: true}
+	once.NextRunAt, _ = NextScheduleRun(once, now)
+	db.CreateOrderSchedule(once)
+
+	due := now.Add(-time.Minute)
+	milk := &store.OrderSchedule{Name: "milk run", OrderType: OrderTypeRetrieve, PayloadTypeCode: "PART-A",
+		DeliveryNode: lineNode.Name, Quantity: 1, StationID: "line-1", Cron: "*/30 * * * *", Enabled: true, NextRunAt: &due}
+	db.CreateOrderSchedule(milk)
+
+	// Downtime: the recurring run is skipped, the one-off waits
+	downtime := []DowntimeWindow{{Start: 6 * time.Hour, End: 7 * time.Hour}}
+	if n := d.RunSchedules(now, downtime); n != 0 {
+		t.Fatalf("orders raised in downtime = %d, want 0", n)
+	}
+	got, _ := db.GetOrderSchedule(milk.ID)
+	if got.LastResult != "skipped: downtime" || !got.NextRunAt.Equal(now.Add(15*time.Minute)) {
+		t.Errorf("recurring after downtime = %q next %v", got.LastResult, got.NextRunAt)
+	}
+	if got, _ := db.GetOrderSchedule(once.ID); !got.Enabled || got.LastRunAt != nil {
+		t.Errorf("one-off ran or was disabled during downtime: %+v", got)
+	}
+
+	later := now.Add(20 * time.Minute)
+	if n := d.RunSchedules(later, nil); n != 2 {
+		t.Fatalf("orders raised = %d, want 2", n)
+	}
+	if len(backend.reqs) != 2 {
+		t.Fatalf("transport orders = %d, want 2", len(backend.reqs))
+	}
+	got, _ = db.GetOrderSchedule(once.ID)
+	if got.Enabled || got.NextRunAt != nil || !strings.HasSuffix(got.LastResult, StatusDispatched) {
+		t.Errorf("one-off after run = %+v", got)
+	}
+	got, _ = db.GetOrderSchedule(milk.ID)
+	if !got.Enabled || !got.NextRunAt.Equal(now.Add(45*time.Minute)) {
+		t.Errorf("recurring next run = %v, want %v", got.NextRunAt, now.Add(45*time.Minute))
+	}
+	orders, _ := db.ListOrdersByStation("line-1", 10)
+	if len(orders) != 1 || orders[0].PayloadDesc != "scheduled: milk run" {
+		t.Fatalf("line-1 orders = %+v, want the milk run", orders)
+	}
+
+	// No edge sends a receipt for a scheduled order; core confirms it
+	milkOrder := orders[0]
+	if !IsCoreOrder(milkOrder) {
+		t.Fatalf("scheduled order %q not marked as core's own", milkOrder.EdgeUUID)
+	}
+	db.UpdateOrderStatus(milkOrder.ID, StatusDelivered, "payload delivered")
+	d.ConfirmCoreOrder(milkOrder)
+	if o, _ := db.GetOrder(milkOrder.ID); o.Status != StatusConfirmed {
+		t.Errorf("scheduled order status = %q, want %q", o.Status, StatusConfirmed)
+	}
+	if len(emitter.completed) != 1 || emitter.completed[0].orderID != milkOrder.ID {
+		t.Errorf("completed = %+v, want order %d", emitter.completed, milkOrder.ID)
+	}
+}
+
+// flakyBackend fails the first failures creates, then records like recordingBackend.
+type flakyBackend struct {
+	recordingBackend
+	failures int
+	attempts []string
+}
+
+func (m *flakyBackend) CreateTransportOrder(req fleet.TransportOrderRequest) (fleet.TransportOrderResult, error) {
+	m.attempts = append(m.attempts, req.OrderID)
+	if len(m.attempts) <= m.failures {
+		return fleet.TransportOrderResult{}, fmt.Errorf("mock: fleet busy")
+	}
+	return m.recordingBackend.CreateTransportOrder(req)
+}
+
+// waitForStatus polls until a retried order reaches status.
+func waitForStatus(t *testing.T, db *store.DB, id int64, status string) *store.Order {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		order, err := db.GetOrder(id)
+		if err == nil && order.Status == status {
+			return order
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("order %d status = %q, want %q", id, order.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{Backoff: 10 * time.Second, MaxBackoff: time.Minute}
+	for n, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 10: time.Minute} {
+		if got := p.Delay(n); got != want {
+			t.Errorf("Delay(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestCreateRetry_RedispatchesWithNewVendorID(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+
+	backend := &flakyBackend{failures: 1}
+	d, emitter := newTestDispatcher(t, db, backend)
+	d.CreateRetry = RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+
+	d.HandleOrderRequest(testEnvelope(), &protocol.OrderRequest{
+		OrderUUID:       "uuid-retry",
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    lineNode.Name,
+		Quantity:        1,
+	})
+
+	order, _ := db.GetOrderByUUID("uuid-retry")
+	order = waitForStatus(t, db, order.ID, StatusDispatched)
+	if len(emitter.failed) != 0 {
+		t.Fatalf("failed = %+v, want none", emitter.failed)
+	}
+	if order.CreateRetries != 1 {
+		t.Errorf("create retries = %d, want 1", order.CreateRetries)
+	}
+	if len(backend.attempts) != 2 || backend.attempts[0] == backend.attempts[1] || order.VendorOrderID != backend.attempts[1] {
+		t.Errorf("attempts = %v, vendor order = %s; want two distinct IDs, the second kept", backend.attempts, order.VendorOrderID)
+	}
+	history, _ := db.ListOrderHistory(order.ID)
+	retried := false
+	for _, h := range history {
+		if strings.HasPrefix(h.Detail, "fleet create failed: mock: fleet busy; retry 1/2") {
+			retried = true
+		}
+	}
+	if !retried {
+		t.Errorf("history has no retry entry: %+v", history)
+	}
+}
+
+func TestCreateRetry_ExhaustedFails(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+
+	d, _ := newTestDispatcher(t, db, &mockBackend{})
+	d.CreateRetry = RetryPolicy{MaxAttempts: 1, Backoff: time.Millisecond}
+
+	d.HandleOrderRequest(testEnvelope(), &protocol.OrderRequest{
+		OrderUUID:       "uuid-retry-fail",
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    lineNode.Name,
+		Quantity:        1,
+	})
+	order, _ := db.GetOrderByUUID("uuid-retry-fail")
+	if order.Status != StatusRetrying || order.NextRetryAt == nil {
+		t.Fatalf("status after first error = %q (next retry %v), want %q", order.Status, order.NextRetryAt, StatusRetrying)
+	}
+	msgs, _ := db.ListPendingOutbox(10)
+	for _, m := range msgs {
+		if m.MsgType == "order.error" {
+			t.Fatal("order.error sent before retries were exhausted")
+		}
+	}
+
+	order = waitForStatus(t, db, order.ID, StatusFailed)
+	if !strings.HasSuffix(order.ErrorDetail, "(after 1 retries)") {
+		t.Errorf("error detail = %q", order.ErrorDetail)
+	}
+	errors := 0
+	msgs, _ = db.ListPendingOutbox(10)
+	for _, m := range msgs {
+		if m.MsgType == "order.error" {
+			errors++
+		}
+	}
+	if errors != 1 {
+		t.Errorf("order.error messages = %d, want 1", errors)
+	}
+}
+
+func TestRestoreRetries_ResumesAfterRestart(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	first := &store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"}
+	db.CreatePayload(first)
+
+	d, _ := newTestDispatcher(t, db, &mockBackend{})
+	d.CreateRetry = RetryPolicy{MaxAttempts: 2, Backoff: time.Hour}
+	d.HandleOrderRequest(testEnvelope(), &protocol.OrderRequest{
+		OrderUUID:       "uuid-restart",
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    lineNode.Name,
+		Quantity:        1,
+	})
+	order, _ := db.GetOrderByUUID("uuid-restart")
+	if order.Status != StatusRetrying {
+		t.Fatalf("status = %q, want %q", order.Status, StatusRetrying)
+	}
+
+	// Restart: the timer is gone, and more stock has arrived meanwhile
+	second := &store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"}
+	db.CreatePayload(second)
+	db.Exec(`UPDATE orders SET next_retry_at=datetime('now','localtime','-1 minute') WHERE id=?`, order.ID)
+
+	backend := &recordingBackend{}
+	d, _ = newTestDispatcher(t, db, backend)
+	d.ReconcileStuckOrders()
+	d.RestoreWaiting()
+	if n := d.RestoreRetries(); n != 1 {
+		t.Fatalf("restored = %d, want 1", n)
+	}
+	order = waitForStatus(t, db, order.ID, StatusDispatched)
+	if len(backend.reqs) != 1 {
+		t.Errorf("transport orders = %d, want 1", len(backend.reqs))
+	}
+	if got, _ := db.GetPayload(first.ID); got.ClaimedBy == nil || *got.ClaimedBy != order.ID {
+		t.Error("retried order lost its original payload")
+	}
+	if got, _ := db.GetPayload(second.ID); got.ClaimedBy != nil {
+		t.Error("retried order claimed a second payload")
+	}
+}
+
+func TestHandleVendorFailure_RetriesThenFails(t *testing.T) {
+	db, order, p := setupStuckRetrieve(t)
+	backend := &recordingBackend{}
+	d, emitter := newTestDispatcher(t, db, backend)
+	d.VendorRetry = RetryPolicy{MaxAttempts: 1, Backoff: time.Millisecond}
+
+	d.ReconcileStuckOrders()
+	order, _ = db.GetOrder(order.ID)
+	first := order.VendorOrderID
+
+	d.HandleVendorFailure(order, "FAILED", "", "fleet: RUNNING -> FAILED")
+	order = waitForStatus(t, db, order.ID, StatusDispatched)
+	if order.VendorOrderID == first || order.VendorRetries != 1 {
+		t.Errorf("after retry vendor order = %s (was %s), vendor retries = %d", order.VendorOrderID, first, order.VendorRetries)
+	}
+	if got, _ := db.GetPayload(p.ID); got.ClaimedBy == nil || *got.ClaimedBy != order.ID {
+		t.Error("payload claim released during retry")
+	}
+
+	// A late report for the vendor order the retry replaced changes nothing.
+	stale := *order
+	stale.VendorOrderID = first
+	d.HandleVendorFailure(&stale, "FAILED", "", "fleet: RUNNING -> FAILED")
+	if got, _ := db.GetOrder(order.ID); got.Status != StatusDispatched || got.VendorRetries != 1 {
+		t.Errorf("after stale failure status = %q, vendor retries = %d", got.Status, got.VendorRetries)
+	}
+
+	d.HandleVendorFailure(order, "FAILED", "", "fleet: RUNNING -> FAILED")
+	order, _ = db.GetOrder(order.ID)
+	if order.Status != StatusFailed || len(emitter.failed) != 1 {
+		t.Errorf("status = %q, failed events = %d; want failed once retries are used", order.Status, len(emitter.failed))
+	}
+	if got, _ := db.GetPayload(p.ID); got.ClaimedBy != nil {
+		t.Error("payload still claimed after the order failed")
+	}
+
+	// An order that already ended is never put back in retrying.
+	d.VendorRetry.MaxAttempts = 5
+	d.HandleVendorFailure(order, "FAILED", "", "fleet: RUNNING -> FAILED")
+	if err := db.RecordFleetRetry(order.ID, true, time.Now(), "late"); !errors.Is(err, store.ErrOrderEnded) {
+		t.Errorf("retry of a failed order = %v, want ErrOrderEnded", err)
+	}
+	if got, _ := db.GetOrder(order.ID); got.Status != StatusFailed || len(emitter.failed) != 1 {
+		t.Errorf("ended order status = %q, failed events = %d", got.Status, len(emitter.failed))
+	}
+}
+
+func TestPlanOrder_RetrieveExplainsCandidates(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	disabled := &store.Node{Name: "STORAGE-B1", VendorLocation: "Loc-02", NodeType: "storage", Capacity: 10, Enabled: false}
+	db.CreateNode(disabled)
+	first := &store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"}
+	db.CreatePayload(first)
+	second := &store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"}
+	db.CreatePayload(second)
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &disabled.ID, Status: "available"})
+	claimed := &store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"}
+	db.CreatePayload(claimed)
+	other := &store.Order{EdgeUUID: "other", StationID: "line-2", OrderType: OrderTypeRetrieve, Status: StatusInTransit}
+	db.CreateOrder(other)
+	db.ClaimPayload(claimed.ID, other.ID)
+
+	d, _ := newTestDispatcher(t, db, &mockBackend{})
+	plan := d.PlanOrder("line-1", &protocol.OrderRequest{
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    lineNode.Name,
+	})
+
+	if plan.Outcome != PlanDispatch || plan.Source != storageNode.Name || plan.PayloadID != first.ID || plan.Destination != lineNode.Name {
+		t.Fatalf("plan = %+v, want dispatch of payload %d from %s to %s", plan, first.ID, storageNode.Name, lineNode.Name)
+	}
+	reasons := make(map[int64]string)
+	for _, c := range plan.Sources {
+		reasons[c.PayloadID] = c.Reason
+	}
+	if len(reasons) != 4 {
+		t.Errorf("sources = %+v, want all 4 payloads", plan.Sources)
+	}
+	if reasons[claimed.ID] != fmt.Sprintf("claimed by order %d", other.ID) || reasons[second.ID] != "ranked 2 by fifo" {
+		t.Errorf("reasons = %v", reasons)
+	}
+
+	// Nothing was written: no new order, no claim.
+	if orders, _ := db.ListActiveOrders(); len(orders) != 1 {
+		t.Errorf("plan created %d orders", len(orders))
+	}
+	if got, _ := db.GetPayload(first.ID); got.ClaimedBy != nil {
+		t.Error("plan claimed the source payload")
+	}
+}
+
+func TestPlanOrder_StoreAndFailures(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, _ := setupTestData(t, db)
+	full := &store.Node{Name: "STORAGE-FULL", VendorLocation: "Loc-03", NodeType: "storage", Capacity: 1, Enabled: true}
+	db.CreateNode(full)
+	other, _ := db.GetPayloadTypeByName("PART-A")
+	db.CreatePayload(&store.Payload{PayloadTypeID: other.ID, NodeID: &full.ID, Status: "available"})
+
+	d, _ := newTestDispatcher(t, db, &mockBackend{})
+	plan := d.PlanOrder("line-1", &protocol.OrderRequest{
+		OrderType:       OrderTypeStore,
+		PayloadTypeCode: "PART-A",
+		PickupNode:      lineNode.Name,
+	})
+	if plan.Outcome != PlanDispatch || plan.Source != lineNode.Name || plan.Destination != storageNode.Name {
+		t.Fatalf("store plan = %+v", plan)
+	}
+	for _, c := range plan.Destinations {
+		if c.Node == full.Name && c.Reason != "full (1/1)" {
+			t.Errorf("full node reason = %q", c.Reason)
+		}
+	}
+
+	plan = d.PlanOrder("line-1", &protocol.OrderRequest{OrderType: OrderTypeRetrieve, PayloadTypeCode: "NOPE", DeliveryNode: lineNode.Name})
+	if plan.Outcome != PlanFail || plan.ErrorCode != "payload_type_error" {
+		t.Errorf("unknown payload type plan = %+v", plan)
+	}
+
+	d.MaxSourcingWait = time.Minute
+	db.CreatePayloadType(&store.PayloadType{Name: "PART-B", FormFactor: "tote", DefaultManifestJSON: "{}"})
+	plan = d.PlanOrder("line-1", &protocol.OrderRequest{OrderType: OrderTypeRetrieve, PayloadTypeCode: "PART-B", DeliveryNode: lineNode.Name})
+	if plan.Outcome != PlanWait || plan.ErrorCode != "no_source" {
+		t.Errorf("no stock plan = %+v, want wait", plan)
+	}
+
+	d.Limits = InFlightLimits{Station: 1}
+	db.CreateOrder(&store.Order{EdgeUUID: "busy", StationID: "line-1", OrderType: OrderTypeMove, Status: StatusInTransit})
+	plan = d.PlanOrder("line-1", &protocol.OrderRequest{OrderType: OrderTypeStore, PayloadTypeCode: "PART-A", PickupNode: lineNode.Name})
+	if plan.Outcome != PlanQueue {
+		t.Errorf("capped plan = %+v, want queue", plan)
+	}
+}
+
+func TestStatusConstants(t *testing.T) {
+	// Verify all plan-defined statuses exist
+	statuses := []string{
+		StatusPending, StatusSourcing, StatusSubmitted, StatusDispatched,
+		StatusAcknowledged, StatusInTransit, StatusDelivered, StatusConfirmed,
+		StatusFailed, StatusCancelled,
+	}
+	expected := []string{
+		"pending", "sourcing", "submitted", "dispatched",
+		"acknowledged", "in_transit", "delivered", "confirmed",
+		"failed", "cancelled",
+	}
+	for i, s := range statuses {
+		if s != expected[i] {
+			t.Errorf("status[%d] = %q, want %q", i, s, expected[i])
+		}
+	}
+}
+
+func TestOrderTypeConstants(t *testing.T) {
+	if OrderTypeRetrieve != "retrieve" {
+		t.Errorf("OrderTypeRetrieve = %q", OrderTypeRetrieve)
+	}
+	if OrderTypeMove != "move" {
+		t.Errorf("OrderTypeMove = %q", OrderTypeMove)
+	}
+	if OrderTypeStore != "store" {
+		t.Errorf("OrderTypeStore = %q", OrderTypeStore)
+	}
+}
+
+// setupTwoFleets puts the line zone on a second fleet. The storage node stays
+// on the default fleet.
+func setupTwoFleets(t *testing.T, handoff bool) (*store.DB, *Dispatcher, *recordingBackend, *recordingBackend) {
+	t.Helper()
+	db := testDB(t)
+	storageNode, _, pt := setupTestData(t, db)
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+
+	warehouse, assembly := &recordingBackend{}, &recordingBackend{}
+	reg := fleet.NewRegistry()
+	reg.Add("warehouse", warehouse)
+	reg.Add("assembly", assembly)
+	reg.BindNode("LINE1-IN", "assembly")
+	if handoff {
+		db.CreateNode(&store.Node{Name: "HANDOFF-1", VendorLocation: "Loc-30", NodeType: "staging", Capacity: 1, Enabled: true})
+		reg.SetHandoff("assembly", "warehouse", "HANDOFF-1")
+	}
+	d, _ := newTestDispatcher(t, db, reg)
+	return db, d, warehouse, assembly
+}
+
+func retrieveRequest(uuid string) *protocol.OrderRequest {
+	return &protocol.OrderRequest{
+		OrderUUID:       uuid,
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    "LINE1-IN",
+		Quantity:        1,
+	}
+}
+
+func TestFleetRegistry_CrossFleetWithoutHandoffFails(t *testing.T) {
+	db, d, warehouse, assembly := setupTwoFleets(t, false)
+	d.HandleOrderRequest(testEnvelope(), retrieveRequest("uuid-cross"))
+
+	order, _ := db.GetOrderByUUID("uuid-cross")
+	if order.Status != StatusFailed {
+		t.Fatalf("status = %q, want failed", order.Status)
+	}
+	if !strings.Contains(order.ErrorDetail, "no handoff node") {
+		t.Errorf("error detail = %q", order.ErrorDetail)
+	}
+	if len(warehouse.reqs)+len(assembly.reqs) != 0 {
+		t.Error("cross-fleet order reached a fleet")
+	}
+}
+
+func TestFleetRegistry_HandoffRunsTwoLegs(t *testing.T) {
+	db, d, warehouse, assembly := setupTwoFleets(t, true)
+	d.HandleOrderRequest(testEnvelope(), retrieveRequest("uuid-handoff"))
+
+	order, _ := db.GetOrderByUUID("uuid-handoff")
+	if len(warehouse.reqs) != 1 || warehouse.reqs[0].ToLoc != "Loc-30" {
+		t.Fatalf("warehouse requests = %+v, want one leg to the handoff node", warehouse.reqs)
+	}
+	if order.Fleet != "warehouse" || order.HandoffNode != "HANDOFF-1" || !IsHandoffLeg(order) {
+		t.Errorf("order fleet = %q handoff = %q, want warehouse via HANDOFF-1", order.Fleet, order.HandoffNode)
+	}
+
+	d.ContinueHandoff(order)
+	if len(assembly.reqs) != 1 || assembly.reqs[0].FromLoc != "Loc-30" || assembly.reqs[0].ToLoc != "Loc-10" {
+		t.Fatalf("assembly requests = %+v, want handoff -> line", assembly.reqs)
+	}
+	got, _ := db.GetOrder(order.ID)
+	if got.Fleet != "assembly" || got.PickupNode != "HANDOFF-1" || IsHandoffLeg(got) {
+		t.Errorf("after handoff fleet = %q pickup = %q", got.Fleet, got.PickupNode)
+	}
+	if got.Status != StatusDispatched {
+		t.Errorf("status = %q, want dispatched", got.Status)
+	}
+
+	// Cancels go to the fleet running the current leg.
+	reg := d.backend.(*fleet.Registry)
+	if reg.ForOrder(got.VendorOrderID) != assembly {
+		t.Error("second leg not owned by the assembly fleet")
+	}
+}
+
+func TestPlanOrder_RoutesFleetsAndZoneLocks(t *testing.T) {
+	_, d, _, _ := setupTwoFleets(t, false)
+	plan := d.PlanOrder("line-1", retrieveRequest("plan-cross"))
+	if plan.Outcome != PlanFail || plan.ErrorCode != "cross_fleet" {
+		t.Errorf("cross-fleet plan = %+v, want cross_fleet failure", plan)
+	}
+
+	db, d, _, _ := setupTwoFleets(t, true)
+	plan = d.PlanOrder("line-1", retrieveRequest("plan-handoff"))
+	if plan.Outcome != PlanDispatch || plan.Fleet != "warehouse" || plan.HandoffNode != "HANDOFF-1" {
+		t.Errorf("handoff plan = %+v, want warehouse leg to HANDOFF-1", plan)
+	}
+
+	db.SaveExclusiveZone("A", "")
+	if err := d.LockZone("A", "die change", "operator"); err != nil {
+		t.Fatalf("lock zone: %v", err)
+	}
+	plan = d.PlanOrder("line-1", retrieveRequest("plan-locked"))
+	if plan.Outcome != PlanQueue || plan.Detail != "queued: zone A locked" {
+		t.Errorf("locked plan = %+v, want queued for zone A", plan)
+	}
+}
+
+// zoneLockBackend records mutex group occupy and release calls.
+type zoneLockBackend struct {
+	recordingBackend
+	calls []string
+}
+
+func (m *zoneLockBackend) OccupyMutexGroups(holder string, groups []string) error {
+	m.calls = append(m.calls, fmt.Sprintf("occupy %s %v", holder, groups))
+	return nil
+}
+
+func (m *zoneLockBackend) ReleaseMutexGroups(holder string, groups []string) error {
+	m.calls = append(m.calls, fmt.Sprintf("release %s %v", holder, groups))
+	return nil
+}
+
+func TestZoneLock_HoldsAndReleasesOrders(t *testing.T) {
+	db := testDB(t)
+	storageNode, lineNode, pt := setupTestData(t, db)
+	db.CreatePayload(&store.Payload{PayloadTypeID: pt.ID, NodeID: &storageNode.ID, Status: "available"})
+
+	backend := &zoneLockBackend{}
+	d, emitter := newTestDispatcher(t, db, backend)
+
+	if err := d.LockZone("A", "die change", "operator"); err == nil {
+		t.Fatal("locking a zone that is not exclusive should fail")
+	}
+	db.SaveExclusiveZone("A", "aisle-a, press-3")
+	if err := d.LockZone("A", "die change", "operator"); err != nil {
+		t.Fatalf("lock zone: %v", err)
+	}
+
+	env := testEnvelope()
+	d.HandleOrderRequest(env, &protocol.OrderRequest{
+		OrderUUID:       "uuid-zone",
+		OrderType:       OrderTypeRetrieve,
+		PayloadTypeCode: "PART-A",
+		DeliveryNode:    lineNode.Name,
+		Quantity:        1,
+	})
+
+	order, _ := db.GetOrderByUUID("uuid-zone")
+	if order.Status != StatusQueued || order.HeldZone != "A" {
+		t.Fatalf("order status = %q held_zone = %q, want queued for zone A", order.Status, order.HeldZone)
+	}
+	if order.PickupNode != storageNode.Name {
+		t.Errorf("pickup node = %q, want %q", order.PickupNode, storageNode.Name)
+	}
+	if len(backend.reqs) != 0 {
+		t.Fatalf("transport orders = %d while zone locked, want 0", len(backend.reqs))
+	}
+	if n := d.ReleaseQueued(); n != 0 {
+		t.Errorf("ReleaseQueued started %d held orders, want 0", n)
+	}
+	if n := d.ReleaseHeld(); n != 0 {
+		t.Errorf("ReleaseHeld released %d orders while zone locked, want 0", n)
+	}
+
+	if err := d.UnlockZone("A", "operator", false); err != nil {
+		t.Fatalf("unlock zone: %v", err)
+	}
+	if n := d.ReleaseHeld(); n != 1 {
+		t.Fatalf("released = %d, want 1", n)
+	}
+	order, _ = db.GetOrder(order.ID)
+	if order.Status != StatusDispatched || order.HeldZone != "" {
+		t.Errorf("order status = %q held_zone = %q, want dispatched and not held", order.Status, order.HeldZone)
+	}
+	if len(backend.reqs) != 1 || backend.reqs[0].FromLoc != storageNode.VendorLocation {
+		t.Errorf("transport orders = %+v, want one from %s", backend.reqs, storageNode.VendorLocation)
+	}
+
+	wantCalls := []string{"occupy core [aisle-a press-3]", "release core [aisle-a press-3]"}
+	if fmt.Sprint(backend.calls) != fmt.Sprint(wantCalls) {
+		t.Errorf("mutex calls = %v, want %v", backend.calls, wantCalls)
+	}
+	if fmt.Sprint(emitter.zoneLocks) != "[A:true A:false]" {
+		t.Errorf("zone lock events = %v", emitter.zoneLocks)
+	}
+}
+
+func TestZoneLock_UsesZoneFleet(t *testing.T) {
+	db := testDB(t)
+	warehouse, assembly := &zoneLockBackend{}, &zoneLockBackend{}
+	reg := fleet.NewRegistry()
+	reg.Add("warehouse", warehouse)
+	reg.Add("assembly", assembly)
+	reg.BindZone("A", "assembly")
+	d, _ := newTestDispatcher(t, db, reg)
+	db.SaveExclusiveZone("A", "press-3")
+
+	if err := d.LockZone("A", "die change", "operator"); err != nil {
+		t.Fatalf("lock zone: %v", err)
+	}
+	if err := d.UnlockZone("A", "operator", false); err != nil {
+		t.Fatalf("unlock zone: %v", err)
+	}
+	if len(warehouse.calls) != 0 {
+		t.Errorf("default fleet calls = %v, want none", warehouse.calls)
+	}
+	if fmt.Sprint(assembly.calls) != "[occupy core [press-3] release core [press-3]]" {
+		t.Errorf("zone fleet calls = %v", assembly.calls)
+	}
+}
+
+// zoneLockReplies decodes the zone lock replies queued for edges.
+func zoneLockReplies(t *testing.T, db *store.DB) []protocol.ZoneLockReply {
+	t.Helper()
+	msgs, _ := db.ListPendingOutbox(50)
+	var out []protocol.ZoneLockReply
+	for _, m := range msgs {
+		var env protocol.Envelope
+		var data protocol.Data
+		if json.Unmarshal(m.Payload, &env) != nil || env.DecodePayload(&data) != nil || data.Subject != protocol.SubjectZoneLockReply {
+			continue
+		}
+		var r protocol.ZoneLockReply
+		if err := json.Unmarshal(data.Body, &r); err != nil {
+			t.Fatalf("decode zone lock reply: %v", err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestZoneLock_OwnerReleasesOrOperatorOverrides(t *testing.T) {
+	db := testDB(t)
+	d, _ := newTestDispatcher(t, db, &zoneLockBackend{})
+	db.SaveExclusiveZone("A", "")
+
+	if err := d.LockZone("A", "die change", "alice"); err != nil {
+		t.Fatalf("lock zone: %v", err)
+	}
+	// An edge changeover cannot take or release the operator's lock
+	env := testEnvelope()
+	d.HandleZoneLock(env, &protocol.ZoneLock{StationID: "line-1", Zone: "A", Locked: true, Reason: "changeover"})
+	d.HandleZoneLock(env, &protocol.ZoneLock{StationID: "line-1", Zone: "A", Locked: false})
+	if z, _ := db.GetExclusiveZone("A"); !z.Locked || z.LockedBy != "alice" {
+		t.Fatalf("zone locked=%v by %q, want still locked by alice", z.Locked, z.LockedBy)
+	}
+	replies := zoneLockReplies(t, db)
+	if len(replies) != 2 || replies[0].Granted || replies[0].HeldBy != "alice" || replies[1].Granted {
+		t.Errorf("replies = %+v, want both refused with alice holding the zone", replies)
+	}
+
+	if err := d.UnlockZone("A", "bob", false); !errors.Is(err, ErrZoneHeld) {
+		t.Errorf("unlock by another operator err = %v, want ErrZoneHeld", err)
+	}
+	if err := d.UnlockZone("A", "bob", true); err != nil {
+		t.Fatalf("override unlock: %v", err)
+	}
+
+	d.HandleZoneLock(env, &protocol.ZoneLock{StationID: "line-1", Zone: "A", Locked: true, Reason: "changeover"})
+	if z, _ := db.GetExclusiveZone("A"); !z.Locked || z.LockedBy != "edge:line-1" {
+		t.Fatalf("zone locked=%v by %q, want locked by edge:line-1", z.Locked, z.LockedBy)
+	}
+	if replies := zoneLockReplies(t, db); !replies[len(replies)-1].Granted {
+		t.Errorf("last reply = %+v, want granted", replies[len(replies)-1])
 	}
 }
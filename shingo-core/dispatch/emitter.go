@@ -1,5 +1,7 @@
 package dispatch
 
+import "time"
+
 // Emitter is the interface adapters must satisfy to bridge dispatch events to the engine.
 type Emitter interface {
 	EmitOrderReceived(orderID int64, edgeUUID, stationID, orderType, payloadTypeCode, deliveryNode string)
@@ -7,4 +9,6 @@ type Emitter interface {
 	EmitOrderFailed(orderID int64, edgeUUID, stationID, errorCode, detail string)
 	EmitOrderCancelled(orderID int64, edgeUUID, stationID, reason string)
 	EmitOrderCompleted(orderID int64, edgeUUID, stationID string)
+	EmitSLABreached(orderID int64, edgeUUID, stationID string, sla, waited time.Duration)
+	EmitZoneLock(zoneID int64, zone string, locked bool, reason, actor string)
 }
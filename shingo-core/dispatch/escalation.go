@@ -0,0 +1,88 @@
+package dispatch
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"shingocore/store"
+)
+
+// EscalationPolicy decides how far an order's priority rises while it waits.
+type EscalationPolicy struct {
+	AgeStep       time.Duration            // each full step waited adds one priority level; 0 disables aging
+	DefaultSLA    time.Duration            // wait allowed before an order breaches its SLA; 0 disables SLAs
+	StationSLA    map[string]time.Duration // per-station SLA, overriding DefaultSLA
+	LoadTypeBoost map[string]int           // fixed boost per load type, e.g. "hot": 10
+	BreachBoost   int                      // added once an order breaches its SLA
+	MaxPriority   int                      // ceiling for escalated priority; 0 means none
+}
+
+// SLAFor returns the SLA target for a station, or 0 if it has none.
+func (p EscalationPolicy) SLAFor(stationID string) time.Duration {
+	if sla, ok := p.StationSLA[stationID]; ok {
+		return sla
+	}
+	return p.DefaultSLA
+}
+
+// TargetPriority is the priority an order should have after waiting.
+func (p EscalationPolicy) TargetPriority(order *store.Order, waited time.Duration, breached bool) int {
+	target := order.BasePriority + p.LoadTypeBoost[order.LoadType]
+	if p.AgeStep > 0 {
+		target += int(waited / p.AgeStep)
+	}
+	if breached {
+		target += p.BreachBoost
+	}
+	if p.MaxPriority > 0 && target > p.MaxPriority {
+		target = p.MaxPriority
+	}
+	return target
+}
+
+// EscalatePriorities raises the priority of orders still waiting for a robot
+// and reports orders that breach their SLA. Priority only ever goes up, so a
+// manual raise is never undone. Orders queued at the fleet are re-prioritised
+// there first. It returns the number of orders escalated.
+func (d *Dispatcher) EscalatePriorities(p EscalationPolicy, now time.Time) int {
+	orders, err := d.db.ListWaitingOrders()
+	if err != nil {
+		log.Printf("dispatch: escalate: list waiting orders: %v", err)
+		return 0
+	}
+
+	n := 0
+	for _, o := range orders {
+		waited := now.Sub(o.CreatedAt)
+		sla := p.SLAFor(o.StationID)
+		breached := sla > 0 && waited > sla
+		if breached && o.SLABreachedAt == nil {
+			d.dbg("escalate: order %d breached %s SLA after %s", o.ID, sla, waited.Round(time.Second))
+			d.db.MarkSLABreached(o.ID)
+			d.emitter.EmitSLABreached(o.ID, o.EdgeUUID, o.StationID, sla, waited)
+		}
+
+		target := p.TargetPriority(o, waited, breached)
+		if target <= o.Priority {
+			continue
+		}
+		if o.Status == StatusDispatched && o.VendorOrderID != "" {
+			if err := d.backend.SetOrderPriority(o.VendorOrderID, target); err != nil {
+				log.Printf("dispatch: escalate order %d: set fleet priority: %v", o.ID, err)
+				continue
+			}
+		}
+		detail := fmt.Sprintf("priority %d -> %d after waiting %s", o.Priority, target, waited.Round(time.Second))
+		if breached {
+			detail += " (SLA breached)"
+		}
+		if err := d.db.EscalateOrderPriority(o.ID, target, detail); err != nil {
+			log.Printf("dispatch: escalate order %d: %v", o.ID, err)
+			continue
+		}
+		d.dbg("escalate: order %d %s", o.ID, detail)
+		n++
+	}
+	return n
+}
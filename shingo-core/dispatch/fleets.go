@@ -0,0 +1,127 @@
+package dispatch
+
+import (
+	"fmt"
+	"log"
+
+	"shingo/protocol"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// IsHandoffLeg reports whether the order's current vendor order is the first
+// leg of a cross-fleet order, i.e. it ends at the handoff node where the
+// payload changes fleet. Once handed off, the handoff node becomes the pickup
+// node.
+func IsHandoffLeg(order *store.Order) bool {
+	return order.HandoffNode != "" && order.PickupNode != order.HandoffNode
+}
+
+// fleetLeg is the routing decision for one transport leg.
+type fleetLeg struct {
+	fleet   string // fleet running the leg, "" for a single backend
+	next    string // fleet taking over at the handoff node
+	handoff string // node the leg is cut short at, "" if it runs through
+}
+
+// routeLeg picks the fleet for a transport leg from its source and
+// destination nodes without changing the order. With a single backend there
+// is nothing to pick. When the nodes are served by different fleets the leg
+// is cut short at the handoff node between them; with no handoff node it
+// returns an error.
+func (d *Dispatcher) routeLeg(sourceNode, destNode *store.Node) (fleetLeg, error) {
+	reg, ok := d.backend.(*fleet.Registry)
+	if !ok {
+		return fleetLeg{}, nil
+	}
+	from := reg.Route(sourceNode.Name, sourceNode.Zone)
+	to := reg.Route(destNode.Name, destNode.Zone)
+	if from == to {
+		return fleetLeg{fleet: from}, nil
+	}
+
+	switch handoff := reg.Handoff(from, to); handoff {
+	case sourceNode.Name:
+		return fleetLeg{fleet: to}, nil
+	case destNode.Name:
+		return fleetLeg{fleet: from}, nil
+	case "":
+		return fleetLeg{}, fmt.Errorf("%s is served by fleet %s and %s by fleet %s, with no handoff node between them",
+			sourceNode.Name, from, destNode.Name, to)
+	default:
+		return fleetLeg{fleet: from, next: to, handoff: handoff}, nil
+	}
+}
+
+// routeFleet applies routeLeg to an order: a cross-fleet order with no
+// handoff node fails, and one with a handoff node records it. It returns the
+// fleet name ("" for a single backend) and where the leg ends.
+func (d *Dispatcher) routeFleet(order *store.Order, env *protocol.Envelope, sourceNode, destNode *store.Node) (string, *store.Node, bool) {
+	leg, err := d.routeLeg(sourceNode, destNode)
+	if err != nil {
+		d.failOrder(order, env, "cross_fleet", err.Error())
+		return "", nil, false
+	}
+	if leg.handoff == "" {
+		return leg.fleet, destNode, true
+	}
+
+	handoffNode, err := d.db.GetNodeByName(leg.handoff)
+	if err != nil {
+		d.failOrder(order, env, "node_error", fmt.Sprintf("handoff node %q not found", leg.handoff))
+		return "", nil, false
+	}
+	order.HandoffNode = handoffNode.Name
+	d.db.UpdateOrderHandoffNode(order.ID, handoffNode.Name)
+	d.dbg("fleet route: order %d crosses from fleet %s to %s via %s", order.ID, leg.fleet, leg.next, handoffNode.Name)
+	return leg.fleet, handoffNode, true
+}
+
+// createTransportOrder sends a transport order to the routed fleet and
+// records that fleet on the order.
+func (d *Dispatcher) createTransportOrder(order *store.Order, fleetName string, req fleet.TransportOrderRequest) error {
+	reg, ok := d.backend.(*fleet.Registry)
+	if !ok {
+		_, err := d.backend.CreateTransportOrder(req)
+		return err
+	}
+	if _, err := reg.CreateOn(fleetName, req); err != nil {
+		return err
+	}
+	d.setOrderFleet(order, fleetName)
+	return nil
+}
+
+func (d *Dispatcher) setOrderFleet(order *store.Order, fleetName string) {
+	if order.Fleet == fleetName {
+		return
+	}
+	order.Fleet = fleetName
+	if err := d.db.UpdateOrderFleet(order.ID, fleetName); err != nil {
+		log.Printf("dispatch: record fleet for order %d: %v", order.ID, err)
+	}
+}
+
+// ContinueHandoff sends a cross-fleet order on its second leg, from the
+// handoff node with the fleet serving its destination, once the first fleet
+// has dropped the payload there.
+func (d *Dispatcher) ContinueHandoff(order *store.Order) {
+	env := edgeEnvelope(order.StationID)
+	handoffNode, err := d.db.GetNodeByName(order.HandoffNode)
+	if err != nil {
+		d.failOrder(order, env, "node_error", fmt.Sprintf("handoff node %q not found", order.HandoffNode))
+		return
+	}
+
+	order.PickupNode = handoffNode.Name
+	d.db.UpdateOrderPickupNode(order.ID, handoffNode.Name)
+
+	destNode, err := d.db.GetNodeByName(LegDest(order))
+	if err != nil {
+		d.failOrder(order, env, "node_error", fmt.Sprintf("node %q not found", LegDest(order)))
+		return
+	}
+	d.db.UpdateOrderStatus(order.ID, StatusInTransit, fmt.Sprintf("handed off at %s", handoffNode.Name))
+	d.dbg("handoff: order %d continues from %s to %s", order.ID, handoffNode.Name, destNode.Name)
+	d.dispatchToFleet(order, env, handoffNode, destNode)
+}
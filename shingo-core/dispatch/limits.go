@@ -0,0 +1,273 @@
+package dispatch
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+
+	"shingo/protocol"
+	"shingocore/store"
+)
+
+// InFlightLimits caps how many orders may be in flight at once per station,
+// destination zone and payload type. A cap of 0 is unlimited.
+type InFlightLimits struct {
+	Station      int            // default cap per station
+	Stations     map[string]int // per-station caps, overriding Station
+	Zone         int            // default cap per destination zone
+	Zones        map[string]int // per-zone caps, overriding Zone
+	PayloadType  int            // default cap per payload type
+	PayloadTypes map[string]int // per-payload-type caps, overriding PayloadType
+}
+
+// inFlightStatuses count against a cap: from the start of sourcing until the
+// fleet has delivered the payload. Queued and pending orders do not.
+var inFlightStatuses = []string{
+	StatusSourcing, StatusRetrying, StatusSubmitted, StatusDispatched,
+	StatusAcknowledged, StatusInTransit, StatusStaged,
+}
+
+// InFlight holds the current in-flight order counts.
+type InFlight struct {
+	Stations     map[string]int `json:"stations"`
+	Zones        map[string]int `json:"zones"`
+	PayloadTypes map[string]int `json:"payload_types"`
+}
+
+func (f *InFlight) add(stationID, zone, payloadType string, n int) {
+	f.Stations[stationID] += n
+	if zone != "" {
+		f.Zones[zone] += n
+	}
+	if payloadType != "" {
+		f.PayloadTypes[payloadType] += n
+	}
+}
+
+func (l InFlightLimits) enabled() bool {
+	return l.Station > 0 || l.Zone > 0 || l.PayloadType > 0 ||
+		len(l.Stations) > 0 || len(l.Zones) > 0 || len(l.PayloadTypes) > 0
+}
+
+func capFor(def int, caps map[string]int, key string) int {
+	if c, ok := caps[key]; ok {
+		return c
+	}
+	return def
+}
+
+// blockedBy describes the first cap an order would exceed, or returns "" if
+// it may start.
+func (l InFlightLimits) blockedBy(f *InFlight, stationID, zone, payloadType string) string {
+	if c := capFor(l.Station, l.Stations, stationID); c > 0 && f.Stations[stationID] >= c {
+		return fmt.Sprintf("station %s at in-flight cap %d", stationID, c)
+	}
+	if zone != "" {
+		if c := capFor(l.Zone, l.Zones, zone); c > 0 && f.Zones[zone] >= c {
+			return fmt.Sprintf("zone %s at in-flight cap %d", zone, c)
+		}
+	}
+	if payloadType != "" {
+		if c := capFor(l.PayloadType, l.PayloadTypes, payloadType); c > 0 && f.PayloadTypes[payloadType] >= c {
+			return fmt.Sprintf("payload type %s at in-flight cap %d", payloadType, c)
+		}
+	}
+	return ""
+}
+
+// InFlightCounts returns the number of in-flight orders per station,
+// destination zone and payload type.
+func (d *Dispatcher) InFlightCounts() (*InFlight, error) {
+	rows, err := d.db.CountOrdersByStatus(inFlightStatuses)
+	if err != nil {
+		return nil, err
+	}
+	f := &InFlight{
+		Stations:     make(map[string]int),
+		Zones:        make(map[string]int),
+		PayloadTypes: make(map[string]int),
+	}
+	for _, r := range rows {
+		f.add(r.StationID, r.Zone, r.PayloadType, r.Count)
+	}
+	return f, nil
+}
+
+// InFlightUsage is one in-flight count next to its cap (0 when uncapped).
+type InFlightUsage struct {
+	Scope string `json:"scope"` // "station", "zone" or "payload_type"
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Cap   int    `json:"cap"`
+}
+
+// InFlightUsage lists current in-flight counts with their caps, by scope then key.
+func (d *Dispatcher) InFlightUsage() ([]InFlightUsage, error) {
+	f, err := d.InFlightCounts()
+	if err != nil {
+		return nil, err
+	}
+	l := d.Limits
+	var usage []InFlightUsage
+	for k, n := range f.Stations {
+		usage = append(usage, InFlightUsage{"station", k, n, capFor(l.Station, l.Stations, k)})
+	}
+	for k, n := range f.Zones {
+		usage = append(usage, InFlightUsage{"zone", k, n, capFor(l.Zone, l.Zones, k)})
+	}
+	for k, n := range f.PayloadTypes {
+		usage = append(usage, InFlightUsage{"payload_type", k, n, capFor(l.PayloadType, l.PayloadTypes, k)})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Scope != usage[j].Scope {
+			return usage[i].Scope < usage[j].Scope
+		}
+		return usage[i].Key < usage[j].Key
+	})
+	return usage, nil
+}
+
+// capKeys returns the destination zone and payload type an order counts against.
+func (d *Dispatcher) capKeys(order *store.Order) (zone, payloadType string) {
+	if order.DeliveryNode != "" {
+		if n, err := d.db.GetNodeByName(order.DeliveryNode); err == nil {
+			zone = n.Zone
+		}
+	}
+	return zone, d.payloadTypeCode(order)
+}
+
+// capSlot is the station, destination zone and payload type an order
+// counts against.
+type capSlot struct {
+	stationID, zone, payloadType string
+}
+
+// admitOrder starts a new order, or queues it in core if it would exceed an
+// in-flight cap. The slot is reserved under capMu so concurrent requests
+// cannot both take the last one, but the order starts outside the lock so a
+// slow fleet call does not stall every other admission.
+func (d *Dispatcher) admitOrder(order *store.Order, env *protocol.Envelope) {
+	if !d.Limits.enabled() {
+		d.startOrder(order, env)
+		return
+	}
+	reason, err := d.reserveSlot(order)
+	if err != nil {
+		log.Printf("dispatch: count in-flight orders: %v", err)
+		d.startOrder(order, env)
+		return
+	}
+	if reason != "" {
+		msg := "queued: " + reason
+		d.db.UpdateOrderStatus(order.ID, StatusQueued, msg)
+		d.dbg("limits: order %d %s", order.ID, msg)
+		d.sendUpdate(env, order.EdgeUUID, StatusQueued, msg)
+		return
+	}
+	defer d.releaseSlot(order.ID)
+	d.startOrder(order, env)
+}
+
+// reserveSlot counts the order against its caps, on top of the orders
+// already in flight. It returns the cap that blocks the order instead, if any.
+func (d *Dispatcher) reserveSlot(order *store.Order) (string, error) {
+	zone, payloadType := d.capKeys(order)
+	d.capMu.Lock()
+	defer d.capMu.Unlock()
+	counts, err := d.countWithReserved()
+	if err != nil {
+		return "", err
+	}
+	if reason := d.Limits.blockedBy(counts, order.StationID, zone, payloadType); reason != "" {
+		return reason, nil
+	}
+	if d.reserved == nil {
+		d.reserved = make(map[int64]capSlot)
+	}
+	d.reserved[order.ID] = capSlot{order.StationID, zone, payloadType}
+	return "", nil
+}
+
+// releaseSlot drops an order's reservation once it has started. From then on
+// the database counts it if it is still in flight; if it failed, it holds no
+// slot at all.
+func (d *Dispatcher) releaseSlot(orderID int64) {
+	d.capMu.Lock()
+	defer d.capMu.Unlock()
+	delete(d.reserved, orderID)
+}
+
+// countWithReserved returns the in-flight counts plus the reservations of
+// orders still starting that the database does not count yet. Reserved
+// statuses are read before counting, so an order that moves into flight in
+// between is counted twice rather than missed. The caller holds capMu.
+func (d *Dispatcher) countWithReserved() (*InFlight, error) {
+	var pending []capSlot
+	for id, slot := range d.reserved {
+		if o, err := d.db.GetOrder(id); err == nil && slices.Contains(inFlightStatuses, o.Status) {
+			continue
+		}
+		pending = append(pending, slot)
+	}
+	counts, err := d.InFlightCounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range pending {
+		counts.add(slot.stationID, slot.zone, slot.payloadType, 1)
+	}
+	return counts, nil
+}
+
+// ReleaseQueued starts queued orders, highest priority first, as far as their
+// caps allow. An order blocked by its own cap does not hold back orders behind
+// it that count against other caps. It returns the number of orders started.
+func (d *Dispatcher) ReleaseQueued() int {
+	// Only one release pass at a time, so an order still marked queued while
+	// it starts is not picked twice.
+	d.releaseMu.Lock()
+	defer d.releaseMu.Unlock()
+
+	orders, err := d.db.ListQueuedOrders()
+	if err != nil {
+		log.Printf("dispatch: list queued orders: %v", err)
+		return 0
+	}
+
+	n := 0
+	for _, order := range orders {
+		reason, err := d.reserveSlot(order)
+		if err != nil {
+			log.Printf("dispatch: count in-flight orders: %v", err)
+			return n
+		}
+		if reason != "" {
+			continue
+		}
+		d.dbg("limits: releasing queued order %d (priority %d)", order.ID, order.Priority)
+		d.startOrder(order, edgeEnvelope(order.StationID))
+		d.releaseSlot(order.ID)
+		n++
+	}
+	return n
+}
+
+// startOrder runs a new order's sourcing for its type.
+func (d *Dispatcher) startOrder(order *store.Order, env *protocol.Envelope) {
+	code := d.payloadTypeCode(order)
+	switch order.OrderType {
+	case OrderTypeRetrieve:
+		d.handleRetrieve(order, env, code)
+	case OrderTypeMove:
+		d.handleMove(order, env, code)
+	case OrderTypeStore:
+		d.handleStore(order, env)
+	case OrderTypeSwap:
+		d.handleSwap(order, env, code)
+	default:
+		log.Printf("dispatch: unknown order type %q", order.OrderType)
+		d.failOrder(order, env, "unknown_type", fmt.Sprintf("unknown order type: %s", order.OrderType))
+	}
+}
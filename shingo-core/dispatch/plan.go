@@ -0,0 +1,348 @@
+package dispatch
+
+import (
+	"fmt"
+
+	"shingo/protocol"
+	"shingocore/store"
+)
+
+// Plan outcomes: what core would do with an order request right now.
+const (
+	PlanDispatch = "dispatch" // sent to the fleet
+	PlanQueue    = "queue"    // held by an in-flight cap or a locked zone
+	PlanWait     = "wait"     // held in sourcing until stock or storage frees up
+	PlanFail     = "fail"     // rejected or failed
+)
+
+// Plan is the result of a dry run of an order request. Sources and
+// Destinations list every candidate that was considered, with the reason it
+// was or was not chosen.
+type Plan struct {
+	OrderType    string          `json:"order_type"`
+	PayloadType  string          `json:"payload_type"`
+	Strategy     string          `json:"strategy,omitempty"`
+	Outcome      string          `json:"outcome"`
+	ErrorCode    string          `json:"error_code,omitempty"`
+	Detail       string          `json:"detail,omitempty"`
+	Source       string          `json:"source,omitempty"`
+	PayloadID    int64           `json:"payload_id,omitempty"`
+	Destination  string          `json:"destination,omitempty"`
+	StagingNode  string          `json:"staging_node,omitempty"`
+	ReturnNode   string          `json:"return_node,omitempty"`
+	Fleet        string          `json:"fleet,omitempty"`
+	HandoffNode  string          `json:"handoff_node,omitempty"`
+	Sources      []PlanCandidate `json:"sources,omitempty"`
+	Destinations []PlanCandidate `json:"destinations,omitempty"`
+}
+
+// PlanCandidate is one source payload or destination node a plan considered.
+type PlanCandidate struct {
+	Node      string `json:"node"`
+	PayloadID int64  `json:"payload_id,omitempty"`
+	Selected  bool   `json:"selected"`
+	Reason    string `json:"reason"`
+}
+
+func (p *Plan) fail(code, detail string) *Plan {
+	p.Outcome, p.ErrorCode, p.Detail = PlanFail, code, detail
+	return p
+}
+
+// PlanOrder runs the resolution an order request from stationID would go
+// through — payload type lookup, node validation, in-flight caps, source
+// selection, storage placement, zone locks and fleet routing — without creating an order, claiming
+// payloads or calling the fleet.
+func (d *Dispatcher) PlanOrder(stationID string, p *protocol.OrderRequest) *Plan {
+	plan := &Plan{OrderType: p.OrderType, PayloadType: p.PayloadTypeCode, Outcome: PlanDispatch}
+
+	pt, err := d.db.GetPayloadTypeByName(p.PayloadTypeCode)
+	if err != nil {
+		return plan.fail("payload_type_error", fmt.Sprintf("payload type %q not found", p.PayloadTypeCode))
+	}
+	var destNode *store.Node
+	if p.DeliveryNode != "" {
+		if destNode, err = d.db.GetNodeByName(p.DeliveryNode); err != nil {
+			return plan.fail("invalid_node", fmt.Sprintf("delivery node %q not found", p.DeliveryNode))
+		}
+	}
+	if p.StagingNode != "" {
+		if _, err := d.db.GetNodeByName(p.StagingNode); err != nil {
+			return plan.fail("invalid_node", fmt.Sprintf("staging node %q not found", p.StagingNode))
+		}
+	}
+
+	order := &store.Order{
+		StationID:     stationID,
+		OrderType:     p.OrderType,
+		PayloadTypeID: &pt.ID,
+		PickupNode:    p.PickupNode,
+		DeliveryNode:  p.DeliveryNode,
+		StagingNode:   p.StagingNode,
+		MaxWaitSec:    p.MaxWaitSec,
+	}
+
+	switch p.OrderType {
+	case OrderTypeRetrieve:
+		d.planRetrieve(plan, order, pt, destNode)
+	case OrderTypeMove:
+		d.planMove(plan, order, pt)
+	case OrderTypeStore:
+		d.planStore(plan, order, pt)
+	case OrderTypeSwap:
+		d.planSwap(plan, order, pt, destNode)
+	default:
+		return plan.fail("unknown_type", fmt.Sprintf("unknown order type: %s", p.OrderType))
+	}
+
+	if plan.Outcome == PlanDispatch {
+		d.planLeg(plan)
+	}
+	if plan.Outcome == PlanFail || !d.Limits.enabled() {
+		return plan
+	}
+	counts, err := d.InFlightCounts()
+	if err != nil {
+		return plan
+	}
+	zone := ""
+	if destNode != nil {
+		zone = destNode.Zone
+	}
+	if reason := d.Limits.blockedBy(counts, stationID, zone, pt.Name); reason != "" {
+		plan.Outcome, plan.Detail = PlanQueue, "queued: "+reason
+	}
+	return plan
+}
+
+// planLeg applies the checks made before a leg goes to the fleet: the
+// holdForZone lock check over the leg's nodes, then routeFleet's choice of
+// fleet and handoff node. Swaps stay on the source's fleet, so only their
+// zones are checked.
+func (d *Dispatcher) planLeg(plan *Plan) {
+	sourceNode, err := d.db.GetNodeByName(plan.Source)
+	if err != nil {
+		return
+	}
+	legEnd := plan.Destination
+	if plan.StagingNode != "" {
+		legEnd = plan.StagingNode
+	}
+	destNode, err := d.db.GetNodeByName(legEnd)
+	if err != nil {
+		return
+	}
+	nodes := []*store.Node{sourceNode, destNode}
+	if plan.ReturnNode != "" {
+		if n, err := d.db.GetNodeByName(plan.ReturnNode); err == nil {
+			nodes = append(nodes, n)
+		}
+	}
+	if zone := d.lockedZone(nodes...); zone != "" {
+		plan.Outcome, plan.Detail = PlanQueue, fmt.Sprintf("queued: zone %s locked", zone)
+		return
+	}
+	if plan.OrderType == OrderTypeSwap {
+		return
+	}
+	leg, err := d.routeLeg(sourceNode, destNode)
+	if err != nil {
+		plan.fail("cross_fleet", err.Error())
+		return
+	}
+	plan.Fleet, plan.HandoffNode = leg.fleet, leg.handoff
+}
+
+// holdOrFailPlan mirrors holdOrFail: unsourceable orders wait when a wait is
+// configured and fail otherwise.
+func (d *Dispatcher) holdOrFailPlan(plan *Plan, order *store.Order, code, detail string) {
+	if maxWait := d.maxWaitFor(order); maxWait > 0 {
+		plan.Outcome, plan.ErrorCode = PlanWait, code
+		plan.Detail = fmt.Sprintf("waiting for stock: %s (max wait %s)", detail, maxWait)
+		return
+	}
+	plan.fail(code, detail)
+}
+
+func (d *Dispatcher) planRetrieve(plan *Plan, order *store.Order, pt *store.PayloadType, destNode *store.Node) {
+	if !d.planSource(plan, pt, destNode) {
+		d.holdOrFailPlan(plan, order, "no_source", fmt.Sprintf("no source payload found for type %s", pt.Name))
+		return
+	}
+	if destNode == nil {
+		plan.fail("node_error", fmt.Sprintf("delivery node %q not found", order.DeliveryNode))
+		return
+	}
+	plan.Destination = destNode.Name
+	plan.StagingNode = order.StagingNode
+}
+
+func (d *Dispatcher) planMove(plan *Plan, order *store.Order, pt *store.PayloadType) {
+	if order.PickupNode == "" {
+		plan.fail("missing_pickup", "move order requires pickup_node")
+		return
+	}
+	pickupNode, err := d.db.GetNodeByName(order.PickupNode)
+	if err != nil {
+		plan.fail("invalid_node", fmt.Sprintf("pickup node %q not found", order.PickupNode))
+		return
+	}
+	payloads, _ := d.db.ListPayloadsByNode(pickupNode.ID)
+	for _, p := range payloads {
+		c := PlanCandidate{Node: pickupNode.Name, PayloadID: p.ID}
+		switch {
+		case p.PayloadTypeName != pt.Name:
+			c.Reason = fmt.Sprintf("payload type %s", p.PayloadTypeName)
+		case p.ClaimedBy != nil:
+			c.Reason = fmt.Sprintf("claimed by order %d", *p.ClaimedBy)
+		case plan.PayloadID != 0:
+			c.Reason = "another payload was chosen"
+		default:
+			c.Selected, c.Reason = true, "unclaimed at pickup node"
+			plan.Source, plan.PayloadID = pickupNode.Name, p.ID
+		}
+		plan.Sources = append(plan.Sources, c)
+	}
+	if plan.PayloadID == 0 {
+		plan.fail("no_payload", fmt.Sprintf("no unclaimed %s payload at %s", pt.Name, order.PickupNode))
+		return
+	}
+	if _, err := d.db.GetNodeByName(order.DeliveryNode); err != nil {
+		plan.fail("node_error", fmt.Sprintf("delivery node %q not found", order.DeliveryNode))
+		return
+	}
+	plan.Destination = order.DeliveryNode
+}
+
+func (d *Dispatcher) planStore(plan *Plan, order *store.Order, pt *store.PayloadType) {
+	pickup := order.PickupNode
+	if pickup == "" {
+		pickup = order.DeliveryNode
+	}
+	if pickup == "" {
+		plan.fail("missing_pickup", "store order requires a pickup location")
+		return
+	}
+	if _, err := d.db.GetNodeByName(pickup); err != nil {
+		plan.fail("invalid_node", fmt.Sprintf("pickup node %q not found", pickup))
+		return
+	}
+	plan.Source = pickup
+
+	dest, ok := d.planStorage(plan, pt.ID)
+	if !ok {
+		d.holdOrFailPlan(plan, order, "no_storage", "no available storage node found")
+		return
+	}
+	plan.Destination = dest
+}
+
+func (d *Dispatcher) planSwap(plan *Plan, order *store.Order, pt *store.PayloadType, lineNode *store.Node) {
+	if lineNode == nil {
+		plan.fail("node_error", fmt.Sprintf("delivery node %q not found", order.DeliveryNode))
+		return
+	}
+	if empty := d.findLineEmpty(lineNode); empty != nil {
+		dest, ok := d.planStorage(plan, empty.PayloadTypeID)
+		if !ok {
+			d.holdOrFailPlan(plan, order, "no_storage", fmt.Sprintf("no available storage node for empty from %s", lineNode.Name))
+			return
+		}
+		plan.ReturnNode = dest
+	}
+	if !d.planSource(plan, pt, lineNode) {
+		d.holdOrFailPlan(plan, order, "no_source", fmt.Sprintf("no source payload found for type %s", pt.Name))
+		return
+	}
+	plan.Destination = lineNode.Name
+}
+
+// planSource explains selectSource: the payloads it would rank come from the
+// same candidate query, and every other payload of the type is listed with
+// why it is not a candidate. It reports whether a source was found.
+func (d *Dispatcher) planSource(plan *Plan, pt *store.PayloadType, destNode *store.Node) bool {
+	selector := SelectorFor(pt.SourceStrategy)
+	plan.Strategy = selector.Name()
+
+	ranked, _ := d.rankSources(selector, pt.Name, destNode)
+	candidate := make(map[int64]bool, len(ranked))
+	for _, c := range ranked {
+		candidate[c.Payload.ID] = true
+	}
+
+	payloads, _ := d.db.ListPayloadsByType(pt.ID)
+	for _, p := range payloads {
+		if candidate[p.ID] {
+			continue
+		}
+		c := PlanCandidate{Node: p.NodeName, PayloadID: p.ID}
+		var node *store.Node
+		if p.NodeID != nil {
+			node, _ = d.db.GetNode(*p.NodeID)
+		}
+		switch {
+		case node == nil:
+			c.Reason = "not at a node"
+		case p.ClaimedBy != nil:
+			c.Reason = fmt.Sprintf("claimed by order %d", *p.ClaimedBy)
+		case p.Status != "available":
+			c.Reason = fmt.Sprintf("status %s", p.Status)
+		case node.NodeType != "storage":
+			c.Reason = fmt.Sprintf("at %s node", node.NodeType)
+		case !node.Enabled:
+			c.Reason = "node disabled"
+		default:
+			c.Reason = "not a source candidate"
+		}
+		plan.Sources = append(plan.Sources, c)
+	}
+
+	for i, c := range ranked {
+		pc := PlanCandidate{Node: c.Node.Name, PayloadID: c.Payload.ID}
+		if i == 0 {
+			pc.Selected, pc.Reason = true, fmt.Sprintf("first by %s", selector.Name())
+			plan.Source, plan.PayloadID = c.Node.Name, c.Payload.ID
+		} else {
+			pc.Reason = fmt.Sprintf("ranked %d by %s", i+1, selector.Name())
+		}
+		plan.Sources = append(plan.Sources, pc)
+	}
+	return len(ranked) > 0
+}
+
+// planStorage explains FindStorageDestinationForPayload: every storage node is
+// listed with why it is ineligible or was passed over. It returns the chosen
+// node's name.
+func (d *Dispatcher) planStorage(plan *Plan, payloadTypeID int64) (string, bool) {
+	chosen, err := d.db.FindStorageDestinationForPayload(payloadTypeID)
+	nodes, _ := d.db.ListNodesByType("storage")
+	for _, n := range nodes {
+		payloads, _ := d.db.ListPayloadsByNode(n.ID)
+		same := 0
+		for _, p := range payloads {
+			if p.PayloadTypeID == payloadTypeID {
+				same++
+			}
+		}
+		c := PlanCandidate{Node: n.Name}
+		switch {
+		case !n.Enabled:
+			c.Reason = "node disabled"
+		case n.Capacity <= 0:
+			c.Reason = "no capacity set"
+		case len(payloads) >= n.Capacity:
+			c.Reason = fmt.Sprintf("full (%d/%d)", len(payloads), n.Capacity)
+		case err == nil && n.ID == chosen.ID && same > 0:
+			c.Selected, c.Reason = true, fmt.Sprintf("consolidates %d of this type (%d/%d)", same, len(payloads), n.Capacity)
+		case err == nil && n.ID == chosen.ID:
+			c.Selected, c.Reason = true, fmt.Sprintf("emptiest with room (%d/%d)", len(payloads), n.Capacity)
+		default:
+			c.Reason = fmt.Sprintf("not preferred: %d of this type (%d/%d)", same, len(payloads), n.Capacity)
+		}
+		plan.Destinations = append(plan.Destinations, c)
+	}
+	if err != nil {
+		return "", false
+	}
+	return chosen.Name, true
+}
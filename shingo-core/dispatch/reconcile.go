@@ -0,0 +1,157 @@
+package dispatch
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"shingo/protocol"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// ReconcileResult counts what a reconciliation pass did.
+type ReconcileResult struct {
+	Resumed   int // orders committed as dispatched or sourced again
+	Failed    int // orders failed because their dispatch could not be recovered
+	Unclaimed int // payload claims released from interrupted orders
+}
+
+// ReconcileStuckOrders repairs orders left between steps by a crash. It runs
+// once at startup, before the tracker and the sourcing backlog load orders:
+//
+//   - a pending fleet intent is checked against the fleet; a vendor order that
+//     exists is committed as dispatched, one that does not is sourced again
+//   - pending and sourcing orders release their claims and are sourced again
+//   - dispatched orders whose vendor order the fleet no longer knows are failed
+//
+// Backends without fleet.OrderLookup cannot confirm an interrupted dispatch,
+// so those orders are cancelled at the fleet best-effort and failed.
+func (d *Dispatcher) ReconcileStuckOrders() ReconcileResult {
+	var res ReconcileResult
+	lookup, _ := d.backend.(fleet.OrderLookup)
+
+	intents, err := d.db.ListPendingFleetIntents()
+	if err != nil {
+		log.Printf("dispatch: reconcile: list fleet intents: %v", err)
+	}
+	withIntent := make(map[int64]bool, len(intents))
+	for _, fi := range intents {
+		withIntent[fi.OrderID] = true
+		order, err := d.db.GetOrder(fi.OrderID)
+		if err != nil {
+			d.db.AbandonFleetIntent(fi.ID)
+			continue
+		}
+		d.reconcileIntent(order, fi, lookup, &res)
+	}
+
+	orders, err := d.db.ListStuckOrders()
+	if err != nil {
+		log.Printf("dispatch: reconcile: list stuck orders: %v", err)
+		return res
+	}
+	for _, order := range orders {
+		if withIntent[order.ID] {
+			continue
+		}
+		d.reconcileOrder(order, lookup, &res)
+	}
+	return res
+}
+
+// reconcileIntent resolves a fleet call that was in flight when core stopped.
+func (d *Dispatcher) reconcileIntent(order *store.Order, fi *store.FleetIntent, lookup fleet.OrderLookup, res *ReconcileResult) {
+	env := edgeEnvelope(order.StationID)
+
+	if lookup == nil {
+		d.dbg("reconcile: order %d intent %s unverifiable on %s", order.ID, fi.VendorOrderID, d.backend.Name())
+		d.backend.CancelOrder(fi.VendorOrderID)
+		d.db.AbandonFleetIntent(fi.ID)
+		d.failOrder(order, env, "dispatch_interrupted",
+			fmt.Sprintf("dispatch of vendor order %s interrupted; %s cannot confirm it", fi.VendorOrderID, d.backend.Name()))
+		res.Failed++
+		return
+	}
+
+	state, err := lookup.GetOrderState(fi.VendorOrderID)
+	switch {
+	case err == nil:
+		d.dbg("reconcile: order %d vendor order %s exists (%s), committing", order.ID, fi.VendorOrderID, state)
+		d.recordDispatch(order, env, fi, order.PickupNode, LegDest(order))
+		res.Resumed++
+	case errors.Is(err, fleet.ErrOrderNotFound):
+		d.dbg("reconcile: order %d vendor order %s never created", order.ID, fi.VendorOrderID)
+		d.db.AbandonFleetIntent(fi.ID)
+		if order.Status == StatusStaged {
+			// A release that never reached the fleet; the order stays staged
+			// until it is released again.
+			return
+		}
+		d.resumeSourcing(order, env, res)
+	default:
+		log.Printf("dispatch: reconcile order %d: look up vendor order %s: %v", order.ID, fi.VendorOrderID, err)
+	}
+}
+
+// reconcileOrder handles a stuck order with no fleet call in flight.
+func (d *Dispatcher) reconcileOrder(order *store.Order, lookup fleet.OrderLookup, res *ReconcileResult) {
+	env := edgeEnvelope(order.StationID)
+
+	switch order.Status {
+	case StatusPending, StatusSourcing:
+		d.resumeSourcing(order, env, res)
+	case StatusDispatched:
+		if lookup == nil || order.VendorOrderID == "" {
+			return
+		}
+		if _, err := lookup.GetOrderState(order.VendorOrderID); errors.Is(err, fleet.ErrOrderNotFound) {
+			d.failOrder(order, env, "fleet_order_lost", fmt.Sprintf("vendor order %s not found at %s", order.VendorOrderID, d.backend.Name()))
+			res.Failed++
+		}
+	}
+}
+
+// resumeSourcing releases whatever an interrupted order had claimed and runs
+// its sourcing again from the start.
+func (d *Dispatcher) resumeSourcing(order *store.Order, env *protocol.Envelope, res *ReconcileResult) {
+	if n, err := d.db.UnclaimOrderPayloads(order.ID); err == nil {
+		res.Unclaimed += int(n)
+	}
+	if order.ReturnNode != "" {
+		order.ReturnNode = ""
+		d.db.UpdateOrderReturnNode(order.ID, "")
+	}
+	d.dbg("reconcile: order %d resuming %s from %s", order.ID, order.OrderType, order.Status)
+
+	code := d.payloadTypeCode(order)
+	switch order.OrderType {
+	case OrderTypeRetrieve:
+		d.sourceRetrieve(order, env, code)
+	case OrderTypeSwap:
+		d.sourceSwap(order, env, code)
+	case OrderTypeStore:
+		d.sourceStore(order, env)
+	case OrderTypeMove:
+		d.handleMove(order, env, code)
+	default:
+		d.failOrder(order, env, "unknown_type", fmt.Sprintf("unknown order type: %s", order.OrderType))
+	}
+
+	if got, err := d.db.GetOrder(order.ID); err == nil && got.Status == StatusFailed {
+		res.Failed++
+		return
+	}
+	res.Resumed++
+}
+
+// LegDest is the node the order's current vendor order delivers to.
+func LegDest(order *store.Order) string {
+	if IsHandoffLeg(order) {
+		return order.HandoffNode
+	}
+	if IsStagingLeg(order) {
+		return order.StagingNode
+	}
+	return order.DeliveryNode
+}
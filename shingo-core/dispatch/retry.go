@@ -0,0 +1,163 @@
+package dispatch
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"shingo/protocol"
+	"shingocore/store"
+)
+
+// RetryPolicy bounds automatic retries of an order the fleet could not run.
+type RetryPolicy struct {
+	MaxAttempts int           // retries before the order fails; 0 disables retries
+	Backoff     time.Duration // wait before the first retry, doubled for each further one
+	MaxBackoff  time.Duration // ceiling for the wait; 0 means none
+}
+
+// Delay returns the wait before retry n (1-based).
+func (p RetryPolicy) Delay(n int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// HandleVendorFailure deals with a vendor order the fleet reported as failed:
+// the order is retried under VendorRetry, or failed once retries run out.
+// order is the order as it stood when the failure was matched to it; a
+// report that arrives after the order ended or moved on to another vendor
+// order is ignored.
+func (d *Dispatcher) HandleVendorFailure(order *store.Order, vendorState, robotID, detail string) {
+	unlock := d.lockOrder(order.ID)
+	defer unlock()
+	current, err := d.db.GetOrder(order.ID)
+	if err != nil {
+		log.Printf("dispatch: vendor failure of order %d: %v", order.ID, err)
+		return
+	}
+	if isTerminal(current.Status) || current.VendorOrderID != order.VendorOrderID {
+		d.dbg("retry: order %d ignoring failure of vendor order %s (now %s, vendor order %s)",
+			order.ID, order.VendorOrderID, current.Status, current.VendorOrderID)
+		return
+	}
+	d.db.UpdateOrderVendor(current.ID, current.VendorOrderID, vendorState, robotID)
+	d.retryOrFail(current, edgeEnvelope(current.StationID), true, detail)
+}
+
+func isTerminal(status string) bool {
+	return status == StatusConfirmed || status == StatusFailed || status == StatusCancelled
+}
+
+// retryOrFail schedules a fresh dispatch of an order whose vendor order could
+// not be created (vendor false) or was failed by the fleet (vendor true). The
+// order keeps its claims and waits in retrying, with the time of the next
+// attempt stored so RestoreRetries can pick it up after a restart. Once the
+// policy's attempts are used up the order fails and ShinGo Edge is told.
+func (d *Dispatcher) retryOrFail(order *store.Order, env *protocol.Envelope, vendor bool, detail string) {
+	policy, what := d.CreateRetry, "fleet create"
+	if vendor {
+		policy, what = d.VendorRetry, "fleet order"
+	}
+	current, err := d.db.GetOrder(order.ID)
+	if err != nil {
+		d.failOrder(order, env, "fleet_failed", detail)
+		return
+	}
+	used := current.CreateRetries
+	if vendor {
+		used = current.VendorRetries
+	}
+	if used >= policy.MaxAttempts {
+		if policy.MaxAttempts > 0 {
+			detail = fmt.Sprintf("%s (after %d retries)", detail, used)
+		}
+		d.failOrder(order, env, "fleet_failed", detail)
+		return
+	}
+
+	n := used + 1
+	delay := policy.Delay(n)
+	msg := fmt.Sprintf("%s failed: %s; retry %d/%d in %s", what, detail, n, policy.MaxAttempts, delay)
+	if err := d.db.RecordFleetRetry(order.ID, vendor, time.Now().Add(delay), msg); errors.Is(err, store.ErrOrderEnded) {
+		d.dbg("retry: order %d ended before its retry was recorded", order.ID)
+		return
+	} else if err != nil {
+		log.Printf("dispatch: record retry for order %d: %v", order.ID, err)
+		d.failOrder(order, env, "fleet_failed", detail)
+		return
+	}
+	d.dbg("retry: order %d %s", order.ID, msg)
+	// ShinGo Edge has no retrying status; to it the order is still sourcing.
+	d.sendUpdate(env, order.EdgeUUID, StatusSourcing, msg)
+
+	time.AfterFunc(delay, func() { d.runRetry(order.ID, env) })
+}
+
+// RestoreRetries schedules the retries of orders that were waiting in
+// retrying when core stopped, at their stored time or straight away if that
+// has passed. It returns the number of orders scheduled.
+func (d *Dispatcher) RestoreRetries() int {
+	orders, err := d.db.ListRetryingOrders()
+	if err != nil {
+		log.Printf("dispatch: restore retries: %v", err)
+		return 0
+	}
+	for _, order := range orders {
+		var delay time.Duration
+		if order.NextRetryAt != nil {
+			delay = max(time.Until(*order.NextRetryAt), 0)
+		}
+		id, env := order.ID, edgeEnvelope(order.StationID)
+		d.dbg("retry: order %d restored, retrying in %s", id, delay.Round(time.Second))
+		time.AfterFunc(delay, func() { d.runRetry(id, env) })
+	}
+	return len(orders)
+}
+
+// runRetry re-dispatches an order waiting on a retry under a new vendor order
+// ID, unless it was cancelled or otherwise moved on in the meantime.
+func (d *Dispatcher) runRetry(orderID int64, env *protocol.Envelope) {
+	unlock := d.lockOrder(orderID)
+	defer unlock()
+	order, err := d.db.GetOrder(orderID)
+	if err != nil || order.Status != StatusRetrying {
+		return
+	}
+	d.dbg("retry: re-dispatching order %d", order.ID)
+	d.redispatch(order, env)
+}
+
+// redispatch sends an order's current leg to the fleet again, from its pickup
+// node to where that leg ends.
+func (d *Dispatcher) redispatch(order *store.Order, env *protocol.Envelope) {
+	sourceNode, err := d.db.GetNodeByName(order.PickupNode)
+	if err != nil {
+		d.failOrder(order, env, "node_error", fmt.Sprintf("pickup node %q not found", order.PickupNode))
+		return
+	}
+	destNode, err := d.db.GetNodeByName(LegDest(order))
+	if err != nil {
+		d.failOrder(order, env, "node_error", fmt.Sprintf("node %q not found", LegDest(order)))
+		return
+	}
+	if order.OrderType == OrderTypeSwap && order.ReturnNode != "" {
+		returnNode, err := d.db.GetNodeByName(order.ReturnNode)
+		if err != nil {
+			d.failOrder(order, env, "node_error", fmt.Sprintf("return node %q not found", order.ReturnNode))
+			return
+		}
+		d.dispatchSwap(order, env, sourceNode, destNode, returnNode)
+		return
+	}
+	d.dispatchToFleet(order, env, sourceNode, destNode)
+}
@@ -0,0 +1,210 @@
+package dispatch
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"shingo/protocol"
+	"shingocore/store"
+)
+
+// ScheduleStationID is the station scheduled orders are raised for when the
+// schedule names none.
+const ScheduleStationID = "core-schedule"
+
+// CoreOrderPrefix starts the UUID of every order core raises itself, from a
+// schedule or a scenario. No edge tracks these orders, so core releases them
+// from staging and confirms their delivery itself.
+const CoreOrderPrefix = "core-"
+
+// IsCoreOrder reports whether core raised the order itself.
+func IsCoreOrder(order *store.Order) bool {
+	return strings.HasPrefix(order.EdgeUUID, CoreOrderPrefix)
+}
+
+// ConfirmCoreOrder completes a core-raised order the fleet has delivered,
+// standing in for the receipt an edge would send.
+func (d *Dispatcher) ConfirmCoreOrder(order *store.Order) {
+	d.HandleOrderReceipt(edgeEnvelope(order.StationID), &protocol.OrderReceipt{
+		OrderUUID:   order.EdgeUUID,
+		ReceiptType: "confirmed",
+		FinalCount:  order.Quantity,
+	})
+}
+
+// ReleaseCoreOrder sends a core-raised order on from its staging node,
+// standing in for the release an edge would send.
+func (d *Dispatcher) ReleaseCoreOrder(order *store.Order) {
+	d.HandleOrderRelease(edgeEnvelope(order.StationID), &protocol.OrderRelease{
+		OrderUUID: order.EdgeUUID,
+		Reason:    "core order",
+	})
+}
+
+// DowntimeWindow is a daily stretch of local time in which schedules do not
+// fire. A window whose end is before its start runs past midnight.
+type DowntimeWindow struct {
+	Days       []time.Weekday // days the window starts on; empty means every day
+	Start, End time.Duration  // offsets from local midnight
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseDowntimeWindow builds a window from day names ("mon".."sun") and
+// HH:MM start and end times.
+func ParseDowntimeWindow(days []string, start, end string) (DowntimeWindow, error) {
+	var w DowntimeWindow
+	for _, name := range days {
+		day, ok := weekdays[strings.ToLower(name)[:min(3, len(name))]]
+		if !ok {
+			return w, fmt.Errorf("downtime: unknown day %q", name)
+		}
+		w.Days = append(w.Days, day)
+	}
+	var err error
+	if w.Start, err = parseClock(start); err != nil {
+		return w, fmt.Errorf("downtime start: %w", err)
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return w, fmt.Errorf("downtime end: %w", err)
+	}
+	return w, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window.
+func (w DowntimeWindow) Contains(t time.Time) bool {
+	t = t.Local()
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.Start <= w.End {
+		return w.onDay(t.Weekday()) && clock >= w.Start && clock < w.End
+	}
+	if clock >= w.Start {
+		return w.onDay(t.Weekday())
+	}
+	return clock < w.End && w.onDay((t.Weekday()+6)%7) // started yesterday
+}
+
+func (w DowntimeWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+func inDowntime(windows []DowntimeWindow, t time.Time) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextScheduleRun returns when a schedule is next due after t: its next cron
+// time if recurring, otherwise its one-off run time.
+func NextScheduleRun(s *store.OrderSchedule, t time.Time) (*time.Time, error) {
+	if s.Recurring() {
+		rule, err := ParseCron(s.Cron)
+		if err != nil {
+			return nil, err
+		}
+		next := rule.Next(t)
+		if next.IsZero() {
+			return nil, fmt.Errorf("cron %q never fires", s.Cron)
+		}
+		return &next, nil
+	}
+	if s.RunAt == nil {
+		return nil, fmt.Errorf("schedule needs a run time or a cron rule")
+	}
+	return s.RunAt, nil
+}
+
+// RunSchedules raises an order for every enabled schedule that is due. During
+// downtime recurring runs are skipped and one-off runs wait for the window to
+// end. A recurring schedule that missed several runs (e.g. core was down)
+// fires once and resumes from now. It returns the number of orders raised.
+func (d *Dispatcher) RunSchedules(now time.Time, downtime []DowntimeWindow) int {
+	schedules, err := d.db.ListOrderSchedules()
+	if err != nil {
+		log.Printf("dispatch: list schedules: %v", err)
+		return 0
+	}
+
+	n := 0
+	for _, s := range schedules {
+		if !s.Enabled || s.NextRunAt == nil || s.NextRunAt.After(now) {
+			continue
+		}
+
+		var next *time.Time
+		if s.Recurring() {
+			if next, err = NextScheduleRun(s, now); err != nil {
+				log.Printf("dispatch: schedule %d: %v", s.ID, err)
+				d.db.RecordScheduleRun(s.ID, now, nil, false, err.Error())
+				continue
+			}
+		}
+
+		if inDowntime(downtime, now) {
+			if !s.Recurring() {
+				continue
+			}
+			d.dbg("schedule: %d (%s) skipped in downtime", s.ID, s.Name)
+			d.db.RecordScheduleRun(s.ID, now, next, true, "skipped: downtime")
+			continue
+		}
+
+		result := d.fireSchedule(s)
+		d.dbg("schedule: %d (%s) %s", s.ID, s.Name, result)
+		d.db.RecordScheduleRun(s.ID, now, next, s.Recurring(), result)
+		n++
+	}
+	return n
+}
+
+// fireSchedule raises a schedule's order through the normal order request
+// path and describes the outcome.
+func (d *Dispatcher) fireSchedule(s *store.OrderSchedule) string {
+	stationID := s.StationID
+	if stationID == "" {
+		stationID = ScheduleStationID
+	}
+	orderUUID := fmt.Sprintf("%ssched-%d-%s", CoreOrderPrefix, s.ID, uuid.New().String()[:8])
+	d.HandleOrderRequest(edgeEnvelope(stationID), &protocol.OrderRequest{
+		OrderUUID:       orderUUID,
+		OrderType:       s.OrderType,
+		PayloadTypeCode: s.PayloadTypeCode,
+		PickupNode:      s.PickupNode,
+		DeliveryNode:    s.DeliveryNode,
+		Quantity:        s.Quantity,
+		Priority:        s.Priority,
+		PayloadDesc:     "scheduled: " + s.Name,
+	})
+
+	order, err := d.db.GetOrderByUUID(orderUUID)
+	if err != nil {
+		return "order rejected (check payload type and nodes)"
+	}
+	return fmt.Sprintf("order %d %s", order.ID, order.Status)
+}
@@ -0,0 +1,150 @@
+package dispatch
+
+import (
+	"math"
+	"sort"
+
+	"shingocore/store"
+)
+
+// Source selection strategy names, stored per payload type in payload_types.source_strategy.
+const (
+	StrategyFIFO    = "fifo"
+	StrategyLIFO    = "lifo"
+	StrategyFEFO    = "fefo"
+	StrategyNearest = "nearest"
+	StrategyZone    = "zone"
+)
+
+// SourceCandidate is an unclaimed payload eligible to satisfy a retrieve order,
+// paired with the storage node it currently sits at.
+type SourceCandidate struct {
+	Payload *store.Payload
+	Node    *store.Node
+}
+
+// SourceSelector ranks source candidates for a retrieve order. The first
+// candidate returned is the one the dispatcher claims; the rest are fallbacks.
+// Candidates arrive in FIFO order (oldest delivery first), so selectors that
+// use a stable sort keep FIFO as the tie-breaker.
+type SourceSelector interface {
+	Name() string
+	Rank(db *store.DB, candidates []SourceCandidate, dest *store.Node) []SourceCandidate
+}
+
+var sourceSelectors = map[string]SourceSelector{
+	StrategyFIFO:    fifoSelector{},
+	StrategyLIFO:    lifoSelector{},
+	StrategyFEFO:    fefoSelector{},
+	StrategyNearest: nearestSelector{},
+	StrategyZone:    zoneSelector{},
+}
+
+// SourceStrategies lists the selectable strategy names in display order.
+func SourceStrategies() []string {
+	return []string{StrategyFIFO, StrategyLIFO, StrategyFEFO, StrategyNearest, StrategyZone}
+}
+
+// SelectorFor returns the selector for a strategy name, falling back to FIFO
+// for empty or unknown names.
+func SelectorFor(strategy string) SourceSelector {
+	if s, ok := sourceSelectors[strategy]; ok {
+		return s
+	}
+	return fifoSelector{}
+}
+
+// fifoSelector keeps the oldest delivered payload first.
+type fifoSelector struct{}
+
+func (fifoSelector) Name() string { return StrategyFIFO }
+
+func (fifoSelector) Rank(_ *store.DB, candidates []SourceCandidate, _ *store.Node) []SourceCandidate {
+	return candidates
+}
+
+// lifoSelector takes the most recently delivered payload first.
+type lifoSelector struct{}
+
+func (lifoSelector) Name() string { return StrategyLIFO }
+
+func (lifoSelector) Rank(_ *store.DB, candidates []SourceCandidate, _ *store.Node) []SourceCandidate {
+	out := make([]SourceCandidate, len(candidates))
+	for i, c := range candidates {
+		out[len(candidates)-1-i] = c
+	}
+	return out
+}
+
+// fefoSelector takes the payload with the oldest manifest production date first.
+// Payloads without a production date sort after dated ones.
+type fefoSelector struct{}
+
+func (fefoSelector) Name() string { return StrategyFEFO }
+
+func (fefoSelector) Rank(db *store.DB, candidates []SourceCandidate, _ *store.Node) []SourceCandidate {
+	dates := make(map[int64]string, len(candidates))
+	for _, c := range candidates {
+		d, _ := db.EarliestProductionDate(c.Payload.ID)
+		dates[c.Payload.ID] = d
+	}
+	out := append([]SourceCandidate(nil), candidates...)
+	sort.SliceStable(out, func(i, j int) bool {
+		di, dj := dates[out[i].Payload.ID], dates[out[j].Payload.ID]
+		if di == "" || dj == "" {
+			return di != "" && dj == ""
+		}
+		return di < dj
+	})
+	return out
+}
+
+// nearestSelector takes the payload whose node is closest to the destination,
+// using scene point coordinates. Nodes without coordinates sort last.
+type nearestSelector struct{}
+
+func (nearestSelector) Name() string { return StrategyNearest }
+
+func (nearestSelector) Rank(db *store.DB, candidates []SourceCandidate, dest *store.Node) []SourceCandidate {
+	if dest == nil {
+		return candidates
+	}
+	destPt, err := db.GetScenePointByInstance(dest.VendorLocation)
+	if err != nil {
+		return candidates
+	}
+	dist := make(map[int64]float64, len(candidates))
+	for _, c := range candidates {
+		if _, ok := dist[c.Node.ID]; ok {
+			continue
+		}
+		sp, err := db.GetScenePointByInstance(c.Node.VendorLocation)
+		if err != nil {
+			dist[c.Node.ID] = math.Inf(1)
+			continue
+		}
+		dist[c.Node.ID] = math.Hypot(sp.PosX-destPt.PosX, sp.PosY-destPt.PosY)
+	}
+	out := append([]SourceCandidate(nil), candidates...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return dist[out[i].Node.ID] < dist[out[j].Node.ID]
+	})
+	return out
+}
+
+// zoneSelector prefers payloads stored in the destination node's zone, then
+// falls back to the remaining candidates in FIFO order.
+type zoneSelector struct{}
+
+func (zoneSelector) Name() string { return StrategyZone }
+
+func (zoneSelector) Rank(_ *store.DB, candidates []SourceCandidate, dest *store.Node) []SourceCandidate {
+	if dest == nil || dest.Zone == "" {
+		return candidates
+	}
+	out := append([]SourceCandidate(nil), candidates...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Node.Zone == dest.Zone && out[j].Node.Zone != dest.Zone
+	})
+	return out
+}
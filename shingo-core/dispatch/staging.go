@@ -0,0 +1,55 @@
+package dispatch
+
+import (
+	"fmt"
+	"log"
+
+	"shingo/protocol"
+	"shingocore/store"
+)
+
+// IsStagingLeg reports whether the order's current vendor order is the first
+// leg of a staged delivery, i.e. it ends at the staging node rather than the
+// delivery node. Once released, the staging node becomes the pickup node.
+func IsStagingLeg(order *store.Order) bool {
+	return order.StagingNode != "" && order.PickupNode != order.StagingNode
+}
+
+// HandleOrderRelease sends a staged order on its second leg, from the staging
+// node to the delivery node, as a new vendor order.
+func (d *Dispatcher) HandleOrderRelease(env *protocol.Envelope, p *protocol.OrderRelease) {
+	d.dbg("release: uuid=%s reason=%s", p.OrderUUID, p.Reason)
+
+	order, unlock, err := d.lockOrderByUUID(p.OrderUUID)
+	if err != nil {
+		log.Printf("dispatch: release order %s not found: %v", p.OrderUUID, err)
+		return
+	}
+	defer unlock()
+	if order.Status != StatusStaged {
+		log.Printf("dispatch: release order %s ignored in status %s", p.OrderUUID, order.Status)
+		return
+	}
+
+	stagingNode, err := d.db.GetNodeByName(order.StagingNode)
+	if err != nil {
+		d.failOrder(order, env, "node_error", fmt.Sprintf("staging node %q not found", order.StagingNode))
+		return
+	}
+	destNode, err := d.db.GetNodeByName(order.DeliveryNode)
+	if err != nil {
+		d.failOrder(order, env, "node_error", fmt.Sprintf("delivery node %q not found", order.DeliveryNode))
+		return
+	}
+
+	order.PickupNode = stagingNode.Name
+	d.db.UpdateOrderPickupNode(order.ID, stagingNode.Name)
+
+	detail := fmt.Sprintf("released from %s", stagingNode.Name)
+	if p.Reason != "" {
+		detail += ": " + p.Reason
+	}
+	d.db.UpdateOrderStatus(order.ID, StatusStaged, detail)
+
+	d.dispatchToFleet(order, env, stagingNode, destNode)
+}
@@ -0,0 +1,143 @@
+package dispatch
+
+import (
+	"fmt"
+	"log"
+
+	"shingo/protocol"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+func (d *Dispatcher) handleSwap(order *store.Order, env *protocol.Envelope, payloadTypeCode string) {
+	d.db.UpdateOrderStatus(order.ID, StatusSourcing, "finding source and empty return")
+	d.sourceSwap(order, env, payloadTypeCode)
+}
+
+// sourceSwap finds the full payload to deliver, the empty to take off the line
+// and a storage node for that empty, then dispatches one multi-stop vendor
+// order. With nothing to take back, the swap runs as a plain delivery.
+func (d *Dispatcher) sourceSwap(order *store.Order, env *protocol.Envelope, payloadTypeCode string) {
+	lineNode, err := d.db.GetNodeByName(order.DeliveryNode)
+	if err != nil {
+		d.failOrder(order, env, "node_error", fmt.Sprintf("delivery node %q not found", order.DeliveryNode))
+		return
+	}
+
+	empty := d.findLineEmpty(lineNode)
+	var returnNode *store.Node
+	if empty != nil {
+		returnNode, err = d.db.FindStorageDestinationForPayload(empty.PayloadTypeID)
+		if err != nil {
+			d.dbg("swap: no storage for empty payload=%d", empty.ID)
+			d.holdOrFail(order, env, "no_storage", fmt.Sprintf("no available storage node for empty from %s", lineNode.Name))
+			return
+		}
+	}
+
+	source, sourceNode, err := d.selectSource(order, payloadTypeCode, lineNode)
+	if err != nil {
+		d.dbg("swap: no source payload for type %s: %v", payloadTypeCode, err)
+		d.holdOrFail(order, env, "no_source", fmt.Sprintf("no source payload found for type %s", payloadTypeCode))
+		return
+	}
+	d.dbg("swap: claimed payload=%d at %s for order %d", source.ID, sourceNode.Name, order.ID)
+
+	order.PickupNode = sourceNode.Name
+
+	if empty == nil {
+		d.dbg("swap: nothing to take back at %s, delivering only", lineNode.Name)
+		d.dispatchToFleet(order, env, sourceNode, lineNode)
+		return
+	}
+
+	if err := d.db.ClaimReturnPayload(empty.ID, order.ID, returnNode.Name); err != nil {
+		d.failOrder(order, env, "claim_failed", fmt.Sprintf("claim empty payload %d: %v", empty.ID, err))
+		return
+	}
+	order.ReturnNode = returnNode.Name
+
+	d.dispatchSwap(order, env, sourceNode, lineNode, returnNode)
+}
+
+// findLineEmpty picks the unclaimed empty a swap takes back from the line.
+// Full payloads are never taken back, so with no empty the swap delivers only.
+func (d *Dispatcher) findLineEmpty(lineNode *store.Node) *store.Payload {
+	payloads, err := d.db.ListPayloadsByNode(lineNode.ID)
+	if err != nil {
+		return nil
+	}
+	for _, p := range payloads {
+		if p.ClaimedBy == nil && p.Status == "empty" {
+			return p
+		}
+	}
+	return nil
+}
+
+// dispatchSwap sends the swap to the fleet as a single four-stop vendor order:
+// pick full at storage, drop at line, pick empty at line, drop at storage.
+func (d *Dispatcher) dispatchSwap(order *store.Order, env *protocol.Envelope, sourceNode, lineNode, returnNode *store.Node) {
+	if d.holdForZone(order, env, sourceNode, lineNode, returnNode) {
+		return
+	}
+	backend := d.backend
+	fleetName := ""
+	if reg, ok := d.backend.(*fleet.Registry); ok {
+		fleetName = reg.Route(sourceNode.Name, sourceNode.Zone)
+		for _, n := range []*store.Node{lineNode, returnNode} {
+			if f := reg.Route(n.Name, n.Zone); f != fleetName {
+				d.failOrder(order, env, "cross_fleet", fmt.Sprintf("swap cannot cross fleets: %s is served by fleet %s and %s by fleet %s",
+					sourceNode.Name, fleetName, n.Name, f))
+				return
+			}
+		}
+		backend = reg.Get(fleetName)
+	}
+	ms, ok := backend.(fleet.MultiStopCreator)
+	if !ok {
+		d.failOrder(order, env, "fleet_failed", fmt.Sprintf("%s does not support multi-stop orders", backend.Name()))
+		return
+	}
+
+	vendorOrderID := newVendorOrderID(order)
+	req := fleet.MultiStopOrderRequest{
+		OrderID:    vendorOrderID,
+		ExternalID: order.EdgeUUID,
+		Priority:   order.Priority,
+		Stops: []fleet.TransportStop{
+			{Loc: sourceNode.VendorLocation, Action: fleet.StopPick},
+			{Loc: lineNode.VendorLocation, Action: fleet.StopDrop},
+			{Loc: lineNode.VendorLocation, Action: fleet.StopPick},
+			{Loc: returnNode.VendorLocation, Action: fleet.StopDrop},
+		},
+	}
+
+	d.dbg("fleet swap dispatch: order=%d vendor_id=%s full %s -> %s, empty %s -> %s priority=%d",
+		order.ID, vendorOrderID, sourceNode.Name, lineNode.Name, lineNode.Name, returnNode.Name, order.Priority)
+
+	intent, ok := d.recordIntent(order, env, vendorOrderID, req)
+	if !ok {
+		return
+	}
+
+	var err error
+	if reg, ok := d.backend.(*fleet.Registry); ok {
+		_, err = reg.CreateMultiStopOn(fleetName, req)
+	} else {
+		_, err = ms.CreateMultiStopOrder(req)
+	}
+	if err != nil {
+		log.Printf("dispatch: fleet create swap order failed: %v", err)
+		d.dbg("fleet swap dispatch failed: %v", err)
+		d.db.AbandonFleetIntent(intent.ID)
+		d.retryOrFail(order, env, false, err.Error())
+		return
+	}
+
+	if fleetName != "" {
+		d.setOrderFleet(order, fleetName)
+	}
+	log.Printf("dispatch: order %d dispatched as swap %s (%s -> %s -> %s)", order.ID, vendorOrderID, sourceNode.Name, lineNode.Name, returnNode.Name)
+	d.recordDispatch(order, env, intent, sourceNode.Name, lineNode.Name)
+}
@@ -2,20 +2,25 @@ package dispatch
 
 import "shingo/protocol"
 
+// Order types aliased from protocol for local use.
 const (
-	OrderTypeRetrieve = "retrieve"
-	OrderTypeMove     = "move"
-	OrderTypeStore    = "store"
+	OrderTypeRetrieve = protocol.OrderTypeRetrieve
+	OrderTypeMove     = protocol.OrderTypeMove
+	OrderTypeStore    = protocol.OrderTypeStore
+	OrderTypeSwap     = protocol.OrderTypeSwap
 )
 
 // Order statuses aliased from protocol for local use.
 const (
 	StatusPending      = protocol.StatusPending
+	StatusQueued       = protocol.StatusQueued
 	StatusSourcing     = protocol.StatusSourcing
+	StatusRetrying     = protocol.StatusRetrying
 	StatusSubmitted    = protocol.StatusSubmitted
 	StatusDispatched   = protocol.StatusDispatched
 	StatusAcknowledged = protocol.StatusAcknowledged
 	StatusInTransit    = protocol.StatusInTransit
+	StatusStaged       = protocol.StatusStaged
 	StatusDelivered    = protocol.StatusDelivered
 	StatusConfirmed    = protocol.StatusConfirmed
 	StatusFailed       = protocol.StatusFailed
@@ -0,0 +1,190 @@
+package dispatch
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"shingo/protocol"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// ErrZoneHeld is returned for a lock or unlock of a zone locked by someone
+// else.
+var ErrZoneHeld = errors.New("zone is held by another owner")
+
+// LockZone locks an exclusive zone on behalf of actor, who then owns the
+// lock. Core occupies the zone's fleet mutex groups so no robot enters it,
+// and holds orders to or from the zone until it is unlocked. Locking a zone
+// the actor already holds does nothing; one held by anyone else fails with
+// ErrZoneHeld.
+func (d *Dispatcher) LockZone(zone, reason, actor string) error {
+	z, err := d.db.GetExclusiveZone(zone)
+	if err != nil {
+		return fmt.Errorf("zone %q is not exclusive", zone)
+	}
+	if z.Locked {
+		if z.LockedBy == actor {
+			return nil
+		}
+		return fmt.Errorf("zone %s is locked by %s: %w", zone, z.LockedBy, ErrZoneHeld)
+	}
+	if zl, ok := fleet.ForZone(d.backend, zone).(fleet.ZoneLocker); ok {
+		if err := zl.OccupyMutexGroups(d.stationID, z.Groups()); err != nil {
+			return fmt.Errorf("occupy mutex groups for zone %s: %w", zone, err)
+		}
+	}
+	if err := d.db.SetZoneLock(zone, true, reason, actor); err != nil {
+		return err
+	}
+	log.Printf("dispatch: zone %s locked by %s: %s", zone, actor, reason)
+	d.emitter.EmitZoneLock(z.ID, zone, true, reason, actor)
+	return nil
+}
+
+// UnlockZone releases an exclusive zone's mutex groups and unlocks it. Only
+// the lock's owner can unlock it unless override is set, which operators use
+// to take back a zone an edge left locked. Held orders are released by the
+// next ReleaseHeld.
+func (d *Dispatcher) UnlockZone(zone, actor string, override bool) error {
+	z, err := d.db.GetExclusiveZone(zone)
+	if err != nil {
+		return fmt.Errorf("zone %q is not exclusive", zone)
+	}
+	if !z.Locked {
+		return nil
+	}
+	if z.LockedBy != actor && !override {
+		return fmt.Errorf("zone %s is locked by %s: %w", zone, z.LockedBy, ErrZoneHeld)
+	}
+	if zl, ok := fleet.ForZone(d.backend, zone).(fleet.ZoneLocker); ok {
+		if err := zl.ReleaseMutexGroups(d.stationID, z.Groups()); err != nil {
+			return fmt.Errorf("release mutex groups for zone %s: %w", zone, err)
+		}
+	}
+	if err := d.db.SetZoneLock(zone, false, "", ""); err != nil {
+		return err
+	}
+	if z.LockedBy != actor {
+		log.Printf("dispatch: zone %s unlocked by %s, overriding %s", zone, actor, z.LockedBy)
+	} else {
+		log.Printf("dispatch: zone %s unlocked by %s", zone, actor)
+	}
+	d.emitter.EmitZoneLock(z.ID, zone, false, "", actor)
+	return nil
+}
+
+// HandleZoneLock locks or unlocks a zone at an edge's request, typically for
+// the length of a changeover. The edge owns its lock as "edge:<station>" and
+// never overrides another owner, so a zone an operator holds is refused. The
+// edge is told either way.
+func (d *Dispatcher) HandleZoneLock(env *protocol.Envelope, p *protocol.ZoneLock) {
+	actor := "edge:" + env.Src.Station
+	d.dbg("zone lock: station=%s zone=%s locked=%v reason=%s", env.Src.Station, p.Zone, p.Locked, p.Reason)
+	var err error
+	if p.Locked {
+		err = d.LockZone(p.Zone, p.Reason, actor)
+	} else {
+		err = d.UnlockZone(p.Zone, actor, false)
+	}
+	reply := &protocol.ZoneLockReply{StationID: env.Src.Station, Zone: p.Zone, Locked: p.Locked, Granted: err == nil}
+	if err != nil {
+		log.Printf("dispatch: zone lock from %s: %v", env.Src.Station, err)
+		reply.Detail = err.Error()
+		if z, zerr := d.db.GetExclusiveZone(p.Zone); zerr == nil && z.Locked {
+			reply.HeldBy = z.LockedBy
+		}
+	}
+	d.sendZoneLockReply(env, reply)
+}
+
+func (d *Dispatcher) sendZoneLockReply(env *protocol.Envelope, p *protocol.ZoneLockReply) {
+	stationID := env.Src.Station
+	edgeAddr := protocol.Address{Role: protocol.RoleEdge, Station: stationID}
+	reply, err := protocol.NewDataReply(protocol.SubjectZoneLockReply, d.coreAddress(), edgeAddr, env.ID, p)
+	if err != nil {
+		log.Printf("dispatch: build zone lock reply: %v", err)
+		return
+	}
+	data, err := reply.Encode()
+	if err != nil {
+		log.Printf("dispatch: encode zone lock reply: %v", err)
+		return
+	}
+	d.db.EnqueueOutbox(d.dispatchTopic, data, protocol.TypeData, stationID)
+}
+
+// lockedZone returns the first locked zone among the nodes, or "" if none is
+// locked.
+func (d *Dispatcher) lockedZone(nodes ...*store.Node) string {
+	locked, err := d.db.LockedZones()
+	if err != nil {
+		log.Printf("dispatch: list locked zones: %v", err)
+		return ""
+	}
+	for _, n := range nodes {
+		if n != nil && n.Zone != "" && locked[n.Zone] {
+			return n.Zone
+		}
+	}
+	return ""
+}
+
+// holdForZone queues an order whose leg starts or ends in a locked zone,
+// before anything is sent to the fleet. The order keeps its payload claims;
+// ReleaseHeld sends the leg on once the zone is unlocked. It reports whether
+// the order was held.
+func (d *Dispatcher) holdForZone(order *store.Order, env *protocol.Envelope, sourceNode *store.Node, nodes ...*store.Node) bool {
+	zone := d.lockedZone(append([]*store.Node{sourceNode}, nodes...)...)
+	if zone == "" {
+		return false
+	}
+	if order.PickupNode != sourceNode.Name {
+		order.PickupNode = sourceNode.Name
+		d.db.UpdateOrderPickupNode(order.ID, sourceNode.Name)
+	}
+	order.HeldZone = zone
+	d.db.UpdateOrderHeldZone(order.ID, zone)
+
+	msg := fmt.Sprintf("queued: zone %s locked", zone)
+	d.db.UpdateOrderStatus(order.ID, StatusQueued, msg)
+	d.dbg("zones: order %d %s", order.ID, msg)
+	d.sendUpdate(env, order.EdgeUUID, StatusQueued, msg)
+	return true
+}
+
+// ReleaseHeld sends orders held for a locked zone on to the fleet once that
+// zone is unlocked. An order whose leg touches another locked zone is held
+// again for that one. It returns the number of orders released.
+func (d *Dispatcher) ReleaseHeld() int {
+	orders, err := d.db.ListHeldOrders()
+	if err != nil {
+		log.Printf("dispatch: list held orders: %v", err)
+		return 0
+	}
+	if len(orders) == 0 {
+		return 0
+	}
+	locked, err := d.db.LockedZones()
+	if err != nil {
+		log.Printf("dispatch: list locked zones: %v", err)
+		return 0
+	}
+
+	n := 0
+	for _, order := range orders {
+		if locked[order.HeldZone] {
+			continue
+		}
+		zone := order.HeldZone
+		order.HeldZone = ""
+		d.db.UpdateOrderHeldZone(order.ID, "")
+		order.Status = StatusSourcing
+		d.db.UpdateOrderStatus(order.ID, StatusSourcing, fmt.Sprintf("zone %s unlocked", zone))
+		d.dbg("zones: releasing order %d held for zone %s", order.ID, zone)
+		d.redispatch(order, edgeEnvelope(order.StationID))
+		n++
+	}
+	return n
+}
@@ -1,5 +1,7 @@
 package engine
 
+import "time"
+
 // dispatchEmitter bridges the dispatch package's emitter interface to the EventBus.
 type dispatchEmitter struct {
 	bus *EventBus
@@ -52,6 +54,26 @@ func (e *dispatchEmitter) EmitOrderCompleted(orderID int64, edgeUUID, stationID
 	}})
 }
 
+func (e *dispatchEmitter) EmitSLABreached(orderID int64, edgeUUID, stationID string, sla, waited time.Duration) {
+	e.bus.Emit(Event{Type: EventSLABreached, Payload: SLABreachedEvent{
+		OrderID:   orderID,
+		EdgeUUID:  edgeUUID,
+		StationID: stationID,
+		SLA:       sla,
+		Waited:    waited,
+	}})
+}
+
+func (e *dispatchEmitter) EmitZoneLock(zoneID int64, zone string, locked bool, reason, actor string) {
+	e.bus.Emit(Event{Type: EventZoneLock, Payload: ZoneLockEvent{
+		ZoneID: zoneID,
+		Zone:   zone,
+		Locked: locked,
+		Reason: reason,
+		Actor:  actor,
+	}})
+}
+
 // pollerEmitter bridges the fleet tracker's status change events to the EventBus.
 type pollerEmitter struct {
 	bus *EventBus
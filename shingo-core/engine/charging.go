@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"errors"
+	"time"
+
+	"shingocore/charging"
+	"shingocore/dispatch"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// startCharging builds the charging manager from config and feeds it every
+// robot status refresh. It does nothing unless charging is enabled and the
+// fleet reports robots.
+func (e *Engine) startCharging() {
+	cc := e.cfg.Charging
+	if !cc.Enabled {
+		return
+	}
+	rl, ok := e.fleet.(fleet.RobotLister)
+	if !ok {
+		e.logFn("engine: charging: fleet backend %s does not report robots; charging disabled", e.fleet.Name())
+		return
+	}
+	policy := charging.Policy{
+		ChargeBelow:      cc.ChargeBelow,
+		ReleaseAbove:     cc.ReleaseAbove,
+		OpportunityBelow: cc.OpportunityBelow,
+		OpportunityIdle:  cc.OpportunityIdle,
+		ChargeTimeout:    cc.ChargeTimeout,
+	}
+	for _, sc := range cc.Shifts {
+		w, err := dispatch.ParseDowntimeWindow(sc.Days, sc.Start, sc.End)
+		if err != nil {
+			e.logFn("engine: charging: ignoring shift: %v", err)
+			continue
+		}
+		policy.Shifts = append(policy.Shifts, charging.Shift{Window: w, MinAvailable: sc.MinAvailable})
+	}
+
+	ch, ok := fleet.As[fleet.RobotCharger](e.fleet)
+	if !ok {
+		e.logFn("engine: charging: fleet backend %s cannot send robots to charge; robots charge in place", e.fleet.Name())
+	}
+	m := charging.NewManager(rl, ch, policy)
+	m.DebugLog = e.debugLog
+	m.Stations = e.chargingStations
+	m.Store = chargeStore{e.db}
+	m.OrderEnded = e.chargeOrderEnded
+	m.Action = func(a charging.Action) {
+		e.Events.Emit(Event{Type: EventRobotCharging, Payload: RobotChargingEvent{
+			VehicleID: a.VehicleID, Action: a.Action, Reason: a.Reason, Station: a.Station, Battery: a.Battery,
+		}})
+	}
+	if err := m.Load(); err != nil {
+		e.logFn("engine: charging: load robots out of dispatch: %v", err)
+	}
+	e.charging = m
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(RobotsUpdatedEvent)
+		m.Update(ev.Robots, time.Now())
+	}, EventRobotsUpdated)
+
+	e.logFn("engine: charging: below %.0f%%, release at %.0f%%, %d shifts", policy.ChargeBelow, policy.ReleaseAbove, len(policy.Shifts))
+}
+
+// chargingStations lists the enabled charging nodes.
+func (e *Engine) chargingStations() ([]charging.Station, error) {
+	nodes, err := e.db.ListNodesByType(charging.NodeType)
+	if err != nil {
+		return nil, err
+	}
+	var stations []charging.Station
+	for _, n := range nodes {
+		if n.Enabled {
+			stations = append(stations, charging.Station{Node: n.Name, VendorLocation: n.VendorLocation})
+		}
+	}
+	return stations, nil
+}
+
+// chargeOrderEnded looks up a charge order in the fleet of its robot. An
+// order the fleet does not know counts as ended.
+func (e *Engine) chargeOrderEnded(fleetName, vendorOrderID string) (bool, error) {
+	fb := e.fleet
+	if reg, ok := e.fleet.(*fleet.Registry); ok && fleetName != "" {
+		if b := reg.Get(fleetName); b != nil {
+			fb = b
+		}
+	}
+	lookup, ok := fb.(fleet.OrderLookup)
+	if !ok {
+		return false, nil
+	}
+	state, err := lookup.GetOrderState(vendorOrderID)
+	if errors.Is(err, fleet.ErrOrderNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return fb.IsTerminalState(state), nil
+}
+
+// chargeStore keeps the charging manager's robots in the database.
+type chargeStore struct {
+	db *store.DB
+}
+
+func (s chargeStore) SaveCharge(v charging.View) error {
+	return s.db.SaveChargingRobot(&store.ChargingRobot{
+		VehicleID: v.VehicleID, Reason: v.Reason, Station: v.Station, OrderID: v.OrderID, Since: v.Since,
+	})
+}
+
+func (s chargeStore) DeleteCharge(vehicleID string) error {
+	return s.db.DeleteChargingRobot(vehicleID)
+}
+
+func (s chargeStore) LoadCharges() ([]charging.View, error) {
+	saved, err := s.db.ListChargingRobots()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]charging.View, len(saved))
+	for i, r := range saved {
+		out[i] = charging.View{VehicleID: r.VehicleID, Reason: r.Reason, Station: r.Station, OrderID: r.OrderID, Since: r.Since}
+	}
+	return out, nil
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"shingocore/devices"
+	"shingocore/dispatch"
+	"shingocore/fleet"
+)
+
+// startDevices builds the door and lift manager from config and ties it to
+// order dispatch, robot movement and completion. Without configured devices it does nothing.
+func (e *Engine) startDevices() {
+	dc := e.cfg.Devices
+	if len(dc.Devices) == 0 {
+		return
+	}
+	var devs []devices.Device
+	for _, c := range dc.Devices {
+		if c.Kind != fleet.DeviceDoor && c.Kind != fleet.DeviceLift {
+			e.logFn("engine: devices: ignoring %s: unknown kind %q", c.Name, c.Kind)
+			continue
+		}
+		d := devices.Device{Name: c.Name, Kind: c.Kind}
+		for _, r := range c.Routes {
+			d.Routes = append(d.Routes, devices.Route{From: r.From, To: r.To, Area: r.Area})
+		}
+		for _, wc := range c.Maintenance {
+			w, err := dispatch.ParseDowntimeWindow(wc.Days, wc.Start, wc.End)
+			if err != nil {
+				e.logFn("engine: devices: %s: ignoring maintenance %v", c.Name, err)
+				continue
+			}
+			d.Maintenance = append(d.Maintenance, w)
+		}
+		devs = append(devs, d)
+	}
+
+	ctl, ok := e.deviceController()
+	if !ok {
+		e.logFn("engine: devices: fleet backend %s has no device control; tracking only", e.fleet.Name())
+	}
+	m := devices.NewManager(e.db, ctl, devs)
+	m.DebugLog = e.debugLog
+	m.Alert = func(a devices.Alert) {
+		e.Events.Emit(Event{Type: EventDeviceAlert, Payload: DeviceAlertEvent{
+			Device: a.Device, Kind: a.Kind, OrderID: a.OrderID, Reason: a.Reason,
+		}})
+	}
+	e.devices = m
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(OrderDispatchedEvent)
+		m.Reserve(ev.OrderID, e.deviceEndpoint(ev.SourceNode), e.deviceEndpoint(ev.DestNode))
+	}, EventOrderDispatched)
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		m.Advance(evt.Payload.(RobotsUpdatedEvent).Robots)
+	}, EventRobotsUpdated)
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(OrderStatusChangedEvent)
+		if fleet.ForOrder(e.fleet, ev.VendorOrderID).IsTerminalState(ev.NewStatus) {
+			m.Release(ev.OrderID)
+		}
+	}, EventOrderStatusChanged)
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		switch ev := evt.Payload.(type) {
+		case OrderFailedEvent:
+			m.Release(ev.OrderID)
+		case OrderCancelledEvent:
+			m.Release(ev.OrderID)
+		}
+	}, EventOrderFailed, EventOrderCancelled)
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(DeviceAlertEvent)
+		e.db.AppendAudit("order", ev.OrderID, "device_alert", "", fmt.Sprintf("%s %s: %s", ev.Kind, ev.Device, ev.Reason), "system")
+	}, EventDeviceAlert)
+
+	go e.deviceLoop(m, dc.Interval)
+	e.logFn("engine: devices: managing %d doors and lifts", len(devs))
+}
+
+// deviceController returns what operates the configured devices. With
+// several fleets it is the registry, which sends each command to the fleet
+// that reports the device, provided any fleet controls devices at all.
+func (e *Engine) deviceController() (fleet.DeviceController, bool) {
+	reg, ok := e.fleet.(*fleet.Registry)
+	if !ok {
+		dc, ok := e.fleet.(fleet.DeviceController)
+		return dc, ok
+	}
+	for _, name := range reg.Names() {
+		if _, ok := reg.Get(name).(fleet.DeviceController); ok {
+			return reg, true
+		}
+	}
+	return nil, false
+}
+
+func (e *Engine) deviceEndpoint(nodeName string) devices.Endpoint {
+	ep := devices.Endpoint{Node: nodeName}
+	if n, err := e.db.GetNodeByName(nodeName); err == nil {
+		ep.Zone = n.Zone
+	}
+	return ep
+}
+
+// deviceLoop applies maintenance windows and checks device faults.
+func (e *Engine) deviceLoop(m *devices.Manager, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	m.Check(time.Now())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopChan:
+			return
+		case now := <-ticker.C:
+			m.Check(now)
+		}
+	}
+}
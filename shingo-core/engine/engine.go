@@ -7,12 +7,18 @@ import (
 	"sync/atomic"
 	"time"
 
+	"shingocore/charging"
 	"shingocore/config"
+	"shingocore/devices"
 	"shingocore/dispatch"
+	"shingocore/eta"
 	"shingocore/fleet"
+	"shingocore/goods"
 	"shingocore/messaging"
 	"shingocore/nodestate"
+	"shingocore/scenario"
 	"shingocore/store"
+	"shingocore/telemetry"
 )
 
 type LogFunc func(format string, args ...any)
@@ -38,12 +44,23 @@ type Engine struct {
 	msgClient      *messaging.Client
 	dispatcher     *dispatch.Dispatcher
 	tracker        fleet.OrderTracker
+	devices        *devices.Manager
+	charging       *charging.Manager
+	telemetry      *telemetry.Recorder
+	goods          *goods.Manager
+	eta            *eta.Estimator
+	scenarios      *scenario.Runner
+	shifts         []telemetry.Shift
 	Events         *EventBus
 	logFn          LogFunc
 	debugLog       func(string, ...any)
 	stopChan       chan struct{}
 	stopOnce       sync.Once
+	sourcingKick   chan struct{}
 	sceneSyncing   atomic.Bool
+	reconciling    atomic.Bool
+	reconcileMu    sync.Mutex
+	lastReconcile  *FleetReconcileReport
 	fleetConnected bool
 	msgConnected   bool
 	redisConnected bool
@@ -55,16 +72,17 @@ func New(c Config) *Engine {
 		logFn = log.Printf
 	}
 	return &Engine{
-		cfg:        c.AppConfig,
-		configPath: c.ConfigPath,
-		db:         c.DB,
-		fleet:      c.Fleet,
-		nodeState:  c.NodeState,
-		msgClient:  c.MsgClient,
-		Events:     NewEventBus(),
-		logFn:      logFn,
-		debugLog:   c.DebugLog,
-		stopChan:   make(chan struct{}),
+		cfg:          c.AppConfig,
+		configPath:   c.ConfigPath,
+		db:           c.DB,
+		fleet:        c.Fleet,
+		nodeState:    c.NodeState,
+		msgClient:    c.MsgClient,
+		Events:       NewEventBus(),
+		logFn:        logFn,
+		debugLog:     c.DebugLog,
+		stopChan:     make(chan struct{}),
+		sourcingKick: make(chan struct{}, 1),
 	}
 }
 
@@ -87,6 +105,29 @@ func (e *Engine) Start() {
 		e.cfg.Messaging.StationID,
 		e.cfg.Messaging.DispatchTopic,
 	)
+	e.dispatcher.MaxSourcingWait = e.cfg.Dispatch.MaxSourcingWait
+	e.dispatcher.CreateRetry = retryPolicy(e.cfg.Dispatch.CreateRetry)
+	e.dispatcher.VendorRetry = retryPolicy(e.cfg.Dispatch.VendorRetry)
+	lc := e.cfg.Dispatch.InFlight
+	e.dispatcher.Limits = dispatch.InFlightLimits{
+		Station:      lc.Station,
+		Stations:     lc.Stations,
+		Zone:         lc.Zone,
+		Zones:        lc.Zones,
+		PayloadType:  lc.PayloadType,
+		PayloadTypes: lc.PayloadTypes,
+	}
+
+	// Orders from before a restart belong to the fleet recorded on them
+	if reg, ok := e.fleet.(*fleet.Registry); ok {
+		reg.OwnerOf = func(vendorOrderID string) string {
+			order, err := e.db.GetOrderByVendorID(vendorOrderID)
+			if err != nil {
+				return ""
+			}
+			return order.Fleet
+		}
+	}
 
 	// Initialize tracker if backend supports it
 	if tb, ok := e.fleet.(fleet.TrackingBackend); ok {
@@ -97,6 +138,23 @@ func (e *Engine) Start() {
 	// Wire event handlers
 	e.wireEventHandlers()
 
+	// Open doors and call lifts on the routes of dispatched orders
+	e.startDevices()
+	e.startCharging()
+	e.startTelemetry()
+	e.startGoods()
+	e.startETA()
+	e.startScenarios()
+
+	// Repair orders a crash left mid-dispatch
+	if r := e.dispatcher.ReconcileStuckOrders(); r.Resumed+r.Failed+r.Unclaimed > 0 {
+		e.logFn("engine: reconciled stuck orders: %d resumed, %d failed, %d payload claims released", r.Resumed, r.Failed, r.Unclaimed)
+	}
+
+	// Apply vendor orders that finished while core was down before the
+	// tracker starts, so the two never race on the same order
+	e.startupFleetReconcile()
+
 	// Load active vendor orders into tracker
 	e.loadActiveOrders()
 
@@ -105,6 +163,18 @@ func (e *Engine) Start() {
 		e.tracker.Start()
 	}
 
+	// Reload orders still waiting for stock
+	if n := e.dispatcher.RestoreWaiting(); n > 0 {
+		e.logFn("engine: restored %d orders into sourcing backlog", n)
+	}
+	// Resume fleet retries whose backoff timers died with the old process
+	if n := e.dispatcher.RestoreRetries(); n > 0 {
+		e.logFn("engine: restored %d orders waiting to retry", n)
+	}
+	go e.sourcingBacklogLoop()
+	go e.priorityEscalationLoop()
+	go e.scheduleLoop()
+
 	// Emit initial connection status
 	e.checkConnectionStatus()
 
@@ -134,6 +204,11 @@ func (e *Engine) NodeState() *nodestate.Manager     { return e.nodeState }
 func (e *Engine) Tracker() fleet.OrderTracker       { return e.tracker }
 func (e *Engine) Fleet() fleet.Backend              { return e.fleet }
 func (e *Engine) MsgClient() *messaging.Client      { return e.msgClient }
+func (e *Engine) Devices() *devices.Manager         { return e.devices }
+func (e *Engine) Charging() *charging.Manager       { return e.charging }
+func (e *Engine) Telemetry() *telemetry.Recorder    { return e.telemetry }
+func (e *Engine) Goods() *goods.Manager             { return e.goods }
+func (e *Engine) Scenarios() *scenario.Runner       { return e.scenarios }
 
 func (e *Engine) checkConnectionStatus() {
 	// Fleet
@@ -201,21 +276,34 @@ func (e *Engine) loadActiveOrders() {
 	if e.tracker == nil {
 		return
 	}
-	ids, err := e.db.ListDispatchedVendorOrderIDs()
+	states, err := e.db.ListDispatchedVendorStates()
 	if err != nil {
 		e.logFn("engine: load active orders: %v", err)
 		return
 	}
-	for _, id := range ids {
-		e.tracker.Track(id)
+	// Resume from the last recorded vendor state, so the first poll reports
+	// only what changed while core was down.
+	seeded, canSeed := e.tracker.(fleet.SeededTracker)
+	for id, state := range states {
+		if canSeed {
+			seeded.TrackFrom(id, state)
+		} else {
+			e.tracker.Track(id)
+		}
 	}
-	if len(ids) > 0 {
-		e.logFn("engine: loaded %d active vendor orders into tracker", len(ids))
+	if len(states) > 0 {
+		e.logFn("engine: loaded %d active vendor orders into tracker", len(states))
 	}
 }
 
-// ReconfigureFleet applies fleet config changes live.
+// ReconfigureFleet applies fleet config changes live. The top-level rds
+// section only configures a single backend; each of several fleets keeps
+// its own settings from fleet.fleets.
 func (e *Engine) ReconfigureFleet() {
+	if _, ok := e.fleet.(*fleet.Registry); ok {
+		e.logFn("engine: fleet reconfigure skipped: %s reads its settings per fleet", e.fleet.Name())
+		return
+	}
 	e.fleet.Reconfigure(fleet.ReconfigureParams{
 		BaseURL: e.cfg.RDS.BaseURL,
 		Timeout: e.cfg.RDS.Timeout,
@@ -366,6 +454,105 @@ func (e *Engine) SceneSync() (int, int, int, error) {
 	return total, created, deleted, nil
 }
 
+// KickSourcing asks the sourcing backlog to re-evaluate waiting orders soon.
+// Calls coalesce, so it is cheap to call on every inventory change or
+// whenever an order leaves flight.
+func (e *Engine) KickSourcing() {
+	select {
+	case e.sourcingKick <- struct{}{}:
+	default:
+	}
+}
+
+// sourcingBacklogLoop releases orders held for locked zones or queued by
+// in-flight caps and re-evaluates orders waiting for stock, on a timer and
+// whenever inventory, nodes, zone locks or in-flight orders change.
+func (e *Engine) sourcingBacklogLoop() {
+	interval := e.cfg.Dispatch.SourcingRetryInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopChan:
+			return
+		case <-ticker.C:
+		case <-e.sourcingKick:
+		}
+		if n := e.dispatcher.ReleaseHeld(); n > 0 {
+			e.dbg("released %d orders held for locked zones", n)
+		}
+		if n := e.dispatcher.ReleaseQueued(); n > 0 {
+			e.dbg("released %d queued orders", n)
+		}
+		e.dispatcher.RetryWaiting()
+	}
+}
+
+func retryPolicy(rc config.RetryConfig) dispatch.RetryPolicy {
+	return dispatch.RetryPolicy{MaxAttempts: rc.MaxAttempts, Backoff: rc.Backoff, MaxBackoff: rc.MaxBackoff}
+}
+
+// priorityEscalationLoop periodically raises the priority of orders waiting
+// for a robot and reports SLA breaches.
+func (e *Engine) priorityEscalationLoop() {
+	ec := e.cfg.Dispatch.Escalation
+	if ec.Interval <= 0 {
+		return
+	}
+	policy := dispatch.EscalationPolicy{
+		AgeStep:       ec.AgeStep,
+		DefaultSLA:    ec.DefaultSLA,
+		StationSLA:    ec.StationSLA,
+		LoadTypeBoost: ec.LoadTypeBoost,
+		BreachBoost:   ec.BreachBoost,
+		MaxPriority:   ec.MaxPriority,
+	}
+	ticker := time.NewTicker(ec.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopChan:
+			return
+		case now := <-ticker.C:
+			if n := e.dispatcher.EscalatePriorities(policy, now); n > 0 {
+				e.dbg("escalated priority of %d waiting orders", n)
+			}
+		}
+	}
+}
+
+// scheduleLoop raises orders for due schedules, honouring downtime windows.
+func (e *Engine) scheduleLoop() {
+	sc := e.cfg.Dispatch.Schedules
+	if sc.Interval <= 0 {
+		return
+	}
+	var downtime []dispatch.DowntimeWindow
+	for _, dc := range sc.Downtime {
+		w, err := dispatch.ParseDowntimeWindow(dc.Days, dc.Start, dc.End)
+		if err != nil {
+			e.logFn("engine: schedules: ignoring %v", err)
+			continue
+		}
+		downtime = append(downtime, w)
+	}
+	ticker := time.NewTicker(sc.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopChan:
+			return
+		case now := <-ticker.C:
+			if n := e.dispatcher.RunSchedules(now, downtime); n > 0 {
+				e.dbg("raised %d scheduled orders", n)
+			}
+		}
+	}
+}
+
 // robotRefreshLoop polls robot status every 2 seconds and emits EventRobotsUpdated.
 func (e *Engine) robotRefreshLoop() {
 	ticker := time.NewTicker(2 * time.Second)
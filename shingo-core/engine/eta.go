@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"log"
+	"time"
+
+	"shingo/protocol"
+	"shingocore/dispatch"
+	"shingocore/eta"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// startETA builds the ETA estimator, resumes tracking orders already on a
+// robot and keeps estimates current with every robot status refresh. It does
+// nothing unless ETAs are enabled.
+func (e *Engine) startETA() {
+	ec := e.cfg.ETA
+	if !ec.Enabled {
+		return
+	}
+	est := eta.NewEstimator(e.db, ec.Speed, ec.Refresh, ec.Change)
+	est.DebugLog = e.debugLog
+	est.Send = e.sendETA
+	e.eta = est
+
+	if orders, err := e.db.ListActiveOrders(); err == nil {
+		now := time.Now()
+		for _, o := range orders {
+			if o.RobotID != "" && (o.Status == dispatch.StatusDispatched || o.Status == dispatch.StatusInTransit) {
+				est.Track(o, o.RobotID, now)
+			}
+		}
+	}
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(OrderStatusChangedEvent)
+		fb := fleet.ForOrder(e.fleet, ev.VendorOrderID)
+		switch {
+		case fb.MapState(ev.NewStatus) == dispatch.StatusDelivered:
+			est.Complete(ev.OrderID, time.Now())
+		case fb.IsTerminalState(ev.NewStatus):
+			est.Drop(ev.OrderID)
+		case ev.RobotID != "":
+			// Covers legs whose robot was assigned without a waybill, such as
+			// the second leg of a staged delivery.
+			if order, err := e.db.GetOrder(ev.OrderID); err == nil {
+				est.Track(order, ev.RobotID, time.Now())
+			}
+		}
+	}, EventOrderStatusChanged)
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		switch ev := evt.Payload.(type) {
+		case OrderFailedEvent:
+			est.Drop(ev.OrderID)
+		case OrderCancelledEvent:
+			est.Drop(ev.OrderID)
+		}
+	}, EventOrderFailed, EventOrderCancelled)
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(RobotsUpdatedEvent)
+		est.Update(ev.Robots, time.Now())
+	}, EventRobotsUpdated)
+
+	e.logFn("engine: eta: robots at %.1f m/s without history, refresh every %s", ec.Speed, ec.Refresh)
+}
+
+// trackETA starts estimating an order the fleet has assigned a robot to and
+// returns the ETA for its waybill, or "" without an estimate.
+func (e *Engine) trackETA(order *store.Order, robotID string) string {
+	if e.eta == nil {
+		return ""
+	}
+	return formatETA(e.eta.Track(order, robotID, time.Now()))
+}
+
+// orderETA returns an order's current ETA for an update message, or "".
+func (e *Engine) orderETA(orderID int64) string {
+	if e.eta == nil {
+		return ""
+	}
+	return formatETA(e.eta.ETA(orderID))
+}
+
+func formatETA(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// sendETA sends a refreshed ETA to ShinGo Edge as an order update.
+func (e *Engine) sendETA(u eta.Update) {
+	order, err := e.db.GetOrder(u.OrderID)
+	if err != nil {
+		e.dbg("eta: get order %d: %v", u.OrderID, err)
+		return
+	}
+	coreAddr := protocol.Address{Role: protocol.RoleCore, Station: e.cfg.Messaging.StationID}
+	edgeAddr := protocol.Address{Role: protocol.RoleEdge, Station: order.StationID}
+	reply, err := protocol.NewEnvelope(protocol.TypeOrderUpdate, coreAddr, edgeAddr, &protocol.OrderUpdate{
+		OrderUUID: order.EdgeUUID,
+		Status:    order.Status,
+		Detail:    "eta updated",
+		ETA:       formatETA(u.ETA),
+	})
+	if err != nil {
+		log.Printf("engine: build eta update: %v", err)
+		return
+	}
+	data, err := reply.Encode()
+	if err != nil {
+		log.Printf("engine: encode eta update: %v", err)
+		return
+	}
+	if err := e.db.EnqueueOutbox(e.cfg.Messaging.DispatchTopic, data, "order.update", order.StationID); err != nil {
+		e.dbg("EnqueueOutbox eta update error (silently dropped): %v", err)
+	}
+}
@@ -1,6 +1,10 @@
 package engine
 
-import "shingocore/fleet"
+import (
+	"time"
+
+	"shingocore/fleet"
+)
 
 const (
 	EventOrderReceived EventType = iota + 1
@@ -19,6 +23,11 @@ const (
 	EventRedisConnected
 	EventRedisDisconnected
 	EventRobotsUpdated
+	EventSLABreached
+	EventDeviceAlert
+	EventZoneLock
+	EventRobotCharging
+	EventGoodsMismatch
 )
 
 // --- Event payloads ---
@@ -69,6 +78,14 @@ type OrderCancelledEvent struct {
 	Reason   string
 }
 
+type SLABreachedEvent struct {
+	OrderID   int64
+	EdgeUUID  string
+	StationID string
+	SLA       time.Duration
+	Waited    time.Duration
+}
+
 type PayloadChangedEvent struct {
 	NodeID          int64
 	NodeName        string
@@ -100,3 +117,37 @@ type ConnectionEvent struct {
 type RobotsUpdatedEvent struct {
 	Robots []fleet.RobotStatus
 }
+
+// DeviceAlertEvent reports a door or lift that blocks an active order.
+type DeviceAlertEvent struct {
+	Device  string
+	Kind    string
+	OrderID int64
+	Reason  string
+}
+
+// RobotChargingEvent reports a robot sent to charge or returned to service.
+type RobotChargingEvent struct {
+	VehicleID string
+	Action    string // "charge" or "release"
+	Reason    string
+	Station   string
+	Battery   float64
+}
+
+// ZoneLockEvent reports an exclusive zone being locked or unlocked.
+type ZoneLockEvent struct {
+	ZoneID int64
+	Zone   string
+	Locked bool
+	Reason string
+	Actor  string
+}
+
+// GoodsMismatchEvent reports goods on a robot that no active order accounts
+// for.
+type GoodsMismatchEvent struct {
+	VehicleID string
+	GoodsID   string
+	Reason    string
+}
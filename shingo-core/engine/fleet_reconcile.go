@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"shingocore/charging"
+	"shingocore/dispatch"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+const (
+	fleetReconcilePageSize = 100
+	fleetReconcileMaxPages = 20
+)
+
+// Fleet mismatch kinds.
+const (
+	MismatchOrphan            = "orphan"              // active vendor order with no ShinGo order behind it
+	MismatchMissedTransition  = "missed_transition"   // vendor order finished without ShinGo seeing it
+	MismatchUnknownExternalID = "unknown_external_id" // active vendor order naming a ShinGo order that does not exist
+	MismatchMissing           = "missing"             // active ShinGo order the fleet has no vendor order for
+)
+
+// Errors ReconcileFleet returns without a report.
+var (
+	ErrReconcileUnsupported = errors.New("fleet backend does not support order listing")
+	ErrReconcileBusy        = errors.New("fleet reconciliation already in progress")
+)
+
+// FleetMismatch is one disagreement between ShinGo's orders and the fleet's.
+type FleetMismatch struct {
+	Kind          string `json:"kind"`
+	VendorOrderID string `json:"vendor_order_id"`
+	ExternalID    string `json:"external_id"`
+	VendorState   string `json:"vendor_state"`
+	OrderID       int64  `json:"order_id,omitempty"`
+	OrderStatus   string `json:"order_status,omitempty"`
+	Detail        string `json:"detail"`
+	Applied       bool   `json:"applied"`
+}
+
+// FleetReconcileReport is the outcome of one fleet reconciliation pass.
+type FleetReconcileReport struct {
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Scanned    int             `json:"scanned"`
+	Applied    int             `json:"applied"`
+	Mismatches []FleetMismatch `json:"mismatches"`
+	Error      string          `json:"error,omitempty"`
+}
+
+func (r *FleetReconcileReport) add(m FleetMismatch) {
+	r.Mismatches = append(r.Mismatches, m)
+	if m.Applied {
+		r.Applied++
+	}
+}
+
+// ReconcileFleet compares ShinGo's orders with the fleet's order list. It pages
+// through the fleet's recent orders, then looks up by external ID any active
+// ShinGo order the listing did not cover. Terminal states ShinGo missed are
+// applied through the normal vendor status path; everything else is reported.
+// Finished vendor orders ShinGo never owned are history, not mismatches.
+func (e *Engine) ReconcileFleet() (*FleetReconcileReport, error) {
+	lister, ok := e.fleet.(fleet.OrderLister)
+	if !ok {
+		return nil, ErrReconcileUnsupported
+	}
+	if !e.reconciling.CompareAndSwap(false, true) {
+		return nil, ErrReconcileBusy
+	}
+	defer e.reconciling.Store(false)
+
+	report := &FleetReconcileReport{StartedAt: time.Now(), Mismatches: []FleetMismatch{}}
+	err := e.reconcileFleet(lister, report)
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	e.reconcileMu.Lock()
+	e.lastReconcile = report
+	e.reconcileMu.Unlock()
+	return report, err
+}
+
+// LastFleetReconcile returns the most recent reconciliation report, or nil.
+func (e *Engine) LastFleetReconcile() *FleetReconcileReport {
+	e.reconcileMu.Lock()
+	defer e.reconcileMu.Unlock()
+	return e.lastReconcile
+}
+
+func (e *Engine) reconcileFleet(lister fleet.OrderLister, report *FleetReconcileReport) error {
+	seen := make(map[string]bool)
+	for page := 1; page <= fleetReconcileMaxPages; page++ {
+		orders, err := lister.ListOrders(page, fleetReconcilePageSize)
+		if err != nil {
+			return fmt.Errorf("list fleet orders page %d: %w", page, err)
+		}
+		for _, vo := range orders {
+			seen[vo.VendorOrderID] = true
+			report.Scanned++
+			e.reconcileVendorOrder(vo, report)
+		}
+		if len(orders) < fleetReconcilePageSize {
+			break
+		}
+	}
+
+	ids, err := e.db.ListDispatchedVendorOrderIDs()
+	if err != nil {
+		return fmt.Errorf("list active orders: %w", err)
+	}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		order, err := e.db.GetOrderByVendorID(id)
+		if err != nil {
+			continue
+		}
+		vo, err := lister.GetOrderByExternalID(order.EdgeUUID)
+		switch {
+		case errors.Is(err, fleet.ErrOrderNotFound):
+			report.add(FleetMismatch{
+				Kind:          MismatchMissing,
+				VendorOrderID: id,
+				ExternalID:    order.EdgeUUID,
+				OrderID:       order.ID,
+				OrderStatus:   order.Status,
+				Detail:        "fleet has no order for this external ID",
+			})
+		case err != nil:
+			e.logFn("engine: fleet reconcile: look up external ID %s: %v", order.EdgeUUID, err)
+		case vo.VendorOrderID != id:
+			report.add(FleetMismatch{
+				Kind:          MismatchMissing,
+				VendorOrderID: id,
+				ExternalID:    order.EdgeUUID,
+				VendorState:   vo.State,
+				OrderID:       order.ID,
+				OrderStatus:   order.Status,
+				Detail:        fmt.Sprintf("fleet external ID maps to vendor order %s", vo.VendorOrderID),
+			})
+		default:
+			report.Scanned++
+			e.reconcileVendorOrder(vo, report)
+		}
+	}
+	return nil
+}
+
+// reconcileVendorOrder checks one fleet order against ShinGo.
+func (e *Engine) reconcileVendorOrder(vo fleet.VendorOrder, report *FleetReconcileReport) {
+	terminal := fleet.ForOrder(e.fleet, vo.VendorOrderID).IsTerminalState(vo.State)
+
+	order, err := e.db.GetOrderByVendorID(vo.VendorOrderID)
+	if err != nil {
+		if terminal || charging.IsChargeOrder(vo.VendorOrderID) {
+			return
+		}
+		m := FleetMismatch{
+			Kind:          MismatchOrphan,
+			VendorOrderID: vo.VendorOrderID,
+			ExternalID:    vo.ExternalID,
+			VendorState:   vo.State,
+			Detail:        "no ShinGo order for this vendor order",
+		}
+		if vo.ExternalID != "" {
+			if owner, err := e.db.GetOrderByUUID(vo.ExternalID); err == nil {
+				m.OrderID = owner.ID
+				m.OrderStatus = owner.Status
+				m.Detail = fmt.Sprintf("ShinGo order %d tracks vendor order %q instead", owner.ID, owner.VendorOrderID)
+			} else {
+				m.Kind = MismatchUnknownExternalID
+				m.Detail = "external ID matches no ShinGo order"
+			}
+		}
+		report.add(m)
+		return
+	}
+
+	if !terminal || vo.State == order.VendorState || orderClosed(order) {
+		return
+	}
+	e.logFn("engine: fleet reconcile: order %d missed vendor state %s -> %s", order.ID, order.VendorState, vo.State)
+	e.Events.Emit(Event{Type: EventOrderStatusChanged, Payload: OrderStatusChangedEvent{
+		OrderID:       order.ID,
+		VendorOrderID: vo.VendorOrderID,
+		OldStatus:     order.VendorState,
+		NewStatus:     vo.State,
+		RobotID:       vo.RobotID,
+		Detail:        "fleet reconciliation",
+	}})
+	report.add(FleetMismatch{
+		Kind:          MismatchMissedTransition,
+		VendorOrderID: vo.VendorOrderID,
+		ExternalID:    vo.ExternalID,
+		VendorState:   vo.State,
+		OrderID:       order.ID,
+		OrderStatus:   order.Status,
+		Detail:        fmt.Sprintf("vendor state %s -> %s applied", order.VendorState, vo.State),
+		Applied:       true,
+	})
+}
+
+// orderClosed reports whether ShinGo is done with an order.
+func orderClosed(order *store.Order) bool {
+	switch order.Status {
+	case dispatch.StatusConfirmed, dispatch.StatusFailed, dispatch.StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// startupFleetReconcile runs a reconciliation pass before the tracker is
+// loaded. Missed transitions are applied synchronously, so the tracker is
+// seeded from vendor states that already include them.
+func (e *Engine) startupFleetReconcile() {
+	if _, ok := e.fleet.(fleet.OrderLister); !ok {
+		return
+	}
+	report, err := e.ReconcileFleet()
+	if err != nil {
+		e.logFn("engine: fleet reconcile: %v", err)
+		return
+	}
+	if len(report.Mismatches) > 0 {
+		e.logFn("engine: fleet reconcile: %d orders scanned, %d mismatches, %d transitions applied",
+			report.Scanned, len(report.Mismatches), report.Applied)
+	}
+}
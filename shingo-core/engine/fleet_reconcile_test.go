@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shingocore/config"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// fakeLister is a fleet backend whose order list and external ID lookups
+// are set by the test.
+type fakeLister struct {
+	orders     []fleet.VendorOrder
+	byExternal map[string]fleet.VendorOrder
+	lookupErr  error
+}
+
+func (f *fakeLister) CreateTransportOrder(req fleet.TransportOrderRequest) (fleet.TransportOrderResult, error) {
+	return fleet.TransportOrderResult{}, fmt.Errorf("fake: not supported")
+}
+func (f *fakeLister) CancelOrder(vendorOrderID string) error                    { return nil }
+func (f *fakeLister) SetOrderPriority(vendorOrderID string, priority int) error { return nil }
+func (f *fakeLister) Ping() error                                               { return nil }
+func (f *fakeLister) Name() string                                              { return "fake" }
+func (f *fakeLister) MapState(vendorState string) string                        { return "dispatched" }
+func (f *fakeLister) IsTerminalState(vendorState string) bool                   { return vendorState == "FINISHED" }
+func (f *fakeLister) Reconfigure(cfg fleet.ReconfigureParams)                   {}
+
+func (f *fakeLister) ListOrders(page, size int) ([]fleet.VendorOrder, error) {
+	if page > 1 {
+		return nil, nil
+	}
+	return f.orders, nil
+}
+
+func (f *fakeLister) GetOrderByExternalID(externalID string) (fleet.VendorOrder, error) {
+	if f.lookupErr != nil {
+		return fleet.VendorOrder{}, f.lookupErr
+	}
+	vo, ok := f.byExternal[externalID]
+	if !ok {
+		return fleet.VendorOrder{}, fleet.ErrOrderNotFound
+	}
+	return vo, nil
+}
+
+func testEngine(t *testing.T, backend fleet.Backend) (*Engine, *store.DB) {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	db, err := store.Open(&config.DatabaseConfig{
+		Driver: "sqlite",
+		SQLite: config.SQLiteConfig{Path: dbPath},
+	})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		os.Remove(dbPath)
+	})
+	e := New(Config{DB: db, Fleet: backend, LogFunc: t.Logf})
+	return e, db
+}
+
+// dispatchedOrder creates an order the fleet is running as vendorID.
+func dispatchedOrder(t *testing.T, db *store.DB, uuid, vendorID string) *store.Order {
+	t.Helper()
+	o := &store.Order{EdgeUUID: uuid, StationID: "line-1", OrderType: "retrieve", Status: "dispatched", DeliveryNode: "LINE1-IN"}
+	if err := db.CreateOrder(o); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	if err := db.UpdateOrderVendor(o.ID, vendorID, "RUNNING", ""); err != nil {
+		t.Fatalf("update vendor: %v", err)
+	}
+	return o
+}
+
+func statusEvents(e *Engine) *[]OrderStatusChangedEvent {
+	var got []OrderStatusChangedEvent
+	e.Events.SubscribeTypes(func(evt Event) {
+		got = append(got, evt.Payload.(OrderStatusChangedEvent))
+	}, EventOrderStatusChanged)
+	return &got
+}
+
+func TestReconcileFleet_FoundAppliesMissedTransition(t *testing.T) {
+	backend := &fakeLister{}
+	e, db := testEngine(t, backend)
+	o := dispatchedOrder(t, db, "uuid-1", "v-1")
+	// Not in the listing, so it is found by external ID
+	backend.byExternal = map[string]fleet.VendorOrder{
+		"uuid-1": {VendorOrderID: "v-1", ExternalID: "uuid-1", State: "FINISHED", RobotID: "AMR-1"},
+	}
+	events := statusEvents(e)
+
+	report, err := e.ReconcileFleet()
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if report.Applied != 1 || len(report.Mismatches) != 1 {
+		t.Fatalf("applied=%d mismatches=%v, want 1 applied", report.Applied, report.Mismatches)
+	}
+	m := report.Mismatches[0]
+	if m.Kind != MismatchMissedTransition || m.OrderID != o.ID {
+		t.Errorf("mismatch = %+v, want missed transition for order %d", m, o.ID)
+	}
+	if len(*events) != 1 || (*events)[0].NewStatus != "FINISHED" || (*events)[0].OldStatus != "RUNNING" {
+		t.Errorf("events = %+v, want RUNNING -> FINISHED", *events)
+	}
+}
+
+func TestReconcileFleet_NotFoundReportsMissing(t *testing.T) {
+	backend := &fakeLister{}
+	e, db := testEngine(t, backend)
+	o := dispatchedOrder(t, db, "uuid-2", "v-2")
+	events := statusEvents(e)
+
+	report, err := e.ReconcileFleet()
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if len(report.Mismatches) != 1 {
+		t.Fatalf("mismatches = %v, want 1", report.Mismatches)
+	}
+	m := report.Mismatches[0]
+	if m.Kind != MismatchMissing || m.OrderID != o.ID || m.Applied {
+		t.Errorf("mismatch = %+v, want unapplied missing for order %d", m, o.ID)
+	}
+	if len(*events) != 0 {
+		t.Errorf("events = %+v, want none", *events)
+	}
+}
+
+func TestReconcileFleet_LookupErrorIsNotApplied(t *testing.T) {
+	backend := &fakeLister{lookupErr: errors.New("fleet unreachable")}
+	e, db := testEngine(t, backend)
+	dispatchedOrder(t, db, "uuid-3", "v-3")
+	events := statusEvents(e)
+
+	report, err := e.ReconcileFleet()
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if len(report.Mismatches) != 0 || report.Applied != 0 {
+		t.Errorf("report = %+v, want no mismatches for a failed lookup", report)
+	}
+	if len(*events) != 0 {
+		t.Errorf("events = %+v, want none", *events)
+	}
+}
+
+func TestReconcileFleet_Unsupported(t *testing.T) {
+	// Embedding only the Backend interface hides the listing methods
+	e, _ := testEngine(t, struct{ fleet.Backend }{&fakeLister{}})
+	if _, err := e.ReconcileFleet(); !errors.Is(err, ErrReconcileUnsupported) {
+		t.Errorf("err = %v, want ErrReconcileUnsupported", err)
+	}
+}
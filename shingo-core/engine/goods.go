@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"shingocore/fleet"
+	"shingocore/goods"
+)
+
+// startGoods binds the payloads of retrieve and store orders to the robots
+// carrying them and checks robot goods on every status refresh. It does
+// nothing unless goods binding is enabled and the fleet supports it.
+func (e *Engine) startGoods() {
+	gc := e.cfg.Goods
+	if !gc.Enabled {
+		return
+	}
+	gb, ok := fleet.As[fleet.GoodsBinder](e.fleet)
+	if !ok {
+		e.logFn("engine: goods: fleet backend %s cannot bind goods; goods binding disabled", e.fleet.Name())
+		return
+	}
+	m := goods.NewManager(e.db, gb, gc.Container)
+	m.DebugLog = e.debugLog
+	m.Mismatch = func(mm goods.Mismatch) {
+		e.Events.Emit(Event{Type: EventGoodsMismatch, Payload: GoodsMismatchEvent{
+			VehicleID: mm.VehicleID, GoodsID: mm.GoodsID, Reason: mm.Reason,
+		}})
+	}
+	e.goods = m
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(OrderStatusChangedEvent)
+		if fleet.ForOrder(e.fleet, ev.VendorOrderID).IsTerminalState(ev.NewStatus) {
+			m.Unbind(ev.OrderID)
+			return
+		}
+		if ev.RobotID == "" {
+			return
+		}
+		order, err := e.db.GetOrder(ev.OrderID)
+		if err != nil {
+			e.logFn("engine: goods: get order %d: %v", ev.OrderID, err)
+			return
+		}
+		m.Bind(order, ev.RobotID)
+	}, EventOrderStatusChanged)
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		switch ev := evt.Payload.(type) {
+		case OrderFailedEvent:
+			m.Unbind(ev.OrderID)
+		case OrderCancelledEvent:
+			m.Unbind(ev.OrderID)
+		}
+	}, EventOrderFailed, EventOrderCancelled)
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(RobotsUpdatedEvent)
+		m.Check(ev.Robots, time.Now())
+	}, EventRobotsUpdated)
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(GoodsMismatchEvent)
+		if payloadID, ok := goods.PayloadID(ev.GoodsID); ok {
+			e.db.AppendAudit("payload", payloadID, "goods_mismatch", "", fmt.Sprintf("on robot %s: %s", ev.VehicleID, ev.Reason), "system")
+		}
+	}, EventGoodsMismatch)
+
+	e.logFn("engine: goods: binding payloads to robot containers")
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"shingo/protocol"
+	"shingocore/dispatch"
+	"shingocore/fleet"
+	"shingocore/scenario"
+)
+
+// startScenarios sets up the scenario runner and feeds it ShinGo events and
+// robot refreshes. It does nothing unless scenarios are enabled and the
+// fleet can change simulated robot state.
+func (e *Engine) startScenarios() {
+	if !e.cfg.Scenarios.Enabled {
+		return
+	}
+	sim, ok := fleet.As[fleet.SimStateSetter](e.fleet)
+	if !ok {
+		e.logFn("engine: scenarios: fleet backend %s has no simulated robots; scenarios disabled", e.fleet.Name())
+		return
+	}
+	r := scenario.NewRunner(sim, e.injectScenarioOrder)
+	r.DebugLog = e.debugLog
+	e.scenarios = r
+
+	e.Events.Subscribe(func(evt Event) {
+		if ev, ok := evt.Payload.(RobotsUpdatedEvent); ok {
+			r.Robots(ev.Robots)
+			return
+		}
+		name, detail := describeEvent(evt)
+		r.Record(eventOrderID(evt), name, detail)
+	})
+
+	e.logFn("engine: scenarios: runner ready")
+}
+
+// injectScenarioOrder raises a scenario's order through the normal order
+// request path, as if an edge station had sent it. The order is marked as
+// core's own, so core confirms it on delivery.
+func (e *Engine) injectScenarioOrder(req *protocol.OrderRequest) (int64, error) {
+	req.OrderUUID = dispatch.CoreOrderPrefix + req.OrderUUID
+	env := &protocol.Envelope{
+		Src: protocol.Address{Role: protocol.RoleEdge, Station: scenario.StationID},
+		Dst: protocol.Address{Role: protocol.RoleCore, Station: e.cfg.Messaging.StationID},
+	}
+	e.dispatcher.HandleOrderRequest(env, req)
+	order, err := e.db.GetOrderByUUID(req.OrderUUID)
+	if err != nil {
+		return 0, errors.New("order rejected (check payload type and nodes)")
+	}
+	return order.ID, nil
+}
+
+// eventOrderID returns the order an event is about, from its payload's
+// OrderID field, or 0 if it has none.
+func eventOrderID(evt Event) int64 {
+	v := reflect.Indirect(reflect.ValueOf(evt.Payload))
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+	if f := v.FieldByName("OrderID"); f.IsValid() && f.Kind() == reflect.Int64 {
+		return f.Int()
+	}
+	return 0
+}
+
+// describeEvent names an event after its payload type and prints the
+// payload's fields, e.g. "OrderFailed", "{OrderID:7 ErrorCode:fleet_failed ...}".
+func describeEvent(evt Event) (string, string) {
+	t := reflect.TypeOf(evt.Payload)
+	if t == nil {
+		return fmt.Sprintf("event %d", evt.Type), ""
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return strings.TrimSuffix(t.Name(), "Event"), fmt.Sprintf("%+v", evt.Payload)
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"time"
+
+	"shingocore/dispatch"
+	"shingocore/telemetry"
+)
+
+// startTelemetry records every robot status refresh and prunes old history.
+// It does nothing unless telemetry is enabled.
+func (e *Engine) startTelemetry() {
+	tc := e.cfg.Telemetry
+	if !tc.Enabled {
+		return
+	}
+	var shifts []telemetry.Shift
+	for _, sc := range tc.Shifts {
+		w, err := dispatch.ParseDowntimeWindow(sc.Days, sc.Start, sc.End)
+		if err != nil {
+			e.logFn("engine: telemetry: ignoring shift %s: %v", sc.Name, err)
+			continue
+		}
+		shifts = append(shifts, telemetry.Shift{Name: sc.Name, Window: w})
+	}
+	e.shifts = shifts
+
+	r := telemetry.NewRecorder(e.db, tc.SampleInterval)
+	r.DebugLog = e.debugLog
+	if err := r.Resume(); err != nil {
+		e.logFn("engine: telemetry: close previous state intervals: %v", err)
+	}
+	e.telemetry = r
+
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(RobotsUpdatedEvent)
+		r.Record(ev.Robots, time.Now())
+	}, EventRobotsUpdated)
+
+	if tc.Retention > 0 {
+		go e.telemetryPruneLoop(r, tc.Retention)
+	}
+	e.logFn("engine: telemetry: sampling every %s, keeping %s, %d shifts", tc.SampleInterval, tc.Retention, len(shifts))
+}
+
+// telemetryPruneLoop deletes robot history past the retention period hourly.
+func (e *Engine) telemetryPruneLoop(r *telemetry.Recorder, retention time.Duration) {
+	r.Prune(time.Now(), retention)
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopChan:
+			return
+		case now := <-ticker.C:
+			r.Prune(now, retention)
+		}
+	}
+}
+
+// Utilization reports how each robot spent the shifts starting on the given
+// day.
+func (e *Engine) Utilization(day time.Time) ([]telemetry.Usage, error) {
+	periods := telemetry.Periods(day, e.shifts)
+	if len(periods) == 0 {
+		return nil, nil
+	}
+	from, to := periods[0].From, periods[0].To
+	for _, p := range periods[1:] {
+		if p.From.Before(from) {
+			from = p.From
+		}
+		if p.To.After(to) {
+			to = p.To
+		}
+	}
+	intervals, err := e.db.ListRobotIntervals(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return telemetry.Utilization(intervals, periods, time.Now()), nil
+}
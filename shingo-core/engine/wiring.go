@@ -7,6 +7,7 @@ import (
 
 	"shingo/protocol"
 	"shingocore/dispatch"
+	"shingocore/fleet"
 	"shingocore/store"
 )
 
@@ -34,11 +35,12 @@ func (e *Engine) wireEventHandlers() {
 		e.handleVendorStatusChange(ev)
 	}, EventOrderStatusChanged)
 
-	// When an order fails, log it
+	// When an order fails, log it and free its in-flight slot
 	e.Events.SubscribeTypes(func(evt Event) {
 		ev := evt.Payload.(OrderFailedEvent)
 		e.logFn("engine: order %d failed: %s - %s", ev.OrderID, ev.ErrorCode, ev.Detail)
 		e.db.AppendAudit("order", ev.OrderID, "failed", "", ev.Detail, "system")
+		e.KickSourcing()
 	}, EventOrderFailed)
 
 	// When an order is completed, update inventory and audit
@@ -49,11 +51,12 @@ func (e *Engine) wireEventHandlers() {
 		e.handleOrderCompleted(ev)
 	}, EventOrderCompleted)
 
-	// When an order is cancelled, audit it
+	// When an order is cancelled, audit it and free its in-flight slot
 	e.Events.SubscribeTypes(func(evt Event) {
 		ev := evt.Payload.(OrderCancelledEvent)
 		e.logFn("engine: order %d cancelled: %s", ev.OrderID, ev.Reason)
 		e.db.AppendAudit("order", ev.OrderID, "cancelled", "", ev.Reason, "system")
+		e.KickSourcing()
 	}, EventOrderCancelled)
 
 	// When an order is received, audit it
@@ -63,18 +66,38 @@ func (e *Engine) wireEventHandlers() {
 		e.db.AppendAudit("order", ev.OrderID, "received", "", fmt.Sprintf("%s %s from %s", ev.OrderType, ev.PayloadTypeCode, ev.StationID), "system")
 	}, EventOrderReceived)
 
-	// Payload changes: audit
+	// Payload changes: audit, re-check orders waiting for stock
 	e.Events.SubscribeTypes(func(evt Event) {
 		ev := evt.Payload.(PayloadChangedEvent)
 		e.db.AppendAudit("payload", ev.PayloadID, ev.Action, "", fmt.Sprintf("type=%s node=%d", ev.PayloadTypeCode, ev.NodeID), "system")
+		e.KickSourcing()
 	}, EventPayloadChanged)
 
-	// Node updates: audit
+	// Node updates: audit, re-check orders waiting for stock
 	e.Events.SubscribeTypes(func(evt Event) {
 		ev := evt.Payload.(NodeUpdatedEvent)
 		e.db.AppendAudit("node", ev.NodeID, ev.Action, "", ev.NodeName, "system")
+		e.KickSourcing()
 	}, EventNodeUpdated)
 
+	// SLA breaches: log and audit
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(SLABreachedEvent)
+		e.logFn("engine: order %d from %s breached its %s SLA (waiting %s)", ev.OrderID, ev.StationID, ev.SLA, ev.Waited.Round(time.Second))
+		e.db.AppendAudit("order", ev.OrderID, "sla_breached", "", fmt.Sprintf("sla=%s waited=%s", ev.SLA, ev.Waited.Round(time.Second)), "system")
+	}, EventSLABreached)
+
+	// Zone locks: audit; an unlock releases the orders held for the zone
+	e.Events.SubscribeTypes(func(evt Event) {
+		ev := evt.Payload.(ZoneLockEvent)
+		if ev.Locked {
+			e.db.AppendAudit("zone", ev.ZoneID, "locked", "", ev.Reason, ev.Actor)
+			return
+		}
+		e.db.AppendAudit("zone", ev.ZoneID, "unlocked", "", ev.Zone, ev.Actor)
+		e.KickSourcing()
+	}, EventZoneLock)
+
 	// Corrections: audit
 	e.Events.SubscribeTypes(func(evt Event) {
 		ev := evt.Payload.(CorrectionAppliedEvent)
@@ -101,6 +124,7 @@ func (e *Engine) handleVendorStatusChange(ev OrderStatusChangedEvent) {
 			OrderUUID: order.EdgeUUID,
 			WaybillID: order.VendorOrderID,
 			RobotID:   ev.RobotID,
+			ETA:       e.trackETA(order, ev.RobotID),
 		})
 		if err != nil {
 			log.Printf("engine: build waybill reply: %v", err)
@@ -116,8 +140,36 @@ func (e *Engine) handleVendorStatusChange(ev OrderStatusChangedEvent) {
 		}
 	}
 
-	newStatus := e.fleet.MapState(ev.NewStatus)
+	fb := fleet.ForOrder(e.fleet, order.VendorOrderID)
+	newStatus := fb.MapState(ev.NewStatus)
+	// The first leg of a cross-fleet order ends at the handoff node, where the
+	// next fleet takes over.
+	if newStatus == dispatch.StatusDelivered && dispatch.IsHandoffLeg(order) {
+		e.db.UpdateOrderVendor(order.ID, order.VendorOrderID, ev.NewStatus, ev.RobotID)
+		e.handleOrderHandoff(order)
+		return
+	}
+	// The first leg of a staged delivery ends at the staging node, not the line.
+	if newStatus == dispatch.StatusDelivered && dispatch.IsStagingLeg(order) {
+		newStatus = dispatch.StatusStaged
+	}
 	if newStatus == order.Status {
+		// Keep the vendor state current even when the order status does not
+		// move; the tracker resumes from it after a restart.
+		if ev.NewStatus != order.VendorState {
+			robotID := ev.RobotID
+			if robotID == "" {
+				robotID = order.RobotID
+			}
+			e.db.UpdateOrderVendor(order.ID, order.VendorOrderID, ev.NewStatus, robotID)
+		}
+		return
+	}
+
+	// A failed vendor order goes to the dispatcher's retry policy, which
+	// re-dispatches it or fails the order and tells ShinGo Edge.
+	if newStatus == dispatch.StatusFailed && fb.IsTerminalState(ev.NewStatus) {
+		e.dispatcher.HandleVendorFailure(order, ev.NewStatus, ev.RobotID, fmt.Sprintf("fleet: %s -> %s", ev.OldStatus, ev.NewStatus))
 		return
 	}
 
@@ -129,6 +181,7 @@ func (e *Engine) handleVendorStatusChange(ev OrderStatusChangedEvent) {
 		OrderUUID: order.EdgeUUID,
 		Status:    newStatus,
 		Detail:    fmt.Sprintf("fleet state: %s", ev.NewStatus),
+		ETA:       e.orderETA(order.ID),
 	})
 	if err != nil {
 		log.Printf("engine: build update reply: %v", err)
@@ -144,28 +197,29 @@ func (e *Engine) handleVendorStatusChange(ev OrderStatusChangedEvent) {
 	}
 
 	// Handle terminal states
-	if e.fleet.IsTerminalState(ev.NewStatus) {
+	if fb.IsTerminalState(ev.NewStatus) {
 		switch newStatus {
 		case dispatch.StatusDelivered:
 			e.handleOrderDelivered(order)
-		case dispatch.StatusFailed:
-			e.db.UpdateOrderStatus(order.ID, dispatch.StatusFailed, "fleet order failed")
-			e.Events.Emit(Event{Type: EventOrderFailed, Payload: OrderFailedEvent{
-				OrderID:   order.ID,
-				EdgeUUID:  order.EdgeUUID,
-				StationID: order.StationID,
-				ErrorCode: "fleet_failed",
-				Detail:    "fleet order failed",
-			}})
+		case dispatch.StatusStaged:
+			e.handleOrderStaged(order)
 		case dispatch.StatusCancelled:
 			e.db.UpdateOrderStatus(order.ID, dispatch.StatusCancelled, "fleet order stopped")
 		}
+		// The order has left flight; let queued orders take its slot.
+		e.KickSourcing()
 	}
 }
 
 func (e *Engine) handleOrderDelivered(order *store.Order) {
 	e.db.UpdateOrderStatus(order.ID, dispatch.StatusDelivered, "payload delivered")
 
+	// No edge will send a receipt for an order core raised itself
+	if dispatch.IsCoreOrder(order) {
+		e.dispatcher.ConfirmCoreOrder(order)
+		return
+	}
+
 	// Send delivered notification to ShinGo Edge
 	coreAddr := protocol.Address{Role: protocol.RoleCore, Station: e.cfg.Messaging.StationID}
 	edgeAddr := protocol.Address{Role: protocol.RoleEdge, Station: order.StationID}
@@ -187,6 +241,75 @@ func (e *Engine) handleOrderDelivered(order *store.Order) {
 	}
 }
 
+// handleOrderStaged records the payload at the staging node and holds the order
+// there until ShinGo Edge releases it. The payload stays claimed by the order.
+// Orders core raised itself are released straight away.
+func (e *Engine) handleOrderStaged(order *store.Order) {
+	stagingNode, err := e.db.GetNodeByName(order.StagingNode)
+	if err != nil {
+		e.logFn("engine: staging node %s not found for order %d: %v", order.StagingNode, order.ID, err)
+		return
+	}
+	e.db.UpdateOrderStatus(order.ID, dispatch.StatusStaged, fmt.Sprintf("payload held at %s awaiting release", stagingNode.Name))
+
+	sourceNode, _ := e.db.GetNodeByName(order.PickupNode)
+	sourceNodeID := int64(0)
+	if sourceNode != nil {
+		sourceNodeID = sourceNode.ID
+	}
+
+	payloads, _ := e.db.ListPayloadsByClaimedOrder(order.ID)
+	for _, p := range payloads {
+		e.nodeState.MovePayload(p.ID, stagingNode.ID)
+		e.db.ClaimPayload(p.ID, order.ID)
+		e.Events.Emit(Event{Type: EventPayloadChanged, Payload: PayloadChangedEvent{
+			Action:          "staged",
+			PayloadID:       p.ID,
+			PayloadTypeCode: p.PayloadTypeName,
+			FromNodeID:      sourceNodeID,
+			ToNodeID:        stagingNode.ID,
+			NodeID:          stagingNode.ID,
+		}})
+	}
+
+	// No edge will release an order core raised itself
+	if dispatch.IsCoreOrder(order) {
+		e.dispatcher.ReleaseCoreOrder(order)
+	}
+}
+
+// handleOrderHandoff records the payload at the handoff node and sends the
+// order on with the fleet that serves its destination. The payload stays
+// claimed by the order.
+func (e *Engine) handleOrderHandoff(order *store.Order) {
+	handoffNode, err := e.db.GetNodeByName(order.HandoffNode)
+	if err != nil {
+		e.logFn("engine: handoff node %s not found for order %d: %v", order.HandoffNode, order.ID, err)
+		return
+	}
+
+	sourceNode, _ := e.db.GetNodeByName(order.PickupNode)
+	sourceNodeID := int64(0)
+	if sourceNode != nil {
+		sourceNodeID = sourceNode.ID
+	}
+
+	payloads, _ := e.db.ListPayloadsByClaimedOrder(order.ID)
+	for _, p := range payloads {
+		e.nodeState.MovePayload(p.ID, handoffNode.ID)
+		e.db.ClaimPayload(p.ID, order.ID)
+		e.Events.Emit(Event{Type: EventPayloadChanged, Payload: PayloadChangedEvent{
+			Action:          "handed_off",
+			PayloadID:       p.ID,
+			PayloadTypeCode: p.PayloadTypeName,
+			FromNodeID:      sourceNodeID,
+			ToNodeID:        handoffNode.ID,
+			NodeID:          handoffNode.ID,
+		}})
+	}
+	e.dispatcher.ContinueHandoff(order)
+}
+
 // handleOrderCompleted moves payloads from source to dest after ShinGo Edge confirms physical receipt.
 func (e *Engine) handleOrderCompleted(ev OrderCompletedEvent) {
 	order, err := e.db.GetOrder(ev.OrderID)
@@ -211,16 +334,30 @@ func (e *Engine) handleOrderCompleted(ev OrderCompletedEvent) {
 		sourceNodeID = sourceNode.ID
 	}
 
+	// Swap orders also carried the line's empty back to storage. Classify
+	// payloads by where they sat before moving anything.
+	var returnNode *store.Node
+	if order.ReturnNode != "" {
+		returnNode, err = e.db.GetNodeByName(order.ReturnNode)
+		if err != nil {
+			e.logFn("engine: return node %s not found for completion: %v", order.ReturnNode, err)
+		}
+	}
+
 	payloads, _ := e.db.ListPayloadsByClaimedOrder(order.ID)
 	for _, p := range payloads {
-		e.nodeState.MovePayload(p.ID, destNode.ID)
+		fromID, toID := sourceNodeID, destNode.ID
+		if returnNode != nil && p.NodeID != nil && *p.NodeID == destNode.ID {
+			fromID, toID = destNode.ID, returnNode.ID
+		}
+		e.nodeState.MovePayload(p.ID, toID)
 		e.Events.Emit(Event{Type: EventPayloadChanged, Payload: PayloadChangedEvent{
 			Action:          "moved",
 			PayloadID:       p.ID,
 			PayloadTypeCode: p.PayloadTypeName,
-			FromNodeID:      sourceNodeID,
-			ToNodeID:        destNode.ID,
-			NodeID:          destNode.ID,
+			FromNodeID:      fromID,
+			ToNodeID:        toID,
+			NodeID:          toID,
 		}})
 	}
 }
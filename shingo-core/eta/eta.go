@@ -0,0 +1,253 @@
+// Package eta estimates when a robot will deliver an order. It learns
+// pickup-to-delivery travel times per node pair, and per zone pair as a
+// fallback, from the orders it watches, and adds the assigned robot's
+// approach to the pickup from its position on the map.
+package eta
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"shingocore/dispatch"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// Update reports a new ETA for an order.
+type Update struct {
+	OrderID int64
+	ETA     time.Time
+}
+
+// point is a position on the fleet map, in meters.
+type point struct {
+	x, y float64
+	ok   bool
+}
+
+func (p point) dist(q point) float64 {
+	return math.Hypot(p.x-q.x, p.y-q.y)
+}
+
+type trip struct {
+	orderID        int64
+	robot          string
+	from, to       *store.Node
+	fromPt, toPt   point
+	pickedAt       time.Time // when the robot was seen at the pickup; zero before
+	eta, sentETA   time.Time
+	sentAt         time.Time
+	legSeconds     float64 // learned or estimated pickup-to-delivery time
+	legFromHistory bool
+}
+
+// Estimator tracks orders from robot assignment to delivery. It is driven
+// by the engine: Track when a robot takes an order, Update with every robot
+// status refresh, Complete on delivery and Drop when the order stops.
+type Estimator struct {
+	db       *store.DB
+	speed    float64       // meters per second, for distances without history
+	refresh  time.Duration // resend an unchanged ETA this often
+	change   time.Duration // resend sooner when the ETA moves this much
+	Send     func(Update)
+	DebugLog func(string, ...any)
+
+	mu     sync.Mutex
+	trips  map[int64]*trip
+	robots []fleet.RobotStatus // last status refresh
+}
+
+// NewEstimator creates an estimator. speed is the robots' average speed in
+// meters per second, used to time the approach to the pickup and legs with
+// no history. ETAs are resent every refresh, or as soon as they move by
+// change.
+func NewEstimator(db *store.DB, speed float64, refresh, change time.Duration) *Estimator {
+	return &Estimator{db: db, speed: speed, refresh: refresh, change: change, trips: make(map[int64]*trip)}
+}
+
+func (e *Estimator) dbg(format string, args ...any) {
+	if fn := e.DebugLog; fn != nil {
+		fn(format, args...)
+	}
+}
+
+// Track starts following an order's current leg with the robot assigned to
+// it and returns the first ETA, or the zero time if none can be estimated.
+// Tracking an order again with the same robot returns its current ETA.
+func (e *Estimator) Track(order *store.Order, robot string, now time.Time) time.Time {
+	e.mu.Lock()
+	t := e.trips[order.ID]
+	e.mu.Unlock()
+	if t != nil && t.robot == robot {
+		return e.ETA(order.ID)
+	}
+
+	from, err := e.db.GetNodeByName(order.PickupNode)
+	if err != nil {
+		return time.Time{}
+	}
+	to, err := e.db.GetNodeByName(dispatch.LegDest(order))
+	if err != nil {
+		return time.Time{}
+	}
+	t = &trip{orderID: order.ID, robot: robot, from: from, to: to, fromPt: e.locate(from), toPt: e.locate(to)}
+	t.legSeconds, t.legFromHistory = e.legSeconds(t)
+
+	e.mu.Lock()
+	e.estimate(t, findRobot(e.robots, robot), now)
+	// The first ETA goes out with the waybill.
+	t.sentETA, t.sentAt = t.eta, now
+	e.trips[order.ID] = t
+	e.mu.Unlock()
+	e.dbg("eta: order %d %s -> %s on %s: leg %.0fs (history=%v), eta %s",
+		order.ID, from.Name, to.Name, robot, t.legSeconds, t.legFromHistory, t.eta.Format(time.TimeOnly))
+	return t.eta
+}
+
+// ETA returns an order's current estimate, or the zero time if the order is
+// not tracked or has no estimate.
+func (e *Estimator) ETA(orderID int64) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if t := e.trips[orderID]; t != nil {
+		return t.eta
+	}
+	return time.Time{}
+}
+
+// Update notes robots reaching their pickup, re-estimates every tracked
+// order and sends the ETAs that moved or are due for a refresh.
+func (e *Estimator) Update(robots []fleet.RobotStatus, now time.Time) {
+	var updates []Update
+	e.mu.Lock()
+	e.robots = robots
+	for _, t := range e.trips {
+		r := findRobot(robots, t.robot)
+		if r != nil && t.pickedAt.IsZero() && t.from.VendorLocation != "" && r.CurrentStation == t.from.VendorLocation {
+			t.pickedAt = now
+			e.dbg("eta: order %d: %s at pickup %s", t.orderID, t.robot, t.from.Name)
+		}
+		e.estimate(t, r, now)
+		if t.eta.IsZero() {
+			continue
+		}
+		moved := t.eta.Sub(t.sentETA)
+		if moved < 0 {
+			moved = -moved
+		}
+		if t.sentETA.IsZero() || moved >= e.change || now.Sub(t.sentAt) >= e.refresh {
+			t.sentETA, t.sentAt = t.eta, now
+			updates = append(updates, Update{OrderID: t.orderID, ETA: t.eta})
+		}
+	}
+	e.mu.Unlock()
+
+	if e.Send == nil {
+		return
+	}
+	for _, u := range updates {
+		e.Send(u)
+	}
+}
+
+// Complete stops tracking a delivered order and learns its travel time if
+// the robot was seen at the pickup.
+func (e *Estimator) Complete(orderID int64, now time.Time) {
+	e.mu.Lock()
+	t := e.trips[orderID]
+	delete(e.trips, orderID)
+	e.mu.Unlock()
+	if t == nil || t.pickedAt.IsZero() {
+		return
+	}
+	secs := now.Sub(t.pickedAt).Seconds()
+	if err := e.db.RecordTravelTime(store.TravelNode, t.from.Name, t.to.Name, secs); err != nil {
+		log.Printf("eta: record travel time %s -> %s: %v", t.from.Name, t.to.Name, err)
+	}
+	if t.from.Zone != "" && t.to.Zone != "" {
+		if err := e.db.RecordTravelTime(store.TravelZone, t.from.Zone, t.to.Zone, secs); err != nil {
+			log.Printf("eta: record travel time %s -> %s: %v", t.from.Zone, t.to.Zone, err)
+		}
+	}
+	e.dbg("eta: order %d: %s -> %s took %.0fs", orderID, t.from.Name, t.to.Name, secs)
+}
+
+// Drop stops tracking an order without learning from it.
+func (e *Estimator) Drop(orderID int64) {
+	e.mu.Lock()
+	delete(e.trips, orderID)
+	e.mu.Unlock()
+}
+
+// estimate sets a trip's ETA. Before the pickup it is the robot's approach
+// plus the leg; after it, what is left of the leg, but no less than the
+// robot's distance to the destination allows. Callers hold e.mu.
+func (e *Estimator) estimate(t *trip, r *fleet.RobotStatus, now time.Time) {
+	if t.legSeconds <= 0 {
+		t.eta = time.Time{}
+		return
+	}
+	var at point
+	if r != nil {
+		at = point{x: r.X, y: r.Y, ok: true}
+	}
+	var remaining float64
+	if t.pickedAt.IsZero() {
+		remaining = t.legSeconds
+		if at.ok && t.fromPt.ok && e.speed > 0 {
+			remaining += at.dist(t.fromPt) / e.speed
+		}
+	} else {
+		remaining = t.legSeconds - now.Sub(t.pickedAt).Seconds()
+		if at.ok && t.toPt.ok && e.speed > 0 {
+			remaining = max(remaining, at.dist(t.toPt)/e.speed)
+		}
+		remaining = max(remaining, 0)
+	}
+	t.eta = now.Add(time.Duration(remaining * float64(time.Second))).Truncate(time.Second)
+}
+
+// legSeconds returns the pickup-to-delivery time for a trip: learned for the
+// node pair, else for the zone pair, else the straight-line distance at the
+// configured speed. It reports whether the time came from history.
+func (e *Estimator) legSeconds(t *trip) (float64, bool) {
+	if tt, err := e.db.GetTravelTime(store.TravelNode, t.from.Name, t.to.Name); err == nil {
+		return tt.AvgSeconds, true
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("eta: travel time %s -> %s: %v", t.from.Name, t.to.Name, err)
+	}
+	if t.from.Zone != "" && t.to.Zone != "" {
+		if tt, err := e.db.GetTravelTime(store.TravelZone, t.from.Zone, t.to.Zone); err == nil {
+			return tt.AvgSeconds, true
+		}
+	}
+	if t.fromPt.ok && t.toPt.ok && e.speed > 0 {
+		return t.fromPt.dist(t.toPt) / e.speed, false
+	}
+	return 0, false
+}
+
+// locate returns a node's position from the synced scene points.
+func (e *Estimator) locate(n *store.Node) point {
+	if n.VendorLocation == "" {
+		return point{}
+	}
+	sp, err := e.db.GetScenePointByInstance(n.VendorLocation)
+	if err != nil {
+		return point{}
+	}
+	return point{x: sp.PosX, y: sp.PosY, ok: true}
+}
+
+func findRobot(robots []fleet.RobotStatus, id string) *fleet.RobotStatus {
+	for i := range robots {
+		if robots[i].VehicleID == id {
+			return &robots[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package eta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shingocore/config"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+func testDB(t *testing.T) *store.DB {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := store.Open(&config.DatabaseConfig{
+		Driver: "sqlite",
+		SQLite: config.SQLiteConfig{Path: dbPath},
+	})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		os.Remove(dbPath)
+	})
+	return db
+}
+
+// setup creates a storage node at (0,0) and a line node at (60,0), and a
+// retrieve order between them.
+func setup(t *testing.T, db *store.DB) *store.Order {
+	t.Helper()
+	for _, n := range []struct {
+		name, loc, zone string
+		x               float64
+	}{{"STORAGE-A1", "Loc-01", "A", 0}, {"LINE1-IN", "Loc-10", "L", 60}} {
+		if err := db.CreateNode(&store.Node{Name: n.name, VendorLocation: n.loc, NodeType: "storage", Zone: n.zone, Enabled: true}); err != nil {
+			t.Fatalf("create node: %v", err)
+		}
+		db.UpsertScenePoint(&store.ScenePoint{AreaName: "main", InstanceName: n.loc, ClassName: "GeneralLocation", PosX: n.x})
+	}
+	order := &store.Order{EdgeUUID: "uuid-1", StationID: "line-1", OrderType: "retrieve", Status: "dispatched",
+		PickupNode: "STORAGE-A1", DeliveryNode: "LINE1-IN"}
+	if err := db.CreateOrder(order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	return order
+}
+
+func robotAt(x float64, station string) []fleet.RobotStatus {
+	return []fleet.RobotStatus{{VehicleID: "AMB-01", Connected: true, X: x, CurrentStation: station}}
+}
+
+func TestETAFromDistanceThenHistory(t *testing.T) {
+	db := testDB(t)
+	order := setup(t, db)
+	e := NewEstimator(db, 1.0, time.Minute, 30*time.Second)
+	var sent []Update
+	e.Send = func(u Update) { sent = append(sent, u) }
+
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local)
+	e.Update(robotAt(-30, ""), now)
+
+	// No history: 30m approach plus the 60m leg at 1 m/s
+	if got := e.Track(order, "AMB-01", now); !got.Equal(now.Add(90 * time.Second)) {
+		t.Fatalf("first eta = %v, want +90s", got.Sub(now))
+	}
+
+	// At the pickup after 40s: the 60s leg is left
+	now = now.Add(40 * time.Second)
+	e.Update(robotAt(0, "Loc-01"), now)
+	if got := e.ETA(order.ID); !got.Equal(now.Add(60 * time.Second)) {
+		t.Errorf("eta at pickup = %v, want +60s", got.Sub(now))
+	}
+	if len(sent) != 0 {
+		t.Errorf("sent %d updates for an ETA that moved 10s, want none", len(sent))
+	}
+
+	// Slow leg: delivered 120s after the pickup, which is learned
+	e.Complete(order.ID, now.Add(120*time.Second))
+	if !e.ETA(order.ID).IsZero() {
+		t.Error("completed order still tracked")
+	}
+	tt, err := db.GetTravelTime(store.TravelNode, "STORAGE-A1", "LINE1-IN")
+	if err != nil || tt.AvgSeconds != 120 {
+		t.Fatalf("node travel time = %+v, %v, want 120s", tt, err)
+	}
+	if zt, err := db.GetTravelTime(store.TravelZone, "A", "L"); err != nil || zt.AvgSeconds != 120 {
+		t.Errorf("zone travel time = %+v, %v, want 120s", zt, err)
+	}
+
+	// The next order uses the learned leg, and a late robot pushes the ETA out
+	next := *order
+	next.ID = order.ID + 1
+	now = now.Add(time.Hour)
+	e.Update(robotAt(0, ""), now)
+	if got := e.Track(&next, "AMB-01", now); !got.Equal(now.Add(120 * time.Second)) {
+		t.Errorf("eta with history = %v, want +120s", got.Sub(now))
+	}
+	e.Update(robotAt(0, "Loc-01"), now)
+	now = now.Add(150 * time.Second)
+	e.Update(robotAt(30, ""), now)
+	if len(sent) != 1 || !sent[0].ETA.Equal(now.Add(30*time.Second)) {
+		t.Errorf("sent = %+v, want one update 30s out", sent)
+	}
+}
@@ -45,6 +45,27 @@ type TransportOrderRequest struct {
 	Priority   int
 }
 
+// Stop actions for multi-stop transport orders.
+const (
+	StopPick = "pick"
+	StopDrop = "drop"
+)
+
+// TransportStop is one location visited by a multi-stop transport order.
+type TransportStop struct {
+	Loc    string // Vendor location
+	Action string // StopPick or StopDrop
+}
+
+// MultiStopOrderRequest describes a single vendor order that visits several
+// stops in sequence with one robot.
+type MultiStopOrderRequest struct {
+	OrderID    string // ShinGo-generated order ID (e.g. "sg-42-abc12345")
+	ExternalID string // Edge UUID for correlation
+	Stops      []TransportStop
+	Priority   int
+}
+
 // TransportOrderResult contains the result of a successful order creation.
 type TransportOrderResult struct {
 	VendorOrderID string // The ID assigned by the vendor system
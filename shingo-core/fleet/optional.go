@@ -1,5 +1,7 @@
 package fleet
 
+import "errors"
+
 // RobotLister provides robot status and control capabilities.
 // Web handlers type-assert Backend to this interface.
 type RobotLister interface {
@@ -9,6 +11,42 @@ type RobotLister interface {
 	ForceComplete(vehicleID string) error
 }
 
+// RobotCharger sends a specific robot to a charging location under the given
+// vendor order ID.
+type RobotCharger interface {
+	SendToCharge(orderID, vehicleID, loc string) error
+}
+
+// GoodsBinder records on the fleet which goods a robot's container holds, so
+// the fleet and ShinGo agree on what a robot carries.
+type GoodsBinder interface {
+	BindGoods(vehicleID, container, goodsID, desc string) error
+	UnbindGoods(vehicleID, goodsID string) error
+}
+
+// MultiStopCreator dispatches one vendor order that visits several stops,
+// e.g. a swap that drops a full payload and picks up the empty in one trip.
+type MultiStopCreator interface {
+	CreateMultiStopOrder(req MultiStopOrderRequest) (TransportOrderResult, error)
+}
+
+// ErrOrderNotFound is returned by OrderLookup when the fleet has no such order.
+var ErrOrderNotFound = errors.New("fleet order not found")
+
+// OrderLookup reports the vendor state of an existing order. The startup
+// reconciler uses it to tell interrupted dispatches that reached the fleet
+// from ones that did not.
+type OrderLookup interface {
+	GetOrderState(vendorOrderID string) (string, error)
+}
+
+// OrderLister pages through the fleet's own order list. The fleet
+// reconciliation pass uses it to find vendor orders ShinGo lost track of.
+type OrderLister interface {
+	ListOrders(page, size int) ([]VendorOrder, error)
+	GetOrderByExternalID(externalID string) (VendorOrder, error)
+}
+
 // NodeOccupancyProvider provides node location occupancy details.
 type NodeOccupancyProvider interface {
 	GetNodeOccupancy(groups ...string) ([]OccupancyDetail, error)
@@ -19,6 +57,43 @@ type SceneSyncer interface {
 	GetSceneAreas() ([]SceneArea, error)
 }
 
+// Device kinds reported by DeviceController.
+const (
+	DeviceDoor = "door"
+	DeviceLift = "lift"
+)
+
+// DeviceController operates the automatic doors and lifts on robot routes.
+type DeviceController interface {
+	GetDevicesStatus() ([]DeviceStatus, error)
+	SetDoor(name string, open bool) error
+	CallLift(name, targetArea string) error
+	DisableDevice(kind, name string, disabled bool) error
+}
+
+// DeviceStatus is a vendor-neutral view of a door or lift.
+type DeviceStatus struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"` // DeviceDoor or DeviceLift
+	State    int    `json:"state"`
+	Disabled bool   `json:"disabled"`
+	Fault    string `json:"fault,omitempty"` // why the device is unavailable; empty when healthy
+}
+
+// ZoneLocker reserves fleet mutex groups so that no robot enters the areas
+// they cover. holder identifies the party occupying the groups.
+type ZoneLocker interface {
+	OccupyMutexGroups(holder string, groups []string) error
+	ReleaseMutexGroups(holder string, groups []string) error
+}
+
+// SimStateSetter changes the state of a simulated robot: battery, e-stop,
+// blocked, or any other field the vendor simulator exposes. Scenario runs use
+// it to rehearse failures before go-live.
+type SimStateSetter interface {
+	SetSimState(vehicleID string, fields map[string]any) error
+}
+
 // VendorProxy exposes the vendor API base URL for raw proxy requests.
 type VendorProxy interface {
 	BaseURL() string
@@ -44,6 +119,14 @@ type RobotStatus struct {
 	NetworkDelay   int
 	CurrentStation string
 	LastStation    string
+	Fleet          string           // set by Registry when several fleets run side by side
+	Goods          []ContainerGoods // goods the robot reports in its containers
+}
+
+// ContainerGoods is a goods binding reported by a robot.
+type ContainerGoods struct {
+	Container string `json:"container"`
+	GoodsID   string `json:"goods_id"`
 }
 
 // State returns a computed state string for the robot: offline, error, busy, paused, or ready.
@@ -63,6 +146,14 @@ func (r RobotStatus) State() string {
 	return "ready"
 }
 
+// VendorOrder is a vendor-neutral summary of an order held by the fleet.
+type VendorOrder struct {
+	VendorOrderID string
+	ExternalID    string
+	State         string
+	RobotID       string
+}
+
 // OccupancyDetail is a vendor-neutral representation of a location's occupancy status.
 type OccupancyDetail struct {
 	ID       string
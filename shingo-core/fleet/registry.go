@@ -0,0 +1,719 @@
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry runs several fleets side by side. Each node or zone is bound to
+// one named fleet; anything unbound belongs to the default fleet, the first
+// one added. The registry is itself a Backend: orders are routed to the fleet
+// that owns them and robots, devices, occupancy and scene areas are merged
+// across all fleets.
+//
+// The dispatcher routes transport orders by node with Route and creates them
+// with CreateOn, which records the owning fleet so cancels, priority changes
+// and tracking reach it. Orders from before a restart are resolved through
+// OwnerOf.
+type Registry struct {
+	// OwnerOf returns the fleet name of a vendor order the registry has not
+	// seen since startup, or "" if unknown.
+	OwnerOf func(vendorOrderID string) string
+
+	mu       sync.RWMutex
+	names    []string
+	backends map[string]Backend
+	nodes    map[string]string    // node name -> fleet
+	zones    map[string]string    // zone -> fleet
+	handoffs map[[2]string]string // sorted fleet pair -> handoff node
+	owners   map[string]string    // vendor order ID -> fleet
+	robots   map[string]string    // vehicle ID -> fleet
+	devices  map[string]string    // device name -> fleet
+	tracker  *registryTracker
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		backends: make(map[string]Backend),
+		nodes:    make(map[string]string),
+		zones:    make(map[string]string),
+		handoffs: make(map[[2]string]string),
+		owners:   make(map[string]string),
+		robots:   make(map[string]string),
+		devices:  make(map[string]string),
+	}
+}
+
+// Add registers a fleet under a name.
+func (r *Registry) Add(name string, b Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.backends[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.backends[name] = b
+}
+
+// BindNode assigns a node to a fleet. Node bindings win over zone bindings.
+// It fails for a fleet that has not been added or a node already bound to
+// another fleet.
+func (r *Registry) BindNode(node, fleet string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bind(r.nodes, "node", node, fleet)
+}
+
+// BindZone assigns every node in a zone to a fleet. It fails for a fleet
+// that has not been added or a zone already bound to another fleet.
+func (r *Registry) BindZone(zone, fleet string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bind(r.zones, "zone", zone, fleet)
+}
+
+func (r *Registry) bind(bindings map[string]string, kind, key, fleet string) error {
+	if _, ok := r.backends[fleet]; !ok {
+		return fmt.Errorf("%s %s bound to unknown fleet %q", kind, key, fleet)
+	}
+	if f, ok := bindings[key]; ok && f != fleet {
+		return fmt.Errorf("%s %s bound to both fleet %s and %s", kind, key, f, fleet)
+	}
+	bindings[key] = fleet
+	return nil
+}
+
+// SetHandoff names the node where payloads pass between two fleets. Both
+// fleets must be able to reach it, and both must have been added.
+func (r *Registry) SetHandoff(a, b, node string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range []string{a, b} {
+		if _, ok := r.backends[f]; !ok {
+			return fmt.Errorf("handoff at %s names unknown fleet %q", node, f)
+		}
+	}
+	if a == b {
+		return fmt.Errorf("handoff at %s must name two different fleets", node)
+	}
+	r.handoffs[pair(a, b)] = node
+	return nil
+}
+
+func pair(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// Names returns the fleet names in the order they were added.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.names...)
+}
+
+// Get returns a fleet by name, or nil.
+func (r *Registry) Get(name string) Backend {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.backends[name]
+}
+
+// Default returns the name of the default fleet.
+func (r *Registry) Default() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.names) == 0 {
+		return ""
+	}
+	return r.names[0]
+}
+
+// Route returns the fleet that serves a node.
+func (r *Registry) Route(node, zone string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if f, ok := r.nodes[node]; ok {
+		return f
+	}
+	if f, ok := r.zones[zone]; ok {
+		return f
+	}
+	if len(r.names) == 0 {
+		return ""
+	}
+	return r.names[0]
+}
+
+// Handoff returns the handoff node between two fleets, or "" if there is none.
+func (r *Registry) Handoff(a, b string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.handoffs[pair(a, b)]
+}
+
+// ForOrder returns the fleet that owns a vendor order, falling back to the
+// default fleet when the owner is unknown.
+func (r *Registry) ForOrder(vendorOrderID string) Backend {
+	return r.Get(r.owner(vendorOrderID))
+}
+
+// ForZone returns the fleet that serves a zone.
+func (r *Registry) ForZone(zone string) Backend {
+	return r.Get(r.Route("", zone))
+}
+
+func (r *Registry) owner(vendorOrderID string) string {
+	r.mu.RLock()
+	name, ok := r.owners[vendorOrderID]
+	r.mu.RUnlock()
+	if ok {
+		return name
+	}
+	if r.OwnerOf != nil {
+		if name = r.OwnerOf(vendorOrderID); name != "" && r.Get(name) != nil {
+			r.mu.Lock()
+			r.owners[vendorOrderID] = name
+			r.mu.Unlock()
+			return name
+		}
+	}
+	return r.Default()
+}
+
+func (r *Registry) own(vendorOrderID, fleet string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owners[vendorOrderID] = fleet
+}
+
+// CreateOn dispatches a transport order to the named fleet.
+func (r *Registry) CreateOn(fleet string, req TransportOrderRequest) (TransportOrderResult, error) {
+	b := r.Get(fleet)
+	if b == nil {
+		return TransportOrderResult{}, fmt.Errorf("unknown fleet %q", fleet)
+	}
+	r.own(req.OrderID, fleet)
+	return b.CreateTransportOrder(req)
+}
+
+// CreateMultiStopOn dispatches a multi-stop order to the named fleet.
+func (r *Registry) CreateMultiStopOn(fleet string, req MultiStopOrderRequest) (TransportOrderResult, error) {
+	b := r.Get(fleet)
+	if b == nil {
+		return TransportOrderResult{}, fmt.Errorf("unknown fleet %q", fleet)
+	}
+	ms, ok := b.(MultiStopCreator)
+	if !ok {
+		return TransportOrderResult{}, fmt.Errorf("%s does not support multi-stop orders", b.Name())
+	}
+	r.own(req.OrderID, fleet)
+	return ms.CreateMultiStopOrder(req)
+}
+
+// each calls fn for every fleet in order.
+func (r *Registry) each(fn func(name string, b Backend)) {
+	r.mu.RLock()
+	names := append([]string(nil), r.names...)
+	backends := make([]Backend, len(names))
+	for i, n := range names {
+		backends[i] = r.backends[n]
+	}
+	r.mu.RUnlock()
+	for i, n := range names {
+		fn(n, backends[i])
+	}
+}
+
+// --- Backend ---
+
+// CreateTransportOrder routes by the source location, taken as a node name.
+// Callers that know the source and destination nodes use Route and CreateOn.
+func (r *Registry) CreateTransportOrder(req TransportOrderRequest) (TransportOrderResult, error) {
+	return r.CreateOn(r.Route(req.FromLoc, ""), req)
+}
+
+func (r *Registry) CancelOrder(vendorOrderID string) error {
+	return r.ForOrder(vendorOrderID).CancelOrder(vendorOrderID)
+}
+
+func (r *Registry) SetOrderPriority(vendorOrderID string, priority int) error {
+	return r.ForOrder(vendorOrderID).SetOrderPriority(vendorOrderID, priority)
+}
+
+// Ping fails if any fleet is unreachable.
+func (r *Registry) Ping() error {
+	var errs []error
+	r.each(func(name string, b Backend) {
+		if err := b.Ping(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func (r *Registry) Name() string {
+	var parts []string
+	r.each(func(name string, b Backend) {
+		parts = append(parts, fmt.Sprintf("%s (%s)", name, b.Name()))
+	})
+	return strings.Join(parts, " + ")
+}
+
+// MapState maps with the default fleet. Fleets name their states
+// differently; callers that know the order use ForOrder(id).MapState.
+func (r *Registry) MapState(vendorState string) string {
+	return r.Get(r.Default()).MapState(vendorState)
+}
+
+// IsTerminalState checks with the default fleet; see MapState.
+func (r *Registry) IsTerminalState(vendorState string) bool {
+	return r.Get(r.Default()).IsTerminalState(vendorState)
+}
+
+// Reconfigure is passed to every fleet.
+func (r *Registry) Reconfigure(cfg ReconfigureParams) {
+	r.each(func(_ string, b Backend) { b.Reconfigure(cfg) })
+}
+
+// --- TrackingBackend ---
+
+// InitTracker initialises the tracker of every fleet that has one. Each
+// reports to the same emitter.
+func (r *Registry) InitTracker(emitter TrackerEmitter, resolver OrderIDResolver) {
+	t := &registryTracker{r: r, trackers: make(map[string]OrderTracker)}
+	r.each(func(name string, b Backend) {
+		if tb, ok := b.(TrackingBackend); ok {
+			tb.InitTracker(emitter, resolver)
+			t.trackers[name] = tb.Tracker()
+		}
+	})
+	r.mu.Lock()
+	r.tracker = t
+	r.mu.Unlock()
+}
+
+func (r *Registry) Tracker() OrderTracker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tracker
+}
+
+// registryTracker hands each order to its owning fleet's tracker.
+type registryTracker struct {
+	r        *Registry
+	trackers map[string]OrderTracker
+}
+
+func (t *registryTracker) Track(vendorOrderID string) {
+	if tr, ok := t.trackers[t.r.owner(vendorOrderID)]; ok {
+		tr.Track(vendorOrderID)
+	}
+}
+
+func (t *registryTracker) TrackFrom(vendorOrderID, lastState string) {
+	tr, ok := t.trackers[t.r.owner(vendorOrderID)]
+	if !ok {
+		return
+	}
+	if st, ok := tr.(SeededTracker); ok {
+		st.TrackFrom(vendorOrderID, lastState)
+	} else {
+		tr.Track(vendorOrderID)
+	}
+}
+
+func (t *registryTracker) Untrack(vendorOrderID string) {
+	for _, tr := range t.trackers {
+		tr.Untrack(vendorOrderID)
+	}
+}
+
+func (t *registryTracker) ActiveCount() int {
+	n := 0
+	for _, tr := range t.trackers {
+		n += tr.ActiveCount()
+	}
+	return n
+}
+
+func (t *registryTracker) Start() {
+	for _, tr := range t.trackers {
+		tr.Start()
+	}
+}
+
+func (t *registryTracker) Stop() {
+	for _, tr := range t.trackers {
+		tr.Stop()
+	}
+}
+
+// --- MultiStopCreator ---
+
+// CreateMultiStopOrder routes by the first stop; see CreateTransportOrder.
+func (r *Registry) CreateMultiStopOrder(req MultiStopOrderRequest) (TransportOrderResult, error) {
+	if len(req.Stops) == 0 {
+		return TransportOrderResult{}, fmt.Errorf("multi-stop order %s has no stops", req.OrderID)
+	}
+	return r.CreateMultiStopOn(r.Route(req.Stops[0].Loc, ""), req)
+}
+
+// --- OrderLookup ---
+
+func (r *Registry) GetOrderState(vendorOrderID string) (string, error) {
+	b := r.ForOrder(vendorOrderID)
+	lookup, ok := b.(OrderLookup)
+	if !ok {
+		return "", fmt.Errorf("%s does not support order lookup", b.Name())
+	}
+	return lookup.GetOrderState(vendorOrderID)
+}
+
+// --- OrderLister ---
+
+// ListOrders returns the given page from every fleet that lists orders, so a
+// page may hold up to size orders per fleet. Listed orders are remembered
+// against their fleet.
+func (r *Registry) ListOrders(page, size int) ([]VendorOrder, error) {
+	var out []VendorOrder
+	var err error
+	r.each(func(name string, b Backend) {
+		lister, ok := b.(OrderLister)
+		if !ok || err != nil {
+			return
+		}
+		orders, lerr := lister.ListOrders(page, size)
+		if lerr != nil {
+			err = fmt.Errorf("%s: %w", name, lerr)
+			return
+		}
+		for _, vo := range orders {
+			r.own(vo.VendorOrderID, name)
+		}
+		out = append(out, orders...)
+	})
+	return out, err
+}
+
+func (r *Registry) GetOrderByExternalID(externalID string) (VendorOrder, error) {
+	var found *VendorOrder
+	var err error
+	r.each(func(name string, b Backend) {
+		lister, ok := b.(OrderLister)
+		if !ok || found != nil || err != nil {
+			return
+		}
+		vo, lerr := lister.GetOrderByExternalID(externalID)
+		switch {
+		case lerr == nil:
+			r.own(vo.VendorOrderID, name)
+			found = &vo
+		case !errors.Is(lerr, ErrOrderNotFound):
+			err = fmt.Errorf("%s: %w", name, lerr)
+		}
+	})
+	if err != nil {
+		return VendorOrder{}, err
+	}
+	if found == nil {
+		return VendorOrder{}, ErrOrderNotFound
+	}
+	return *found, nil
+}
+
+// --- RobotLister ---
+
+// GetRobotsStatus merges robots from every fleet, each tagged with its fleet.
+// A fleet that cannot be reached is skipped unless all fail.
+func (r *Registry) GetRobotsStatus() ([]RobotStatus, error) {
+	var out []RobotStatus
+	var errs []error
+	owners := make(map[string]string)
+	r.each(func(name string, b Backend) {
+		rl, ok := b.(RobotLister)
+		if !ok {
+			return
+		}
+		robots, err := rl.GetRobotsStatus()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		for _, rs := range robots {
+			rs.Fleet = name
+			owners[rs.VehicleID] = name
+			out = append(out, rs)
+		}
+	})
+	if len(out) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Fleet < out[j].Fleet })
+	r.mu.Lock()
+	r.robots = owners
+	r.mu.Unlock()
+	return out, nil
+}
+
+// robotFleet returns the robot lister that owns a vehicle.
+func (r *Registry) robotFleet(vehicleID string) (RobotLister, error) {
+	r.mu.RLock()
+	name, ok := r.robots[vehicleID]
+	r.mu.RUnlock()
+	if !ok {
+		r.GetRobotsStatus()
+		r.mu.RLock()
+		name, ok = r.robots[vehicleID]
+		r.mu.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("vehicle %s not found in any fleet", vehicleID)
+	}
+	rl, _ := r.Get(name).(RobotLister)
+	return rl, nil
+}
+
+func (r *Registry) SetAvailability(vehicleID string, available bool) error {
+	rl, err := r.robotFleet(vehicleID)
+	if err != nil {
+		return err
+	}
+	return rl.SetAvailability(vehicleID, available)
+}
+
+// SendToCharge sends a robot to charge through the fleet that owns it.
+func (r *Registry) SendToCharge(orderID, vehicleID, loc string) error {
+	rl, err := r.robotFleet(vehicleID)
+	if err != nil {
+		return err
+	}
+	c, ok := rl.(RobotCharger)
+	if !ok {
+		return fmt.Errorf("fleet of vehicle %s cannot send robots to charge", vehicleID)
+	}
+	return c.SendToCharge(orderID, vehicleID, loc)
+}
+
+// BindGoods binds goods to a robot's container through the fleet that owns it.
+func (r *Registry) BindGoods(vehicleID, container, goodsID, desc string) error {
+	gb, err := r.goodsFleet(vehicleID)
+	if err != nil {
+		return err
+	}
+	return gb.BindGoods(vehicleID, container, goodsID, desc)
+}
+
+// UnbindGoods removes a goods binding through the fleet that owns the robot.
+func (r *Registry) UnbindGoods(vehicleID, goodsID string) error {
+	gb, err := r.goodsFleet(vehicleID)
+	if err != nil {
+		return err
+	}
+	return gb.UnbindGoods(vehicleID, goodsID)
+}
+
+func (r *Registry) goodsFleet(vehicleID string) (GoodsBinder, error) {
+	rl, err := r.robotFleet(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	gb, ok := rl.(GoodsBinder)
+	if !ok {
+		return nil, fmt.Errorf("fleet of vehicle %s cannot bind goods", vehicleID)
+	}
+	return gb, nil
+}
+
+// SetSimState changes a simulated robot's state through the fleet that owns it.
+func (r *Registry) SetSimState(vehicleID string, fields map[string]any) error {
+	rl, err := r.robotFleet(vehicleID)
+	if err != nil {
+		return err
+	}
+	ss, ok := rl.(SimStateSetter)
+	if !ok {
+		return fmt.Errorf("fleet of vehicle %s has no simulated robots", vehicleID)
+	}
+	return ss.SetSimState(vehicleID, fields)
+}
+
+func (r *Registry) RetryFailed(vehicleID string) error {
+	rl, err := r.robotFleet(vehicleID)
+	if err != nil {
+		return err
+	}
+	return rl.RetryFailed(vehicleID)
+}
+
+func (r *Registry) ForceComplete(vehicleID string) error {
+	rl, err := r.robotFleet(vehicleID)
+	if err != nil {
+		return err
+	}
+	return rl.ForceComplete(vehicleID)
+}
+
+// --- DeviceController ---
+
+// GetDevicesStatus merges the doors and lifts of every fleet that controls
+// devices. A fleet that cannot be reached is skipped unless all fail.
+func (r *Registry) GetDevicesStatus() ([]DeviceStatus, error) {
+	var out []DeviceStatus
+	var errs []error
+	owners := make(map[string]string)
+	r.each(func(name string, b Backend) {
+		dc, ok := b.(DeviceController)
+		if !ok {
+			return
+		}
+		devices, err := dc.GetDevicesStatus()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		for _, d := range devices {
+			owners[d.Name] = name
+		}
+		out = append(out, devices...)
+	})
+	if len(out) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	r.mu.Lock()
+	r.devices = owners
+	r.mu.Unlock()
+	return out, nil
+}
+
+// deviceFleet returns the device controller that operates a door or lift.
+func (r *Registry) deviceFleet(device string) (DeviceController, error) {
+	r.mu.RLock()
+	name, ok := r.devices[device]
+	r.mu.RUnlock()
+	if !ok {
+		r.GetDevicesStatus()
+		r.mu.RLock()
+		name, ok = r.devices[device]
+		r.mu.RUnlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("device %s not found in any fleet", device)
+	}
+	dc, _ := r.Get(name).(DeviceController)
+	return dc, nil
+}
+
+func (r *Registry) SetDoor(name string, open bool) error {
+	dc, err := r.deviceFleet(name)
+	if err != nil {
+		return err
+	}
+	return dc.SetDoor(name, open)
+}
+
+func (r *Registry) CallLift(name, targetArea string) error {
+	dc, err := r.deviceFleet(name)
+	if err != nil {
+		return err
+	}
+	return dc.CallLift(name, targetArea)
+}
+
+func (r *Registry) DisableDevice(kind, name string, disabled bool) error {
+	dc, err := r.deviceFleet(name)
+	if err != nil {
+		return err
+	}
+	return dc.DisableDevice(kind, name, disabled)
+}
+
+// --- NodeOccupancyProvider ---
+
+func (r *Registry) GetNodeOccupancy(groups ...string) ([]OccupancyDetail, error) {
+	var out []OccupancyDetail
+	var errs []error
+	r.each(func(name string, b Backend) {
+		np, ok := b.(NodeOccupancyProvider)
+		if !ok {
+			return
+		}
+		occ, err := np.GetNodeOccupancy(groups...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		out = append(out, occ...)
+	})
+	return out, errors.Join(errs...)
+}
+
+// --- SceneSyncer ---
+
+// GetSceneAreas merges the scenes of every fleet. It fails if any fleet's
+// scene cannot be read, since a partial scene would delete the other
+// fleets' nodes on sync.
+func (r *Registry) GetSceneAreas() ([]SceneArea, error) {
+	var out []SceneArea
+	var errs []error
+	r.each(func(name string, b Backend) {
+		ss, ok := b.(SceneSyncer)
+		if !ok {
+			return
+		}
+		areas, err := ss.GetSceneAreas()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		out = append(out, areas...)
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return out, nil
+}
+
+// As returns the first fleet of type T: b itself, or one of its fleets when
+// b is a Registry. Use it for vendor-specific features such as the RDS
+// explorer, which the registry does not merge.
+func As[T any](b Backend) (T, bool) {
+	if t, ok := b.(T); ok {
+		return t, true
+	}
+	var zero T
+	r, ok := b.(*Registry)
+	if !ok {
+		return zero, false
+	}
+	for _, name := range r.Names() {
+		if t, ok := r.Get(name).(T); ok {
+			return t, true
+		}
+	}
+	return zero, false
+}
+
+// ForOrder returns the fleet that owns a vendor order: b itself, or the
+// owning fleet when b is a Registry. State strings of an order must be mapped
+// with this fleet.
+func ForOrder(b Backend, vendorOrderID string) Backend {
+	if r, ok := b.(*Registry); ok {
+		return r.ForOrder(vendorOrderID)
+	}
+	return b
+}
+
+// ForZone returns the fleet that serves a zone: b itself, or the fleet the
+// zone is bound to when b is a Registry. Zone mutex groups belong to that
+// fleet.
+func ForZone(b Backend, zone string) Backend {
+	if r, ok := b.(*Registry); ok {
+		return r.ForZone(zone)
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package fleet
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRegistry_BindingsNeedKnownFleets(t *testing.T) {
+	r := NewRegistry()
+	r.Add("warehouse", nil)
+	r.Add("assembly", nil)
+
+	if err := r.BindZone("A", "assmebly"); err == nil {
+		t.Error("binding a zone to an unknown fleet should fail")
+	}
+	if err := r.BindZone("A", "assembly"); err != nil {
+		t.Fatalf("bind zone: %v", err)
+	}
+	if err := r.BindZone("A", "warehouse"); err == nil {
+		t.Error("binding a zone to a second fleet should fail")
+	}
+	if err := r.BindNode("LINE1-IN", "nowhere"); err == nil {
+		t.Error("binding a node to an unknown fleet should fail")
+	}
+	if err := r.SetHandoff("warehouse", "nowhere", "HANDOFF-1"); err == nil {
+		t.Error("a handoff naming an unknown fleet should fail")
+	}
+	if got := r.Route("STORAGE-A1", "A"); got != "assembly" {
+		t.Errorf("route = %q, want assembly", got)
+	}
+}
+
+// deviceFleet is a fleet that controls the named doors and records commands.
+type deviceFleet struct {
+	Backend
+	doors []string
+	calls []string
+}
+
+func (f *deviceFleet) GetDevicesStatus() ([]DeviceStatus, error) {
+	var out []DeviceStatus
+	for _, d := range f.doors {
+		out = append(out, DeviceStatus{Name: d, Kind: DeviceDoor})
+	}
+	return out, nil
+}
+
+func (f *deviceFleet) SetDoor(name string, open bool) error {
+	f.calls = append(f.calls, fmt.Sprintf("door %s open=%v", name, open))
+	return nil
+}
+
+func (f *deviceFleet) CallLift(name, area string) error { return nil }
+
+func (f *deviceFleet) DisableDevice(kind, name string, disabled bool) error {
+	f.calls = append(f.calls, fmt.Sprintf("%s %s disabled=%v", kind, name, disabled))
+	return nil
+}
+
+func TestRegistry_DevicesRouteToTheirFleet(t *testing.T) {
+	warehouse := &deviceFleet{doors: []string{"D1"}}
+	assembly := &deviceFleet{doors: []string{"D2"}}
+	r := NewRegistry()
+	r.Add("warehouse", warehouse)
+	r.Add("assembly", assembly)
+
+	if err := r.SetDoor("D2", true); err != nil {
+		t.Fatalf("set door: %v", err)
+	}
+	r.DisableDevice(DeviceDoor, "D1", true)
+	if fmt.Sprint(assembly.calls) != "[door D2 open=true]" || fmt.Sprint(warehouse.calls) != "[door D1 disabled=true]" {
+		t.Errorf("warehouse calls = %v, assembly calls = %v", warehouse.calls, assembly.calls)
+	}
+	if err := r.SetDoor("D3", true); err == nil {
+		t.Error("a device no fleet reports should fail")
+	}
+	if devices, _ := r.GetDevicesStatus(); len(devices) != 2 {
+		t.Errorf("devices = %+v, want both fleets' doors", devices)
+	}
+}
@@ -2,6 +2,9 @@ package seerrds
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"shingocore/fleet"
@@ -13,14 +16,20 @@ type Config struct {
 	BaseURL      string
 	Timeout      time.Duration
 	PollInterval time.Duration
-	DebugLog     func(string, ...any)
+	// PollIntervals and PageSize tune the poller; see rds.Poller.
+	PollIntervals rds.PollIntervals
+	PageSize      int
+	DebugLog      func(string, ...any)
 }
 
-// Adapter wraps an rds.Client to implement fleet.TrackingBackend,
-// fleet.RobotLister, fleet.NodeOccupancyProvider, and fleet.VendorProxy.
+// Adapter wraps an rds.Client to implement fleet.TrackingBackend, fleet.MultiStopCreator,
+// fleet.OrderLookup, fleet.OrderLister, fleet.RobotLister, fleet.NodeOccupancyProvider, fleet.RobotCharger,
+// fleet.DeviceController, fleet.ZoneLocker and fleet.VendorProxy.
 type Adapter struct {
 	client       *rds.Client
 	pollInterval time.Duration
+	intervals    rds.PollIntervals
+	pageSize     int
 	poller       *rds.Poller
 	debugLog     func(string, ...any)
 }
@@ -32,6 +41,8 @@ func New(cfg Config) *Adapter {
 	return &Adapter{
 		client:       client,
 		pollInterval: cfg.PollInterval,
+		intervals:    cfg.PollIntervals,
+		pageSize:     cfg.PageSize,
 		debugLog:     cfg.DebugLog,
 	}
 }
@@ -52,6 +63,40 @@ func (a *Adapter) CreateTransportOrder(req fleet.TransportOrderRequest) (fleet.T
 	return fleet.TransportOrderResult{VendorOrderID: req.OrderID}, nil
 }
 
+// --- fleet.MultiStopCreator ---
+
+// Bin tasks run at each block of a multi-stop order.
+const (
+	binTaskLoad   = "load"
+	binTaskUnload = "unload"
+)
+
+func (a *Adapter) CreateMultiStopOrder(req fleet.MultiStopOrderRequest) (fleet.TransportOrderResult, error) {
+	blocks := make([]rds.Block, len(req.Stops))
+	for i, s := range req.Stops {
+		binTask := binTaskUnload
+		if s.Action == fleet.StopPick {
+			binTask = binTaskLoad
+		}
+		blocks[i] = rds.Block{
+			BlockID:  fmt.Sprintf("%s-%d", req.OrderID, i+1),
+			Location: s.Loc,
+			BinTask:  binTask,
+		}
+	}
+	rdsReq := &rds.SetOrderRequest{
+		ID:         req.OrderID,
+		ExternalID: req.ExternalID,
+		Priority:   req.Priority,
+		Blocks:     blocks,
+		Complete:   true,
+	}
+	if err := a.client.CreateOrder(rdsReq); err != nil {
+		return fleet.TransportOrderResult{}, err
+	}
+	return fleet.TransportOrderResult{VendorOrderID: req.OrderID}, nil
+}
+
 func (a *Adapter) CancelOrder(vendorOrderID string) error {
 	return a.client.TerminateOrder(&rds.TerminateRequest{
 		ID:             vendorOrderID,
@@ -88,6 +133,56 @@ func (a *Adapter) Reconfigure(cfg fleet.ReconfigureParams) {
 	a.client.Reconfigure(cfg.BaseURL, timeout)
 }
 
+// --- fleet.OrderLookup ---
+
+// GetOrderState returns the RDS state of an order. Only RDS reporting the
+// order as unknown maps to fleet.ErrOrderNotFound; any other failure is
+// passed through, since the order may still be running.
+func (a *Adapter) GetOrderState(vendorOrderID string) (string, error) {
+	detail, err := a.client.GetOrderDetails(vendorOrderID)
+	if err != nil {
+		if rds.IsNotFound(err) {
+			return "", fleet.ErrOrderNotFound
+		}
+		return "", err
+	}
+	return string(detail.State), nil
+}
+
+// --- fleet.OrderLister ---
+
+func (a *Adapter) ListOrders(page, size int) ([]fleet.VendorOrder, error) {
+	details, err := a.client.ListOrders(page, size)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]fleet.VendorOrder, len(details))
+	for i := range details {
+		out[i] = vendorOrder(&details[i])
+	}
+	return out, nil
+}
+
+func (a *Adapter) GetOrderByExternalID(externalID string) (fleet.VendorOrder, error) {
+	detail, err := a.client.GetOrderByExternalID(externalID)
+	if err != nil {
+		if rds.IsNotFound(err) {
+			return fleet.VendorOrder{}, fleet.ErrOrderNotFound
+		}
+		return fleet.VendorOrder{}, err
+	}
+	return vendorOrder(detail), nil
+}
+
+func vendorOrder(d *rds.OrderDetail) fleet.VendorOrder {
+	return fleet.VendorOrder{
+		VendorOrderID: d.ID,
+		ExternalID:    d.ExternalID,
+		State:         string(d.State),
+		RobotID:       d.Vehicle,
+	}
+}
+
 // --- fleet.TrackingBackend ---
 
 func (a *Adapter) InitTracker(emitter fleet.TrackerEmitter, resolver fleet.OrderIDResolver) {
@@ -95,12 +190,24 @@ func (a *Adapter) InitTracker(emitter fleet.TrackerEmitter, resolver fleet.Order
 	resolverBridge := &resolverBridge{resolver: resolver}
 	a.poller = rds.NewPoller(a.client, bridge, resolverBridge, a.pollInterval)
 	a.poller.DebugLog = a.debugLog
+	a.poller.Intervals = a.intervals
+	if a.pageSize > 0 {
+		a.poller.PageSize = a.pageSize
+	}
 }
 
 func (a *Adapter) Tracker() fleet.OrderTracker {
 	return a.poller
 }
 
+// PollStats reports the poller's call counts and latency.
+func (a *Adapter) PollStats() rds.PollStats {
+	if a.poller == nil {
+		return rds.PollStats{}
+	}
+	return a.poller.Stats()
+}
+
 // --- fleet.RobotLister ---
 
 func (a *Adapter) GetRobotsStatus() ([]fleet.RobotStatus, error) {
@@ -126,6 +233,47 @@ func (a *Adapter) SetAvailability(vehicleID string, available bool) error {
 	})
 }
 
+// --- fleet.RobotCharger ---
+
+// SendToCharge drives the robot to a charge point; RDS starts charging when
+// the robot arrives there.
+func (a *Adapter) SendToCharge(orderID, vehicleID, loc string) error {
+	return a.client.CreateOrder(&rds.SetOrderRequest{
+		ID:       orderID,
+		Vehicle:  vehicleID,
+		Blocks:   []rds.Block{{BlockID: orderID + "-1", Location: loc}},
+		Complete: true,
+	})
+}
+
+// --- fleet.GoodsBinder ---
+
+func (a *Adapter) BindGoods(vehicleID, container, goodsID, desc string) error {
+	return a.client.BindContainerGoods(&rds.BindGoodsRequest{
+		Vehicle:       vehicleID,
+		ContainerName: container,
+		GoodsID:       goodsID,
+		Desc:          desc,
+	})
+}
+
+func (a *Adapter) UnbindGoods(vehicleID, goodsID string) error {
+	return a.client.UnbindGoods(vehicleID, goodsID)
+}
+
+// --- fleet.SimStateSetter ---
+
+// SetSimState updates a robot simulated by RDS. Field names are those of the
+// simulator's state template (getSimRobotStateTemplate).
+func (a *Adapter) SetSimState(vehicleID string, fields map[string]any) error {
+	req := make(map[string]any, len(fields)+1)
+	for k, v := range fields {
+		req[k] = v
+	}
+	req["vehicle"] = vehicleID
+	return a.client.UpdateSimState(req)
+}
+
 func (a *Adapter) RetryFailed(vehicleID string) error {
 	return a.client.RedoFailed(&rds.RedoFailedRequest{
 		Vehicles: []string{vehicleID},
@@ -157,6 +305,86 @@ func (a *Adapter) GetNodeOccupancy(groups ...string) ([]fleet.OccupancyDetail, e
 	return result, nil
 }
 
+// --- fleet.DeviceController ---
+
+// RDS door states for callDoor.
+const (
+	doorClose = 0
+	doorOpen  = 1
+)
+
+func (a *Adapter) GetDevicesStatus() ([]fleet.DeviceStatus, error) {
+	resp, err := a.client.GetDevicesStatus()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]fleet.DeviceStatus, 0, len(resp.Doors)+len(resp.Lifts))
+	for _, d := range resp.Doors {
+		out = append(out, fleet.DeviceStatus{Name: d.Name, Kind: fleet.DeviceDoor, State: d.State, Disabled: d.Disabled, Fault: joinReasons(d.Reasons)})
+	}
+	for _, l := range resp.Lifts {
+		out = append(out, fleet.DeviceStatus{Name: l.Name, Kind: fleet.DeviceLift, State: l.State, Disabled: l.Disabled, Fault: joinReasons(l.Reasons)})
+	}
+	return out, nil
+}
+
+func joinReasons(reasons []rds.UnavailableReason) string {
+	parts := make([]string, len(reasons))
+	for i, r := range reasons {
+		parts[i] = r.Reason
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (a *Adapter) SetDoor(name string, open bool) error {
+	state := doorClose
+	if open {
+		state = doorOpen
+	}
+	return a.client.CallDoor([]rds.CallDoorRequest{{Name: name, State: state}})
+}
+
+func (a *Adapter) CallLift(name, targetArea string) error {
+	return a.client.CallLift([]rds.CallLiftRequest{{Name: name, TargetArea: targetArea}})
+}
+
+func (a *Adapter) DisableDevice(kind, name string, disabled bool) error {
+	req := &rds.DisableDeviceRequest{Names: []string{name}, Disabled: disabled}
+	switch kind {
+	case fleet.DeviceDoor:
+		return a.client.DisableDoor(req)
+	case fleet.DeviceLift:
+		return a.client.DisableLift(req)
+	}
+	return fmt.Errorf("unknown device kind %q", kind)
+}
+
+// --- fleet.ZoneLocker ---
+
+// OccupyMutexGroups occupies the groups for holder. If another party already
+// holds any of them, the groups taken are released again and an error names
+// the holder.
+func (a *Adapter) OccupyMutexGroups(holder string, groups []string) error {
+	results, err := a.client.OccupyMutexGroup(holder, groups)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Occupier != holder {
+			if _, err := a.client.ReleaseMutexGroup(holder, groups); err != nil {
+				log.Printf("seerrds: release mutex groups after failed occupy: %v", err)
+			}
+			return fmt.Errorf("mutex group %s is held by %s", r.Name, r.Occupier)
+		}
+	}
+	return nil
+}
+
+func (a *Adapter) ReleaseMutexGroups(holder string, groups []string) error {
+	_, err := a.client.ReleaseMutexGroup(holder, groups)
+	return err
+}
+
 // --- fleet.VendorProxy ---
 
 func (a *Adapter) BaseURL() string {
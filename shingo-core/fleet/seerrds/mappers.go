@@ -31,6 +31,12 @@ func IsTerminalState(vendorState string) bool {
 
 // mapRobotStatus converts an rds.RobotStatus to a fleet.RobotStatus.
 func mapRobotStatus(r rds.RobotStatus) fleet.RobotStatus {
+	var goods []fleet.ContainerGoods
+	for _, c := range r.RbkReport.Containers {
+		if c.HasGoods && c.GoodsID != "" {
+			goods = append(goods, fleet.ContainerGoods{Container: c.ContainerName, GoodsID: c.GoodsID})
+		}
+	}
 	return fleet.RobotStatus{
 		VehicleID:      r.VehicleID,
 		Connected:      r.ConnectionStatus != 0,
@@ -50,5 +56,6 @@ func mapRobotStatus(r rds.RobotStatus) fleet.RobotStatus {
 		NetworkDelay:   r.NetworkDelay,
 		CurrentStation: r.RbkReport.CurrentStation,
 		LastStation:    r.RbkReport.LastStation,
+		Goods:          goods,
 	}
 }
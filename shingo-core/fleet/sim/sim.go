@@ -0,0 +1,600 @@
+// Package sim is an in-process simulated fleet backend. Virtual robots drive
+// between scene locations at a fixed speed and take orders through the same
+// states as Seer RDS, so core and edge can run end to end without a fleet.
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"shingocore/fleet"
+	"shingocore/fleet/seerrds"
+	"shingocore/rds"
+)
+
+// AreaName is the scene area every simulated location belongs to.
+const AreaName = "sim"
+
+// Location is a named point robots can pick from and drop at.
+type Location struct {
+	Name  string
+	Group string
+	X, Y  float64 // metres
+}
+
+// DefaultLocations is a small plant layout: a row of eight storage
+// locations facing four line-side locations 20 m away.
+func DefaultLocations() []Location {
+	var locs []Location
+	for i := 0; i < 8; i++ {
+		locs = append(locs, Location{Name: fmt.Sprintf("STORE-%02d", i+1), Group: "storage", X: float64(i) * 3})
+	}
+	for i := 0; i < 4; i++ {
+		locs = append(locs, Location{Name: fmt.Sprintf("LINE-%02d", i+1), Group: "line", X: float64(i) * 6, Y: 20})
+	}
+	return locs
+}
+
+// Config holds the configuration for a simulated fleet.
+type Config struct {
+	Robots       int
+	Speed        float64       // metres per second
+	HandlingTime time.Duration // time spent at each stop picking or dropping
+	TickInterval time.Duration
+	FailRate     float64       // chance that a stop fails, failing its order
+	RejectRate   float64       // chance that order creation is rejected
+	RecoverTime  time.Duration // how long a robot stays in error after a failure
+	History      int           // ended orders kept for listing, default 1000
+	Locations    []Location
+	DebugLog     func(string, ...any)
+}
+
+// Backend is a simulated fleet. It implements fleet.TrackingBackend,
+// fleet.MultiStopCreator, fleet.OrderLookup, fleet.OrderLister,
+// fleet.RobotLister, fleet.SceneSyncer and fleet.NodeOccupancyProvider.
+type Backend struct {
+	cfg     Config
+	tracker *tracker
+
+	mu       sync.Mutex
+	robots   []*robot
+	orders   map[string]*order
+	created  []*order // creation order, for listing; ended ones beyond History are dropped
+	pending  []*order // orders not yet assigned to a robot, oldest first
+	locs     map[string]Location
+	occupied map[string]bool
+	failNext int
+	rng      *rand.Rand
+	stopChan chan struct{}
+}
+
+type order struct {
+	id         string
+	externalID string
+	stops      []fleet.TransportStop
+	next       int // index of the stop being driven to or worked at
+	dwell      time.Duration
+	state      rds.OrderState
+	priority   int
+	robot      *robot
+}
+
+type robot struct {
+	id        string
+	x, y      float64
+	battery   float64
+	available bool
+	errorFor  time.Duration // remaining time in error; 0 when healthy
+	order     *order
+	station   string // location the robot is stopped at, "" while driving
+	last      string
+}
+
+// New creates a simulated fleet with its robots parked at the first
+// locations.
+func New(cfg Config) *Backend {
+	if cfg.Robots <= 0 {
+		cfg.Robots = 1
+	}
+	if cfg.Speed <= 0 {
+		cfg.Speed = 1
+	}
+	if cfg.TickInterval <= 0 {
+		cfg.TickInterval = 500 * time.Millisecond
+	}
+	if cfg.History <= 0 {
+		cfg.History = 1000
+	}
+	b := &Backend{
+		cfg:      cfg,
+		orders:   make(map[string]*order),
+		locs:     make(map[string]Location),
+		occupied: make(map[string]bool),
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, l := range cfg.Locations {
+		b.locs[l.Name] = l
+	}
+	for i := 0; i < cfg.Robots; i++ {
+		r := &robot{id: fmt.Sprintf("SIM-%02d", i+1), battery: 100, available: true}
+		if len(cfg.Locations) > 0 {
+			l := cfg.Locations[i%len(cfg.Locations)]
+			r.x, r.y, r.station, r.last = l.X, l.Y, l.Name, l.Name
+		}
+		b.robots = append(b.robots, r)
+	}
+	return b
+}
+
+func (b *Backend) dbg(format string, args ...any) {
+	if fn := b.cfg.DebugLog; fn != nil {
+		fn(format, args...)
+	}
+}
+
+// --- fleet.Backend ---
+
+func (b *Backend) CreateTransportOrder(req fleet.TransportOrderRequest) (fleet.TransportOrderResult, error) {
+	return b.create(req.OrderID, req.ExternalID, req.Priority, []fleet.TransportStop{
+		{Loc: req.FromLoc, Action: fleet.StopPick},
+		{Loc: req.ToLoc, Action: fleet.StopDrop},
+	})
+}
+
+// --- fleet.MultiStopCreator ---
+
+func (b *Backend) CreateMultiStopOrder(req fleet.MultiStopOrderRequest) (fleet.TransportOrderResult, error) {
+	return b.create(req.OrderID, req.ExternalID, req.Priority, req.Stops)
+}
+
+func (b *Backend) create(id, externalID string, priority int, stops []fleet.TransportStop) (fleet.TransportOrderResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, exists := b.orders[id]; exists {
+		return fleet.TransportOrderResult{}, fmt.Errorf("sim: order %s already exists", id)
+	}
+	if len(stops) == 0 {
+		return fleet.TransportOrderResult{}, fmt.Errorf("sim: order %s has no stops", id)
+	}
+	for _, s := range stops {
+		if _, ok := b.locs[s.Loc]; !ok {
+			return fleet.TransportOrderResult{}, fmt.Errorf("sim: unknown location %q", s.Loc)
+		}
+	}
+	if b.cfg.RejectRate > 0 && b.rng.Float64() < b.cfg.RejectRate {
+		return fleet.TransportOrderResult{}, fmt.Errorf("sim: order %s rejected (injected)", id)
+	}
+	o := &order{id: id, externalID: externalID, stops: stops, state: rds.StateCreated, priority: priority}
+	b.orders[id] = o
+	b.created = append(b.created, o)
+	b.pending = append(b.pending, o)
+	b.dbg("create %s: %d stops, priority %d", id, len(stops), priority)
+	return fleet.TransportOrderResult{VendorOrderID: id}, nil
+}
+
+func (b *Backend) CancelOrder(vendorOrderID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.orders[vendorOrderID]
+	if !ok {
+		return fmt.Errorf("sim: order %s not found", vendorOrderID)
+	}
+	if o.state.IsTerminal() {
+		return nil
+	}
+	b.finish(o, rds.StateStopped)
+	return nil
+}
+
+func (b *Backend) SetOrderPriority(vendorOrderID string, priority int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.orders[vendorOrderID]
+	if !ok {
+		return fmt.Errorf("sim: order %s not found", vendorOrderID)
+	}
+	o.priority = priority
+	return nil
+}
+
+func (b *Backend) Ping() error { return nil }
+
+func (b *Backend) Name() string { return "Simulator" }
+
+// MapState uses the RDS mapping, since the simulator reports RDS states.
+func (b *Backend) MapState(vendorState string) string {
+	return seerrds.MapState(vendorState)
+}
+
+func (b *Backend) IsTerminalState(vendorState string) bool {
+	return rds.OrderState(vendorState).IsTerminal()
+}
+
+// Reconfigure is a no-op: the simulator has no connection settings.
+func (b *Backend) Reconfigure(fleet.ReconfigureParams) {}
+
+// --- fleet.OrderLookup ---
+
+func (b *Backend) GetOrderState(vendorOrderID string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.orders[vendorOrderID]
+	if !ok {
+		return "", fleet.ErrOrderNotFound
+	}
+	return string(o.state), nil
+}
+
+// --- fleet.OrderLister ---
+
+// ListOrders pages through orders newest first; page is 1-based.
+func (b *Backend) ListOrders(page, size int) ([]fleet.VendorOrder, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []fleet.VendorOrder
+	start := (page - 1) * size
+	for i := len(b.created) - 1 - start; i >= 0 && len(out) < size; i-- {
+		out = append(out, b.created[i].vendorOrder())
+	}
+	return out, nil
+}
+
+func (b *Backend) GetOrderByExternalID(externalID string) (fleet.VendorOrder, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := len(b.created) - 1; i >= 0; i-- {
+		if b.created[i].externalID == externalID {
+			return b.created[i].vendorOrder(), nil
+		}
+	}
+	return fleet.VendorOrder{}, fleet.ErrOrderNotFound
+}
+
+func (o *order) vendorOrder() fleet.VendorOrder {
+	vo := fleet.VendorOrder{VendorOrderID: o.id, ExternalID: o.externalID, State: string(o.state)}
+	if o.robot != nil {
+		vo.RobotID = o.robot.id
+	}
+	return vo
+}
+
+// --- fleet.TrackingBackend ---
+
+func (b *Backend) InitTracker(emitter fleet.TrackerEmitter, resolver fleet.OrderIDResolver) {
+	b.tracker = newTracker(b, emitter, resolver)
+}
+
+func (b *Backend) Tracker() fleet.OrderTracker {
+	return b.tracker
+}
+
+// --- fleet.RobotLister ---
+
+func (b *Backend) GetRobotsStatus() ([]fleet.RobotStatus, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]fleet.RobotStatus, len(b.robots))
+	for i, r := range b.robots {
+		out[i] = fleet.RobotStatus{
+			VehicleID:      r.id,
+			Connected:      true,
+			Available:      r.available,
+			Busy:           r.order != nil,
+			IsError:        r.errorFor > 0,
+			BatteryLevel:   r.battery,
+			Charging:       r.order == nil && r.battery < 100,
+			CurrentMap:     AreaName,
+			Model:          "sim",
+			X:              r.x,
+			Y:              r.y,
+			CurrentStation: r.station,
+			LastStation:    r.last,
+		}
+	}
+	return out, nil
+}
+
+func (b *Backend) SetAvailability(vehicleID string, available bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, err := b.robot(vehicleID)
+	if err != nil {
+		return err
+	}
+	r.available = available
+	return nil
+}
+
+// RetryFailed clears a robot's error state.
+func (b *Backend) RetryFailed(vehicleID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, err := b.robot(vehicleID)
+	if err != nil {
+		return err
+	}
+	r.errorFor = 0
+	return nil
+}
+
+// ForceComplete finishes a robot's current order on the spot and clears its
+// error state.
+func (b *Backend) ForceComplete(vehicleID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, err := b.robot(vehicleID)
+	if err != nil {
+		return err
+	}
+	r.errorFor = 0
+	if r.order != nil {
+		b.finish(r.order, rds.StateFinished)
+	}
+	return nil
+}
+
+func (b *Backend) robot(vehicleID string) (*robot, error) {
+	for _, r := range b.robots {
+		if r.id == vehicleID {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("sim: robot %s not found", vehicleID)
+}
+
+// --- fleet.SceneSyncer ---
+
+func (b *Backend) GetSceneAreas() ([]fleet.SceneArea, error) {
+	area := fleet.SceneArea{Name: AreaName}
+	for _, l := range b.cfg.Locations {
+		area.BinLocations = append(area.BinLocations, fleet.ScenePoint{
+			ClassName:    "GeneralLocation",
+			InstanceName: l.Name,
+			PointName:    l.Name,
+			GroupName:    l.Group,
+			PosX:         l.X,
+			PosY:         l.Y,
+		})
+	}
+	return []fleet.SceneArea{area}, nil
+}
+
+// --- fleet.NodeOccupancyProvider ---
+
+func (b *Backend) GetNodeOccupancy(groups ...string) ([]fleet.OccupancyDetail, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []fleet.OccupancyDetail
+	for _, l := range b.cfg.Locations {
+		if len(groups) > 0 && !contains(groups, l.Group) {
+			continue
+		}
+		out = append(out, fleet.OccupancyDetail{ID: l.Name, Occupied: b.occupied[l.Name]})
+	}
+	return out, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Failure injection ---
+
+// FailNext makes the next n stops worked by any robot fail.
+func (b *Backend) FailNext(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failNext += n
+}
+
+// FailOrder fails an order now, putting its robot into error.
+func (b *Backend) FailOrder(vendorOrderID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.orders[vendorOrderID]
+	if !ok {
+		return fmt.Errorf("sim: order %s not found", vendorOrderID)
+	}
+	if o.state.IsTerminal() {
+		return fmt.Errorf("sim: order %s already %s", vendorOrderID, o.state)
+	}
+	b.fail(o)
+	return nil
+}
+
+// --- Simulation ---
+
+func (b *Backend) start() {
+	b.mu.Lock()
+	if b.stopChan != nil {
+		b.mu.Unlock()
+		return
+	}
+	b.stopChan = make(chan struct{})
+	stop := b.stopChan
+	b.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(b.cfg.TickInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				b.step(b.cfg.TickInterval)
+				if b.tracker != nil {
+					b.tracker.Check()
+				}
+			}
+		}
+	}()
+}
+
+func (b *Backend) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopChan != nil {
+		close(b.stopChan)
+		b.stopChan = nil
+	}
+}
+
+// step advances the simulation by dt: new orders queue for dispatch, waiting
+// orders are assigned to the nearest free robot, and robots drive and work
+// their stops.
+func (b *Backend) step(dt time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var waiting []*order
+	for _, o := range b.pending {
+		switch o.state {
+		case rds.StateCreated:
+			o.state = rds.StateToBeDispatched
+		case rds.StateToBeDispatched:
+			waiting = append(waiting, o)
+		}
+	}
+	sort.SliceStable(waiting, func(i, j int) bool { return waiting[i].priority > waiting[j].priority })
+	for _, o := range waiting {
+		if r := b.nearestFree(b.locs[o.stops[0].Loc]); r != nil {
+			o.robot, r.order = r, o
+			o.state = rds.StateRunning
+			b.dbg("assign %s to %s", o.id, r.id)
+		}
+	}
+	// Assigned and cancelled orders leave the queue; the robots carry the rest.
+	left := b.pending[:0]
+	for _, o := range b.pending {
+		if o.state == rds.StateCreated || o.state == rds.StateToBeDispatched {
+			left = append(left, o)
+		}
+	}
+	clear(b.pending[len(left):])
+	b.pending = left
+
+	for _, r := range b.robots {
+		if r.errorFor > 0 {
+			r.errorFor = max(r.errorFor-dt, 0)
+			continue
+		}
+		if r.order == nil {
+			r.battery = math.Min(100, r.battery+dt.Seconds()*0.5)
+			continue
+		}
+		b.advance(r, dt)
+	}
+}
+
+func (b *Backend) nearestFree(l Location) *robot {
+	var best *robot
+	bestDist := math.Inf(1)
+	for _, r := range b.robots {
+		if r.order != nil || !r.available || r.errorFor > 0 {
+			continue
+		}
+		if d := math.Hypot(l.X-r.x, l.Y-r.y); d < bestDist {
+			best, bestDist = r, d
+		}
+	}
+	return best
+}
+
+// advance drives a robot toward its order's current stop and works the stop
+// once there.
+func (b *Backend) advance(r *robot, dt time.Duration) {
+	o := r.order
+	stop := o.stops[o.next]
+	l := b.locs[stop.Loc]
+
+	left := dt
+	if d := math.Hypot(l.X-r.x, l.Y-r.y); d > 0 {
+		travel := b.cfg.Speed * dt.Seconds()
+		if travel < d {
+			r.x += (l.X - r.x) * travel / d
+			r.y += (l.Y - r.y) * travel / d
+			r.battery = math.Max(0, r.battery-travel*0.05)
+			r.station = ""
+			return
+		}
+		r.x, r.y = l.X, l.Y
+		r.battery = math.Max(0, r.battery-d*0.05)
+		left -= time.Duration(d / b.cfg.Speed * float64(time.Second))
+	}
+	r.station, r.last = stop.Loc, stop.Loc
+
+	o.dwell += left
+	if o.dwell < b.cfg.HandlingTime {
+		return
+	}
+	if b.failNext > 0 || (b.cfg.FailRate > 0 && b.rng.Float64() < b.cfg.FailRate) {
+		if b.failNext > 0 {
+			b.failNext--
+		}
+		b.fail(o)
+		return
+	}
+	b.occupied[stop.Loc] = stop.Action == fleet.StopDrop
+	o.dwell = 0
+	o.next++
+	if o.next == len(o.stops) {
+		b.finish(o, rds.StateFinished)
+	}
+}
+
+// fail ends an order as FAILED and puts its robot into error.
+func (b *Backend) fail(o *order) {
+	if r := o.robot; r != nil {
+		r.errorFor = b.cfg.RecoverTime
+		if r.errorFor <= 0 {
+			r.errorFor = 30 * time.Second
+		}
+	}
+	b.dbg("fail %s at stop %d", o.id, o.next+1)
+	b.finish(o, rds.StateFailed)
+}
+
+// finish moves an order to a terminal state and frees its robot.
+func (b *Backend) finish(o *order, state rds.OrderState) {
+	o.state = state
+	if o.robot != nil {
+		o.robot.order = nil
+	}
+	b.trim()
+}
+
+// trim drops the oldest ended orders once more than History have ended.
+// Orders still in flight are always kept.
+func (b *Backend) trim() {
+	ended := 0
+	for _, o := range b.created {
+		if o.state.IsTerminal() {
+			ended++
+		}
+	}
+	if ended <= b.cfg.History {
+		return
+	}
+	drop := ended - b.cfg.History
+	kept := b.created[:0]
+	for _, o := range b.created {
+		if drop > 0 && o.state.IsTerminal() {
+			delete(b.orders, o.id)
+			drop--
+			continue
+		}
+		kept = append(kept, o)
+	}
+	clear(b.created[len(kept):])
+	b.created = kept
+}
@@ -0,0 +1,170 @@
+package sim
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"shingocore/fleet"
+	"shingocore/rds"
+)
+
+type recordingEmitter struct {
+	states []string
+}
+
+func (e *recordingEmitter) EmitOrderStatusChanged(orderID int64, vendorOrderID, oldStatus, newStatus, robotID, detail string) {
+	e.states = append(e.states, newStatus)
+}
+
+type fixedResolver struct{}
+
+func (fixedResolver) ResolveVendorOrderID(string) (int64, error) { return 1, nil }
+
+func testBackend(t *testing.T) (*Backend, *recordingEmitter) {
+	t.Helper()
+	b := New(Config{
+		Robots:       1,
+		Speed:        2,
+		HandlingTime: time.Second,
+		Locations: []Location{
+			{Name: "A", Group: "storage"},
+			{Name: "B", Group: "line", X: 10},
+		},
+	})
+	em := &recordingEmitter{}
+	b.InitTracker(em, fixedResolver{})
+	return b, em
+}
+
+// run steps the simulation in one-second ticks until the order is terminal.
+func run(t *testing.T, b *Backend, id string) string {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		b.step(time.Second)
+		b.tracker.Check()
+		state, _ := b.GetOrderState(id)
+		if rds.OrderState(state).IsTerminal() {
+			return state
+		}
+	}
+	t.Fatalf("order %s never finished", id)
+	return ""
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	b, em := testBackend(t)
+	if _, err := b.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "o1", FromLoc: "A", ToLoc: "B"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	b.Tracker().Track("o1")
+
+	if got := run(t, b, "o1"); got != string(rds.StateFinished) {
+		t.Fatalf("final state = %s", got)
+	}
+	want := []string{"TOBEDISPATCHED", "RUNNING", "FINISHED"}
+	if fmt.Sprint(em.states) != fmt.Sprint(want) {
+		t.Errorf("transitions = %v, want %v", em.states, want)
+	}
+	if b.Tracker().ActiveCount() != 0 {
+		t.Error("terminal order still tracked")
+	}
+
+	occ, _ := b.GetNodeOccupancy("line")
+	if len(occ) != 1 || occ[0].ID != "B" || !occ[0].Occupied {
+		t.Errorf("line occupancy = %+v, want B occupied", occ)
+	}
+	robots, _ := b.GetRobotsStatus()
+	if robots[0].LastStation != "B" || robots[0].X != 10 || robots[0].Busy {
+		t.Errorf("robot = %+v, want idle at B", robots[0])
+	}
+}
+
+func TestCreateRejectsUnknownLocation(t *testing.T) {
+	b, _ := testBackend(t)
+	if _, err := b.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "o1", FromLoc: "A", ToLoc: "nowhere"}); err == nil {
+		t.Fatal("expected error for unknown location")
+	}
+	if _, err := b.GetOrderState("o1"); err != fleet.ErrOrderNotFound {
+		t.Errorf("lookup err = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestFailureInjection(t *testing.T) {
+	b, _ := testBackend(t)
+	b.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "o1", FromLoc: "A", ToLoc: "B"})
+	b.FailNext(1)
+	if got := run(t, b, "o1"); got != string(rds.StateFailed) {
+		t.Fatalf("final state = %s, want FAILED", got)
+	}
+	robots, _ := b.GetRobotsStatus()
+	if !robots[0].IsError {
+		t.Error("robot not in error after failure")
+	}
+
+	// An errored robot takes no work until it is cleared.
+	b.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "o2", FromLoc: "A", ToLoc: "B"})
+	b.step(time.Second)
+	b.step(time.Second)
+	if state, _ := b.GetOrderState("o2"); state != string(rds.StateToBeDispatched) {
+		t.Errorf("state with robot in error = %s", state)
+	}
+	b.RetryFailed(robots[0].VehicleID)
+	if got := run(t, b, "o2"); got != string(rds.StateFinished) {
+		t.Errorf("after retry final state = %s", got)
+	}
+}
+
+func TestCancelAndPriority(t *testing.T) {
+	b, _ := testBackend(t)
+	b.SetAvailability("SIM-01", false)
+	b.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "low", FromLoc: "A", ToLoc: "B"})
+	b.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "high", FromLoc: "A", ToLoc: "B"})
+	b.SetOrderPriority("high", 10)
+	b.step(time.Second)
+	b.step(time.Second)
+
+	b.SetAvailability("SIM-01", true)
+	b.step(time.Second)
+	if state, _ := b.GetOrderState("high"); state != string(rds.StateRunning) {
+		t.Errorf("high priority order = %s, want RUNNING", state)
+	}
+
+	if err := b.CancelOrder("high"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if state, _ := b.GetOrderState("high"); state != string(rds.StateStopped) {
+		t.Errorf("cancelled order = %s, want STOPPED", state)
+	}
+	if got := run(t, b, "low"); got != string(rds.StateFinished) {
+		t.Errorf("low priority order = %s after robot freed", got)
+	}
+	if vo, _ := b.ListOrders(1, 1); len(vo) != 1 || vo[0].VendorOrderID != "high" {
+		t.Errorf("first page = %+v, want newest order", vo)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	b, _ := testBackend(t)
+	b.cfg.History = 2
+	b.SetAvailability("SIM-01", false)
+	for _, id := range []string{"o1", "o2", "o3", "o4"} {
+		b.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: id, FromLoc: "A", ToLoc: "B"})
+	}
+	b.step(time.Second)
+	for _, id := range []string{"o1", "o2", "o3"} {
+		b.CancelOrder(id)
+	}
+	b.step(time.Second)
+
+	if len(b.pending) != 1 || b.pending[0].id != "o4" {
+		t.Errorf("pending = %d orders, want only o4", len(b.pending))
+	}
+	if _, err := b.GetOrderState("o1"); err != fleet.ErrOrderNotFound {
+		t.Errorf("oldest ended order still kept: err = %v", err)
+	}
+	vo, _ := b.ListOrders(1, 10)
+	if len(vo) != 3 || vo[0].VendorOrderID != "o4" || vo[2].VendorOrderID != "o2" {
+		t.Errorf("listed %+v, want o4, o3, o2", vo)
+	}
+}
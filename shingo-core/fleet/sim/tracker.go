@@ -0,0 +1,43 @@
+package sim
+
+import (
+	"shingocore/fleet"
+	"shingocore/rds"
+)
+
+// tracker reports state changes of tracked orders after every simulation
+// step, the way rds.Poller does for a real fleet. Starting it starts the
+// simulation clock.
+type tracker struct {
+	*fleet.StateTracker
+	b *Backend
+}
+
+func newTracker(b *Backend, emitter fleet.TrackerEmitter, resolver fleet.OrderIDResolver) *tracker {
+	t := &tracker{StateTracker: fleet.NewStateTracker("sim", string(rds.StateCreated), b, emitter, resolver), b: b}
+	t.DebugLog = b.dbg
+	return t
+}
+
+func (t *tracker) Start() { t.b.start() }
+
+func (t *tracker) Stop() { t.b.stop() }
+
+// OrderStates implements fleet.StateSource.
+func (b *Backend) OrderStates(ids []string) map[string]fleet.VendorState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]fleet.VendorState, len(ids))
+	for _, id := range ids {
+		o, ok := b.orders[id]
+		if !ok {
+			continue
+		}
+		vs := fleet.VendorState{State: string(o.state)}
+		if o.robot != nil {
+			vs.RobotID = o.robot.id
+		}
+		out[id] = vs
+	}
+	return out
+}
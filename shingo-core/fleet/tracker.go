@@ -1,5 +1,12 @@
 package fleet
 
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
 // OrderTracker tracks active vendor orders and emits status change events.
 type OrderTracker interface {
 	Track(vendorOrderID string)
@@ -9,6 +16,14 @@ type OrderTracker interface {
 	Stop()
 }
 
+// SeededTracker is implemented by trackers that can resume an order from its
+// last persisted vendor state. On startup the engine seeds the tracker with
+// orders.vendor_state so the first poll after a restart reports only what
+// changed while core was down.
+type SeededTracker interface {
+	TrackFrom(vendorOrderID, lastState string)
+}
+
 // TrackerEmitter receives state transition events from a tracker.
 type TrackerEmitter interface {
 	EmitOrderStatusChanged(orderID int64, vendorOrderID, oldStatus, newStatus, robotID, detail string)
@@ -25,3 +40,130 @@ type TrackingBackend interface {
 	InitTracker(emitter TrackerEmitter, resolver OrderIDResolver)
 	Tracker() OrderTracker
 }
+
+// VendorState is a backend's current view of one order, as read by a
+// StateTracker.
+type VendorState struct {
+	State   string
+	RobotID string
+	Detail  string // optional, appended to the transition detail
+}
+
+// StateSource is the backend a StateTracker reads. OrderStates returns the
+// current state of each of the given orders it knows.
+type StateSource interface {
+	OrderStates(vendorOrderIDs []string) map[string]VendorState
+	IsTerminalState(vendorState string) bool
+}
+
+// StateTracker is the OrderTracker of backends that hold their orders in
+// memory and know when they change, such as the simulator and VDA 5050.
+// Nothing is polled: the backend calls Check after anything that may move an
+// order. Backends embed it and add their own Start and Stop.
+type StateTracker struct {
+	name     string // log prefix
+	initial  string // state of a newly tracked order
+	source   StateSource
+	emitter  TrackerEmitter
+	resolver OrderIDResolver
+	DebugLog func(string, ...any)
+
+	mu     sync.Mutex
+	active map[string]string // vendor order ID -> last reported state
+}
+
+func NewStateTracker(name, initial string, source StateSource, emitter TrackerEmitter, resolver OrderIDResolver) *StateTracker {
+	return &StateTracker{
+		name:     name,
+		initial:  initial,
+		source:   source,
+		emitter:  emitter,
+		resolver: resolver,
+		active:   make(map[string]string),
+	}
+}
+
+func (t *StateTracker) Track(vendorOrderID string) {
+	t.TrackFrom(vendorOrderID, "")
+}
+
+// TrackFrom starts reporting an order from its last known state, so an
+// order resumed after a restart reports only what changed since.
+func (t *StateTracker) TrackFrom(vendorOrderID, lastState string) {
+	if lastState == "" {
+		lastState = t.initial
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, exists := t.active[vendorOrderID]; !exists {
+		t.active[vendorOrderID] = lastState
+	}
+}
+
+func (t *StateTracker) Untrack(vendorOrderID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.active, vendorOrderID)
+}
+
+func (t *StateTracker) ActiveCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.active)
+}
+
+func (t *StateTracker) Start() {}
+
+func (t *StateTracker) Stop() {}
+
+type stateTransition struct {
+	id       string
+	old, new string
+	robot    string
+	detail   string
+}
+
+// Check emits one event per tracked order whose state moved since the last
+// check. The tracker lock is held while reading the backend so concurrent
+// checks never report a transition twice; events are emitted after it is
+// released, since handlers may call back into the backend.
+func (t *StateTracker) Check() {
+	var changes []stateTransition
+	t.mu.Lock()
+	ids := make([]string, 0, len(t.active))
+	for id := range t.active {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	states := t.source.OrderStates(ids)
+	for _, id := range ids {
+		old := t.active[id]
+		cur, ok := states[id]
+		if !ok || cur.State == old {
+			continue
+		}
+		tr := stateTransition{id: id, old: old, new: cur.State, robot: cur.RobotID, detail: fmt.Sprintf("fleet state: %s -> %s", old, cur.State)}
+		if cur.Detail != "" {
+			tr.detail += " (" + cur.Detail + ")"
+		}
+		changes = append(changes, tr)
+		if t.source.IsTerminalState(cur.State) {
+			delete(t.active, id)
+		} else {
+			t.active[id] = cur.State
+		}
+	}
+	t.mu.Unlock()
+
+	for _, c := range changes {
+		orderID, err := t.resolver.ResolveVendorOrderID(c.id)
+		if err != nil {
+			log.Printf("%s: resolve %s: %v", t.name, c.id, err)
+			continue
+		}
+		if fn := t.DebugLog; fn != nil {
+			fn("transition %s: %s -> %s (robot=%s)", c.id, c.old, c.new, c.robot)
+		}
+		t.emitter.EmitOrderStatusChanged(orderID, c.id, c.old, c.new, c.robot, c.detail)
+	}
+}
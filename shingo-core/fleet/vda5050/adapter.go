@@ -0,0 +1,796 @@
+// Package vda5050 is a fleet backend for vehicles that speak VDA 5050 v2 over
+// MQTT. VDA 5050 has no fleet manager in the middle, so the adapter is the
+// master control: it queues transport orders, assigns each to a free
+// vehicle, publishes the order, and derives the order's state from the
+// vehicle's state messages.
+package vda5050
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shingocore/dispatch"
+	"shingocore/fleet"
+)
+
+// Vendor order states the adapter reports through the tracker and
+// GetOrderState.
+const (
+	StateCreated   = "CREATED"   // waiting for a free vehicle
+	StateAssigned  = "ASSIGNED"  // published to a vehicle that has not taken it up yet
+	StateRunning   = "RUNNING"   // the vehicle is working the order
+	StateFinished  = "FINISHED"  // every node, edge and action is done
+	StateFailed    = "FAILED"    // an action failed or the vehicle raised a fatal error
+	StateCancelled = "CANCELLED" // cancelled before or during the run
+)
+
+// Config holds the configuration for a VDA 5050 adapter.
+type Config struct {
+	InterfaceName string                  // topic prefix, default "uagv"
+	MajorVersion  string                  // topic version, default "v2"
+	Version       string                  // header version, default "2.0.0"
+	Manufacturer  string                  // only control this manufacturer's vehicles; "" for any
+	MapID         string                  // map of configured node positions
+	Locations     map[string]NodePosition // optional positions of vendor locations
+	PickAction    string                  // action type for pick stops, default "pick"
+	DropAction    string                  // action type for drop stops, default "drop"
+	Orders        OrderStore              // keeps orders across restarts; nil keeps them in memory only
+	DebugLog      func(string, ...any)
+}
+
+// OrderStore keeps the adapter's orders across restarts. The adapter is the
+// only record of which orders are queued and which vehicle holds which, so
+// without a store a restart loses them and startup reconciliation fails the
+// orders still running.
+type OrderStore interface {
+	SaveOrder(id string, data []byte) error
+	DeleteOrder(id string) error
+	LoadOrders() ([][]byte, error) // oldest first
+}
+
+// Adapter implements fleet.TrackingBackend, fleet.MultiStopCreator,
+// fleet.OrderLookup and fleet.RobotLister over a Transport.
+type Adapter struct {
+	cfg     Config
+	t       Transport
+	tracker *tracker
+
+	mu        sync.Mutex
+	vehicles  map[string]*vehicle // by serial number
+	orders    map[string]*order
+	queue     []*order // CREATED orders, oldest first
+	headerIDs map[string]int
+	ended     []*order // terminal orders not yet forgotten, see prune
+}
+
+type vehicle struct {
+	manufacturer string
+	serial       string
+	connection   string
+	state        *State
+	available    bool
+	order        *order
+}
+
+type order struct {
+	id         string
+	externalID string
+	stops      []fleet.TransportStop
+	priority   int
+	state      string
+	detail     string
+	vehicle    *vehicle
+	actionIDs  []string
+	cancelID   string
+	restored   bool // assigned before a restart; not yet confirmed by the vehicle
+	endedAt    time.Time
+	reported   bool // the tracker has read the terminal state
+}
+
+// endedRetention is how long a terminal order no tracker reports stays
+// known to GetOrderState.
+const endedRetention = 10 * time.Minute
+
+// savedOrder is an order as the OrderStore keeps it.
+type savedOrder struct {
+	ID           string                `json:"id"`
+	ExternalID   string                `json:"external_id,omitempty"`
+	Stops        []fleet.TransportStop `json:"stops"`
+	Priority     int                   `json:"priority,omitempty"`
+	State        string                `json:"state"`
+	Manufacturer string                `json:"manufacturer,omitempty"`
+	Vehicle      string                `json:"vehicle,omitempty"`
+	ActionIDs    []string              `json:"action_ids,omitempty"`
+	CancelID     string                `json:"cancel_id,omitempty"`
+}
+
+type outMsg struct {
+	topic string
+	msg   any
+	order *order // set for order messages, so a failed publish can requeue
+}
+
+// New creates an adapter and subscribes to every vehicle's state and
+// connection topics.
+func New(t Transport, cfg Config) (*Adapter, error) {
+	if cfg.InterfaceName == "" {
+		cfg.InterfaceName = "uagv"
+	}
+	if cfg.MajorVersion == "" {
+		cfg.MajorVersion = "v2"
+	}
+	if cfg.Version == "" {
+		cfg.Version = "2.0.0"
+	}
+	if cfg.PickAction == "" {
+		cfg.PickAction = "pick"
+	}
+	if cfg.DropAction == "" {
+		cfg.DropAction = "drop"
+	}
+	a := &Adapter{
+		cfg:       cfg,
+		t:         t,
+		vehicles:  make(map[string]*vehicle),
+		orders:    make(map[string]*order),
+		headerIDs: make(map[string]int),
+	}
+	if err := a.restore(); err != nil {
+		return nil, fmt.Errorf("vda5050: load orders: %w", err)
+	}
+	manufacturer := cfg.Manufacturer
+	if manufacturer == "" {
+		manufacturer = "+"
+	}
+	for _, name := range []string{"state", "connection"} {
+		topic := fmt.Sprintf("%s/%s/%s/+/%s", cfg.InterfaceName, cfg.MajorVersion, manufacturer, name)
+		if err := t.Subscribe(topic, a.handle); err != nil {
+			return nil, fmt.Errorf("vda5050: subscribe %s: %w", topic, err)
+		}
+	}
+	return a, nil
+}
+
+// restore loads saved orders: queued ones go back in the queue and assigned
+// or running ones back on their vehicle, whose next state message picks them
+// up where they left off.
+func (a *Adapter) restore() error {
+	if a.cfg.Orders == nil {
+		return nil
+	}
+	saved, err := a.cfg.Orders.LoadOrders()
+	if err != nil {
+		return err
+	}
+	for _, data := range saved {
+		var so savedOrder
+		if err := json.Unmarshal(data, &so); err != nil {
+			log.Printf("vda5050: bad saved order: %v", err)
+			continue
+		}
+		if a.IsTerminalState(so.State) || len(so.Stops) == 0 {
+			a.cfg.Orders.DeleteOrder(so.ID)
+			continue
+		}
+		o := &order{
+			id: so.ID, externalID: so.ExternalID, stops: so.Stops, priority: so.Priority,
+			state: so.State, actionIDs: so.ActionIDs, cancelID: so.CancelID,
+		}
+		a.orders[o.id] = o
+		if so.Vehicle == "" {
+			o.state = StateCreated
+			a.queue = append(a.queue, o)
+			continue
+		}
+		v := a.vehicles[so.Vehicle]
+		if v == nil {
+			v = &vehicle{manufacturer: so.Manufacturer, serial: so.Vehicle, available: true}
+			a.vehicles[so.Vehicle] = v
+		}
+		o.vehicle, v.order = v, o
+		o.restored = true
+	}
+	if len(a.orders) > 0 {
+		log.Printf("vda5050: resumed %d orders (%d queued)", len(a.orders), len(a.queue))
+	}
+	return nil
+}
+
+// persist saves an order, or drops it from the store once it is over. It is
+// called under a.mu so saves land in the order the changes happened.
+func (a *Adapter) persist(o *order) {
+	st := a.cfg.Orders
+	if st == nil {
+		return
+	}
+	if a.IsTerminalState(o.state) {
+		if err := st.DeleteOrder(o.id); err != nil {
+			log.Printf("vda5050: delete saved order %s: %v", o.id, err)
+		}
+		return
+	}
+	so := savedOrder{
+		ID: o.id, ExternalID: o.externalID, Stops: o.stops, Priority: o.priority,
+		State: o.state, ActionIDs: o.actionIDs, CancelID: o.cancelID,
+	}
+	if v := o.vehicle; v != nil {
+		so.Manufacturer, so.Vehicle = v.manufacturer, v.serial
+	}
+	data, err := json.Marshal(so)
+	if err == nil {
+		err = st.SaveOrder(o.id, data)
+	}
+	if err != nil {
+		log.Printf("vda5050: save order %s: %v", o.id, err)
+	}
+}
+
+func (a *Adapter) dbg(format string, args ...any) {
+	if fn := a.cfg.DebugLog; fn != nil {
+		fn(format, args...)
+	}
+}
+
+func (a *Adapter) topic(v *vehicle, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s", a.cfg.InterfaceName, a.cfg.MajorVersion, v.manufacturer, v.serial, name)
+}
+
+func (a *Adapter) header(v *vehicle, topic string) Header {
+	a.headerIDs[topic]++
+	return Header{
+		HeaderID:     a.headerIDs[topic],
+		Timestamp:    timestamp(time.Now()),
+		Version:      a.cfg.Version,
+		Manufacturer: v.manufacturer,
+		SerialNumber: v.serial,
+	}
+}
+
+// --- Inbound ---
+
+// handle processes a state or connection message, then assigns queued orders
+// and reports order state changes.
+func (a *Adapter) handle(topic string, payload []byte) {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 5 {
+		return
+	}
+	manufacturer, serial, kind := parts[len(parts)-3], parts[len(parts)-2], parts[len(parts)-1]
+
+	a.mu.Lock()
+	v := a.vehicles[serial]
+	if v == nil {
+		v = &vehicle{manufacturer: manufacturer, serial: serial, available: true}
+		a.vehicles[serial] = v
+		a.dbg("vehicle %s/%s discovered", manufacturer, serial)
+	}
+	switch kind {
+	case "state":
+		var s State
+		if err := json.Unmarshal(payload, &s); err != nil {
+			a.mu.Unlock()
+			log.Printf("vda5050: bad state from %s: %v", serial, err)
+			return
+		}
+		a.applyState(v, &s)
+	case "connection":
+		var c Connection
+		if err := json.Unmarshal(payload, &c); err != nil {
+			a.mu.Unlock()
+			log.Printf("vda5050: bad connection from %s: %v", serial, err)
+			return
+		}
+		v.connection = c.ConnectionState
+		a.dbg("vehicle %s connection %s", serial, c.ConnectionState)
+	}
+	out := a.assign()
+	a.mu.Unlock()
+
+	a.send(out)
+	a.checkTracker()
+}
+
+// applyState records a vehicle's state and moves its order along: the order
+// runs once the vehicle reports it, finishes when no nodes, edges or actions
+// remain, and fails on a failed action or fatal error.
+func (a *Adapter) applyState(v *vehicle, s *State) {
+	v.state = s
+	o := v.order
+	if o == nil {
+		return
+	}
+	restored := o.restored
+	o.restored = false
+
+	if o.cancelID != "" {
+		for _, as := range s.ActionStates {
+			if as.ActionID == o.cancelID && (as.ActionStatus == ActionFinished || as.ActionStatus == ActionFailed) {
+				a.finish(o, StateCancelled, "cancelled on vehicle")
+				return
+			}
+		}
+	}
+
+	if s.OrderID != o.id {
+		// Not taken up yet; a rejected order comes back as an error naming it.
+		for _, e := range s.Errors {
+			for _, ref := range e.ErrorReferences {
+				if ref.ReferenceKey == "orderId" && ref.ReferenceValue == o.id {
+					a.finish(o, StateFailed, fmt.Sprintf("%s: %s", e.ErrorType, e.ErrorDescription))
+					return
+				}
+			}
+		}
+		if restored && o.state == StateAssigned && len(s.NodeStates) == 0 {
+			// Published just before a restart and never received: the
+			// vehicle is idle on another order, so queue it again.
+			a.dbg("order %s never reached %s, requeueing", o.id, v.serial)
+			v.order, o.vehicle, o.state = nil, nil, StateCreated
+			a.queue = append(a.queue, o)
+			a.persist(o)
+		}
+		return
+	}
+
+	done := len(s.NodeStates) == 0 && len(s.EdgeStates) == 0
+	for _, id := range o.actionIDs {
+		status := ""
+		for _, as := range s.ActionStates {
+			if as.ActionID == id {
+				status = as.ActionStatus
+				if status == ActionFailed {
+					a.finish(o, StateFailed, fmt.Sprintf("action %s (%s) failed: %s", id, as.ActionType, as.ResultDescription))
+					return
+				}
+			}
+		}
+		if status != ActionFinished {
+			done = false
+		}
+	}
+	for _, e := range s.Errors {
+		if e.ErrorLevel == ErrorFatal {
+			a.finish(o, StateFailed, fmt.Sprintf("%s: %s", e.ErrorType, e.ErrorDescription))
+			return
+		}
+	}
+	if done {
+		a.finish(o, StateFinished, "")
+		return
+	}
+	if o.state != StateRunning {
+		o.state = StateRunning
+		a.persist(o)
+	}
+}
+
+// finish moves an order to a terminal state and frees its vehicle.
+func (a *Adapter) finish(o *order, state, detail string) {
+	a.dbg("order %s %s %s", o.id, state, detail)
+	o.state, o.detail = state, detail
+	if o.vehicle != nil && o.vehicle.order == o {
+		o.vehicle.order = nil
+	}
+	a.persist(o)
+	o.endedAt = time.Now()
+	a.ended = append(a.ended, o)
+}
+
+// prune forgets terminal orders once the tracker has reported them, or after
+// endedRetention for orders nobody tracks, so memory does not grow with
+// every order run.
+func (a *Adapter) prune() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	left := a.ended[:0]
+	for _, o := range a.ended {
+		if !o.reported && time.Since(o.endedAt) < endedRetention {
+			left = append(left, o)
+			continue
+		}
+		if a.orders[o.id] == o {
+			delete(a.orders, o.id)
+		}
+	}
+	clear(a.ended[len(left):])
+	a.ended = left
+}
+
+// --- Assignment ---
+
+// free reports whether a vehicle can take a new order.
+func (v *vehicle) free() bool {
+	if v.order != nil || !v.available || v.state == nil {
+		return false
+	}
+	if v.connection != "" && v.connection != ConnectionOnline {
+		return false
+	}
+	s := v.state
+	if len(s.NodeStates) > 0 || s.Paused || (s.SafetyState.EStop != "" && s.SafetyState.EStop != "NONE") {
+		return false
+	}
+	if s.OperatingMode != "" && s.OperatingMode != "AUTOMATIC" {
+		return false
+	}
+	for _, e := range s.Errors {
+		if e.ErrorLevel == ErrorFatal {
+			return false
+		}
+	}
+	return true
+}
+
+// assign hands queued orders, highest priority first, to the nearest free
+// vehicle and returns the order messages to publish.
+func (a *Adapter) assign() []outMsg {
+	sort.SliceStable(a.queue, func(i, j int) bool { return a.queue[i].priority > a.queue[j].priority })
+	var out []outMsg
+	var left []*order
+	for _, o := range a.queue {
+		v := a.nearestFree(o.stops[0].Loc)
+		if v == nil {
+			left = append(left, o)
+			continue
+		}
+		o.vehicle, v.order = v, o
+		o.state = StateAssigned
+		topic := a.topic(v, "order")
+		out = append(out, outMsg{topic: topic, msg: a.buildOrder(o, v, topic), order: o})
+		a.persist(o)
+		a.dbg("assign %s to %s", o.id, v.serial)
+	}
+	a.queue = left
+	return out
+}
+
+func (a *Adapter) nearestFree(loc string) *vehicle {
+	serials := make([]string, 0, len(a.vehicles))
+	for s := range a.vehicles {
+		serials = append(serials, s)
+	}
+	sort.Strings(serials)
+
+	pos, known := a.cfg.Locations[loc]
+	var best *vehicle
+	bestDist := math.Inf(1)
+	for _, s := range serials {
+		v := a.vehicles[s]
+		if !v.free() {
+			continue
+		}
+		d := 0.0
+		if p := v.state.AGVPosition; known && p != nil {
+			d = math.Hypot(pos.X-p.X, pos.Y-p.Y)
+		}
+		if best == nil || d < bestDist {
+			best, bestDist = v, d
+		}
+	}
+	return best
+}
+
+// buildOrder lays the stops out as released nodes joined by edges, each stop
+// carrying a blocking pick or drop action. A vehicle resting at a known node
+// other than the first stop gets that node as the order's start.
+func (a *Adapter) buildOrder(o *order, v *vehicle, topic string) *Order {
+	msg := &Order{Header: a.header(v, topic), OrderID: o.id}
+	var nodeIDs []string
+	var actions [][]Action
+	if last := v.state.LastNodeID; last != "" && last != o.stops[0].Loc {
+		nodeIDs = append(nodeIDs, last)
+		actions = append(actions, []Action{})
+	}
+	o.actionIDs = nil
+	for i, s := range o.stops {
+		actionType := a.cfg.DropAction
+		if s.Action == fleet.StopPick {
+			actionType = a.cfg.PickAction
+		}
+		id := fmt.Sprintf("%s-%d", o.id, i+1)
+		o.actionIDs = append(o.actionIDs, id)
+		nodeIDs = append(nodeIDs, s.Loc)
+		actions = append(actions, []Action{{ActionType: actionType, ActionID: id, BlockingType: BlockingHard}})
+	}
+	for i, id := range nodeIDs {
+		n := Node{NodeID: id, SequenceID: 2 * i, Released: true, Actions: actions[i]}
+		if p, ok := a.cfg.Locations[id]; ok {
+			if p.MapID == "" {
+				p.MapID = a.cfg.MapID
+			}
+			n.NodePosition = &p
+		}
+		msg.Nodes = append(msg.Nodes, n)
+		if i > 0 {
+			msg.Edges = append(msg.Edges, Edge{
+				EdgeID:      fmt.Sprintf("%s-e%d", o.id, i),
+				SequenceID:  2*i - 1,
+				Released:    true,
+				StartNodeID: nodeIDs[i-1],
+				EndNodeID:   id,
+				Actions:     []Action{},
+			})
+		}
+	}
+	return msg
+}
+
+// send publishes messages built under the lock. An order that cannot be
+// published goes back to the queue.
+func (a *Adapter) send(out []outMsg) {
+	for _, m := range out {
+		data, err := json.Marshal(m.msg)
+		if err == nil {
+			err = a.t.Publish(m.topic, data)
+		}
+		if err == nil {
+			continue
+		}
+		log.Printf("vda5050: publish %s: %v", m.topic, err)
+		if o := m.order; o != nil {
+			a.mu.Lock()
+			if o.state == StateAssigned {
+				if o.vehicle != nil && o.vehicle.order == o {
+					o.vehicle.order = nil
+				}
+				o.vehicle, o.state = nil, StateCreated
+				a.queue = append(a.queue, o)
+				a.persist(o)
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+func (a *Adapter) instantAction(v *vehicle, actionType, actionID string) outMsg {
+	topic := a.topic(v, "instantActions")
+	return outMsg{topic: topic, msg: &InstantActions{
+		Header:  a.header(v, topic),
+		Actions: []Action{{ActionType: actionType, ActionID: actionID, BlockingType: BlockingHard}},
+	}}
+}
+
+// --- fleet.Backend ---
+
+func (a *Adapter) CreateTransportOrder(req fleet.TransportOrderRequest) (fleet.TransportOrderResult, error) {
+	return a.create(req.OrderID, req.ExternalID, req.Priority, []fleet.TransportStop{
+		{Loc: req.FromLoc, Action: fleet.StopPick},
+		{Loc: req.ToLoc, Action: fleet.StopDrop},
+	})
+}
+
+// --- fleet.MultiStopCreator ---
+
+func (a *Adapter) CreateMultiStopOrder(req fleet.MultiStopOrderRequest) (fleet.TransportOrderResult, error) {
+	return a.create(req.OrderID, req.ExternalID, req.Priority, req.Stops)
+}
+
+func (a *Adapter) create(id, externalID string, priority int, stops []fleet.TransportStop) (fleet.TransportOrderResult, error) {
+	if !a.t.Connected() {
+		return fleet.TransportOrderResult{}, fmt.Errorf("vda5050: broker not connected")
+	}
+	if len(stops) == 0 {
+		return fleet.TransportOrderResult{}, fmt.Errorf("vda5050: order %s has no stops", id)
+	}
+	a.mu.Lock()
+	if _, exists := a.orders[id]; exists {
+		a.mu.Unlock()
+		return fleet.TransportOrderResult{}, fmt.Errorf("vda5050: order %s already exists", id)
+	}
+	o := &order{id: id, externalID: externalID, stops: stops, priority: priority, state: StateCreated}
+	a.orders[id] = o
+	a.queue = append(a.queue, o)
+	a.persist(o)
+	out := a.assign()
+	a.mu.Unlock()
+
+	a.send(out)
+	return fleet.TransportOrderResult{VendorOrderID: id}, nil
+}
+
+// CancelOrder drops a queued order, or sends cancelOrder to the vehicle
+// running it. The order is cancelled once the vehicle confirms.
+func (a *Adapter) CancelOrder(vendorOrderID string) error {
+	a.mu.Lock()
+	o, ok := a.orders[vendorOrderID]
+	if !ok {
+		a.mu.Unlock()
+		return fmt.Errorf("vda5050: order %s not found", vendorOrderID)
+	}
+	var out []outMsg
+	switch o.state {
+	case StateCreated:
+		for i, q := range a.queue {
+			if q == o {
+				a.queue = append(a.queue[:i], a.queue[i+1:]...)
+				break
+			}
+		}
+		a.finish(o, StateCancelled, "cancelled before assignment")
+	case StateAssigned, StateRunning:
+		o.cancelID = o.id + "-cancel"
+		a.persist(o)
+		out = append(out, a.instantAction(o.vehicle, "cancelOrder", o.cancelID))
+	}
+	a.mu.Unlock()
+
+	a.send(out)
+	a.checkTracker()
+	return nil
+}
+
+// SetOrderPriority reorders the queue; VDA 5050 has no priority for orders a
+// vehicle already holds.
+func (a *Adapter) SetOrderPriority(vendorOrderID string, priority int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	o, ok := a.orders[vendorOrderID]
+	if !ok {
+		return fmt.Errorf("vda5050: order %s not found", vendorOrderID)
+	}
+	o.priority = priority
+	a.persist(o)
+	return nil
+}
+
+func (a *Adapter) Ping() error {
+	if !a.t.Connected() {
+		return fmt.Errorf("vda5050: broker not connected")
+	}
+	return nil
+}
+
+func (a *Adapter) Name() string {
+	return "VDA 5050"
+}
+
+func (a *Adapter) MapState(vendorState string) string {
+	switch vendorState {
+	case StateRunning:
+		return dispatch.StatusInTransit
+	case StateFinished:
+		return dispatch.StatusDelivered
+	case StateFailed:
+		return dispatch.StatusFailed
+	case StateCancelled:
+		return dispatch.StatusCancelled
+	default:
+		return dispatch.StatusDispatched
+	}
+}
+
+func (a *Adapter) IsTerminalState(vendorState string) bool {
+	return vendorState == StateFinished || vendorState == StateFailed || vendorState == StateCancelled
+}
+
+// Reconfigure is a no-op: broker settings apply at startup.
+func (a *Adapter) Reconfigure(fleet.ReconfigureParams) {}
+
+// --- fleet.OrderLookup ---
+
+// GetOrderState returns an order's state. Without an OrderStore, orders from
+// before a restart are known only while a vehicle still reports them, and
+// then count as running.
+func (a *Adapter) GetOrderState(vendorOrderID string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if o, ok := a.orders[vendorOrderID]; ok {
+		return o.state, nil
+	}
+	for _, v := range a.vehicles {
+		if v.state != nil && v.state.OrderID == vendorOrderID && len(v.state.NodeStates) > 0 {
+			return StateRunning, nil
+		}
+	}
+	return "", fleet.ErrOrderNotFound
+}
+
+// --- fleet.TrackingBackend ---
+
+func (a *Adapter) InitTracker(emitter fleet.TrackerEmitter, resolver fleet.OrderIDResolver) {
+	a.tracker = newTracker(a, emitter, resolver)
+}
+
+func (a *Adapter) Tracker() fleet.OrderTracker {
+	return a.tracker
+}
+
+func (a *Adapter) checkTracker() {
+	if a.tracker != nil {
+		a.tracker.Check()
+	}
+	a.prune()
+}
+
+// --- fleet.RobotLister ---
+
+func (a *Adapter) GetRobotsStatus() ([]fleet.RobotStatus, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]fleet.RobotStatus, 0, len(a.vehicles))
+	for _, v := range a.vehicles {
+		out = append(out, robotStatus(v))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].VehicleID < out[j].VehicleID })
+	return out, nil
+}
+
+func robotStatus(v *vehicle) fleet.RobotStatus {
+	r := fleet.RobotStatus{
+		VehicleID: v.serial,
+		Model:     v.manufacturer,
+		Connected: v.connection == ConnectionOnline || (v.connection == "" && v.state != nil),
+		Available: v.available,
+		Busy:      v.order != nil,
+	}
+	s := v.state
+	if s == nil {
+		return r
+	}
+	r.Available = v.available && !s.Paused && (s.OperatingMode == "" || s.OperatingMode == "AUTOMATIC")
+	r.Busy = v.order != nil || len(s.NodeStates) > 0
+	r.Emergency = s.SafetyState.EStop != "" && s.SafetyState.EStop != "NONE"
+	r.Blocked = s.SafetyState.FieldViolation
+	for _, e := range s.Errors {
+		if e.ErrorLevel == ErrorFatal {
+			r.IsError = true
+		}
+	}
+	r.BatteryLevel = s.BatteryState.BatteryCharge
+	r.Charging = s.BatteryState.Charging
+	r.LastStation = s.LastNodeID
+	if !s.Driving {
+		r.CurrentStation = s.LastNodeID
+	}
+	if p := s.AGVPosition; p != nil {
+		r.X, r.Y, r.Angle, r.CurrentMap = p.X, p.Y, p.Theta, p.MapID
+	}
+	return r
+}
+
+// SetAvailability stops or resumes assigning orders to a vehicle.
+func (a *Adapter) SetAvailability(vehicleID string, available bool) error {
+	a.mu.Lock()
+	v, ok := a.vehicles[vehicleID]
+	if !ok {
+		a.mu.Unlock()
+		return fmt.Errorf("vda5050: vehicle %s not found", vehicleID)
+	}
+	v.available = available
+	out := a.assign()
+	a.mu.Unlock()
+	a.send(out)
+	return nil
+}
+
+func (a *Adapter) RetryFailed(vehicleID string) error {
+	return fmt.Errorf("vda5050: retrying a failed order is not part of VDA 5050; clear the fault on the vehicle")
+}
+
+// ForceComplete marks a vehicle's order finished and tells the vehicle to
+// drop it.
+func (a *Adapter) ForceComplete(vehicleID string) error {
+	a.mu.Lock()
+	v, ok := a.vehicles[vehicleID]
+	if !ok {
+		a.mu.Unlock()
+		return fmt.Errorf("vda5050: vehicle %s not found", vehicleID)
+	}
+	var out []outMsg
+	if o := v.order; o != nil {
+		out = append(out, a.instantAction(v, "cancelOrder", o.id+"-cancel"))
+		a.finish(o, StateFinished, "force completed")
+	}
+	out = append(out, a.assign()...)
+	a.mu.Unlock()
+
+	a.send(out)
+	a.checkTracker()
+	return nil
+}
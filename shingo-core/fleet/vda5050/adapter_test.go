@@ -0,0 +1,511 @@
+package vda5050
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"shingocore/fleet"
+)
+
+// memBroker is an in-memory Transport that delivers messages synchronously
+// to every subscription whose filter matches, with MQTT + and # wildcards.
+type memBroker struct {
+	mu   sync.Mutex
+	subs []memSub
+}
+
+type memSub struct {
+	filter  string
+	handler func(string, []byte)
+}
+
+func (b *memBroker) Publish(topic string, payload []byte) error {
+	b.mu.Lock()
+	var hs []func(string, []byte)
+	for _, s := range b.subs {
+		if topicMatches(s.filter, topic) {
+			hs = append(hs, s.handler)
+		}
+	}
+	b.mu.Unlock()
+	for _, h := range hs {
+		h(topic, payload)
+	}
+	return nil
+}
+
+func (b *memBroker) Subscribe(topic string, handler func(string, []byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, memSub{topic, handler})
+	return nil
+}
+
+func (b *memBroker) Connected() bool { return true }
+func (b *memBroker) Close()          {}
+
+func topicMatches(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, part := range f {
+		if part == "#" {
+			return true
+		}
+		if i >= len(t) || (part != "+" && part != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// fakeVehicle is a scripted VDA 5050 vehicle. It accepts every order it is
+// sent and then waits for the test to finish or fail it.
+type fakeVehicle struct {
+	t      Transport
+	prefix string
+
+	mu      sync.Mutex
+	state   State
+	orders  []Order
+	instant []InstantActions
+}
+
+func newFakeVehicle(t *testing.T, tr Transport, serial string, x, y float64) *fakeVehicle {
+	t.Helper()
+	v := &fakeVehicle{t: tr, prefix: "uagv/v2/acme/" + serial + "/"}
+	v.state = State{
+		Header:        Header{Manufacturer: "acme", SerialNumber: serial},
+		LastNodeID:    "HOME",
+		OperatingMode: "AUTOMATIC",
+		BatteryState:  BatteryState{BatteryCharge: 80},
+		AGVPosition:   &AGVPosition{X: x, Y: y, PositionInitialized: true},
+		SafetyState:   SafetyState{EStop: "NONE"},
+	}
+	if err := tr.Subscribe(v.prefix+"order", v.onOrder); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := tr.Subscribe(v.prefix+"instantActions", v.onInstant); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	v.publish("connection", Connection{Header: v.state.Header, ConnectionState: ConnectionOnline})
+	v.report(func(*State) {})
+	return v
+}
+
+func (v *fakeVehicle) publish(name string, msg any) {
+	data, _ := json.Marshal(msg)
+	v.t.Publish(v.prefix+name, data)
+}
+
+// report applies fn to the vehicle state and publishes it. The lock is
+// released before publishing, since the adapter may answer synchronously.
+func (v *fakeVehicle) report(fn func(*State)) {
+	v.mu.Lock()
+	fn(&v.state)
+	v.state.HeaderID++
+	s := v.state
+	v.mu.Unlock()
+	v.publish("state", s)
+}
+
+func (v *fakeVehicle) onOrder(_ string, payload []byte) {
+	var o Order
+	json.Unmarshal(payload, &o)
+	v.mu.Lock()
+	v.orders = append(v.orders, o)
+	v.mu.Unlock()
+	v.report(func(s *State) {
+		s.OrderID, s.NodeStates, s.EdgeStates = o.OrderID, nil, nil
+		for _, n := range o.Nodes {
+			s.NodeStates = append(s.NodeStates, NodeState{NodeID: n.NodeID, SequenceID: n.SequenceID, Released: true})
+			for _, a := range n.Actions {
+				s.ActionStates = append(s.ActionStates, ActionState{ActionID: a.ActionID, ActionType: a.ActionType, ActionStatus: ActionWaiting})
+			}
+		}
+		for _, e := range o.Edges {
+			s.EdgeStates = append(s.EdgeStates, EdgeState{EdgeID: e.EdgeID, SequenceID: e.SequenceID, Released: true})
+		}
+		s.Driving = true
+	})
+}
+
+func (v *fakeVehicle) onInstant(_ string, payload []byte) {
+	var ia InstantActions
+	json.Unmarshal(payload, &ia)
+	v.mu.Lock()
+	v.instant = append(v.instant, ia)
+	v.mu.Unlock()
+	for _, a := range ia.Actions {
+		if a.ActionType == "cancelOrder" {
+			a := a
+			v.report(func(s *State) {
+				s.NodeStates, s.EdgeStates, s.Driving = nil, nil, false
+				s.ActionStates = append(s.ActionStates, ActionState{ActionID: a.ActionID, ActionType: a.ActionType, ActionStatus: ActionFinished})
+			})
+		}
+	}
+}
+
+// finish completes the current order at its last node.
+func (v *fakeVehicle) finish() {
+	v.report(func(s *State) {
+		if n := len(s.NodeStates); n > 0 {
+			s.LastNodeID = s.NodeStates[n-1].NodeID
+		}
+		s.NodeStates, s.EdgeStates, s.Driving = nil, nil, false
+		for i := range s.ActionStates {
+			s.ActionStates[i].ActionStatus = ActionFinished
+		}
+	})
+}
+
+func (v *fakeVehicle) lastOrder() Order {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.orders) == 0 {
+		return Order{}
+	}
+	return v.orders[len(v.orders)-1]
+}
+
+type recordingEmitter struct {
+	mu     sync.Mutex
+	states []string
+}
+
+func (e *recordingEmitter) EmitOrderStatusChanged(orderID int64, vendorOrderID, oldStatus, newStatus, robotID, detail string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.states = append(e.states, newStatus)
+}
+
+// wait polls until the emitted transitions equal want.
+func (e *recordingEmitter) wait(t *testing.T, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		e.mu.Lock()
+		got := fmt.Sprint(e.states)
+		e.mu.Unlock()
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("transitions = %s, want %s", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type fixedResolver struct{}
+
+func (fixedResolver) ResolveVendorOrderID(string) (int64, error) { return 1, nil }
+
+func testAdapter(t *testing.T, tr Transport) (*Adapter, *recordingEmitter) {
+	t.Helper()
+	a, err := New(tr, Config{
+		Manufacturer: "acme",
+		MapID:        "plant",
+		Locations:    map[string]NodePosition{"A": {X: 0, Y: 0}, "B": {X: 10, Y: 0}},
+	})
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	em := &recordingEmitter{}
+	a.InitTracker(em, fixedResolver{})
+	return a, em
+}
+
+// waitState polls until the order reaches want; the in-memory broker is
+// synchronous, a real one is not.
+func waitState(t *testing.T, a *Adapter, id, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := a.GetOrderState(id)
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("order %s state = %s, want %s", id, got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func runLifecycle(t *testing.T, adapterSide, vehicleSide Transport) {
+	a, em := testAdapter(t, adapterSide)
+	v := newFakeVehicle(t, vehicleSide, "AGV-1", 0, 0)
+	waitRobots(t, a, 1)
+
+	if _, err := a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "o1", FromLoc: "A", ToLoc: "B"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	a.Tracker().Track("o1")
+	em.wait(t, "[RUNNING]")
+
+	o := v.lastOrder()
+	var nodes, actions []string
+	for _, n := range o.Nodes {
+		nodes = append(nodes, fmt.Sprintf("%s/%d", n.NodeID, n.SequenceID))
+		for _, act := range n.Actions {
+			actions = append(actions, act.ActionType)
+		}
+	}
+	if fmt.Sprint(nodes) != "[HOME/0 A/2 B/4]" || fmt.Sprint(actions) != "[pick drop]" || len(o.Edges) != 2 {
+		t.Errorf("order nodes = %v actions = %v edges = %d", nodes, actions, len(o.Edges))
+	}
+	if p := o.Nodes[2].NodePosition; p == nil || p.X != 10 || p.MapID != "plant" {
+		t.Errorf("node B position = %+v", p)
+	}
+
+	v.finish()
+	em.wait(t, "[RUNNING FINISHED]")
+	if a.Tracker().ActiveCount() != 0 {
+		t.Error("terminal order still tracked")
+	}
+	// Once reported, the finished order is forgotten.
+	waitState(t, a, "o1", "")
+	if a.MapState(StateFinished) != "delivered" || !a.IsTerminalState(StateFinished) {
+		t.Error("FINISHED should map to terminal delivered")
+	}
+}
+
+func waitRobots(t *testing.T, a *Adapter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		robots, _ := a.GetRobotsStatus()
+		if len(robots) == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d vehicles discovered, want %d", len(robots), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	b := &memBroker{}
+	runLifecycle(t, b, b)
+}
+
+// TestOrderLifecycleBroker runs the lifecycle through a real MQTT broker,
+// e.g. VDA5050_TEST_BROKER=tcp://localhost:1883.
+func TestOrderLifecycleBroker(t *testing.T) {
+	broker := os.Getenv("VDA5050_TEST_BROKER")
+	if broker == "" {
+		t.Skip("VDA5050_TEST_BROKER not set")
+	}
+	dial := func(id string) Transport {
+		tr, err := DialMQTT(MQTTConfig{Broker: broker, ClientID: id, Timeout: 5 * time.Second})
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(tr.Close)
+		return tr
+	}
+	runLifecycle(t, dial("shingo-test-core"), dial("shingo-test-agv"))
+}
+
+func TestActionFailureFailsOrder(t *testing.T) {
+	b := &memBroker{}
+	a, _ := testAdapter(t, b)
+	v := newFakeVehicle(t, b, "AGV-1", 0, 0)
+	a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "o1", FromLoc: "A", ToLoc: "B"})
+
+	v.report(func(s *State) {
+		s.ActionStates[0].ActionStatus = ActionFailed
+		s.ActionStates[0].ResultDescription = "no load detected"
+		s.Errors = []Error{{ErrorType: "loadMissing", ErrorLevel: ErrorFatal}}
+	})
+	waitState(t, a, "o1", StateFailed)
+
+	robots, _ := a.GetRobotsStatus()
+	if !robots[0].IsError {
+		t.Errorf("robot = %+v, want errored", robots[0])
+	}
+
+	// A vehicle with a fatal error takes no new work until it clears.
+	a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "o2", FromLoc: "A", ToLoc: "B"})
+	if state, _ := a.GetOrderState("o2"); state != StateCreated {
+		t.Errorf("o2 with vehicle in error = %s, want CREATED", state)
+	}
+	v.report(func(s *State) { s.Errors, s.ActionStates, s.NodeStates = nil, nil, nil })
+	waitState(t, a, "o2", StateRunning)
+}
+
+func TestCancelOrder(t *testing.T) {
+	b := &memBroker{}
+	a, em := testAdapter(t, b)
+
+	// Queued with no vehicle: cancelled at once.
+	a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "q1", FromLoc: "A", ToLoc: "B"})
+	a.Tracker().Track("q1")
+	if err := a.CancelOrder("q1"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	em.wait(t, "[CANCELLED]")
+
+	// Running: cancelled once the vehicle confirms cancelOrder.
+	v := newFakeVehicle(t, b, "AGV-1", 0, 0)
+	a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "o1", FromLoc: "A", ToLoc: "B"})
+	a.Tracker().Track("o1")
+	em.wait(t, "[CANCELLED RUNNING]")
+	if err := a.CancelOrder("o1"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	em.wait(t, "[CANCELLED RUNNING CANCELLED]")
+	if len(v.instant) != 1 || v.instant[0].Actions[0].ActionType != "cancelOrder" {
+		t.Errorf("instant actions = %+v", v.instant)
+	}
+}
+
+func TestAssignmentPriorityAndDistance(t *testing.T) {
+	b := &memBroker{}
+	a, _ := testAdapter(t, b)
+	a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "low", FromLoc: "B", ToLoc: "A"})
+	a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "high", FromLoc: "B", ToLoc: "A"})
+	a.SetOrderPriority("high", 10)
+
+	far := newFakeVehicle(t, b, "AGV-FAR", 100, 0)
+	if far.lastOrder().OrderID != "high" {
+		t.Fatalf("first vehicle got %q, want high priority order", far.lastOrder().OrderID)
+	}
+	near := newFakeVehicle(t, b, "AGV-NEAR", 9, 0)
+	if near.lastOrder().OrderID != "low" {
+		t.Fatalf("second vehicle got %q, want low", near.lastOrder().OrderID)
+	}
+
+	// With both free, the next order goes to the vehicle nearer its pick.
+	far.finish()
+	near.finish()
+	a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "next", FromLoc: "B", ToLoc: "A"})
+	if state, _ := a.GetOrderState("next"); state != StateRunning {
+		t.Fatalf("next = %s", state)
+	}
+	if near.lastOrder().OrderID != "next" {
+		t.Errorf("next went to %s, want the nearer vehicle", far.lastOrder().OrderID)
+	}
+}
+
+func TestRobotStatus(t *testing.T) {
+	b := &memBroker{}
+	a, _ := testAdapter(t, b)
+	v := newFakeVehicle(t, b, "AGV-1", 3, 4)
+	v.report(func(s *State) {
+		s.SafetyState.EStop = "MANUAL"
+		s.BatteryState = BatteryState{BatteryCharge: 42, Charging: true}
+	})
+	robots, _ := a.GetRobotsStatus()
+	r := robots[0]
+	if r.VehicleID != "AGV-1" || !r.Connected || !r.Emergency || r.BatteryLevel != 42 || !r.Charging || r.X != 3 || r.CurrentStation != "HOME" {
+		t.Errorf("robot = %+v", r)
+	}
+
+	v.publish("connection", Connection{ConnectionState: ConnectionBroken})
+	robots, _ = a.GetRobotsStatus()
+	if robots[0].Connected {
+		t.Error("vehicle with broken connection reported connected")
+	}
+	if _, err := a.GetOrderState("missing"); err != fleet.ErrOrderNotFound {
+		t.Errorf("lookup err = %v, want ErrOrderNotFound", err)
+	}
+}
+
+// memOrders is an in-memory OrderStore.
+type memOrders struct {
+	mu   sync.Mutex
+	ids  []string
+	data map[string][]byte
+}
+
+func (m *memOrders) SaveOrder(id string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		m.data = make(map[string][]byte)
+	}
+	if _, ok := m.data[id]; !ok {
+		m.ids = append(m.ids, id)
+	}
+	m.data[id] = data
+	return nil
+}
+
+func (m *memOrders) DeleteOrder(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, id)
+	return nil
+}
+
+func (m *memOrders) LoadOrders() ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out [][]byte
+	for _, id := range m.ids {
+		if d, ok := m.data[id]; ok {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func TestRestartResumesOrders(t *testing.T) {
+	orders := &memOrders{}
+	cfg := Config{Manufacturer: "acme", Orders: orders}
+	b := &memBroker{}
+	a, err := New(b, cfg)
+	if err != nil {
+		t.Fatalf("new adapter: %v", err)
+	}
+	v := newFakeVehicle(t, b, "AGV-1", 0, 0)
+	a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "o1", FromLoc: "A", ToLoc: "B"})
+	a.CreateTransportOrder(fleet.TransportOrderRequest{OrderID: "q1", FromLoc: "B", ToLoc: "A"})
+	waitState(t, a, "o1", StateRunning)
+
+	// Core restarts while o1 runs and q1 waits for a vehicle.
+	b = &memBroker{}
+	a, err = New(b, cfg)
+	if err != nil {
+		t.Fatalf("new adapter after restart: %v", err)
+	}
+	for id, want := range map[string]string{"o1": StateRunning, "q1": StateCreated} {
+		if got, err := a.GetOrderState(id); got != want {
+			t.Errorf("%s after restart = %q (%v), want %s", id, got, err, want)
+		}
+	}
+	em := &recordingEmitter{}
+	a.InitTracker(em, fixedResolver{})
+	a.Tracker().(fleet.SeededTracker).TrackFrom("o1", StateRunning)
+
+	// The vehicle kept running o1 and reports it to the new adapter.
+	v2 := newFakeVehicle(t, b, "AGV-1", 0, 0)
+	v.mu.Lock()
+	running := v.state
+	v.mu.Unlock()
+	v2.report(func(s *State) {
+		s.OrderID, s.NodeStates, s.EdgeStates, s.ActionStates, s.Driving =
+			running.OrderID, running.NodeStates, running.EdgeStates, running.ActionStates, true
+	})
+	if got, _ := a.GetOrderState("q1"); got != StateCreated {
+		t.Errorf("q1 while AGV-1 still runs o1 = %s, want CREATED", got)
+	}
+	v2.finish()
+	em.wait(t, "[FINISHED]")
+	waitState(t, a, "q1", StateRunning)
+	if v2.lastOrder().OrderID != "q1" {
+		t.Errorf("AGV-1 got %q after o1, want q1", v2.lastOrder().OrderID)
+	}
+
+	saved, _ := orders.LoadOrders()
+	if len(saved) != 1 || !strings.Contains(string(saved[0]), `"vehicle":"AGV-1"`) {
+		t.Errorf("saved orders = %s, want only q1 on AGV-1", saved)
+	}
+}
@@ -0,0 +1,176 @@
+package vda5050
+
+import "time"
+
+// Message types of VDA 5050 v2. Only the fields the adapter reads or writes
+// are declared; unknown fields in inbound messages are ignored.
+
+// Header is carried by every message.
+type Header struct {
+	HeaderID     int    `json:"headerId"`
+	Timestamp    string `json:"timestamp"`
+	Version      string `json:"version"`
+	Manufacturer string `json:"manufacturer"`
+	SerialNumber string `json:"serialNumber"`
+}
+
+// Order is published on the order topic.
+type Order struct {
+	Header
+	OrderID       string `json:"orderId"`
+	OrderUpdateID int    `json:"orderUpdateId"`
+	Nodes         []Node `json:"nodes"`
+	Edges         []Edge `json:"edges"`
+}
+
+type Node struct {
+	NodeID       string        `json:"nodeId"`
+	SequenceID   int           `json:"sequenceId"`
+	Released     bool          `json:"released"`
+	NodePosition *NodePosition `json:"nodePosition,omitempty"`
+	Actions      []Action      `json:"actions"`
+}
+
+type NodePosition struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Theta float64 `json:"theta,omitempty"`
+	MapID string  `json:"mapId"`
+}
+
+type Edge struct {
+	EdgeID      string   `json:"edgeId"`
+	SequenceID  int      `json:"sequenceId"`
+	Released    bool     `json:"released"`
+	StartNodeID string   `json:"startNodeId"`
+	EndNodeID   string   `json:"endNodeId"`
+	Actions     []Action `json:"actions"`
+}
+
+// Blocking types for actions.
+const (
+	BlockingNone = "NONE"
+	BlockingSoft = "SOFT"
+	BlockingHard = "HARD"
+)
+
+type Action struct {
+	ActionType       string            `json:"actionType"`
+	ActionID         string            `json:"actionId"`
+	BlockingType     string            `json:"blockingType"`
+	ActionParameters []ActionParameter `json:"actionParameters,omitempty"`
+}
+
+type ActionParameter struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// InstantActions is published on the instantActions topic.
+type InstantActions struct {
+	Header
+	Actions []Action `json:"actions"`
+}
+
+// State is published by a vehicle on the state topic.
+type State struct {
+	Header
+	OrderID            string        `json:"orderId"`
+	OrderUpdateID      int           `json:"orderUpdateId"`
+	LastNodeID         string        `json:"lastNodeId"`
+	LastNodeSequenceID int           `json:"lastNodeSequenceId"`
+	Driving            bool          `json:"driving"`
+	Paused             bool          `json:"paused"`
+	OperatingMode      string        `json:"operatingMode"`
+	NodeStates         []NodeState   `json:"nodeStates"`
+	EdgeStates         []EdgeState   `json:"edgeStates"`
+	ActionStates       []ActionState `json:"actionStates"`
+	BatteryState       BatteryState  `json:"batteryState"`
+	Errors             []Error       `json:"errors"`
+	AGVPosition        *AGVPosition  `json:"agvPosition,omitempty"`
+	SafetyState        SafetyState   `json:"safetyState"`
+}
+
+type NodeState struct {
+	NodeID     string `json:"nodeId"`
+	SequenceID int    `json:"sequenceId"`
+	Released   bool   `json:"released"`
+}
+
+type EdgeState struct {
+	EdgeID     string `json:"edgeId"`
+	SequenceID int    `json:"sequenceId"`
+	Released   bool   `json:"released"`
+}
+
+// Action statuses reported in ActionState.
+const (
+	ActionWaiting      = "WAITING"
+	ActionInitializing = "INITIALIZING"
+	ActionRunning      = "RUNNING"
+	ActionPaused       = "PAUSED"
+	ActionFinished     = "FINISHED"
+	ActionFailed       = "FAILED"
+)
+
+type ActionState struct {
+	ActionID          string `json:"actionId"`
+	ActionType        string `json:"actionType,omitempty"`
+	ActionStatus      string `json:"actionStatus"`
+	ResultDescription string `json:"resultDescription,omitempty"`
+}
+
+type BatteryState struct {
+	BatteryCharge float64 `json:"batteryCharge"` // percent
+	Charging      bool    `json:"charging"`
+}
+
+// Error levels.
+const (
+	ErrorWarning = "WARNING"
+	ErrorFatal   = "FATAL"
+)
+
+type Error struct {
+	ErrorType        string           `json:"errorType"`
+	ErrorLevel       string           `json:"errorLevel"`
+	ErrorDescription string           `json:"errorDescription,omitempty"`
+	ErrorReferences  []ErrorReference `json:"errorReferences,omitempty"`
+}
+
+type ErrorReference struct {
+	ReferenceKey   string `json:"referenceKey"`
+	ReferenceValue string `json:"referenceValue"`
+}
+
+type AGVPosition struct {
+	X                   float64 `json:"x"`
+	Y                   float64 `json:"y"`
+	Theta               float64 `json:"theta"`
+	MapID               string  `json:"mapId"`
+	PositionInitialized bool    `json:"positionInitialized"`
+}
+
+type SafetyState struct {
+	EStop          string `json:"eStop"` // AUTOACK, MANUAL, REMOTE or NONE
+	FieldViolation bool   `json:"fieldViolation"`
+}
+
+// Connection states.
+const (
+	ConnectionOnline  = "ONLINE"
+	ConnectionOffline = "OFFLINE"
+	ConnectionBroken  = "CONNECTIONBROKEN"
+)
+
+// Connection is published by a vehicle (or as its last will) on the
+// connection topic.
+type Connection struct {
+	Header
+	ConnectionState string `json:"connectionState"`
+}
+
+// timestamp formats t as the ISO 8601 UTC time VDA 5050 headers use.
+func timestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
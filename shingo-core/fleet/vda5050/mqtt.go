@@ -0,0 +1,106 @@
+package vda5050
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Transport is the publish/subscribe connection the adapter talks to
+// vehicles over. MQTTTransport is the real one; tests substitute an
+// in-memory broker.
+type Transport interface {
+	Publish(topic string, payload []byte) error
+	Subscribe(topic string, handler func(topic string, payload []byte)) error
+	Connected() bool
+	Close()
+}
+
+// MQTTConfig holds broker connection settings.
+type MQTTConfig struct {
+	Broker   string // e.g. tcp://localhost:1883
+	ClientID string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// MQTTTransport is a Transport over an MQTT broker. Orders and instant
+// actions are published with QoS 1; subscriptions use QoS 0, as the standard
+// recommends for state and connection.
+type MQTTTransport struct {
+	client  mqtt.Client
+	timeout time.Duration
+
+	mu   sync.Mutex
+	subs map[string]mqtt.MessageHandler
+}
+
+// DialMQTT starts connecting to the broker. The transport keeps retrying in
+// the background and restores its subscriptions on every (re)connect, so the
+// returned transport is usable even when the first attempt fails.
+func DialMQTT(cfg MQTTConfig) (*MQTTTransport, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	t := &MQTTTransport{timeout: cfg.Timeout, subs: make(map[string]mqtt.MessageHandler)}
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetConnectTimeout(cfg.Timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetOrderMatters(false).
+		SetOnConnectHandler(t.resubscribe)
+	t.client = mqtt.NewClient(opts)
+	if err := t.wait(t.client.Connect()); err != nil {
+		return t, fmt.Errorf("mqtt connect %s: %w", cfg.Broker, err)
+	}
+	return t, nil
+}
+
+func (t *MQTTTransport) resubscribe(c mqtt.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for topic, h := range t.subs {
+		c.Subscribe(topic, 0, h)
+	}
+}
+
+func (t *MQTTTransport) wait(tok mqtt.Token) error {
+	if !tok.WaitTimeout(t.timeout) {
+		return fmt.Errorf("timed out after %s", t.timeout)
+	}
+	return tok.Error()
+}
+
+func (t *MQTTTransport) Publish(topic string, payload []byte) error {
+	if !t.client.IsConnectionOpen() {
+		return fmt.Errorf("mqtt: not connected")
+	}
+	return t.wait(t.client.Publish(topic, 1, false, payload))
+}
+
+func (t *MQTTTransport) Subscribe(topic string, handler func(topic string, payload []byte)) error {
+	h := func(_ mqtt.Client, m mqtt.Message) { handler(m.Topic(), m.Payload()) }
+	t.mu.Lock()
+	t.subs[topic] = h
+	t.mu.Unlock()
+	if !t.client.IsConnectionOpen() {
+		return nil // subscribed on connect
+	}
+	return t.wait(t.client.Subscribe(topic, 0, h))
+}
+
+func (t *MQTTTransport) Connected() bool {
+	return t.client.IsConnectionOpen()
+}
+
+func (t *MQTTTransport) Close() {
+	t.client.Disconnect(250)
+}
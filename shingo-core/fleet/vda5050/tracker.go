@@ -0,0 +1,49 @@
+package vda5050
+
+import "shingocore/fleet"
+
+// tracker reports state changes of tracked orders. VDA 5050 pushes vehicle
+// state, so there is nothing to poll: the adapter checks after every state
+// message and every local change.
+type tracker struct {
+	*fleet.StateTracker
+	a *Adapter
+}
+
+func newTracker(a *Adapter, emitter fleet.TrackerEmitter, resolver fleet.OrderIDResolver) *tracker {
+	t := &tracker{StateTracker: fleet.NewStateTracker("vda5050", StateCreated, a, emitter, resolver), a: a}
+	t.DebugLog = a.dbg
+	return t
+}
+
+// Track starts reporting an order. The order may already have moved on by
+// the time the caller tracks it, so a check runs straight away rather than
+// waiting for the next state message.
+func (t *tracker) Track(vendorOrderID string) {
+	t.StateTracker.Track(vendorOrderID)
+	go t.Check()
+}
+
+func (t *tracker) Stop() { t.a.t.Close() }
+
+// OrderStates implements fleet.StateSource.
+func (a *Adapter) OrderStates(ids []string) map[string]fleet.VendorState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make(map[string]fleet.VendorState, len(ids))
+	for _, id := range ids {
+		o, ok := a.orders[id]
+		if !ok {
+			continue
+		}
+		vs := fleet.VendorState{State: o.state, Detail: o.detail}
+		if a.IsTerminalState(o.state) {
+			o.reported = true
+		}
+		if o.vehicle != nil {
+			vs.RobotID = o.vehicle.serial
+		}
+		out[id] = vs
+	}
+	return out
+}
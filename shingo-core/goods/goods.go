@@ -0,0 +1,271 @@
+// Package goods binds the payload a retrieve or store order carries to the
+// robot's container on the fleet, unbinds it when the leg ends, and flags
+// goods a robot reports that no ShinGo order accounts for.
+package goods
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shingocore/dispatch"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// idPrefix marks goods IDs bound by ShinGo.
+const idPrefix = "SG-"
+
+// maxSummary bounds the manifest summary, in characters.
+const maxSummary = 64
+
+// ID returns the goods ID bound for a payload, e.g. "SG-42".
+func ID(payloadID int64) string {
+	return idPrefix + strconv.FormatInt(payloadID, 10)
+}
+
+// Summary returns a short description of a payload's manifest, e.g.
+// "PN-100 x24, PN-200 x6", sent alongside the goods ID. Long summaries are
+// cut on a character boundary.
+func Summary(items []*store.ManifestItem) string {
+	var parts []string
+	for _, it := range items {
+		parts = append(parts, fmt.Sprintf("%s x%g", it.PartNumber, it.Quantity))
+	}
+	summary := []rune(strings.Join(parts, ", "))
+	if len(summary) > maxSummary {
+		return string(summary[:maxSummary-3]) + "..."
+	}
+	return string(summary)
+}
+
+// PayloadID returns the payload a goods ID was bound for, or false if the
+// goods were not bound by ShinGo.
+func PayloadID(goodsID string) (int64, bool) {
+	rest, ok := strings.CutPrefix(goodsID, idPrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	return id, err == nil
+}
+
+// Binding is a payload bound to a robot for an order.
+type Binding struct {
+	OrderID   int64  `json:"order_id"`
+	PayloadID int64  `json:"payload_id"`
+	VehicleID string `json:"vehicle_id"`
+	GoodsID   string `json:"goods_id"`
+	Summary   string `json:"summary"` // manifest summary sent with the goods ID
+}
+
+// Mismatch reports goods on a robot that no active ShinGo order accounts for.
+type Mismatch struct {
+	VehicleID string    `json:"vehicle_id"`
+	Container string    `json:"container"`
+	GoodsID   string    `json:"goods_id"`
+	Reason    string    `json:"reason"`
+	At        time.Time `json:"at"`
+}
+
+// maxMismatches bounds the recent mismatches kept for the web UI.
+const maxMismatches = 50
+
+// Manager keeps the fleet's goods bindings in step with ShinGo orders. It is
+// driven by the engine: Bind when a robot takes an order, Unbind when the
+// order's leg ends, and Check with every robot status refresh.
+type Manager struct {
+	db        *store.DB
+	binder    fleet.GoodsBinder
+	container string
+	Mismatch  func(Mismatch)
+	DebugLog  func(string, ...any)
+
+	mu         sync.Mutex
+	bound      map[int64]Binding // order ID -> binding
+	flagged    map[string]bool   // vehicle/goods pairs already flagged
+	mismatches []Mismatch
+}
+
+// NewManager creates a goods manager that binds payloads to the named robot
+// container. An empty container binds to the robot's default container.
+func NewManager(db *store.DB, binder fleet.GoodsBinder, container string) *Manager {
+	return &Manager{
+		db:        db,
+		binder:    binder,
+		container: container,
+		bound:     make(map[int64]Binding),
+		flagged:   make(map[string]bool),
+	}
+}
+
+func (m *Manager) dbg(format string, args ...any) {
+	if fn := m.DebugLog; fn != nil {
+		fn(format, args...)
+	}
+}
+
+// Bind binds an order's payload to the robot carrying it. Orders other than
+// retrieve and store, and orders without a claimed payload, are skipped. An
+// order already bound to another robot, e.g. after a redispatch, is moved.
+func (m *Manager) Bind(order *store.Order, vehicleID string) {
+	if order.PayloadID == nil || vehicleID == "" {
+		return
+	}
+	if order.OrderType != dispatch.OrderTypeRetrieve && order.OrderType != dispatch.OrderTypeStore {
+		return
+	}
+	m.mu.Lock()
+	prev, ok := m.bound[order.ID]
+	m.mu.Unlock()
+	if ok && prev.VehicleID == vehicleID {
+		return
+	}
+	if ok {
+		m.Unbind(order.ID)
+	}
+
+	items, err := m.db.ListManifestItems(*order.PayloadID)
+	if err != nil {
+		log.Printf("goods: manifest of payload %d: %v", *order.PayloadID, err)
+	}
+	b := Binding{OrderID: order.ID, PayloadID: *order.PayloadID, VehicleID: vehicleID, GoodsID: ID(*order.PayloadID), Summary: Summary(items)}
+	if err := m.binder.BindGoods(vehicleID, m.container, b.GoodsID, b.Summary); err != nil {
+		log.Printf("goods: bind %s to %s for order %d: %v", b.GoodsID, vehicleID, order.ID, err)
+		return
+	}
+	m.dbg("goods: bound %s to %s for order %d", b.GoodsID, vehicleID, order.ID)
+	m.mu.Lock()
+	m.bound[order.ID] = b
+	m.mu.Unlock()
+}
+
+// Unbind removes an order's goods binding, if it has one.
+func (m *Manager) Unbind(orderID int64) {
+	m.mu.Lock()
+	b, ok := m.bound[orderID]
+	delete(m.bound, orderID)
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := m.binder.UnbindGoods(b.VehicleID, b.GoodsID); err != nil {
+		log.Printf("goods: unbind %s from %s for order %d: %v", b.GoodsID, b.VehicleID, orderID, err)
+		return
+	}
+	m.dbg("goods: unbound %s from %s for order %d", b.GoodsID, b.VehicleID, orderID)
+}
+
+// Check compares the goods robots report with the bindings of active orders
+// and flags the ones that do not match. Bindings made before a restart are
+// recovered from the orders table.
+func (m *Manager) Check(robots []fleet.RobotStatus, now time.Time) {
+	m.mu.Lock()
+	expected := make(map[string]string, len(m.bound)) // goods ID -> vehicle
+	for _, b := range m.bound {
+		expected[b.GoodsID] = b.VehicleID
+	}
+	m.mu.Unlock()
+
+	var active []*store.Order
+	loaded := false
+	reported := make(map[string]bool)
+	var found []Mismatch
+	for _, r := range robots {
+		for _, g := range r.Goods {
+			key := r.VehicleID + "/" + g.GoodsID
+			reported[key] = true
+			if expected[g.GoodsID] == r.VehicleID {
+				continue
+			}
+			if !loaded {
+				var err error
+				if active, err = m.db.ListActiveOrders(); err != nil {
+					log.Printf("goods: list active orders: %v", err)
+					return
+				}
+				loaded = true
+			}
+			reason := m.reconcile(r.VehicleID, g.GoodsID, active)
+			if reason == "" {
+				continue
+			}
+			m.mu.Lock()
+			seen := m.flagged[key]
+			m.flagged[key] = true
+			m.mu.Unlock()
+			if !seen {
+				found = append(found, Mismatch{VehicleID: r.VehicleID, Container: g.Container, GoodsID: g.GoodsID, Reason: reason, At: now})
+			}
+		}
+	}
+
+	m.mu.Lock()
+	for key := range m.flagged {
+		if !reported[key] {
+			delete(m.flagged, key)
+		}
+	}
+	m.mismatches = append(m.mismatches, found...)
+	if len(m.mismatches) > maxMismatches {
+		m.mismatches = m.mismatches[len(m.mismatches)-maxMismatches:]
+	}
+	m.mu.Unlock()
+
+	for _, mm := range found {
+		log.Printf("goods: mismatch on %s: %s: %s", mm.VehicleID, mm.GoodsID, mm.Reason)
+		if m.Mismatch != nil {
+			m.Mismatch(mm)
+		}
+	}
+}
+
+// reconcile explains why goods on a robot match no binding, or returns ""
+// when an active order on that robot carries the payload, in which case the
+// binding is adopted.
+func (m *Manager) reconcile(vehicleID, goodsID string, active []*store.Order) string {
+	payloadID, ok := PayloadID(goodsID)
+	if !ok {
+		return "goods not bound by ShinGo"
+	}
+	for _, o := range active {
+		if o.PayloadID == nil || *o.PayloadID != payloadID {
+			continue
+		}
+		if o.RobotID != vehicleID {
+			return fmt.Sprintf("payload %d belongs to order %d on robot %s", payloadID, o.ID, o.RobotID)
+		}
+		m.mu.Lock()
+		m.bound[o.ID] = Binding{OrderID: o.ID, PayloadID: payloadID, VehicleID: vehicleID, GoodsID: goodsID}
+		m.mu.Unlock()
+		return ""
+	}
+	return fmt.Sprintf("no active order carries payload %d", payloadID)
+}
+
+// Bindings returns the current goods bindings, by order.
+func (m *Manager) Bindings() []Binding {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Binding, 0, len(m.bound))
+	for _, b := range m.bound {
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OrderID < out[j].OrderID })
+	return out
+}
+
+// Mismatches returns recent mismatches, newest first.
+func (m *Manager) Mismatches() []Mismatch {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Mismatch, len(m.mismatches))
+	for i, mm := range m.mismatches {
+		out[len(out)-1-i] = mm
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package goods
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"shingocore/config"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+func testDB(t *testing.T) *store.DB {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := store.Open(&config.DatabaseConfig{
+		Driver: "sqlite",
+		SQLite: config.SQLiteConfig{Path: dbPath},
+	})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		os.Remove(dbPath)
+	})
+	return db
+}
+
+type fakeBinder struct {
+	calls []string
+}
+
+func (f *fakeBinder) BindGoods(vehicleID, container, goodsID, desc string) error {
+	f.calls = append(f.calls, fmt.Sprintf("bind %s %s %s (%s)", vehicleID, container, goodsID, desc))
+	return nil
+}
+
+func (f *fakeBinder) UnbindGoods(vehicleID, goodsID string) error {
+	f.calls = append(f.calls, fmt.Sprintf("unbind %s %s", vehicleID, goodsID))
+	return nil
+}
+
+// retrieveOrder creates a retrieve order carrying a payload with a manifest.
+func retrieveOrder(t *testing.T, db *store.DB) *store.Order {
+	t.Helper()
+	pt := &store.PayloadType{Name: "PART-A", FormFactor: "tote", DefaultManifestJSON: "{}"}
+	if err := db.CreatePayloadType(pt); err != nil {
+		t.Fatalf("create payload type: %v", err)
+	}
+	p := &store.Payload{PayloadTypeID: pt.ID, Status: "available"}
+	if err := db.CreatePayload(p); err != nil {
+		t.Fatalf("create payload: %v", err)
+	}
+	db.CreateManifestItem(&store.ManifestItem{PayloadID: p.ID, PartNumber: "PN-100", Quantity: 24})
+	order := &store.Order{EdgeUUID: "uuid-1", StationID: "line-1", OrderType: "retrieve", Status: "dispatched", PayloadID: &p.ID}
+	if err := db.CreateOrder(order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	return order
+}
+
+func TestGoodsID(t *testing.T) {
+	id := ID(42)
+	if id != "SG-42" {
+		t.Errorf("ID = %q", id)
+	}
+	if pid, ok := PayloadID(id); !ok || pid != 42 {
+		t.Errorf("PayloadID(%q) = %d, %v", id, pid, ok)
+	}
+	if _, ok := PayloadID("PALLET-7"); ok {
+		t.Error("foreign goods parsed as a ShinGo payload")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	s := Summary([]*store.ManifestItem{{PartNumber: "PN-100,A|B*C", Quantity: 24}, {PartNumber: "PN-200", Quantity: 1.5}})
+	if s != "PN-100,A|B*C x24, PN-200 x1.5" {
+		t.Errorf("Summary = %q", s)
+	}
+	long := Summary([]*store.ManifestItem{{PartNumber: strings.Repeat("é", 100), Quantity: 1}})
+	if !utf8.ValidString(long) || utf8.RuneCountInString(long) != maxSummary {
+		t.Errorf("long summary = %q (%d chars), want %d valid characters", long, utf8.RuneCountInString(long), maxSummary)
+	}
+}
+
+func TestBindUnbind(t *testing.T) {
+	db := testDB(t)
+	order := retrieveOrder(t, db)
+	f := &fakeBinder{}
+	m := NewManager(db, f, "A")
+
+	m.Bind(order, "AMB-01")
+	m.Bind(order, "AMB-01") // already bound
+	m.Bind(order, "AMB-02") // redispatched to another robot
+	m.Unbind(order.ID)
+	m.Unbind(order.ID)
+
+	goodsID := fmt.Sprintf("SG-%d", *order.PayloadID)
+	want := []string{
+		"bind AMB-01 A " + goodsID + " (PN-100 x24)",
+		"unbind AMB-01 " + goodsID,
+		"bind AMB-02 A " + goodsID + " (PN-100 x24)",
+		"unbind AMB-02 " + goodsID,
+	}
+	if fmt.Sprint(f.calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", f.calls, want)
+	}
+
+	move := *order
+	move.ID, move.OrderType = 99, "move"
+	m.Bind(&move, "AMB-01")
+	if len(m.Bindings()) != 0 {
+		t.Errorf("move order bound: %+v", m.Bindings())
+	}
+}
+
+func TestCheckFlagsMismatches(t *testing.T) {
+	db := testDB(t)
+	order := retrieveOrder(t, db)
+	db.UpdateOrderVendor(order.ID, "sg-1", "RUNNING", "AMB-01")
+	m := NewManager(db, &fakeBinder{}, "")
+	var flagged []string
+	m.Mismatch = func(mm Mismatch) { flagged = append(flagged, mm.VehicleID+" "+mm.Reason) }
+
+	goodsID := ID(*order.PayloadID)
+	robots := []fleet.RobotStatus{
+		// Bound before a restart: adopted, not flagged
+		{VehicleID: "AMB-01", Goods: []fleet.ContainerGoods{{GoodsID: goodsID}}},
+		{VehicleID: "AMB-02", Goods: []fleet.ContainerGoods{{GoodsID: goodsID}, {GoodsID: "PALLET-7"}}},
+		{VehicleID: "AMB-03", Goods: []fleet.ContainerGoods{{GoodsID: "SG-999"}}},
+	}
+	m.Check(robots, time.Now())
+	m.Check(robots, time.Now()) // flagged once only
+
+	want := []string{
+		fmt.Sprintf("AMB-02 payload %d belongs to order %d on robot AMB-01", *order.PayloadID, order.ID),
+		"AMB-02 goods not bound by ShinGo",
+		"AMB-03 no active order carries payload 999",
+	}
+	if fmt.Sprint(flagged) != fmt.Sprint(want) {
+		t.Errorf("flagged = %v, want %v", flagged, want)
+	}
+	if b := m.Bindings(); len(b) != 1 || b[0].VehicleID != "AMB-01" {
+		t.Errorf("bindings = %+v, want the adopted AMB-01 binding", b)
+	}
+	if len(m.Mismatches()) != 3 {
+		t.Errorf("mismatches = %d, want 3", len(m.Mismatches()))
+	}
+}
@@ -83,6 +83,13 @@ func (h *CoreHandler) HandleData(env *protocol.Envelope, p *protocol.Data) {
 			return
 		}
 		h.handleProductionReport(env, &rpt)
+	case protocol.SubjectZoneLock:
+		var zl protocol.ZoneLock
+		if err := json.Unmarshal(p.Body, &zl); err != nil {
+			log.Printf("core_handler: decode zone lock body: %v", err)
+			return
+		}
+		h.dispatcher.HandleZoneLock(env, &zl)
 	default:
 		log.Printf("core_handler: unhandled data subject: %s", p.Subject)
 	}
@@ -200,6 +207,12 @@ func (h *CoreHandler) HandleOrderStorageWaybill(env *protocol.Envelope, p *proto
 	h.dispatcher.HandleOrderStorageWaybill(env, p)
 }
 
+func (h *CoreHandler) HandleOrderRelease(env *protocol.Envelope, p *protocol.OrderRelease) {
+	log.Printf("core_handler: release from %s: uuid=%s", env.Src.Station, p.OrderUUID)
+	h.dbg("-> order_release from=%s uuid=%s reason=%s", env.Src.Station, p.OrderUUID, p.Reason)
+	h.dispatcher.HandleOrderRelease(env, p)
+}
+
 func (h *CoreHandler) handleProductionReport(env *protocol.Envelope, rpt *protocol.ProductionReport) {
 	log.Printf("core_handler: production report from %s: %d entries", rpt.StationID, len(rpt.Reports))
 	accepted := 0
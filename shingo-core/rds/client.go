@@ -3,9 +3,11 @@ package rds
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -162,10 +164,34 @@ func (c *Client) Reconfigure(baseURL string, timeout time.Duration) {
 	c.httpClient.Timeout = timeout
 }
 
+// APIError is a non-zero code in the RDS response envelope: the request
+// reached RDS and was rejected.
+type APIError struct {
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("rds error %d: %s", e.Code, e.Msg)
+}
+
+// IsNotFound reports whether err is RDS saying the requested order does
+// not exist: an error envelope whose message says the order was not found
+// or does not exist. HTTP errors, timeouts and every other error code leave
+// the order's state unknown and report false.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	msg := strings.ToLower(apiErr.Msg)
+	return strings.Contains(msg, "not found") || strings.Contains(msg, "not exist")
+}
+
 // checkResponse validates the RDS response envelope code.
 func checkResponse(r *Response) error {
 	if r.Code != 0 {
-		return fmt.Errorf("rds error %d: %s", r.Code, r.Msg)
+		return &APIError{Code: r.Code, Msg: r.Msg}
 	}
 	return nil
 }
@@ -2,6 +2,7 @@ package rds
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -107,6 +108,51 @@ func TestGetOrderDetails_NotFound(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for not found")
 	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 1 {
+		t.Errorf("err = %v, want APIError code 1", err)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	cases := map[string]struct {
+		handler http.HandlerFunc
+		want    bool
+	}{
+		"not found": {func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(Response{Code: 1, Msg: "not found"})
+		}, true},
+		"does not exist": {func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(Response{Code: 60000, Msg: "order sg-1 does not exist"})
+		}, true},
+		"other code": {func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(Response{Code: 2, Msg: "database busy"})
+		}, false},
+		"server error": {func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "not found in cache", http.StatusInternalServerError)
+		}, false},
+		"unauthorized": {func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}, false},
+	}
+	for name, tc := range cases {
+		srv, client := testServer(tc.handler)
+		_, err := client.GetOrderDetails("sg-1")
+		srv.Close()
+		if err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+		if got := IsNotFound(err); got != tc.want {
+			t.Errorf("%s: IsNotFound(%v) = %v, want %v", name, err, got, tc.want)
+		}
+	}
+
+	// Unreachable RDS (the server above is closed).
+	srv, client := testServer(func(w http.ResponseWriter, r *http.Request) {})
+	srv.Close()
+	if _, err := client.GetOrderDetails("sg-1"); err == nil || IsNotFound(err) {
+		t.Errorf("unreachable: err = %v, want a non-not-found error", err)
+	}
 }
 
 func TestTerminateOrder(t *testing.T) {
@@ -361,7 +407,8 @@ func TestPollerDetectsStateTransition(t *testing.T) {
 		t.Errorf("events after first poll = %d, want 0", len(emitter.events))
 	}
 
-	// Second poll detects CREATED -> RUNNING
+	// Second poll, once the order is due again, detects CREATED -> RUNNING
+	expireDue(p)
 	p.poll()
 	emitter.mu.Lock()
 	defer emitter.mu.Unlock()
@@ -400,3 +447,198 @@ func TestPollerRemovesTerminal(t *testing.T) {
 		t.Errorf("count after terminal = %d, want 0", p.ActiveCount())
 	}
 }
+
+func TestPollerBatchesWithListOrders(t *testing.T) {
+	var mu sync.Mutex
+	var listCalls, detailCalls []string
+	srv, client := testServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/orders" {
+			listCalls = append(listCalls, r.URL.Query().Get("page"))
+			json.NewEncoder(w).Encode(OrderListResponse{
+				Response: Response{Code: 0},
+				Data: []OrderDetail{
+					{ID: "rds-1", State: StateRunning, Vehicle: "AMB-01"},
+					{ID: "rds-2", State: StateRunning},
+					{ID: "rds-3", State: StateFinished},
+					{ID: "other", State: StateRunning},
+				},
+			})
+			return
+		}
+		detailCalls = append(detailCalls, r.URL.Path)
+		json.NewEncoder(w).Encode(OrderDetailsResponse{
+			Response: Response{Code: 0},
+			Data:     &OrderDetail{ID: "rds-4", State: StateWaiting},
+		})
+	})
+	defer srv.Close()
+
+	emitter := &mockPollerEmitter{}
+	p := NewPoller(client, emitter, &mockResolver{}, time.Minute)
+	p.BatchMin = 3
+	p.PageSize = 10
+	for _, id := range []string{"rds-1", "rds-2", "rds-3", "rds-4"} {
+		p.Track(id)
+	}
+	p.poll()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(listCalls) != 1 {
+		t.Errorf("list calls = %v, want one page (short page ends the scan)", listCalls)
+	}
+	if len(detailCalls) != 1 {
+		t.Errorf("detail calls = %v, want 1 for the unlisted order", detailCalls)
+	}
+	emitter.mu.Lock()
+	if len(emitter.events) != 4 {
+		t.Errorf("events = %d, want 4", len(emitter.events))
+	}
+	emitter.mu.Unlock()
+	if p.ActiveCount() != 3 {
+		t.Errorf("active = %d, want 3 after the finished order drops out", p.ActiveCount())
+	}
+
+	st := p.Stats()
+	if st.ListCalls != 1 || st.DetailCalls != 1 || st.Polls != 1 || st.Tracked != 3 {
+		t.Errorf("stats = %+v, want 1 list call, 1 detail call, 1 poll, 3 tracked", st)
+	}
+	if st.LastLatency <= 0 || st.LastPoll.IsZero() {
+		t.Errorf("stats = %+v, want latency and poll time recorded", st)
+	}
+}
+
+func TestPollerListStopsAtDueOrders(t *testing.T) {
+	var mu sync.Mutex
+	listCalls := 0
+	srv, client := testServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		listCalls++
+		mu.Unlock()
+		json.NewEncoder(w).Encode(OrderListResponse{
+			Response: Response{Code: 0},
+			Data: []OrderDetail{
+				{ID: "rds-1", State: StateRunning},
+				{ID: "rds-2", State: StateRunning},
+				{ID: "rds-3", State: StateRunning},
+			},
+		})
+	})
+	defer srv.Close()
+
+	p := NewPoller(client, &mockPollerEmitter{}, &mockResolver{}, time.Minute)
+	p.BatchMin = 3
+	p.PageSize = 3
+	for _, id := range []string{"rds-1", "rds-2", "rds-3", "rds-4"} {
+		p.Track(id)
+	}
+	// rds-4 was polled recently and is not due
+	p.due["rds-4"] = time.Now().Add(time.Hour)
+	p.poll()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if listCalls != 1 {
+		t.Errorf("list calls = %d, want 1 once every due order is found", listCalls)
+	}
+}
+
+func TestPollerAdaptiveIntervals(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	details := map[string]*OrderDetail{
+		"/orderDetails/waiting":    {ID: "waiting", State: StateWaiting},
+		"/orderDetails/delivering": {ID: "delivering", State: StateRunning, LoadState: StateFinished},
+		"/orderDetails/loading":    {ID: "loading", State: StateRunning, LoadState: StateRunning},
+	}
+	srv, client := testServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+		json.NewEncoder(w).Encode(OrderDetailsResponse{
+			Response: Response{Code: 0},
+			Data:     details[r.URL.Path],
+		})
+	})
+	defer srv.Close()
+
+	p := NewPoller(client, &mockPollerEmitter{}, &mockResolver{}, time.Minute)
+	p.Intervals = PollIntervals{Waiting: time.Hour, Delivering: time.Nanosecond}
+	if p.tick() != time.Nanosecond {
+		t.Errorf("tick = %v, want the delivering interval", p.tick())
+	}
+	for _, id := range []string{"waiting", "delivering", "loading"} {
+		p.Track(id)
+	}
+	p.poll()
+	p.poll()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["/orderDetails/waiting"] != 1 || calls["/orderDetails/loading"] != 1 {
+		t.Errorf("calls = %v, want waiting and loading orders deferred after the first poll", calls)
+	}
+	if calls["/orderDetails/delivering"] != 2 {
+		t.Errorf("calls = %v, want the delivering order polled again", calls)
+	}
+}
+
+// expireDue makes every tracked order due on the next poll.
+func expireDue(p *Poller) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id := range p.due {
+		p.due[id] = time.Time{}
+	}
+}
+
+func TestPollerTrackFromSeedsState(t *testing.T) {
+	srv, client := testServer(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OrderDetailsResponse{
+			Response: Response{Code: 0},
+			Data:     &OrderDetail{ID: "rds-1", State: StateRunning, Vehicle: "AMB-01"},
+		})
+	})
+	defer srv.Close()
+
+	emitter := &mockPollerEmitter{}
+	p := NewPoller(client, emitter, &mockResolver{}, time.Minute)
+	p.TrackFrom("rds-1", string(StateRunning))
+	p.poll()
+
+	emitter.mu.Lock()
+	defer emitter.mu.Unlock()
+	if len(emitter.events) != 0 {
+		t.Errorf("events = %+v, want none for an order resumed in its current state", emitter.events)
+	}
+}
+
+func TestPollerSynthesizesMissedRunning(t *testing.T) {
+	srv, client := testServer(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OrderDetailsResponse{
+			Response: Response{Code: 0},
+			Data:     &OrderDetail{ID: "rds-1", State: StateFinished, Vehicle: "AMB-01"},
+		})
+	})
+	defer srv.Close()
+
+	emitter := &mockPollerEmitter{}
+	p := NewPoller(client, emitter, &mockResolver{}, time.Minute)
+	p.TrackFrom("rds-1", string(StateToBeDispatched))
+	p.poll()
+
+	emitter.mu.Lock()
+	defer emitter.mu.Unlock()
+	if len(emitter.events) != 2 {
+		t.Fatalf("events = %+v, want 2", emitter.events)
+	}
+	first, second := emitter.events[0], emitter.events[1]
+	if first.oldStatus != "TOBEDISPATCHED" || first.newStatus != "RUNNING" || first.robotID != "AMB-01" {
+		t.Errorf("first = %+v, want synthesized TOBEDISPATCHED -> RUNNING with robot", first)
+	}
+	if second.oldStatus != "RUNNING" || second.newStatus != "FINISHED" {
+		t.Errorf("second = %+v, want RUNNING -> FINISHED", second)
+	}
+}
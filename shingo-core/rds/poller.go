@@ -18,7 +18,10 @@ type OrderIDResolver interface {
 	ResolveRDSOrderID(rdsOrderID string) (int64, error)
 }
 
-// Poller periodically checks active RDS orders for state transitions.
+// Poller periodically checks active RDS orders for state transitions. With
+// enough orders tracked it reads states from the paged order list in a few
+// calls, falling back to one call per order for orders the list does not
+// reach.
 type Poller struct {
 	client   *Client
 	emitter  PollerEmitter
@@ -26,18 +29,59 @@ type Poller struct {
 	interval time.Duration
 	DebugLog func(string, ...any)
 
+	// Intervals slows or speeds up polling of an order by where it is in its
+	// run. A zero interval polls that phase at the base interval.
+	Intervals PollIntervals
+
+	// BatchMin is the number of due orders at which the poller switches to
+	// the order list. Below it, per-order calls are cheaper than a page.
+	BatchMin int
+	PageSize int
+	MaxPages int
+
 	mu       sync.Mutex
 	active   map[string]OrderState // rdsOrderID -> last known state
+	due      map[string]time.Time  // rdsOrderID -> next poll; unset until first polled
+	stats    PollStats
 	stopChan chan struct{}
 }
 
+// PollIntervals are per-phase poll intervals.
+type PollIntervals struct {
+	Waiting    time.Duration // queued in RDS, not yet running
+	Delivering time.Duration // running the final drop
+}
+
+// PollStats reports poller load and latency for tuning.
+type PollStats struct {
+	Tracked     int           `json:"tracked"`
+	Polls       int64         `json:"polls"`
+	ListCalls   int64         `json:"list_calls"`
+	DetailCalls int64         `json:"detail_calls"`
+	Errors      int64         `json:"errors"`
+	LastLatency time.Duration `json:"last_latency_ns"`
+	MaxLatency  time.Duration `json:"max_latency_ns"`
+	AvgLatency  time.Duration `json:"avg_latency_ns"`
+	LastPoll    time.Time     `json:"last_poll"`
+}
+
+const (
+	defaultBatchMin = 5
+	defaultPageSize = 100
+	defaultMaxPages = 5
+)
+
 func NewPoller(client *Client, emitter PollerEmitter, resolver OrderIDResolver, interval time.Duration) *Poller {
 	return &Poller{
 		client:   client,
 		emitter:  emitter,
 		resolver: resolver,
 		interval: interval,
+		BatchMin: defaultBatchMin,
+		PageSize: defaultPageSize,
+		MaxPages: defaultMaxPages,
 		active:   make(map[string]OrderState),
+		due:      make(map[string]time.Time),
 		stopChan: make(chan struct{}),
 	}
 }
@@ -50,10 +94,20 @@ func (p *Poller) dbg(format string, args ...any) {
 
 // Track adds an RDS order ID to the active poll set.
 func (p *Poller) Track(rdsOrderID string) {
+	p.TrackFrom(rdsOrderID, "")
+}
+
+// TrackFrom adds an RDS order ID to the active poll set, starting from its
+// last known state so that a restart neither replays nor drops transitions.
+func (p *Poller) TrackFrom(rdsOrderID, lastState string) {
+	state := OrderState(lastState)
+	if state == "" {
+		state = StateCreated
+	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if _, exists := p.active[rdsOrderID]; !exists {
-		p.active[rdsOrderID] = StateCreated
+		p.active[rdsOrderID] = state
 	}
 }
 
@@ -62,6 +116,7 @@ func (p *Poller) Untrack(rdsOrderID string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	delete(p.active, rdsOrderID)
+	delete(p.due, rdsOrderID)
 }
 
 // ActiveCount returns the number of orders being polled.
@@ -71,6 +126,15 @@ func (p *Poller) ActiveCount() int {
 	return len(p.active)
 }
 
+// Stats returns call counts and poll latency since the poller was created.
+func (p *Poller) Stats() PollStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.stats
+	st.Tracked = len(p.active)
+	return st
+}
+
 func (p *Poller) Start() {
 	go p.run()
 }
@@ -82,8 +146,19 @@ func (p *Poller) Stop() {
 	}
 }
 
+// tick is the shortest configured interval, so orders near delivery can be
+// polled at their rate. Every other order waits for its own due time and
+// skips the ticks in between.
+func (p *Poller) tick() time.Duration {
+	d := p.interval
+	if f := p.Intervals.Delivering; f > 0 && f < d {
+		d = f
+	}
+	return d
+}
+
 func (p *Poller) run() {
-	ticker := time.NewTicker(p.interval)
+	ticker := time.NewTicker(p.tick())
 	defer ticker.Stop()
 
 	for {
@@ -97,59 +172,183 @@ func (p *Poller) run() {
 }
 
 func (p *Poller) poll() {
+	start := time.Now()
+	// Ticks land a little early or late; an order due within half a tick
+	// is polled now rather than a whole tick late.
+	horizon := start.Add(p.tick() / 2)
 	p.mu.Lock()
 	ids := make([]string, 0, len(p.active))
 	for id := range p.active {
-		ids = append(ids, id)
+		if next, ok := p.due[id]; !ok || !horizon.Before(next) {
+			ids = append(ids, id)
+		}
 	}
 	p.mu.Unlock()
 
-	if len(ids) > 0 {
-		if len(ids) <= 10 {
-			p.dbg("poll: %d active orders [%s]", len(ids), strings.Join(ids, ", "))
-		} else {
-			p.dbg("poll: %d active orders", len(ids))
-		}
+	if len(ids) == 0 {
+		return
+	}
+	if len(ids) <= 10 {
+		p.dbg("poll: %d due orders [%s]", len(ids), strings.Join(ids, ", "))
+	} else {
+		p.dbg("poll: %d due orders", len(ids))
+	}
+
+	var listed map[string]*OrderDetail
+	if len(ids) >= p.BatchMin && p.BatchMin > 0 {
+		listed = p.listTracked(ids)
 	}
 
 	for _, rdsID := range ids {
-		detail, err := p.client.GetOrderDetails(rdsID)
-		if err != nil {
-			log.Printf("poller: get order %s: %v", rdsID, err)
-			p.dbg("poll error: GetOrderDetails(%s): %v", rdsID, err)
-			continue
+		detail, ok := listed[rdsID]
+		if !ok {
+			var err error
+			p.count(&p.stats.DetailCalls)
+			detail, err = p.client.GetOrderDetails(rdsID)
+			if err != nil {
+				p.count(&p.stats.Errors)
+				log.Printf("poller: get order %s: %v", rdsID, err)
+				p.dbg("poll error: GetOrderDetails(%s): %v", rdsID, err)
+				continue
+			}
 		}
+		p.apply(rdsID, detail, start)
+	}
 
-		p.mu.Lock()
-		oldState, exists := p.active[rdsID]
-		p.mu.Unlock()
-		if !exists {
-			continue
-		}
+	elapsed := time.Since(start)
+	p.mu.Lock()
+	st := &p.stats
+	st.Polls++
+	st.LastLatency = elapsed
+	if elapsed > st.MaxLatency {
+		st.MaxLatency = elapsed
+	}
+	st.AvgLatency += (elapsed - st.AvgLatency) / time.Duration(st.Polls)
+	st.LastPoll = start
+	p.mu.Unlock()
+}
 
-		newState := detail.State
-		if newState == oldState {
-			continue
-		}
+func (p *Poller) count(n *int64) {
+	p.mu.Lock()
+	*n++
+	p.mu.Unlock()
+}
 
-		p.dbg("transition %s: %s -> %s (robot=%s)", rdsID, oldState, newState, detail.Vehicle)
+// listTracked pages through the order list, newest first, until every due
+// order has been seen or MaxPages is reached. Orders it does not find are
+// polled one by one.
+func (p *Poller) listTracked(ids []string) map[string]*OrderDetail {
+	want := len(ids)
+	tracked := make(map[string]bool, want)
+	for _, id := range ids {
+		tracked[id] = true
+	}
 
-		// State transition detected
-		p.mu.Lock()
-		if newState.IsTerminal() {
-			delete(p.active, rdsID)
-		} else {
-			p.active[rdsID] = newState
+	found := make(map[string]*OrderDetail, want)
+	for page := 1; page <= p.MaxPages; page++ {
+		p.count(&p.stats.ListCalls)
+		orders, err := p.client.ListOrders(page, p.PageSize)
+		if err != nil {
+			p.count(&p.stats.Errors)
+			log.Printf("poller: list orders page %d: %v", page, err)
+			p.dbg("poll error: ListOrders(%d): %v", page, err)
+			break
+		}
+		for i := range orders {
+			if tracked[orders[i].ID] {
+				found[orders[i].ID] = &orders[i]
+			}
 		}
+		if len(found) == want || len(orders) < p.PageSize {
+			break
+		}
+	}
+	p.dbg("poll: order list found %d of %d due orders", len(found), want)
+	return found
+}
+
+// apply records an order's polled state, schedules its next poll from the
+// poll's start and emits a transition if the state moved.
+func (p *Poller) apply(rdsID string, detail *OrderDetail, polled time.Time) {
+	p.mu.Lock()
+	oldState, exists := p.active[rdsID]
+	if !exists {
 		p.mu.Unlock()
+		return
+	}
+	newState := detail.State
+	if newState.IsTerminal() {
+		delete(p.active, rdsID)
+		delete(p.due, rdsID)
+	} else {
+		p.active[rdsID] = newState
+		p.due[rdsID] = polled.Add(p.intervalFor(detail))
+	}
+	p.mu.Unlock()
 
-		orderID, err := p.resolver.ResolveRDSOrderID(rdsID)
-		if err != nil {
-			log.Printf("poller: resolve %s: %v", rdsID, err)
-			p.dbg("poll error: resolve(%s): %v", rdsID, err)
-			continue
+	if newState == oldState {
+		return
+	}
+
+	orderID, err := p.resolver.ResolveRDSOrderID(rdsID)
+	if err != nil {
+		log.Printf("poller: resolve %s: %v", rdsID, err)
+		p.dbg("poll error: resolve(%s): %v", rdsID, err)
+		return
+	}
+
+	// An order seen queued and then already past RUNNING was assigned a
+	// robot between polls (or while core was down). Report the assignment
+	// first so ShinGo Edge still gets its waybill and in-transit update.
+	if missedRunning(oldState, newState, detail) {
+		p.dbg("transition %s: %s -> %s (robot=%s, synthesized)", rdsID, oldState, StateRunning, detail.Vehicle)
+		p.emitter.EmitOrderStatusChanged(orderID, rdsID, string(oldState), string(StateRunning), detail.Vehicle, fmt.Sprintf("fleet state: %s -> %s (missed between polls)", oldState, StateRunning))
+		oldState = StateRunning
+	}
+
+	p.dbg("transition %s: %s -> %s (robot=%s)", rdsID, oldState, newState, detail.Vehicle)
+	p.emitter.EmitOrderStatusChanged(orderID, rdsID, string(oldState), string(newState), detail.Vehicle, fmt.Sprintf("fleet state: %s -> %s", oldState, newState))
+}
+
+// missedRunning reports whether an order went from queued to a terminal
+// state with a robot assigned, skipping the RUNNING state in between.
+func missedRunning(oldState, newState OrderState, d *OrderDetail) bool {
+	if d.Vehicle == "" || !newState.IsTerminal() {
+		return false
+	}
+	switch oldState {
+	case StateCreated, StateToBeDispatched, StateWaiting:
+		return true
+	}
+	return false
+}
+
+// intervalFor returns how long to wait before polling an order again: the
+// base interval, unless the order is queued or on its final drop and that
+// phase has its own interval.
+func (p *Poller) intervalFor(d *OrderDetail) time.Duration {
+	switch d.State {
+	case StateCreated, StateToBeDispatched, StateWaiting:
+		if p.Intervals.Waiting > 0 {
+			return p.Intervals.Waiting
+		}
+	case StateRunning:
+		if nearDelivery(d) && p.Intervals.Delivering > 0 {
+			return p.Intervals.Delivering
 		}
+	}
+	return p.interval
+}
 
-		p.emitter.EmitOrderStatusChanged(orderID, rdsID, string(oldState), string(newState), detail.Vehicle, fmt.Sprintf("fleet state: %s -> %s", oldState, newState))
+// nearDelivery reports whether a running order is on its final drop: a join
+// order that has loaded, or a block order whose last block has started.
+func nearDelivery(d *OrderDetail) bool {
+	if d.LoadState != "" {
+		return d.LoadState == StateFinished
+	}
+	if n := len(d.Blocks); n > 0 {
+		last := d.Blocks[n-1].State
+		return last != "" && last != StateCreated && last != StateToBeDispatched
 	}
+	return false
 }
@@ -403,6 +403,7 @@ type BindGoodsRequest struct {
 	Vehicle       string `json:"vehicle"`
 	ContainerName string `json:"containerName"`
 	GoodsID       string `json:"goodsId"`
+	Desc          string `json:"desc,omitempty"`
 }
 
 type UnbindGoodsRequest struct {
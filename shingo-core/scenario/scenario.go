@@ -0,0 +1,498 @@
+// Package scenario runs scripted rehearsals against simulated robots. A
+// script is a list of timed steps that set a robot's simulated battery,
+// e-stop or blocked state, or inject an order; while it runs, the ShinGo
+// events the steps cause are recorded alongside them.
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"shingo/protocol"
+	"shingocore/fleet"
+)
+
+// StationID is the station scenario orders are raised for.
+const StationID = "core-scenario"
+
+// defaultSettle is how long a run keeps recording after its last step.
+const defaultSettle = 30 * time.Second
+
+// Script is a scenario loaded from YAML:
+//
+//	name: e-stop during delivery
+//	settle: 1m
+//	steps:
+//	  - at: 0s
+//	    order: {type: retrieve, payload_type: TOTE, delivery: LINE1-IN}
+//	  - at: 20s
+//	    robot: AMB-01
+//	    emergency: true
+//	  - at: 50s
+//	    robot: AMB-01
+//	    emergency: false
+type Script struct {
+	Name   string        `yaml:"name"`
+	Settle time.Duration `yaml:"settle"` // recording after the last step; default 30s
+	Steps  []Step        `yaml:"steps"`
+}
+
+// Step is one timed action: it either changes a robot's simulated state,
+// with any mix of Battery, Emergency, Blocked and State, or injects an Order.
+type Step struct {
+	At        time.Duration  `yaml:"at"` // offset from the start of the run
+	Robot     string         `yaml:"robot"`
+	Battery   *float64       `yaml:"battery"` // in the units the fleet reports
+	Emergency *bool          `yaml:"emergency"`
+	Blocked   *bool          `yaml:"blocked"`
+	State     map[string]any `yaml:"state"` // other simulator fields, passed through
+	Order     *Order         `yaml:"order"`
+}
+
+// Order is an order injected through the normal order request path.
+type Order struct {
+	Type        string  `yaml:"type"`
+	PayloadType string  `yaml:"payload_type"`
+	Pickup      string  `yaml:"pickup"`
+	Delivery    string  `yaml:"delivery"`
+	Staging     string  `yaml:"staging"`
+	Quantity    float64 `yaml:"quantity"`
+	Priority    int     `yaml:"priority"`
+}
+
+// Parse loads and checks a script. Steps are sorted by their offset.
+func Parse(data []byte) (*Script, error) {
+	var s Script
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+	if len(s.Steps) == 0 {
+		return nil, errors.New("scenario has no steps")
+	}
+	for i, st := range s.Steps {
+		if err := st.check(); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	if s.Settle < 0 {
+		return nil, errors.New("settle must not be negative")
+	}
+	if s.Settle == 0 {
+		s.Settle = defaultSettle
+	}
+	sort.SliceStable(s.Steps, func(i, j int) bool { return s.Steps[i].At < s.Steps[j].At })
+	return &s, nil
+}
+
+// Load reads and checks a script file.
+func Load(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return s, nil
+}
+
+// Scripts lists the script files in dir, by file name.
+func Scripts(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (st Step) check() error {
+	if st.At < 0 {
+		return errors.New("at must not be negative")
+	}
+	robot := st.Battery != nil || st.Emergency != nil || st.Blocked != nil || len(st.State) > 0
+	switch {
+	case robot && st.Order != nil:
+		return errors.New("a step either changes a robot or injects an order, not both")
+	case robot && st.Robot == "":
+		return errors.New("robot is required")
+	case st.Order != nil:
+		if st.Order.Type == "" || st.Order.Delivery == "" {
+			return errors.New("order needs a type and a delivery node")
+		}
+	case !robot:
+		return errors.New("step does nothing: set battery, emergency, blocked, state or order")
+	}
+	return nil
+}
+
+// fields returns the simulator fields a robot step sets.
+func (st Step) fields() map[string]any {
+	f := make(map[string]any, len(st.State)+3)
+	for k, v := range st.State {
+		f[k] = v
+	}
+	if st.Battery != nil {
+		f["battery_level"] = *st.Battery
+	}
+	if st.Emergency != nil {
+		f["emergency"] = *st.Emergency
+	}
+	if st.Blocked != nil {
+		f["blocked"] = *st.Blocked
+	}
+	return f
+}
+
+func (st Step) String() string {
+	if st.Order != nil {
+		o := st.Order
+		from := o.Pickup
+		if from == "" {
+			from = "auto"
+		}
+		return fmt.Sprintf("inject %s order of %s: %s -> %s", o.Type, o.PayloadType, from, o.Delivery)
+	}
+	f := st.fields()
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := "set " + st.Robot
+	for _, k := range keys {
+		out += fmt.Sprintf(" %s=%v", k, f[k])
+	}
+	return out
+}
+
+// Entry kinds in a run's log.
+const (
+	EntryStep  = "step"
+	EntryEvent = "event"
+	EntryRobot = "robot"
+)
+
+// Entry is one line of a run's log: a step taken, a ShinGo event, or a
+// change in a scripted robot's reported state.
+type Entry struct {
+	At     time.Duration `json:"at"` // offset from the start of the run
+	Kind   string        `json:"kind"`
+	Name   string        `json:"name"`
+	Detail string        `json:"detail"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// Run statuses.
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusStopped = "stopped"
+)
+
+// Run is a snapshot of one scenario run.
+type Run struct {
+	ID       int       `json:"id"`
+	Script   string    `json:"script"`
+	Status   string    `json:"status"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Log      []Entry   `json:"log"`
+}
+
+// maxRuns bounds the finished runs kept for the web UI.
+const maxRuns = 20
+
+// maxEntries bounds one run's log, so a long run cannot grow without limit.
+const maxEntries = 2000
+
+// ErrBusy is returned by Start while another scenario is running.
+var ErrBusy = errors.New("a scenario is already running")
+
+type run struct {
+	Run
+	script    *Script
+	cancel    context.CancelFunc
+	robots    map[string]string // scripted robot -> last reported state
+	orders    map[int64]bool    // orders the run injected
+	injecting bool              // an order step is waiting on inject
+	held      []heldEntry       // events seen while injecting, not yet matched to an order
+}
+
+// heldEntry is an event for an order that may turn out to be the one being
+// injected: its events are emitted before inject returns the order's ID.
+type heldEntry struct {
+	orderID int64
+	entry   Entry
+}
+
+// Runner runs one scenario at a time. The engine feeds it ShinGo events
+// with Record and robot refreshes with Robots.
+type Runner struct {
+	sim      fleet.SimStateSetter
+	inject   func(*protocol.OrderRequest) (int64, error)
+	DebugLog func(string, ...any)
+
+	mu     sync.Mutex
+	nextID int
+	active *run
+	runs   []Run // finished runs, oldest first
+}
+
+// NewRunner creates a runner that changes robots through sim and raises
+// orders through inject, which returns the created order's ID.
+func NewRunner(sim fleet.SimStateSetter, inject func(*protocol.OrderRequest) (int64, error)) *Runner {
+	return &Runner{sim: sim, inject: inject}
+}
+
+func (r *Runner) dbg(format string, args ...any) {
+	if fn := r.DebugLog; fn != nil {
+		fn(format, args...)
+	}
+}
+
+// Start begins running a script in the background and returns its ID.
+func (r *Runner) Start(s *Script) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active != nil {
+		return 0, ErrBusy
+	}
+	r.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	ru := &run{
+		Run:    Run{ID: r.nextID, Script: s.Name, Status: StatusRunning, Started: time.Now()},
+		script: s,
+		cancel: cancel,
+		robots: make(map[string]string),
+		orders: make(map[int64]bool),
+	}
+	for _, st := range s.Steps {
+		if st.Robot != "" {
+			ru.robots[st.Robot] = ""
+		}
+	}
+	r.active = ru
+	log.Printf("scenario: run %d (%s) started, %d steps", ru.ID, s.Name, len(s.Steps))
+	go r.run(ctx, ru)
+	return ru.ID, nil
+}
+
+// Stop cancels the running scenario, if any.
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active != nil {
+		r.active.cancel()
+	}
+}
+
+func (r *Runner) run(ctx context.Context, ru *run) {
+	status := StatusDone
+	for _, st := range ru.script.Steps {
+		if !sleepUntil(ctx, ru.Started.Add(st.At)) {
+			status = StatusStopped
+			break
+		}
+		r.step(ru, st)
+	}
+	if status == StatusDone {
+		last := ru.script.Steps[len(ru.script.Steps)-1].At
+		if !sleepUntil(ctx, ru.Started.Add(last+ru.script.Settle)) {
+			status = StatusStopped
+		}
+	}
+	ru.cancel()
+
+	r.mu.Lock()
+	ru.Status = status
+	ru.Finished = time.Now()
+	r.runs = append(r.runs, ru.snapshot())
+	if len(r.runs) > maxRuns {
+		r.runs = r.runs[len(r.runs)-maxRuns:]
+	}
+	r.active = nil
+	r.mu.Unlock()
+	log.Printf("scenario: run %d (%s) %s", ru.ID, ru.Script, status)
+}
+
+// step performs one step and logs it.
+func (r *Runner) step(ru *run, st Step) {
+	e := Entry{Kind: EntryStep, Name: "step", Detail: st.String(), At: ru.since()}
+	var injected int64
+	if st.Order != nil {
+		o := st.Order
+		r.mu.Lock()
+		ru.injecting = true
+		r.mu.Unlock()
+		id, err := r.inject(&protocol.OrderRequest{
+			OrderUUID:       "scn-" + uuid.New().String()[:8],
+			OrderType:       o.Type,
+			PayloadTypeCode: o.PayloadType,
+			PickupNode:      o.Pickup,
+			DeliveryNode:    o.Delivery,
+			StagingNode:     o.Staging,
+			Quantity:        max(o.Quantity, 1),
+			Priority:        o.Priority,
+			PayloadDesc:     "scenario: " + ru.Script,
+		})
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			e.Detail += fmt.Sprintf(" (order %d)", id)
+			injected = id
+		}
+	} else if err := r.sim.SetSimState(st.Robot, st.fields()); err != nil {
+		e.Error = err.Error()
+	}
+	r.dbg("scenario: run %d: %s", ru.ID, e.Detail)
+	if e.Error != "" {
+		log.Printf("scenario: run %d: %s: %s", ru.ID, e.Detail, e.Error)
+	}
+	r.mu.Lock()
+	ru.append(e)
+	if st.Order != nil {
+		ru.injecting = false
+		if injected != 0 {
+			ru.orders[injected] = true
+		}
+		for _, h := range ru.held {
+			if h.orderID == injected {
+				ru.append(h.entry)
+			}
+		}
+		ru.held = nil
+	}
+	r.mu.Unlock()
+}
+
+// Record logs a ShinGo event about orderID in the running scenario, if the
+// scenario injected that order. Events about other orders, or about none
+// (orderID 0), are left out.
+func (r *Runner) Record(orderID int64, name, detail string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ru := r.active
+	if ru == nil || orderID == 0 {
+		return
+	}
+	e := Entry{Kind: EntryEvent, Name: name, Detail: detail, At: ru.since()}
+	switch {
+	case ru.orders[orderID]:
+		ru.append(e)
+	case ru.injecting:
+		ru.held = append(ru.held, heldEntry{orderID, e})
+	}
+}
+
+// Robots logs the state changes of the robots the running scenario scripts.
+func (r *Runner) Robots(robots []fleet.RobotStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ru := r.active
+	if ru == nil {
+		return
+	}
+	for _, rb := range robots {
+		prev, ok := ru.robots[rb.VehicleID]
+		if !ok {
+			continue
+		}
+		state := robotState(rb)
+		if state == prev {
+			continue
+		}
+		ru.robots[rb.VehicleID] = state
+		ru.add(Entry{Kind: EntryRobot, Name: rb.VehicleID, Detail: state})
+	}
+}
+
+func robotState(r fleet.RobotStatus) string {
+	s := fmt.Sprintf("%s battery=%.0f", r.State(), r.BatteryLevel)
+	if r.Emergency {
+		s += " emergency"
+	}
+	if r.Blocked {
+		s += " blocked"
+	}
+	if r.CurrentStation != "" {
+		s += " at " + r.CurrentStation
+	}
+	return s
+}
+
+// add appends to the log, stamped with the current offset. Callers hold r.mu.
+func (ru *run) add(e Entry) {
+	e.At = ru.since()
+	ru.append(e)
+}
+
+// append appends to the log as stamped. Callers hold r.mu.
+func (ru *run) append(e Entry) {
+	if len(ru.Log) >= maxEntries {
+		return
+	}
+	ru.Log = append(ru.Log, e)
+}
+
+// since is the offset from the start of the run.
+func (ru *run) since() time.Duration {
+	return time.Since(ru.Started).Truncate(time.Millisecond)
+}
+
+func (ru *run) snapshot() Run {
+	out := ru.Run
+	out.Log = append([]Entry(nil), ru.Log...)
+	return out
+}
+
+// Runs returns the running scenario, if any, and recent finished runs,
+// newest first.
+func (r *Runner) Runs() []Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Run, 0, len(r.runs)+1)
+	if r.active != nil {
+		out = append(out, r.active.snapshot())
+	}
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		out = append(out, r.runs[i])
+	}
+	return out
+}
+
+// sleepUntil waits for t and reports whether it got there before ctx ended.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
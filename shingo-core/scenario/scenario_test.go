@@ -0,0 +1,160 @@
+package scenario
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"shingo/protocol"
+	"shingocore/fleet"
+)
+
+type fakeSim struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeSim) SetSimState(vehicleID string, fields map[string]any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf("%s %v", vehicleID, fields))
+	if vehicleID == "GHOST" {
+		return fmt.Errorf("vehicle %s not found in any fleet", vehicleID)
+	}
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(`
+name: e-stop during delivery
+steps:
+  - at: 20s
+    robot: AMB-01
+    emergency: true
+  - at: 0s
+    order: {type: retrieve, payload_type: TOTE, delivery: LINE1-IN}
+  - at: 45s
+    robot: AMB-01
+    battery: 12
+    state: {charging: false}
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if s.Settle != defaultSettle {
+		t.Errorf("settle = %v, want default %v", s.Settle, defaultSettle)
+	}
+	if len(s.Steps) != 3 || s.Steps[0].Order == nil || s.Steps[1].At != 20*time.Second {
+		t.Fatalf("steps not sorted by offset: %+v", s.Steps)
+	}
+	if got, want := s.Steps[2].String(), "set AMB-01 battery_level=12 charging=false"; got != want {
+		t.Errorf("step = %q, want %q", got, want)
+	}
+
+	bad := map[string]string{
+		"no steps":     `name: x`,
+		"no action":    "steps:\n  - at: 1s\n    robot: AMB-01",
+		"no robot":     "steps:\n  - emergency: true",
+		"both":         "steps:\n  - robot: AMB-01\n    blocked: true\n    order: {type: move, delivery: A}",
+		"no delivery":  "steps:\n  - order: {type: move}",
+		"negative at":  "steps:\n  - at: -1s\n    robot: AMB-01\n    blocked: true",
+		"bad duration": "steps:\n  - at: soon\n    robot: AMB-01\n    blocked: true",
+	}
+	for name, src := range bad {
+		if _, err := Parse([]byte(src)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	sim := &fakeSim{}
+	var injected []*protocol.OrderRequest
+	var r *Runner
+	r = NewRunner(sim, func(req *protocol.OrderRequest) (int64, error) {
+		injected = append(injected, req)
+		// The dispatcher emits events before the order ID is returned
+		r.Record(7, "OrderReceived", "order 7")
+		r.Record(8, "OrderReceived", "someone else's order")
+		return 7, nil
+	})
+	yes := true
+	s := &Script{Name: "stuck robot", Settle: 30 * time.Millisecond, Steps: []Step{
+		{At: 10 * time.Millisecond, Order: &Order{Type: "retrieve", PayloadType: "TOTE", Delivery: "LINE1-IN"}},
+		{At: 20 * time.Millisecond, Robot: "AMB-01", Blocked: &yes},
+		{At: 20 * time.Millisecond, Robot: "GHOST", Emergency: &yes},
+	}}
+
+	id, err := r.Start(s)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := r.Start(s); err != ErrBusy {
+		t.Fatalf("second start = %v, want ErrBusy", err)
+	}
+
+	r.Robots([]fleet.RobotStatus{
+		{VehicleID: "AMB-01", Connected: true, Available: true, BatteryLevel: 80},
+		{VehicleID: "AMB-02", Connected: true}, // not scripted, not logged
+	})
+	r.Robots([]fleet.RobotStatus{{VehicleID: "AMB-01", Connected: true, Available: true, BatteryLevel: 80}}) // unchanged
+
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Runs()[0].Status == StatusRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("run did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	r.Record(7, "OrderCompleted", "after the run") // ignored
+
+	run := r.Runs()[0]
+	if run.ID != id || run.Status != StatusDone {
+		t.Fatalf("run = %d %s, want %d done", run.ID, run.Status, id)
+	}
+	if len(injected) != 1 || injected[0].OrderType != "retrieve" || injected[0].Quantity != 1 ||
+		!strings.HasPrefix(injected[0].OrderUUID, "scn-") {
+		t.Errorf("injected = %+v", injected)
+	}
+	if len(sim.calls) != 2 || sim.calls[0] != "AMB-01 map[blocked:true]" {
+		t.Errorf("sim calls = %v", sim.calls)
+	}
+
+	var kinds []string
+	for _, e := range run.Log {
+		kinds = append(kinds, e.Kind+":"+e.Name)
+	}
+	want := []string{"robot:AMB-01", "step:step", "event:OrderReceived", "step:step", "step:step"}
+	if strings.Join(kinds, " ") != strings.Join(want, " ") {
+		t.Fatalf("log = %v, want %v", kinds, want)
+	}
+	if !strings.HasSuffix(run.Log[1].Detail, "(order 7)") {
+		t.Errorf("order step = %q", run.Log[1].Detail)
+	}
+	if run.Log[2].Detail != "order 7" || run.Log[2].At < run.Log[1].At {
+		t.Errorf("order event = %+v after step at %s", run.Log[2], run.Log[1].At)
+	}
+	if run.Log[4].Error == "" {
+		t.Errorf("step on unknown robot logged no error")
+	}
+}
+
+func TestStop(t *testing.T) {
+	r := NewRunner(&fakeSim{}, nil)
+	yes := true
+	r.Start(&Script{Name: "long", Settle: time.Hour, Steps: []Step{{At: time.Hour, Robot: "AMB-01", Blocked: &yes}}})
+	r.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Runs()[0].Status == StatusRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("run did not stop")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if run := r.Runs()[0]; run.Status != StatusStopped || len(run.Log) != 0 {
+		t.Errorf("run = %s with %d entries, want stopped with none", run.Status, len(run.Log))
+	}
+}
@@ -1,6 +1,10 @@
 package store
 
-const schemaSQLite = `
+// baselineSQLite is the SQLite schema as it stood at migration 1, the
+// baseline. It is frozen: a database at version 1 must look the same no
+// matter which build created it, so schema changes go in a new migration
+// appended to migrations, never in this snapshot.
+const baselineSQLite = `
 CREATE TABLE IF NOT EXISTS nodes (
     id          INTEGER PRIMARY KEY AUTOINCREMENT,
     name        TEXT NOT NULL UNIQUE,
@@ -45,7 +49,18 @@ CREATE TABLE IF NOT EXISTS orders (
     updated_at      TEXT NOT NULL DEFAULT (datetime('now','localtime')),
     completed_at    TEXT,
     payload_type_id INTEGER REFERENCES payload_types(id),
-    payload_id      INTEGER REFERENCES payloads(id)
+    payload_id      INTEGER REFERENCES payloads(id),
+    max_wait_sec    INTEGER NOT NULL DEFAULT 0,
+    staging_node    TEXT NOT NULL DEFAULT '',
+    return_node     TEXT NOT NULL DEFAULT '',
+    load_type       TEXT NOT NULL DEFAULT '',
+    base_priority   INTEGER NOT NULL DEFAULT 0,
+    sla_breached_at TEXT,
+    create_retries  INTEGER NOT NULL DEFAULT 0,
+    vendor_retries  INTEGER NOT NULL DEFAULT 0,
+    fleet           TEXT NOT NULL DEFAULT '',
+    handoff_node    TEXT NOT NULL DEFAULT '',
+    held_zone       TEXT NOT NULL DEFAULT ''
 );
 CREATE INDEX IF NOT EXISTS idx_orders_uuid ON orders(edge_uuid);
 CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
@@ -89,6 +104,17 @@ CREATE TABLE IF NOT EXISTS outbox (
 );
 CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(sent_at) WHERE sent_at IS NULL;
 
+CREATE TABLE IF NOT EXISTS fleet_intents (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id        INTEGER NOT NULL REFERENCES orders(id),
+    vendor_order_id TEXT NOT NULL,
+    request         TEXT NOT NULL DEFAULT '',
+    state           TEXT NOT NULL DEFAULT 'pending',
+    created_at      TEXT NOT NULL DEFAULT (datetime('now','localtime')),
+    resolved_at     TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_fleet_intents_pending ON fleet_intents(order_id) WHERE state = 'pending';
+
 CREATE TABLE IF NOT EXISTS audit_log (
     id          INTEGER PRIMARY KEY AUTOINCREMENT,
     entity_type TEXT NOT NULL,
@@ -130,6 +156,7 @@ CREATE TABLE IF NOT EXISTS payload_types (
     description           TEXT NOT NULL DEFAULT '',
     form_factor           TEXT NOT NULL DEFAULT 'other',
     default_manifest_json TEXT NOT NULL DEFAULT '{}',
+    source_strategy       TEXT NOT NULL DEFAULT 'fifo',
     created_at            TEXT NOT NULL DEFAULT (datetime('now','localtime')),
     updated_at            TEXT NOT NULL DEFAULT (datetime('now','localtime'))
 );
@@ -202,6 +229,26 @@ CREATE TABLE IF NOT EXISTS demands (
     updated_at   TEXT NOT NULL DEFAULT (datetime('now','localtime'))
 );
 
+CREATE TABLE IF NOT EXISTS order_schedules (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    name              TEXT NOT NULL,
+    order_type        TEXT NOT NULL,
+    payload_type_code TEXT NOT NULL DEFAULT '',
+    pickup_node       TEXT NOT NULL DEFAULT '',
+    delivery_node     TEXT NOT NULL DEFAULT '',
+    quantity          REAL NOT NULL DEFAULT 1,
+    priority          INTEGER NOT NULL DEFAULT 0,
+    station_id        TEXT NOT NULL DEFAULT '',
+    run_at            TEXT,
+    cron              TEXT NOT NULL DEFAULT '',
+    enabled           INTEGER NOT NULL DEFAULT 1,
+    next_run_at       TEXT,
+    last_run_at       TEXT,
+    last_result       TEXT NOT NULL DEFAULT '',
+    created_at        TEXT NOT NULL DEFAULT (datetime('now','localtime')),
+    updated_at        TEXT NOT NULL DEFAULT (datetime('now','localtime'))
+);
+
 CREATE TABLE IF NOT EXISTS production_log (
     id          INTEGER PRIMARY KEY AUTOINCREMENT,
     cat_id      TEXT NOT NULL,
@@ -224,4 +271,50 @@ CREATE TABLE IF NOT EXISTS test_commands (
     updated_at      TEXT NOT NULL DEFAULT (datetime('now','localtime')),
     completed_at    TEXT
 );
+
+CREATE TABLE IF NOT EXISTS exclusive_zones (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    zone         TEXT NOT NULL UNIQUE,
+    mutex_groups TEXT NOT NULL DEFAULT '',
+    locked       INTEGER NOT NULL DEFAULT 0,
+    lock_reason  TEXT NOT NULL DEFAULT '',
+    locked_by    TEXT NOT NULL DEFAULT '',
+    locked_at    TEXT,
+    created_at   TEXT NOT NULL DEFAULT (datetime('now','localtime')),
+    updated_at   TEXT NOT NULL DEFAULT (datetime('now','localtime'))
+);
+
+CREATE TABLE IF NOT EXISTS robot_snapshots (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    vehicle_id  TEXT NOT NULL,
+    fleet       TEXT NOT NULL DEFAULT '',
+    state       TEXT NOT NULL,
+    battery     REAL NOT NULL DEFAULT 0,
+    charging    INTEGER NOT NULL DEFAULT 0,
+    x           REAL NOT NULL DEFAULT 0,
+    y           REAL NOT NULL DEFAULT 0,
+    station     TEXT NOT NULL DEFAULT '',
+    recorded_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_robot_snapshots_vehicle ON robot_snapshots(vehicle_id, recorded_at);
+
+CREATE TABLE IF NOT EXISTS robot_state_intervals (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    vehicle_id TEXT NOT NULL,
+    state      TEXT NOT NULL,
+    started_at TEXT NOT NULL,
+    ended_at   TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_robot_intervals_vehicle ON robot_state_intervals(vehicle_id, started_at);
+
+CREATE TABLE IF NOT EXISTS travel_times (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind        TEXT NOT NULL,
+    from_key    TEXT NOT NULL,
+    to_key      TEXT NOT NULL,
+    samples     INTEGER NOT NULL DEFAULT 0,
+    avg_seconds REAL NOT NULL DEFAULT 0,
+    updated_at  TEXT NOT NULL DEFAULT (datetime('now','localtime')),
+    UNIQUE(kind, from_key, to_key)
+);
 `
@@ -0,0 +1,53 @@
+package store
+
+import "time"
+
+// ChargingRobot is a robot the charging manager has taken out of dispatch.
+// It is kept until the robot is returned to service, so a restart does not
+// leave it out of dispatch for good.
+type ChargingRobot struct {
+	VehicleID string    `json:"vehicle_id"`
+	Reason    string    `json:"reason"`
+	Station   string    `json:"station"`  // charging node, "" when charging in place
+	OrderID   string    `json:"order_id"` // vendor order sending it to the station
+	Since     time.Time `json:"since"`
+}
+
+// SaveChargingRobot records a robot taken out of dispatch to charge,
+// replacing any earlier record for it.
+func (db *DB) SaveChargingRobot(r *ChargingRobot) error {
+	_, err := db.Exec(db.Q(`
+		INSERT INTO charging_robots (vehicle_id, reason, station, order_id, since)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(vehicle_id) DO UPDATE SET
+			reason = excluded.reason,
+			station = excluded.station,
+			order_id = excluded.order_id,
+			since = excluded.since
+	`), r.VehicleID, r.Reason, r.Station, r.OrderID, db.timeArg(&r.Since))
+	return err
+}
+
+func (db *DB) DeleteChargingRobot(vehicleID string) error {
+	_, err := db.Exec(db.Q(`DELETE FROM charging_robots WHERE vehicle_id=?`), vehicleID)
+	return err
+}
+
+func (db *DB) ListChargingRobots() ([]*ChargingRobot, error) {
+	rows, err := db.Query(`SELECT vehicle_id, reason, station, order_id, since FROM charging_robots ORDER BY vehicle_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*ChargingRobot
+	for rows.Next() {
+		var r ChargingRobot
+		var since any
+		if err := rows.Scan(&r.VehicleID, &r.Reason, &r.Station, &r.OrderID, &since); err != nil {
+			return nil, err
+		}
+		r.Since = parseLocalTime(since)
+		out = append(out, &r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,70 @@
+package store
+
+import "time"
+
+// DeviceReservation is a door or lift on an order's leg. The robot starts
+// the leg on the Near side of the device's route and ends it on the Far
+// side; the device is opened when the robot reaches Near and released once
+// it reaches Far.
+type DeviceReservation struct {
+	Device    string    `json:"device"`
+	OrderID   int64     `json:"order_id"`
+	Near      string    `json:"near"`
+	Far       string    `json:"far"`
+	Area      string    `json:"area"` // lifts: the area to call the lift to
+	Open      bool      `json:"open"` // opened for the robot's pass
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveDeviceReservation records that an order's leg crosses a device, or
+// updates the reservation if it already exists.
+func (db *DB) SaveDeviceReservation(r *DeviceReservation) error {
+	_, err := db.Exec(db.Q(`
+		INSERT INTO device_reservations (device, order_id, near_side, far_side, area, opened)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(device, order_id) DO UPDATE SET
+			near_side = excluded.near_side,
+			far_side = excluded.far_side,
+			area = excluded.area,
+			opened = excluded.opened
+	`), r.Device, r.OrderID, r.Near, r.Far, r.Area, r.Open)
+	return err
+}
+
+// SetDeviceReservationOpen marks a device opened for an order's robot.
+func (db *DB) SetDeviceReservationOpen(device string, orderID int64, open bool) error {
+	_, err := db.Exec(db.Q(`UPDATE device_reservations SET opened=? WHERE device=? AND order_id=?`), open, device, orderID)
+	return err
+}
+
+// DeleteDeviceReservation drops one device from an order, once its robot is
+// past it.
+func (db *DB) DeleteDeviceReservation(device string, orderID int64) error {
+	_, err := db.Exec(db.Q(`DELETE FROM device_reservations WHERE device=? AND order_id=?`), device, orderID)
+	return err
+}
+
+// DeleteDeviceReservations drops every device reserved for an order.
+func (db *DB) DeleteDeviceReservations(orderID int64) error {
+	_, err := db.Exec(db.Q(`DELETE FROM device_reservations WHERE order_id=?`), orderID)
+	return err
+}
+
+func (db *DB) ListDeviceReservations() ([]*DeviceReservation, error) {
+	rows, err := db.Query(`SELECT device, order_id, near_side, far_side, area, opened, created_at FROM device_reservations ORDER BY order_id, device`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*DeviceReservation
+	for rows.Next() {
+		var r DeviceReservation
+		var createdAt any
+		if err := rows.Scan(&r.Device, &r.OrderID, &r.Near, &r.Far, &r.Area, &r.Open, &createdAt); err != nil {
+			return nil, err
+		}
+		r.CreatedAt = parseTime(createdAt)
+		out = append(out, &r)
+	}
+	return out, rows.Err()
+}
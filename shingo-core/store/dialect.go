@@ -67,6 +67,40 @@ func parseTime(v any) time.Time {
 	return time.Time{}
 }
 
+// parseLocalTime is like parseTime but reads a zoneless SQLite value as
+// local wall-clock time. Use it for columns that are compared with the
+// current time: those written with datetime('now','localtime') or timeArg.
+func parseLocalTime(v any) time.Time {
+	if t, ok := v.(string); ok {
+		if parsed, err := time.ParseInLocation("2006-01-02 15:04:05", t, time.Local); err == nil {
+			return parsed
+		}
+	}
+	return parseTime(v)
+}
+
+// parseLocalTimePtr is like parseLocalTime but returns nil for zero/missing
+// timestamps.
+func parseLocalTimePtr(v any) *time.Time {
+	t := parseLocalTime(v)
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// timeArg converts a timestamp for binding. SQLite columns hold zoneless
+// local wall-clock text; Postgres takes the time as is. Nil binds NULL.
+func (db *DB) timeArg(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	if db.driver == "postgres" {
+		return *t
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
 // parseTimePtr is like parseTime but returns nil for zero/missing timestamps.
 func parseTimePtr(v any) *time.Time {
 	t := parseTime(v)
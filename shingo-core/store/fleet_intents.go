@@ -0,0 +1,94 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Fleet intent states.
+const (
+	IntentPending   = "pending"
+	IntentCommitted = "committed"
+	IntentAbandoned = "abandoned"
+)
+
+// FleetIntent records a vendor order about to be created. It is written before
+// the fleet call and resolved after it, so a pending intent found at startup
+// marks a dispatch that was interrupted with the fleet's answer unknown.
+type FleetIntent struct {
+	ID            int64      `json:"id"`
+	OrderID       int64      `json:"order_id"`
+	VendorOrderID string     `json:"vendor_order_id"`
+	Request       string     `json:"request"`
+	State         string     `json:"state"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+}
+
+const fleetIntentSelectCols = `id, order_id, vendor_order_id, request, state, created_at, resolved_at`
+
+func scanFleetIntent(row interface{ Scan(...any) error }) (*FleetIntent, error) {
+	var fi FleetIntent
+	var createdAt, resolvedAt any
+	if err := row.Scan(&fi.ID, &fi.OrderID, &fi.VendorOrderID, &fi.Request, &fi.State, &createdAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	fi.CreatedAt = parseTime(createdAt)
+	fi.ResolvedAt = parseTimePtr(resolvedAt)
+	return &fi, nil
+}
+
+// CreateFleetIntent records a pending vendor order for an order.
+func (db *DB) CreateFleetIntent(orderID int64, vendorOrderID, request string) (*FleetIntent, error) {
+	result, err := db.Exec(db.Q(`INSERT INTO fleet_intents (order_id, vendor_order_id, request) VALUES (?, ?, ?)`),
+		orderID, vendorOrderID, request)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &FleetIntent{ID: id, OrderID: orderID, VendorOrderID: vendorOrderID, Request: request, State: IntentPending}, nil
+}
+
+// CommitFleetIntent resolves an intent whose vendor order exists and records
+// the vendor order on the order, with its new status, in one transaction.
+func (db *DB) CommitFleetIntent(fi *FleetIntent, status, detail string) error {
+	return db.WithTx(func(tx *Tx) error {
+		if _, err := tx.Exec(tx.Q(`UPDATE fleet_intents SET state=?, resolved_at=datetime('now','localtime') WHERE id=?`),
+			IntentCommitted, fi.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(tx.Q(`UPDATE orders SET vendor_order_id=?, vendor_state='CREATED', robot_id='', updated_at=datetime('now','localtime') WHERE id=?`),
+			fi.VendorOrderID, fi.OrderID); err != nil {
+			return err
+		}
+		return updateOrderStatus(tx, fi.OrderID, status, detail)
+	})
+}
+
+// AbandonFleetIntent resolves an intent whose vendor order was never created.
+func (db *DB) AbandonFleetIntent(id int64) error {
+	_, err := db.Exec(db.Q(`UPDATE fleet_intents SET state=?, resolved_at=datetime('now','localtime') WHERE id=?`),
+		IntentAbandoned, id)
+	return err
+}
+
+// ListPendingFleetIntents returns unresolved intents, oldest first.
+func (db *DB) ListPendingFleetIntents() ([]*FleetIntent, error) {
+	rows, err := db.Query(db.Q(fmt.Sprintf(`SELECT %s FROM fleet_intents WHERE state=? ORDER BY id`, fleetIntentSelectCols)), IntentPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var intents []*FleetIntent
+	for rows.Next() {
+		fi, err := scanFleetIntent(rows)
+		if err != nil {
+			return nil, err
+		}
+		intents = append(intents, fi)
+	}
+	return intents, rows.Err()
+}
@@ -0,0 +1,40 @@
+package store
+
+// Orders held by a fleet backend that is itself the order master, such as
+// VDA 5050, where no fleet manager remembers queued orders or which vehicle
+// runs which. The backend saves each order as it changes, in its own
+// encoding, and loads them back on startup.
+
+// SaveFleetOrder records a fleet's order, replacing any earlier copy. An
+// order keeps its place in the fleet's list when it is saved again.
+func (db *DB) SaveFleetOrder(fleetName, vendorOrderID string, data []byte) error {
+	_, err := db.Exec(db.Q(`
+		INSERT INTO fleet_orders (fleet, vendor_order_id, data)
+		VALUES (?, ?, ?)
+		ON CONFLICT(fleet, vendor_order_id) DO UPDATE SET data = excluded.data
+	`), fleetName, vendorOrderID, string(data))
+	return err
+}
+
+func (db *DB) DeleteFleetOrder(fleetName, vendorOrderID string) error {
+	_, err := db.Exec(db.Q(`DELETE FROM fleet_orders WHERE fleet=? AND vendor_order_id=?`), fleetName, vendorOrderID)
+	return err
+}
+
+// ListFleetOrders returns a fleet's saved orders, oldest first.
+func (db *DB) ListFleetOrders(fleetName string) ([][]byte, error) {
+	rows, err := db.Query(db.Q(`SELECT data FROM fleet_orders WHERE fleet=? ORDER BY id`), fleetName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][]byte
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		out = append(out, []byte(data))
+	}
+	return out, rows.Err()
+}
@@ -101,3 +101,14 @@ func (db *DB) DeleteManifestItemsByPayload(payloadID int64) error {
 	_, err := db.Exec(db.Q(`DELETE FROM manifest_items WHERE payload_id=?`), payloadID)
 	return err
 }
+
+// EarliestProductionDate returns the oldest production_date across a payload's manifest,
+// or "" if no manifest item carries one.
+func (db *DB) EarliestProductionDate(payloadID int64) (string, error) {
+	var d sql.NullString
+	err := db.QueryRow(db.Q(`SELECT MIN(production_date) FROM manifest_items WHERE payload_id=? AND production_date IS NOT NULL AND production_date <> ''`), payloadID).Scan(&d)
+	if err != nil {
+		return "", err
+	}
+	return d.String, nil
+}
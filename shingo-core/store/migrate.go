@@ -0,0 +1,304 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is one numbered schema change. Each migration runs in its own
+// transaction together with its schema_migrations row, so a failure leaves
+// the database at the previous version instead of half upgraded.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *Tx) error
+	down    func(tx *Tx) error // nil if the migration cannot be reverted
+}
+
+// migrations lists every schema change in version order. Append new
+// migrations at the end, usually built with sqlStep; never renumber or edit
+// one that has shipped.
+var migrations = []migration{
+	{version: 1, name: "baseline", up: migrateBaseline},
+	{
+		version: 2, name: "orders_next_retry_at",
+		up: sqlStep(
+			`ALTER TABLE orders ADD COLUMN next_retry_at TEXT`,
+			`ALTER TABLE orders ADD COLUMN next_retry_at TIMESTAMPTZ`),
+		down: sqlStep(
+			`ALTER TABLE orders DROP COLUMN next_retry_at`,
+			`ALTER TABLE orders DROP COLUMN next_retry_at`),
+	},
+	{
+		version: 3, name: "device_reservations",
+		up: sqlStep(`CREATE TABLE device_reservations (
+    device     TEXT NOT NULL,
+    order_id   INTEGER NOT NULL,
+    near_side  TEXT NOT NULL DEFAULT '',
+    far_side   TEXT NOT NULL DEFAULT '',
+    area       TEXT NOT NULL DEFAULT '',
+    opened     INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT (datetime('now','localtime')),
+    PRIMARY KEY (device, order_id)
+)`, `CREATE TABLE device_reservations (
+    device     TEXT NOT NULL,
+    order_id   BIGINT NOT NULL,
+    near_side  TEXT NOT NULL DEFAULT '',
+    far_side   TEXT NOT NULL DEFAULT '',
+    area       TEXT NOT NULL DEFAULT '',
+    opened     BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device, order_id)
+)`),
+		down: sqlStep(`DROP TABLE device_reservations`, `DROP TABLE device_reservations`),
+	},
+	{
+		version: 4, name: "fleet_orders",
+		up: sqlStep(`CREATE TABLE fleet_orders (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    fleet           TEXT NOT NULL,
+    vendor_order_id TEXT NOT NULL,
+    data            TEXT NOT NULL,
+    UNIQUE (fleet, vendor_order_id)
+)`, `CREATE TABLE fleet_orders (
+    id              BIGSERIAL PRIMARY KEY,
+    fleet           TEXT NOT NULL,
+    vendor_order_id TEXT NOT NULL,
+    data            TEXT NOT NULL,
+    UNIQUE (fleet, vendor_order_id)
+)`),
+		down: sqlStep(`DROP TABLE fleet_orders`, `DROP TABLE fleet_orders`),
+	},
+	{
+		version: 5, name: "charging_robots",
+		up: sqlStep(`CREATE TABLE charging_robots (
+    vehicle_id TEXT PRIMARY KEY,
+    reason     TEXT NOT NULL DEFAULT '',
+    station    TEXT NOT NULL DEFAULT '',
+    order_id   TEXT NOT NULL DEFAULT '',
+    since      TEXT NOT NULL DEFAULT (datetime('now','localtime'))
+)`, `CREATE TABLE charging_robots (
+    vehicle_id TEXT PRIMARY KEY,
+    reason     TEXT NOT NULL DEFAULT '',
+    station    TEXT NOT NULL DEFAULT '',
+    order_id   TEXT NOT NULL DEFAULT '',
+    since      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`),
+		down: sqlStep(`DROP TABLE charging_robots`, `DROP TABLE charging_robots`),
+	},
+}
+
+// sqlStep returns a migration step that runs the given statements for the
+// open dialect.
+func sqlStep(sqlite, postgres string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		query := sqlite
+		if tx.db.driver == "postgres" {
+			query = postgres
+		}
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+const migrationsTableSQLite = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    name       TEXT NOT NULL,
+    applied_at TEXT NOT NULL DEFAULT (datetime('now','localtime'))
+)`
+
+const migrationsTablePostgres = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    name       TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`
+
+// MigrationStatus is one migration and when it was applied. AppliedAt is
+// nil for a pending migration.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrations reports the known migrations followed by any applied versions
+// this build does not know about.
+func (db *DB) Migrations() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]MigrationStatus, len(applied))
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+	var out []MigrationStatus
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.version, Name: m.name}
+		if a, ok := byVersion[m.version]; ok {
+			st.AppliedAt = a.AppliedAt
+			delete(byVersion, m.version)
+		}
+		out = append(out, st)
+	}
+	for _, a := range applied {
+		if _, ok := byVersion[a.Version]; ok {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// Migrate applies every pending migration and returns how many it applied.
+// It refuses to touch a database whose applied versions do not match this
+// build, e.g. one already upgraded by a newer release.
+func (db *DB) Migrate() (int, error) {
+	return db.migrateUp(migrations)
+}
+
+// MigrateDown reverts the most recently applied migration and returns it.
+func (db *DB) MigrateDown() (*MigrationStatus, error) {
+	return db.migrateDown(migrations)
+}
+
+func (db *DB) migrateUp(list []migration) (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkApplied(applied, list); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, m := range list[len(applied):] {
+		err := db.WithTx(func(tx *Tx) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(tx.Q(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`), m.version, m.name)
+			return err
+		})
+		if err != nil {
+			return n, fmt.Errorf("migration %d (%s) failed, schema left at version %d: %w",
+				m.version, m.name, schemaVersion(list, len(applied)+n), err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (db *DB) migrateDown(list []migration) (*MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApplied(applied, list); err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		return nil, fmt.Errorf("no migrations applied")
+	}
+	m := list[len(applied)-1]
+	if m.down == nil {
+		return nil, fmt.Errorf("migration %d (%s) cannot be reverted", m.version, m.name)
+	}
+	err = db.WithTx(func(tx *Tx) error {
+		if err := m.down(tx); err != nil {
+			return err
+		}
+		_, err := tx.Exec(tx.Q(`DELETE FROM schema_migrations WHERE version=?`), m.version)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("revert migration %d (%s): %w", m.version, m.name, err)
+	}
+	st := applied[len(applied)-1]
+	return &st, nil
+}
+
+// checkApplied verifies the applied migrations are exactly the first ones
+// in list. Anything else means the schema is in a state this build cannot
+// reason about, so it stops rather than guess.
+func checkApplied(applied []MigrationStatus, list []migration) error {
+	for i, a := range applied {
+		if i < len(list) && list[i].version == a.Version {
+			continue
+		}
+		latest := schemaVersion(list, len(list))
+		if a.Version > latest {
+			return fmt.Errorf("database schema is at version %d but this build only knows up to %d; run a newer release or migrate down with one",
+				applied[len(applied)-1].Version, latest)
+		}
+		return fmt.Errorf("database has migration %d (%s) applied out of sequence; the schema needs manual repair",
+			a.Version, a.Name)
+	}
+	return nil
+}
+
+// schemaVersion is the version reached after applying the first n migrations.
+func schemaVersion(list []migration, n int) int {
+	if n == 0 {
+		return 0
+	}
+	return list[n-1].version
+}
+
+// appliedMigrations creates schema_migrations if needed and returns its
+// rows in version order.
+func (db *DB) appliedMigrations() ([]MigrationStatus, error) {
+	ddl := migrationsTableSQLite
+	if db.driver == "postgres" {
+		ddl = migrationsTablePostgres
+	}
+	if _, err := db.Exec(ddl); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	rows, err := db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	var out []MigrationStatus
+	for rows.Next() {
+		var st MigrationStatus
+		var appliedAt any
+		if err := rows.Scan(&st.Version, &st.Name, &appliedAt); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		t := parseTime(appliedAt)
+		st.AppliedAt = &t
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+
+// columnExists checks if a column exists in a table. A missing table has
+// no columns.
+func (tx *Tx) columnExists(table, column string) (bool, error) {
+	if tx.db.driver == "postgres" {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema=current_schema() AND table_name=$1 AND column_name=$2)`, table, column).Scan(&exists)
+		if err != nil {
+			return false, fmt.Errorf("check %s.%s: %w", table, column, err)
+		}
+		return exists, nil
+	}
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("check %s.%s: %w", table, column, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notnull, &dflt, &pk); err != nil {
+			return false, fmt.Errorf("check %s.%s: %w", table, column, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -0,0 +1,95 @@
+package store
+
+import "fmt"
+
+// migrateBaseline brings a database to the schema as it stood when
+// versioned migrations were introduced. A fresh database gets the frozen
+// snapshot; one created by an older build gets the renames and column adds
+// those builds used to apply on every start. Like the snapshot, this step
+// never changes.
+func migrateBaseline(tx *Tx) error {
+	if err := baselineRenames(tx); err != nil {
+		return err
+	}
+	schema := baselineSQLite
+	if tx.db.driver == "postgres" {
+		schema = baselinePostgres
+	}
+	if _, err := tx.Exec(schema); err != nil {
+		return fmt.Errorf("create schema: %w", err)
+	}
+	if err := baselineColumns(tx); err != nil {
+		return err
+	}
+	// Migrate completed -> confirmed status
+	if _, err := tx.Exec(`UPDATE orders SET status='confirmed' WHERE status='completed'`); err != nil {
+		return fmt.Errorf("confirm completed orders: %w", err)
+	}
+	return nil
+}
+
+// baselineRenames renames old RDS-specific columns to vendor-neutral names.
+// SQLite 3.25+ and PostgreSQL both support ALTER TABLE RENAME COLUMN.
+func baselineRenames(tx *Tx) error {
+	renames := []struct{ table, oldCol, newCol string }{
+		{"nodes", "rds_location", "vendor_location"},
+		{"orders", "rds_order_id", "vendor_order_id"},
+		{"orders", "rds_state", "vendor_state"},
+		{"orders", "client_id", "station_id"},
+		{"outbox", "event_type", "msg_type"},
+		{"outbox", "client_id", "station_id"},
+	}
+	for _, r := range renames {
+		exists, err := tx.columnExists(r.table, r.oldCol)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s TO %s`, r.table, r.oldCol, r.newCol)); err != nil {
+			return fmt.Errorf("rename %s.%s: %w", r.table, r.oldCol, err)
+		}
+	}
+	// Rename index (drop old, new one created by schema)
+	if tx.db.driver == "postgres" {
+		if _, err := tx.Exec(`DROP INDEX IF EXISTS idx_orders_rds`); err != nil {
+			return fmt.Errorf("drop idx_orders_rds: %w", err)
+		}
+	}
+	return nil
+}
+
+// baselineColumns adds the columns older builds added after a table was
+// first created. CREATE TABLE IF NOT EXISTS leaves existing tables
+// untouched, so databases from those builds pick the columns up here. New
+// columns are migrations of their own, not entries in this list.
+func baselineColumns(tx *Tx) error {
+	adds := []struct{ table, column, ddl string }{
+		{"payload_types", "source_strategy", "TEXT NOT NULL DEFAULT 'fifo'"},
+		{"orders", "max_wait_sec", "INTEGER NOT NULL DEFAULT 0"},
+		{"orders", "staging_node", "TEXT NOT NULL DEFAULT ''"},
+		{"orders", "return_node", "TEXT NOT NULL DEFAULT ''"},
+		{"orders", "load_type", "TEXT NOT NULL DEFAULT ''"},
+		{"orders", "base_priority", "INTEGER NOT NULL DEFAULT 0"},
+		{"orders", "sla_breached_at", tx.db.dialect.TimestampType()},
+		{"orders", "create_retries", "INTEGER NOT NULL DEFAULT 0"},
+		{"orders", "vendor_retries", "INTEGER NOT NULL DEFAULT 0"},
+		{"orders", "fleet", "TEXT NOT NULL DEFAULT ''"},
+		{"orders", "handoff_node", "TEXT NOT NULL DEFAULT ''"},
+		{"orders", "held_zone", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, a := range adds {
+		exists, err := tx.columnExists(a.table, a.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, a.table, a.column, a.ddl)); err != nil {
+			return fmt.Errorf("add %s.%s: %w", a.table, a.column, err)
+		}
+	}
+	return nil
+}
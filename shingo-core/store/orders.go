@@ -2,10 +2,16 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
+// ErrOrderEnded is returned by updates that only apply to an order still in
+// flight, when the order was confirmed, failed or cancelled first.
+var ErrOrderEnded = errors.New("order already ended")
+
 type Order struct {
 	ID            int64      `json:"id"`
 	EdgeUUID      string     `json:"edge_uuid"`
@@ -28,6 +34,18 @@ type Order struct {
 	CompletedAt   *time.Time `json:"completed_at,omitempty"`
 	PayloadTypeID *int64     `json:"payload_type_id,omitempty"`
 	PayloadID     *int64     `json:"payload_id,omitempty"`
+	MaxWaitSec    int        `json:"max_wait_sec"`
+	StagingNode   string     `json:"staging_node"`
+	ReturnNode    string     `json:"return_node"` // swap orders: where the empty taken off the line goes
+	LoadType      string     `json:"load_type"`
+	BasePriority  int        `json:"base_priority"` // priority as requested, before escalation
+	SLABreachedAt *time.Time `json:"sla_breached_at,omitempty"`
+	CreateRetries int        `json:"create_retries"`          // retries after fleet create errors
+	VendorRetries int        `json:"vendor_retries"`          // retries after the fleet failed the order
+	Fleet         string     `json:"fleet"`                   // fleet running the current vendor order, when several are configured
+	HandoffNode   string     `json:"handoff_node"`            // where the payload passes between fleets on a cross-fleet order
+	HeldZone      string     `json:"held_zone"`               // locked exclusive zone the order is held for
+	NextRetryAt   *time.Time `json:"next_retry_at,omitempty"` // when a retrying order is dispatched again
 }
 
 type OrderHistory struct {
@@ -38,19 +56,21 @@ type OrderHistory struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-const orderSelectCols = `id, edge_uuid, station_id, order_type, status, material_id, material_code, quantity, pickup_node, delivery_node, vendor_order_id, vendor_state, robot_id, priority, payload_desc, error_detail, created_at, updated_at, completed_at, payload_type_id, payload_id`
+const orderSelectCols = `id, edge_uuid, station_id, order_type, status, material_id, material_code, quantity, pickup_node, delivery_node, vendor_order_id, vendor_state, robot_id, priority, payload_desc, error_detail, created_at, updated_at, completed_at, payload_type_id, payload_id, max_wait_sec, staging_node, return_node, load_type, base_priority, sla_breached_at, create_retries, vendor_retries, fleet, handoff_node, held_zone, next_retry_at`
 
 func scanOrder(row interface{ Scan(...any) error }) (*Order, error) {
 	var o Order
 	var materialID, payloadTypeID, payloadID sql.NullInt64
 	var createdAt, updatedAt any
-	var completedAt any
+	var completedAt, slaBreachedAt, nextRetryAt any
 
 	err := row.Scan(&o.ID, &o.EdgeUUID, &o.StationID, &o.OrderType, &o.Status,
 		&materialID, &o.MaterialCode, &o.Quantity,
 		&o.PickupNode, &o.DeliveryNode, &o.VendorOrderID, &o.VendorState, &o.RobotID,
 		&o.Priority, &o.PayloadDesc, &o.ErrorDetail, &createdAt, &updatedAt, &completedAt,
-		&payloadTypeID, &payloadID)
+		&payloadTypeID, &payloadID, &o.MaxWaitSec, &o.StagingNode, &o.ReturnNode,
+		&o.LoadType, &o.BasePriority, &slaBreachedAt, &o.CreateRetries, &o.VendorRetries,
+		&o.Fleet, &o.HandoffNode, &o.HeldZone, &nextRetryAt)
 	if err != nil {
 		return nil, err
 	}
@@ -63,9 +83,11 @@ func scanOrder(row interface{ Scan(...any) error }) (*Order, error) {
 	if payloadID.Valid {
 		o.PayloadID = &payloadID.Int64
 	}
-	o.CreatedAt = parseTime(createdAt)
-	o.UpdatedAt = parseTime(updatedAt)
-	o.CompletedAt = parseTimePtr(completedAt)
+	o.CreatedAt = parseLocalTime(createdAt)
+	o.UpdatedAt = parseLocalTime(updatedAt)
+	o.CompletedAt = parseLocalTimePtr(completedAt)
+	o.SLABreachedAt = parseLocalTimePtr(slaBreachedAt)
+	o.NextRetryAt = parseLocalTimePtr(nextRetryAt)
 	return &o, nil
 }
 
@@ -82,6 +104,22 @@ func scanOrders(rows *sql.Rows) ([]*Order, error) {
 }
 
 func (db *DB) CreateOrder(o *Order) error {
+	return db.WithTx(func(tx *Tx) error {
+		return insertOrder(tx, o)
+	})
+}
+
+// ReceiveOrder creates an order together with its first history entry.
+func (db *DB) ReceiveOrder(o *Order, detail string) error {
+	return db.WithTx(func(tx *Tx) error {
+		if err := insertOrder(tx, o); err != nil {
+			return err
+		}
+		return insertOrderHistory(tx, o.ID, o.Status, detail)
+	})
+}
+
+func insertOrder(tx execer, o *Order) error {
 	var matID, ptID, pID any
 	if o.MaterialID != nil {
 		matID = *o.MaterialID
@@ -93,10 +131,11 @@ func (db *DB) CreateOrder(o *Order) error {
 		pID = *o.PayloadID
 	}
 
-	result, err := db.Exec(db.Q(`INSERT INTO orders (edge_uuid, station_id, order_type, status, material_id, material_code, quantity, pickup_node, delivery_node, priority, payload_desc, payload_type_id, payload_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+	result, err := tx.Exec(tx.Q(`INSERT INTO orders (edge_uuid, station_id, order_type, status, material_id, material_code, quantity, pickup_node, delivery_node, priority, payload_desc, payload_type_id, payload_id, max_wait_sec, staging_node, load_type, base_priority) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
 		o.EdgeUUID, o.StationID, o.OrderType, o.Status,
 		matID, o.MaterialCode, o.Quantity,
-		o.PickupNode, o.DeliveryNode, o.Priority, o.PayloadDesc, ptID, pID)
+		o.PickupNode, o.DeliveryNode, o.Priority, o.PayloadDesc, ptID, pID, o.MaxWaitSec, o.StagingNode,
+		o.LoadType, o.Priority)
 	if err != nil {
 		return fmt.Errorf("create order: %w", err)
 	}
@@ -105,18 +144,41 @@ func (db *DB) CreateOrder(o *Order) error {
 		return fmt.Errorf("create order last id: %w", err)
 	}
 	o.ID = id
+	o.BasePriority = o.Priority
 	return nil
 }
 
-func (db *DB) UpdateOrderStatus(id int64, status, detail string) error {
-	_, err := db.Exec(db.Q(`UPDATE orders SET status=?, error_detail=?, updated_at=datetime('now','localtime') WHERE id=?`),
+func insertOrderHistory(tx execer, orderID int64, status, detail string) error {
+	_, err := tx.Exec(tx.Q(`INSERT INTO order_history (order_id, status, detail) VALUES (?, ?, ?)`),
+		orderID, status, detail)
+	return err
+}
+
+func updateOrderStatus(tx execer, id int64, status, detail string) error {
+	_, err := tx.Exec(tx.Q(`UPDATE orders SET status=?, error_detail=?, updated_at=datetime('now','localtime') WHERE id=?`),
 		status, detail, id)
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec(db.Q(`INSERT INTO order_history (order_id, status, detail) VALUES (?, ?, ?)`),
-		id, status, detail)
-	return err
+	return insertOrderHistory(tx, id, status, detail)
+}
+
+func (db *DB) UpdateOrderStatus(id int64, status, detail string) error {
+	return db.WithTx(func(tx *Tx) error {
+		return updateOrderStatus(tx, id, status, detail)
+	})
+}
+
+// CloseOrder moves an order to a terminal status and releases every payload
+// it has claimed in one transaction.
+func (db *DB) CloseOrder(id int64, status, detail string) error {
+	return db.WithTx(func(tx *Tx) error {
+		if err := updateOrderStatus(tx, id, status, detail); err != nil {
+			return err
+		}
+		_, err := unclaimOrderPayloads(tx, id)
+		return err
+	})
 }
 
 func (db *DB) UpdateOrderVendor(id int64, vendorOrderID, vendorState, robotID string) error {
@@ -131,21 +193,51 @@ func (db *DB) UpdateOrderPickupNode(id int64, pickupNode string) error {
 	return err
 }
 
+// UpdateOrderFleet records which fleet runs the order's current vendor order.
+func (db *DB) UpdateOrderFleet(id int64, fleet string) error {
+	_, err := db.Exec(db.Q(`UPDATE orders SET fleet=?, updated_at=datetime('now','localtime') WHERE id=?`),
+		fleet, id)
+	return err
+}
+
+// UpdateOrderHandoffNode records the node where a cross-fleet order changes
+// fleet.
+func (db *DB) UpdateOrderHandoffNode(id int64, node string) error {
+	_, err := db.Exec(db.Q(`UPDATE orders SET handoff_node=?, updated_at=datetime('now','localtime') WHERE id=?`),
+		node, id)
+	return err
+}
+
+// UpdateOrderHeldZone records the locked zone an order is held for, or clears
+// it with "".
+func (db *DB) UpdateOrderHeldZone(id int64, zone string) error {
+	_, err := db.Exec(db.Q(`UPDATE orders SET held_zone=?, updated_at=datetime('now','localtime') WHERE id=?`),
+		zone, id)
+	return err
+}
+
 func (db *DB) UpdateOrderDeliveryNode(id int64, deliveryNode string) error {
 	_, err := db.Exec(db.Q(`UPDATE orders SET delivery_node=?, updated_at=datetime('now','localtime') WHERE id=?`),
 		deliveryNode, id)
 	return err
 }
 
-func (db *DB) CompleteOrder(id int64) error {
-	_, err := db.Exec(db.Q(`UPDATE orders SET status='confirmed', completed_at=datetime('now','localtime'), updated_at=datetime('now','localtime') WHERE id=?`), id)
-	if err != nil {
-		return err
-	}
-	_, err = db.Exec(db.Q(`INSERT INTO order_history (order_id, status, detail) VALUES (?, 'confirmed', 'order confirmed')`), id)
+func (db *DB) UpdateOrderReturnNode(id int64, returnNode string) error {
+	_, err := db.Exec(db.Q(`UPDATE orders SET return_node=?, updated_at=datetime('now','localtime') WHERE id=?`),
+		returnNode, id)
 	return err
 }
 
+func (db *DB) CompleteOrder(id int64) error {
+	return db.WithTx(func(tx *Tx) error {
+		_, err := tx.Exec(tx.Q(`UPDATE orders SET status='confirmed', completed_at=datetime('now','localtime'), updated_at=datetime('now','localtime') WHERE id=?`), id)
+		if err != nil {
+			return err
+		}
+		return insertOrderHistory(tx, id, "confirmed", "order confirmed")
+	})
+}
+
 func (db *DB) GetOrder(id int64) (*Order, error) {
 	row := db.QueryRow(db.Q(fmt.Sprintf(`SELECT %s FROM orders WHERE id=?`, orderSelectCols)), id)
 	return scanOrder(row)
@@ -185,6 +277,26 @@ func (db *DB) ListActiveOrders() ([]*Order, error) {
 	return scanOrders(rows)
 }
 
+// ListSourcingOrders returns orders still waiting in sourcing, oldest first.
+func (db *DB) ListSourcingOrders() ([]*Order, error) {
+	rows, err := db.Query(db.Q(fmt.Sprintf(`SELECT %s FROM orders WHERE status='sourcing' ORDER BY id`, orderSelectCols)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanOrders(rows)
+}
+
+// ListStuckOrders returns orders a crash could have left mid-dispatch, oldest first.
+func (db *DB) ListStuckOrders() ([]*Order, error) {
+	rows, err := db.Query(db.Q(fmt.Sprintf(`SELECT %s FROM orders WHERE status IN ('pending', 'sourcing', 'dispatched') ORDER BY id`, orderSelectCols)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanOrders(rows)
+}
+
 func (db *DB) ListOrderHistory(orderID int64) ([]*OrderHistory, error) {
 	rows, err := db.Query(db.Q(`SELECT id, order_id, status, detail, created_at FROM order_history WHERE order_id=? ORDER BY id`), orderID)
 	if err != nil {
@@ -210,6 +322,136 @@ func (db *DB) UpdateOrderPriority(id int64, priority int) error {
 	return err
 }
 
+// EscalateOrderPriority raises an order's priority and records why in its
+// history, keeping the order's current status.
+func (db *DB) EscalateOrderPriority(id int64, priority int, detail string) error {
+	return db.WithTx(func(tx *Tx) error {
+		if _, err := tx.Exec(tx.Q(`UPDATE orders SET priority=?, updated_at=datetime('now','localtime') WHERE id=?`),
+			priority, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(tx.Q(`INSERT INTO order_history (order_id, status, detail) SELECT id, status, ? FROM orders WHERE id=?`),
+			detail, id)
+		return err
+	})
+}
+
+// RecordFleetRetry counts a retry of a failed fleet order and holds the order,
+// with its claims, in retrying until next. vendor selects the vendor-failure
+// counter rather than the create-error one. An order that already ended is
+// left alone and ErrOrderEnded returned.
+func (db *DB) RecordFleetRetry(id int64, vendor bool, next time.Time, detail string) error {
+	col := "create_retries"
+	if vendor {
+		col = "vendor_retries"
+	}
+	return db.WithTx(func(tx *Tx) error {
+		result, err := tx.Exec(tx.Q(fmt.Sprintf(`UPDATE orders SET %s=%s+1, robot_id='', next_retry_at=? WHERE id=? AND status NOT IN ('confirmed', 'failed', 'cancelled')`, col, col)),
+			db.timeArg(&next), id)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("retry order %d: %w", id, ErrOrderEnded)
+		}
+		return updateOrderStatus(tx, id, "retrying", detail)
+	})
+}
+
+// ListRetryingOrders returns orders waiting to retry a failed fleet order.
+func (db *DB) ListRetryingOrders() ([]*Order, error) {
+	rows, err := db.Query(db.Q(fmt.Sprintf(`SELECT %s FROM orders WHERE status='retrying' ORDER BY id`, orderSelectCols)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanOrders(rows)
+}
+
+// MarkSLABreached records when an order first breached its SLA.
+func (db *DB) MarkSLABreached(id int64) error {
+	_, err := db.Exec(db.Q(`UPDATE orders SET sla_breached_at=datetime('now','localtime') WHERE id=? AND sla_breached_at IS NULL`), id)
+	return err
+}
+
+// ListWaitingOrders returns orders no robot has picked up yet: queued in core,
+// still being sourced, or dispatched and queued at the fleet. Oldest first.
+func (db *DB) ListWaitingOrders() ([]*Order, error) {
+	rows, err := db.Query(db.Q(fmt.Sprintf(`SELECT %s FROM orders WHERE status IN ('pending', 'queued', 'sourcing', 'retrying', 'dispatched') AND robot_id='' ORDER BY id`, orderSelectCols)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanOrders(rows)
+}
+
+// ListQueuedOrders returns orders held by an in-flight cap, highest priority
+// first, then oldest first.
+func (db *DB) ListQueuedOrders() ([]*Order, error) {
+	rows, err := db.Query(db.Q(fmt.Sprintf(`SELECT %s FROM orders WHERE status='queued' AND held_zone='' ORDER BY priority DESC, id`, orderSelectCols)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanOrders(rows)
+}
+
+// ListHeldOrders returns orders held for a locked exclusive zone, oldest
+// first.
+func (db *DB) ListHeldOrders() ([]*Order, error) {
+	rows, err := db.Query(db.Q(fmt.Sprintf(`SELECT %s FROM orders WHERE status='queued' AND held_zone<>'' ORDER BY id`, orderSelectCols)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanOrders(rows)
+}
+
+// InFlightCount is the number of orders in a set of statuses sharing a
+// station, destination zone and payload type.
+type InFlightCount struct {
+	StationID   string `json:"station_id"`
+	Zone        string `json:"zone"`
+	PayloadType string `json:"payload_type"`
+	Count       int    `json:"count"`
+}
+
+// CountOrdersByStatus counts orders in the given statuses, grouped by station,
+// the zone of their delivery node and their payload type.
+func (db *DB) CountOrdersByStatus(statuses []string) ([]InFlightCount, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(statuses))
+	for i, s := range statuses {
+		args[i] = s
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?,", len(statuses)), ",")
+	rows, err := db.Query(db.Q(`SELECT o.station_id, COALESCE(n.zone, ''), COALESCE(pt.name, ''), COUNT(*)
+		FROM orders o
+		LEFT JOIN nodes n ON n.name = o.delivery_node
+		LEFT JOIN payload_types pt ON pt.id = o.payload_type_id
+		WHERE o.status IN (`+marks+`)
+		GROUP BY o.station_id, COALESCE(n.zone, ''), COALESCE(pt.name, '')`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var counts []InFlightCount
+	for rows.Next() {
+		var c InFlightCount
+		if err := rows.Scan(&c.StationID, &c.Zone, &c.PayloadType, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
 func (db *DB) ListOrdersByStation(stationID string, limit int) ([]*Order, error) {
 	rows, err := db.Query(db.Q(fmt.Sprintf(`SELECT %s FROM orders WHERE station_id=? ORDER BY id DESC LIMIT ?`, orderSelectCols)), stationID, limit)
 	if err != nil {
@@ -236,3 +478,23 @@ func (db *DB) ListDispatchedVendorOrderIDs() ([]string, error) {
 	}
 	return ids, rows.Err()
 }
+
+// ListDispatchedVendorStates returns the last recorded vendor state of every
+// non-terminal order, keyed by vendor order ID. The engine seeds the fleet
+// tracker with it on startup.
+func (db *DB) ListDispatchedVendorStates() (map[string]string, error) {
+	rows, err := db.Query(db.Q(`SELECT vendor_order_id, vendor_state FROM orders WHERE vendor_order_id != '' AND status IN ('dispatched', 'in_transit')`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	states := make(map[string]string)
+	for rows.Next() {
+		var id, state string
+		if err := rows.Scan(&id, &state); err != nil {
+			return nil, err
+		}
+		states[id] = state
+	}
+	return states, rows.Err()
+}
@@ -12,16 +12,17 @@ type PayloadType struct {
 	Description         string    `json:"description"`
 	FormFactor          string    `json:"form_factor"`
 	DefaultManifestJSON string    `json:"default_manifest_json"`
+	SourceStrategy      string    `json:"source_strategy"`
 	CreatedAt           time.Time `json:"created_at"`
 	UpdatedAt           time.Time `json:"updated_at"`
 }
 
-const payloadTypeSelectCols = `id, name, description, form_factor, default_manifest_json, created_at, updated_at`
+const payloadTypeSelectCols = `id, name, description, form_factor, default_manifest_json, source_strategy, created_at, updated_at`
 
 func scanPayloadType(row interface{ Scan(...any) error }) (*PayloadType, error) {
 	var pt PayloadType
 	var createdAt, updatedAt any
-	err := row.Scan(&pt.ID, &pt.Name, &pt.Description, &pt.FormFactor, &pt.DefaultManifestJSON, &createdAt, &updatedAt)
+	err := row.Scan(&pt.ID, &pt.Name, &pt.Description, &pt.FormFactor, &pt.DefaultManifestJSON, &pt.SourceStrategy, &createdAt, &updatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -43,8 +44,8 @@ func scanPayloadTypes(rows *sql.Rows) ([]*PayloadType, error) {
 }
 
 func (db *DB) CreatePayloadType(pt *PayloadType) error {
-	result, err := db.Exec(db.Q(`INSERT INTO payload_types (name, description, form_factor, default_manifest_json) VALUES (?, ?, ?, ?)`),
-		pt.Name, pt.Description, pt.FormFactor, pt.DefaultManifestJSON)
+	result, err := db.Exec(db.Q(`INSERT INTO payload_types (name, description, form_factor, default_manifest_json, source_strategy) VALUES (?, ?, ?, ?, ?)`),
+		pt.Name, pt.Description, pt.FormFactor, pt.DefaultManifestJSON, sourceStrategyOrDefault(pt.SourceStrategy))
 	if err != nil {
 		return fmt.Errorf("create payload type: %w", err)
 	}
@@ -57,8 +58,8 @@ func (db *DB) CreatePayloadType(pt *PayloadType) error {
 }
 
 func (db *DB) UpdatePayloadType(pt *PayloadType) error {
-	_, err := db.Exec(db.Q(`UPDATE payload_types SET name=?, description=?, form_factor=?, default_manifest_json=?, updated_at=datetime('now','localtime') WHERE id=?`),
-		pt.Name, pt.Description, pt.FormFactor, pt.DefaultManifestJSON, pt.ID)
+	_, err := db.Exec(db.Q(`UPDATE payload_types SET name=?, description=?, form_factor=?, default_manifest_json=?, source_strategy=?, updated_at=datetime('now','localtime') WHERE id=?`),
+		pt.Name, pt.Description, pt.FormFactor, pt.DefaultManifestJSON, sourceStrategyOrDefault(pt.SourceStrategy), pt.ID)
 	return err
 }
 
@@ -85,3 +86,10 @@ func (db *DB) ListPayloadTypes() ([]*PayloadType, error) {
 	defer rows.Close()
 	return scanPayloadTypes(rows)
 }
+
+func sourceStrategyOrDefault(s string) string {
+	if s == "" {
+		return "fifo"
+	}
+	return s
+}
@@ -2,10 +2,14 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
 
+// ErrPayloadClaimed is returned when a payload is already claimed by another order.
+var ErrPayloadClaimed = errors.New("payload already claimed")
+
 type Payload struct {
 	ID            int64     `json:"id"`
 	PayloadTypeID int64     `json:"payload_type_id"`
@@ -137,6 +141,16 @@ func (db *DB) ListPayloadsByNode(nodeID int64) ([]*Payload, error) {
 	return scanPayloads(rows, true)
 }
 
+// ListPayloadsByType returns every payload of a type, oldest delivery first.
+func (db *DB) ListPayloadsByType(payloadTypeID int64) ([]*Payload, error) {
+	rows, err := db.Query(db.Q(fmt.Sprintf(`%s WHERE p.payload_type_id=? ORDER BY p.delivered_at ASC, p.id ASC`, payloadJoinQuery)), payloadTypeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPayloads(rows, true)
+}
+
 func (db *DB) CountPayloadsByNode(nodeID int64) (int, error) {
 	var count int
 	err := db.QueryRow(db.Q(`SELECT COUNT(*) FROM payloads WHERE node_id=?`), nodeID).Scan(&count)
@@ -144,9 +158,51 @@ func (db *DB) CountPayloadsByNode(nodeID int64) (int, error) {
 }
 
 // ClaimPayload marks a payload as claimed by an order to prevent double-dispatch.
+// It fails with ErrPayloadClaimed if another order holds the payload.
 func (db *DB) ClaimPayload(payloadID, orderID int64) error {
-	_, err := db.Exec(db.Q(`UPDATE payloads SET claimed_by=?, updated_at=datetime('now','localtime') WHERE id=?`), orderID, payloadID)
-	return err
+	return claimPayload(db, payloadID, orderID)
+}
+
+// ClaimPayloadForPickup claims a payload and makes its node the order's pickup
+// node in one transaction.
+func (db *DB) ClaimPayloadForPickup(payloadID, orderID int64, pickupNode string) error {
+	return db.WithTx(func(tx *Tx) error {
+		if err := claimPayload(tx, payloadID, orderID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(tx.Q(`UPDATE orders SET pickup_node=?, updated_at=datetime('now','localtime') WHERE id=?`),
+			pickupNode, orderID)
+		return err
+	})
+}
+
+// ClaimReturnPayload claims the payload a swap takes back and records where it
+// returns to in one transaction.
+func (db *DB) ClaimReturnPayload(payloadID, orderID int64, returnNode string) error {
+	return db.WithTx(func(tx *Tx) error {
+		if err := claimPayload(tx, payloadID, orderID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(tx.Q(`UPDATE orders SET return_node=?, updated_at=datetime('now','localtime') WHERE id=?`),
+			returnNode, orderID)
+		return err
+	})
+}
+
+func claimPayload(tx execer, payloadID, orderID int64) error {
+	result, err := tx.Exec(tx.Q(`UPDATE payloads SET claimed_by=?, updated_at=datetime('now','localtime') WHERE id=? AND (claimed_by IS NULL OR claimed_by=?)`),
+		orderID, payloadID, orderID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("claim payload %d: %w", payloadID, ErrPayloadClaimed)
+	}
+	return nil
 }
 
 // UnclaimPayload releases a payload from an order claim.
@@ -155,6 +211,20 @@ func (db *DB) UnclaimPayload(payloadID int64) error {
 	return err
 }
 
+// UnclaimOrderPayloads releases every payload claimed by an order and returns
+// how many were released.
+func (db *DB) UnclaimOrderPayloads(orderID int64) (int64, error) {
+	return unclaimOrderPayloads(db, orderID)
+}
+
+func unclaimOrderPayloads(tx execer, orderID int64) (int64, error) {
+	result, err := tx.Exec(tx.Q(`UPDATE payloads SET claimed_by=NULL, updated_at=datetime('now','localtime') WHERE claimed_by=?`), orderID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // MovePayload moves a payload to a new node, updating delivered_at.
 func (db *DB) MovePayload(payloadID, toNodeID int64) error {
 	_, err := db.Exec(db.Q(`UPDATE payloads SET node_id=?, delivered_at=datetime('now','localtime'), updated_at=datetime('now','localtime') WHERE id=?`), toNodeID, payloadID)
@@ -174,6 +244,23 @@ func (db *DB) FindSourcePayloadFIFO(payloadTypeCode string) (*Payload, error) {
 	return scanPayload(row, true)
 }
 
+// ListSourcePayloadCandidates returns every unclaimed, available payload of a type at an
+// enabled storage node, oldest delivery first. Source selectors rank this list.
+func (db *DB) ListSourcePayloadCandidates(payloadTypeCode string) ([]*Payload, error) {
+	rows, err := db.Query(db.Q(fmt.Sprintf(`%s
+		WHERE pt.name = ?
+		  AND n.node_type = 'storage'
+		  AND n.enabled = 1
+		  AND p.claimed_by IS NULL
+		  AND p.status = 'available'
+		ORDER BY p.delivered_at ASC, p.id ASC`, payloadJoinQuery)), payloadTypeCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPayloads(rows, true)
+}
+
 // FindStorageDestinationForPayload finds the best storage node for a payload type.
 // Prefers nodes that already have this payload type (consolidation), then emptiest.
 func (db *DB) FindStorageDestinationForPayload(payloadTypeID int64) (*Node, error) {
@@ -98,6 +98,13 @@ func (db *DB) ListScenePointsByArea(areaName string) ([]*ScenePoint, error) {
 	return scanScenePoints(rows)
 }
 
+// GetScenePointByInstance returns the first scene point with the given instance name.
+// Node vendor locations match scene point instance names.
+func (db *DB) GetScenePointByInstance(instanceName string) (*ScenePoint, error) {
+	row := db.QueryRow(db.Q(fmt.Sprintf(`SELECT %s FROM scene_points WHERE instance_name=? ORDER BY id LIMIT 1`, scenePointSelectCols)), instanceName)
+	return scanScenePoint(row)
+}
+
 func (db *DB) ListBinLocations() ([]*ScenePoint, error) {
 	return db.ListScenePointsByClass("GeneralLocation")
 }
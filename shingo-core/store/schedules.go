@@ -0,0 +1,115 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// OrderSchedule creates transport orders on a timetable: once at RunAt, or
+// repeatedly on a cron rule (milk runs).
+type OrderSchedule struct {
+	ID              int64      `json:"id"`
+	Name            string     `json:"name"`
+	OrderType       string     `json:"order_type"`
+	PayloadTypeCode string     `json:"payload_type_code"`
+	PickupNode      string     `json:"pickup_node"`
+	DeliveryNode    string     `json:"delivery_node"`
+	Quantity        float64    `json:"quantity"`
+	Priority        int        `json:"priority"`
+	StationID       string     `json:"station_id"`
+	RunAt           *time.Time `json:"run_at,omitempty"`
+	Cron            string     `json:"cron"`
+	Enabled         bool       `json:"enabled"`
+	NextRunAt       *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	LastResult      string     `json:"last_result"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Recurring reports whether the schedule repeats on a cron rule.
+func (s *OrderSchedule) Recurring() bool {
+	return s.Cron != ""
+}
+
+const scheduleSelectCols = `id, name, order_type, payload_type_code, pickup_node, delivery_node, quantity, priority, station_id,
+	run_at, cron, enabled, next_run_at, last_run_at, last_result, created_at, updated_at`
+
+func scanSchedule(row interface{ Scan(...any) error }) (*OrderSchedule, error) {
+	var s OrderSchedule
+	var runAt, nextRunAt, lastRunAt, createdAt, updatedAt any
+	err := row.Scan(&s.ID, &s.Name, &s.OrderType, &s.PayloadTypeCode, &s.PickupNode, &s.DeliveryNode, &s.Quantity, &s.Priority, &s.StationID,
+		&runAt, &s.Cron, &s.Enabled, &nextRunAt, &lastRunAt, &s.LastResult, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.RunAt = parseLocalTimePtr(runAt)
+	s.NextRunAt = parseLocalTimePtr(nextRunAt)
+	s.LastRunAt = parseLocalTimePtr(lastRunAt)
+	s.CreatedAt = parseTime(createdAt)
+	s.UpdatedAt = parseTime(updatedAt)
+	return &s, nil
+}
+
+func scanSchedules(rows *sql.Rows) ([]*OrderSchedule, error) {
+	var schedules []*OrderSchedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func (db *DB) CreateOrderSchedule(s *OrderSchedule) error {
+	result, err := db.Exec(db.Q(`INSERT INTO order_schedules (name, order_type, payload_type_code, pickup_node, delivery_node, quantity, priority, station_id, run_at, cron, enabled, next_run_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		s.Name, s.OrderType, s.PayloadTypeCode, s.PickupNode, s.DeliveryNode, s.Quantity, s.Priority, s.StationID,
+		db.timeArg(s.RunAt), s.Cron, s.Enabled, db.timeArg(s.NextRunAt))
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	s.ID = id
+	return nil
+}
+
+func (db *DB) UpdateOrderSchedule(s *OrderSchedule) error {
+	_, err := db.Exec(db.Q(`UPDATE order_schedules SET name=?, order_type=?, payload_type_code=?, pickup_node=?, delivery_node=?, quantity=?, priority=?, station_id=?,
+		run_at=?, cron=?, enabled=?, next_run_at=?, updated_at=datetime('now','localtime') WHERE id=?`),
+		s.Name, s.OrderType, s.PayloadTypeCode, s.PickupNode, s.DeliveryNode, s.Quantity, s.Priority, s.StationID,
+		db.timeArg(s.RunAt), s.Cron, s.Enabled, db.timeArg(s.NextRunAt), s.ID)
+	return err
+}
+
+// RecordScheduleRun stores the outcome of a schedule firing (or being
+// skipped) and when it is next due. A nil next clears the due time.
+func (db *DB) RecordScheduleRun(id int64, ranAt time.Time, next *time.Time, enabled bool, result string) error {
+	_, err := db.Exec(db.Q(`UPDATE order_schedules SET last_run_at=?, next_run_at=?, enabled=?, last_result=?, updated_at=datetime('now','localtime') WHERE id=?`),
+		db.timeArg(&ranAt), db.timeArg(next), enabled, result, id)
+	return err
+}
+
+func (db *DB) DeleteOrderSchedule(id int64) error {
+	_, err := db.Exec(db.Q(`DELETE FROM order_schedules WHERE id=?`), id)
+	return err
+}
+
+func (db *DB) GetOrderSchedule(id int64) (*OrderSchedule, error) {
+	row := db.QueryRow(db.Q(`SELECT `+scheduleSelectCols+` FROM order_schedules WHERE id=?`), id)
+	return scanSchedule(row)
+}
+
+func (db *DB) ListOrderSchedules() ([]*OrderSchedule, error) {
+	rows, err := db.Query(db.Q(`SELECT ` + scheduleSelectCols + ` FROM order_schedules ORDER BY name, id`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSchedules(rows)
+}
@@ -17,7 +17,23 @@ type DB struct {
 	driver  string
 }
 
+// Open connects to the configured database and applies any pending
+// schema migrations.
 func Open(cfg *config.DatabaseConfig) (*DB, error) {
+	db, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate %s: %w", db.driver, err)
+	}
+	return db, nil
+}
+
+// Connect connects to the configured database without touching its schema.
+// It is meant for the migrate command; everything else should use Open.
+func Connect(cfg *config.DatabaseConfig) (*DB, error) {
 	switch cfg.Driver {
 	case "sqlite":
 		return openSQLite(cfg.SQLite.Path)
@@ -35,16 +51,7 @@ func openSQLite(path string) (*DB, error) {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
 	sqlDB.SetMaxOpenConns(1)
-	db := &DB{DB: sqlDB, dialect: sqliteDialect{}, driver: "sqlite"}
-	if err := db.migrateRenames(); err != nil {
-		sqlDB.Close()
-		return nil, fmt.Errorf("migrate renames sqlite: %w", err)
-	}
-	if err := db.migrate(); err != nil {
-		sqlDB.Close()
-		return nil, fmt.Errorf("migrate sqlite: %w", err)
-	}
-	return db, nil
+	return &DB{DB: sqlDB, dialect: sqliteDialect{}, driver: "sqlite"}, nil
 }
 
 func openPostgres(cfg *config.PostgresConfig) (*DB, error) {
@@ -54,16 +61,7 @@ func openPostgres(cfg *config.PostgresConfig) (*DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("open postgres: %w", err)
 	}
-	db := &DB{DB: sqlDB, dialect: postgresDialect{}, driver: "postgres"}
-	if err := db.migrateRenames(); err != nil {
-		sqlDB.Close()
-		return nil, fmt.Errorf("migrate renames postgres: %w", err)
-	}
-	if err := db.migrate(); err != nil {
-		sqlDB.Close()
-		return nil, fmt.Errorf("migrate postgres: %w", err)
-	}
-	return db, nil
+	return &DB{DB: sqlDB, dialect: postgresDialect{}, driver: "postgres"}, nil
 }
 
 func (db *DB) Dialect() Dialect { return db.dialect }
@@ -77,78 +75,3 @@ func (db *DB) Q(query string) string {
 	}
 	return query
 }
-
-// migrateRenames idempotently renames old RDS-specific columns to vendor-neutral names.
-// SQLite 3.25+ and PostgreSQL both support ALTER TABLE RENAME COLUMN.
-func (db *DB) migrateRenames() error {
-	renames := []struct{ table, oldCol, newCol string }{
-		{"nodes", "rds_location", "vendor_location"},
-		{"orders", "rds_order_id", "vendor_order_id"},
-		{"orders", "rds_state", "vendor_state"},
-		{"orders", "client_id", "station_id"},
-		{"outbox", "event_type", "msg_type"},
-		{"outbox", "client_id", "station_id"},
-	}
-	for _, r := range renames {
-		if db.columnExists(r.table, r.oldCol) {
-			_, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s TO %s`, r.table, r.oldCol, r.newCol))
-			if err != nil {
-				return fmt.Errorf("rename %s.%s: %w", r.table, r.oldCol, err)
-			}
-		}
-	}
-	// Rename index idempotently (drop old, new one created by schema)
-	if db.driver == "postgres" {
-		db.Exec(`DROP INDEX IF EXISTS idx_orders_rds`)
-	}
-
-	// Migrate completed -> confirmed status
-	db.Exec("UPDATE orders SET status='confirmed' WHERE status='completed'")
-
-	return nil
-}
-
-// columnExists checks if a column exists in a table.
-func (db *DB) columnExists(table, column string) bool {
-	switch db.driver {
-	case "sqlite":
-		rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
-		if err != nil {
-			return false
-		}
-		defer rows.Close()
-		for rows.Next() {
-			var cid int
-			var name, typ string
-			var notnull int
-			var dflt sql.NullString
-			var pk int
-			if err := rows.Scan(&cid, &name, &typ, &notnull, &dflt, &pk); err != nil {
-				return false
-			}
-			if name == column {
-				return true
-			}
-		}
-		return false
-	case "postgres":
-		var exists bool
-		db.QueryRow(`SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name=$1 AND column_name=$2)`, table, column).Scan(&exists)
-		return exists
-	}
-	return false
-}
-
-func (db *DB) migrate() error {
-	var schema string
-	switch db.driver {
-	case "sqlite":
-		schema = schemaSQLite
-	case "postgres":
-		schema = schemaPostgres
-	default:
-		return fmt.Errorf("no schema for driver: %s", db.driver)
-	}
-	_, err := db.Exec(schema)
-	return err
-}
@@ -1,9 +1,15 @@
 package store
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"shingocore/config"
 )
@@ -303,8 +309,350 @@ func TestListDispatchedVendorOrderIDs(t *testing.T) {
 	}
 }
 
+func TestListDispatchedVendorStates(t *testing.T) {
+	db := testDB(t)
+
+	o1 := &Order{EdgeUUID: "u1", Status: "dispatched"}
+	o2 := &Order{EdgeUUID: "u2", Status: "in_transit"}
+	o3 := &Order{EdgeUUID: "u3", Status: "delivered"}
+	db.CreateOrder(o1)
+	db.CreateOrder(o2)
+	db.CreateOrder(o3)
+	db.UpdateOrderVendor(o1.ID, "rds-1", "TOBEDISPATCHED", "")
+	db.UpdateOrderVendor(o2.ID, "rds-2", "RUNNING", "AMB-01")
+	db.UpdateOrderVendor(o3.ID, "rds-3", "FINISHED", "AMB-02")
+
+	states, err := db.ListDispatchedVendorStates()
+	if err != nil {
+		t.Fatalf("list vendor states: %v", err)
+	}
+	if len(states) != 2 || states["rds-1"] != "TOBEDISPATCHED" || states["rds-2"] != "RUNNING" {
+		t.Errorf("states = %v, want rds-1 TOBEDISPATCHED and rds-2 RUNNING", states)
+	}
+}
+
+func TestExclusiveZoneLock(t *testing.T) {
+	db := testDB(t)
+
+	if err := db.SaveExclusiveZone("PRESS-3", ""); err != nil {
+		t.Fatalf("save zone: %v", err)
+	}
+	db.SaveExclusiveZone("AISLE-2", "aisle-2-north, aisle-2-south")
+
+	z, err := db.GetExclusiveZone("PRESS-3")
+	if err != nil {
+		t.Fatalf("get zone: %v", err)
+	}
+	if z.Locked || fmt.Sprint(z.Groups()) != "[PRESS-3]" {
+		t.Errorf("PRESS-3 locked=%v groups=%v, want unlocked with groups [PRESS-3]", z.Locked, z.Groups())
+	}
+
+	if err := db.SetZoneLock("AISLE-2", true, "changeover", "edge:line-1"); err != nil {
+		t.Fatalf("lock zone: %v", err)
+	}
+	z, _ = db.GetExclusiveZone("AISLE-2")
+	if !z.Locked || z.LockReason != "changeover" || z.LockedBy != "edge:line-1" || z.LockedAt == nil {
+		t.Errorf("AISLE-2 = %+v, want locked by edge:line-1", z)
+	}
+	if fmt.Sprint(z.Groups()) != "[aisle-2-north aisle-2-south]" {
+		t.Errorf("groups = %v", z.Groups())
+	}
+	locked, _ := db.LockedZones()
+	if len(locked) != 1 || !locked["AISLE-2"] {
+		t.Errorf("locked zones = %v, want AISLE-2", locked)
+	}
+
+	// Saving again keeps the lock
+	db.SaveExclusiveZone("AISLE-2", "aisle-2")
+	z, _ = db.GetExclusiveZone("AISLE-2")
+	if !z.Locked || z.MutexGroups != "aisle-2" {
+		t.Errorf("after save: locked=%v groups=%q", z.Locked, z.MutexGroups)
+	}
+
+	db.SetZoneLock("AISLE-2", false, "", "")
+	z, _ = db.GetExclusiveZone("AISLE-2")
+	if z.Locked || z.LockedBy != "" || z.LockedAt != nil {
+		t.Errorf("after unlock: %+v", z)
+	}
+
+	db.DeleteExclusiveZone("PRESS-3")
+	zones, _ := db.ListExclusiveZones()
+	if len(zones) != 1 || zones[0].Zone != "AISLE-2" {
+		t.Errorf("zones = %+v, want only AISLE-2", zones)
+	}
+}
+
+func TestRobotTelemetry(t *testing.T) {
+	db := testDB(t)
+
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local)
+	db.StartRobotInterval("AMB-01", "ready", start)
+	db.StartRobotInterval("AMB-01", "busy", start.Add(10*time.Minute))
+	db.StartRobotInterval("AMB-02", "charging", start.Add(5*time.Minute))
+	db.InsertRobotSnapshot(&RobotSnapshot{VehicleID: "AMB-01", State: "ready", Battery: 80, RecordedAt: start})
+	db.InsertRobotSnapshot(&RobotSnapshot{VehicleID: "AMB-01", State: "busy", Battery: 78, X: 1.5, RecordedAt: start.Add(10 * time.Minute)})
+
+	intervals, err := db.ListRobotIntervals(start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("list intervals: %v", err)
+	}
+	if len(intervals) != 3 {
+		t.Fatalf("intervals = %d, want 3", len(intervals))
+	}
+	first := intervals[0]
+	if first.VehicleID != "AMB-01" || first.State != "ready" || first.EndedAt == nil || !first.EndedAt.Equal(start.Add(10*time.Minute)) {
+		t.Errorf("first interval = %+v, want ready ended at 08:10", first)
+	}
+	if intervals[1].EndedAt != nil {
+		t.Errorf("current interval ended at %v, want open", intervals[1].EndedAt)
+	}
+
+	snaps, err := db.ListRobotSnapshots("AMB-01", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("list snapshots: %v", err)
+	}
+	if len(snaps) != 2 || snaps[1].State != "busy" || snaps[1].X != 1.5 {
+		t.Errorf("snapshots = %+v", snaps)
+	}
+	last, err := db.LastRobotSnapshotTime()
+	if err != nil || last == nil || !last.Equal(start.Add(10*time.Minute)) {
+		t.Errorf("last snapshot = %v, %v, want 08:10", last, err)
+	}
+
+	if err := db.CloseRobotIntervals(start.Add(20 * time.Minute)); err != nil {
+		t.Fatalf("close intervals: %v", err)
+	}
+	n, err := db.PruneRobotTelemetry(start.Add(15 * time.Minute))
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	// Both samples and the ready interval predate the cutoff
+	if n != 3 {
+		t.Errorf("pruned %d rows, want 3", n)
+	}
+	intervals, _ = db.ListRobotIntervals(start, start.Add(time.Hour))
+	if len(intervals) != 2 {
+		t.Errorf("intervals after prune = %d, want 2", len(intervals))
+	}
+}
+
+func TestTravelTimes(t *testing.T) {
+	db := testDB(t)
+
+	if _, err := db.GetTravelTime(TravelNode, "A1", "LINE1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("get before any sample: err = %v, want sql.ErrNoRows", err)
+	}
+	db.RecordTravelTime(TravelNode, "A1", "LINE1", 100)
+	db.RecordTravelTime(TravelNode, "A1", "LINE1", 200)
+	db.RecordTravelTime(TravelZone, "A", "L", 50)
+
+	tt, err := db.GetTravelTime(TravelNode, "A1", "LINE1")
+	if err != nil {
+		t.Fatalf("get travel time: %v", err)
+	}
+	if tt.Samples != 2 || tt.AvgSeconds != 150 {
+		t.Errorf("travel time = %d samples avg %.1f, want 2 samples avg 150", tt.Samples, tt.AvgSeconds)
+	}
+	// Past the window, new samples weigh 1/20
+	for i := 0; i < 30; i++ {
+		db.RecordTravelTime(TravelNode, "A1", "LINE1", 150)
+	}
+	db.RecordTravelTime(TravelNode, "A1", "LINE1", 350)
+	tt, _ = db.GetTravelTime(TravelNode, "A1", "LINE1")
+	if tt.AvgSeconds != 160 {
+		t.Errorf("avg after outlier = %.1f, want 160", tt.AvgSeconds)
+	}
+	all, _ := db.ListTravelTimes()
+	if len(all) != 2 {
+		t.Errorf("travel times = %d, want 2", len(all))
+	}
+}
+
+func TestClaimPayload_Conditional(t *testing.T) {
+	db := testDB(t)
+
+	pt := &PayloadType{Name: "PART-A", DefaultManifestJSON: "{}"}
+	db.CreatePayloadType(pt)
+	p := &Payload{PayloadTypeID: pt.ID, Status: "available"}
+	db.CreatePayload(p)
+	o1 := &Order{EdgeUUID: "u1", Status: "sourcing"}
+	o2 := &Order{EdgeUUID: "u2", Status: "sourcing"}
+	db.CreateOrder(o1)
+	db.CreateOrder(o2)
+
+	if err := db.ClaimPayloadForPickup(p.ID, o1.ID, "STORAGE-A1"); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	got, _ := db.GetOrder(o1.ID)
+	if got.PickupNode != "STORAGE-A1" {
+		t.Errorf("PickupNode = %q, want %q", got.PickupNode, "STORAGE-A1")
+	}
+
+	// A second order cannot take the claim, and its pickup node is untouched
+	err := db.ClaimPayloadForPickup(p.ID, o2.ID, "STORAGE-A1")
+	if !errors.Is(err, ErrPayloadClaimed) {
+		t.Fatalf("second claim err = %v, want ErrPayloadClaimed", err)
+	}
+	got2, _ := db.GetOrder(o2.ID)
+	if got2.PickupNode != "" {
+		t.Errorf("PickupNode after failed claim = %q, want empty", got2.PickupNode)
+	}
+
+	// Re-claiming by the holder is allowed
+	if err := db.ClaimPayload(p.ID, o1.ID); err != nil {
+		t.Errorf("re-claim by holder: %v", err)
+	}
+
+	n, err := db.UnclaimOrderPayloads(o1.ID)
+	if err != nil || n != 1 {
+		t.Errorf("unclaim = %d, %v; want 1, nil", n, err)
+	}
+	if err := db.ClaimPayload(p.ID, o2.ID); err != nil {
+		t.Errorf("claim after unclaim: %v", err)
+	}
+}
+
+func TestCloseOrder_ReleasesClaims(t *testing.T) {
+	db := testDB(t)
+
+	pt := &PayloadType{Name: "PART-A", DefaultManifestJSON: "{}"}
+	db.CreatePayloadType(pt)
+	p := &Payload{PayloadTypeID: pt.ID, Status: "available"}
+	db.CreatePayload(p)
+	o := &Order{EdgeUUID: "u1", Status: "pending"}
+	if err := db.ReceiveOrder(o, "order received"); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	db.ClaimPayload(p.ID, o.ID)
+
+	if err := db.CloseOrder(o.ID, "failed", "no route"); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	got, _ := db.GetOrder(o.ID)
+	if got.Status != "failed" || got.ErrorDetail != "no route" {
+		t.Errorf("order = %s/%q, want failed/%q", got.Status, got.ErrorDetail, "no route")
+	}
+	gotP, _ := db.GetPayload(p.ID)
+	if gotP.ClaimedBy != nil {
+		t.Errorf("ClaimedBy = %d, want nil", *gotP.ClaimedBy)
+	}
+	history, _ := db.ListOrderHistory(o.ID)
+	if len(history) != 2 || history[0].Detail != "order received" {
+		t.Errorf("history = %d entries, want received + failed", len(history))
+	}
+}
+
+func TestFleetIntents(t *testing.T) {
+	db := testDB(t)
+
+	o1 := &Order{EdgeUUID: "u1", Status: "sourcing"}
+	o2 := &Order{EdgeUUID: "u2", Status: "sourcing"}
+	db.CreateOrder(o1)
+	db.CreateOrder(o2)
+
+	fi1, err := db.CreateFleetIntent(o1.ID, "sg-1-aaaa", `{"from":"Loc-01"}`)
+	if err != nil {
+		t.Fatalf("create intent: %v", err)
+	}
+	fi2, _ := db.CreateFleetIntent(o2.ID, "sg-2-bbbb", "")
+
+	pending, err := db.ListPendingFleetIntents()
+	if err != nil {
+		t.Fatalf("list pending: %v", err)
+	}
+	if len(pending) != 2 || pending[0].VendorOrderID != "sg-1-aaaa" || pending[0].Request != `{"from":"Loc-01"}` {
+		t.Fatalf("pending = %+v, want both intents oldest first", pending)
+	}
+
+	if err := db.CommitFleetIntent(fi1, "dispatched", "vendor order sg-1-aaaa created"); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	got, _ := db.GetOrder(o1.ID)
+	if got.Status != "dispatched" || got.VendorOrderID != "sg-1-aaaa" || got.VendorState != "CREATED" {
+		t.Errorf("order after commit = %s/%s/%s", got.Status, got.VendorOrderID, got.VendorState)
+	}
+
+	db.AbandonFleetIntent(fi2.ID)
+	pending, _ = db.ListPendingFleetIntents()
+	if len(pending) != 0 {
+		t.Errorf("pending after resolve = %d, want 0", len(pending))
+	}
+	got2, _ := db.GetOrder(o2.ID)
+	if got2.VendorOrderID != "" {
+		t.Errorf("abandoned VendorOrderID = %q, want empty", got2.VendorOrderID)
+	}
+}
+
+func TestChargingRobots(t *testing.T) {
+	db := testDB(t)
+
+	since := time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local)
+	db.SaveChargingRobot(&ChargingRobot{VehicleID: "AMB-02", Reason: "found charging", Station: "CHG-1", Since: since})
+	db.SaveChargingRobot(&ChargingRobot{VehicleID: "AMB-01", Reason: "battery low", Station: "CHG-2", OrderID: "chg-AMB-01-1", Since: since})
+	db.SaveChargingRobot(&ChargingRobot{VehicleID: "AMB-01", Reason: "battery low", Station: "CHG-1", OrderID: "chg-AMB-01-2", Since: since})
+
+	got, err := db.ListChargingRobots()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(got) != 2 || got[0].VehicleID != "AMB-01" || got[0].OrderID != "chg-AMB-01-2" || !got[0].Since.Equal(since) {
+		t.Fatalf("robots = %+v, want AMB-01 resaved and AMB-02", got)
+	}
+
+	db.DeleteChargingRobot("AMB-01")
+	if got, _ = db.ListChargingRobots(); len(got) != 1 || got[0].VehicleID != "AMB-02" {
+		t.Errorf("after delete = %+v", got)
+	}
+}
+
 // --- Outbox tests ---
 
+func TestOrderScheduleCRUD(t *testing.T) {
+	db := testDB(t)
+
+	runAt := time.Date(2026, 3, 2, 6, 45, 0, 0, time.Local)
+	once := &OrderSchedule{Name: "morning", OrderType: "retrieve", PayloadTypeCode: "PART-A", DeliveryNode: "LINE1-IN",
+		Quantity: 1, RunAt: &runAt, Enabled: true, NextRunAt: &runAt}
+	if err := db.CreateOrderSchedule(once); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	milk := &OrderSchedule{Name: "empties", OrderType: "move", PayloadTypeCode: "PART-A", PickupNode: "A", DeliveryNode: "B",
+		Quantity: 1, Cron: "*/30 * * * *", Enabled: true}
+	db.CreateOrderSchedule(milk)
+
+	got, err := db.GetOrderSchedule(once.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Recurring() || got.RunAt == nil || !got.RunAt.Equal(runAt) || !got.NextRunAt.Equal(runAt) || !got.Enabled {
+		t.Errorf("one-off = %+v, want run and next run at %v", got, runAt)
+	}
+	got, _ = db.GetOrderSchedule(milk.ID)
+	if !got.Recurring() || got.NextRunAt != nil {
+		t.Errorf("recurring = %+v", got)
+	}
+
+	ranAt := runAt.Add(time.Minute)
+	if err := db.RecordScheduleRun(once.ID, ranAt, nil, false, "order 7 sourcing"); err != nil {
+		t.Fatalf("record run: %v", err)
+	}
+	got, _ = db.GetOrderSchedule(once.ID)
+	if got.Enabled || got.NextRunAt != nil || got.LastRunAt == nil || !got.LastRunAt.Equal(ranAt) || got.LastResult != "order 7 sourcing" {
+		t.Errorf("after run = %+v", got)
+	}
+
+	milk.Name = "empties A to B"
+	milk.Enabled = false
+	if err := db.UpdateOrderSchedule(milk); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	db.DeleteOrderSchedule(once.ID)
+	list, _ := db.ListOrderSchedules()
+	if len(list) != 1 || list[0].Name != "empties A to B" || list[0].Enabled {
+		t.Errorf("list = %+v", list)
+	}
+}
+
 func TestOutboxCRUD(t *testing.T) {
 	db := testDB(t)
 
@@ -428,3 +776,167 @@ func TestRebind(t *testing.T) {
 		}
 	}
 }
+
+func TestParseLocalTime(t *testing.T) {
+	const wall = "2026-03-04 05:06:07"
+	if got := parseTime(wall); got.Location() != time.UTC {
+		t.Errorf("parseTime location = %v, want UTC", got.Location())
+	}
+	got := parseLocalTime(wall)
+	want := time.Date(2026, 3, 4, 5, 6, 7, 0, time.Local)
+	if !got.Equal(want) {
+		t.Errorf("parseLocalTime = %v, want %v", got, want)
+	}
+	if p := parseLocalTimePtr(""); p != nil {
+		t.Errorf("parseLocalTimePtr(\"\") = %v, want nil", p)
+	}
+}
+
+func TestOrderTimestampsAgree(t *testing.T) {
+	db := testDB(t)
+	o := &Order{EdgeUUID: "uuid-ts", StationID: "line-1", OrderType: "retrieve", Status: "pending"}
+	if err := db.CreateOrder(o); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := db.CompleteOrder(o.ID); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	got, err := db.GetOrder(o.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	// All three are written by the same clock, so they may only differ by
+	// the time the test took, never by the UTC offset.
+	now := time.Now()
+	for name, ts := range map[string]*time.Time{"created_at": &got.CreatedAt, "updated_at": &got.UpdatedAt, "completed_at": got.CompletedAt} {
+		if ts == nil || now.Sub(*ts).Abs() > time.Minute {
+			t.Errorf("%s = %v, want close to %v", name, ts, now)
+		}
+	}
+}
+
+// --- Migration tests ---
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	db := testDB(t)
+
+	// Roll the database back to what a pre-migration build left behind.
+	for _, q := range []string{
+		`DROP TABLE schema_migrations`,
+		`ALTER TABLE nodes RENAME COLUMN vendor_location TO rds_location`,
+		`ALTER TABLE orders DROP COLUMN held_zone`,
+		`ALTER TABLE orders DROP COLUMN next_retry_at`,
+		`DROP TABLE device_reservations`,
+		`DROP TABLE fleet_orders`,
+		`DROP TABLE charging_robots`,
+		`INSERT INTO orders (edge_uuid, station_id, order_type, status) VALUES ('legacy-1', 'line-1', 'retrieve', 'completed')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	n, err := db.Migrate()
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if n != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", n, len(migrations))
+	}
+	if _, err := db.Exec(`SELECT vendor_location FROM nodes`); err != nil {
+		t.Errorf("vendor_location not restored: %v", err)
+	}
+	if _, err := db.Exec(`SELECT held_zone FROM orders`); err != nil {
+		t.Errorf("held_zone not added: %v", err)
+	}
+	var status string
+	db.QueryRow(`SELECT status FROM orders WHERE edge_uuid='legacy-1'`).Scan(&status)
+	if status != "confirmed" {
+		t.Errorf("status = %q, want confirmed", status)
+	}
+
+	if n, err := db.Migrate(); err != nil || n != 0 {
+		t.Errorf("second migrate = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db := testDB(t)
+	// Back down to the baseline, then run a list with test migrations after it
+	for v := len(migrations); v > 1; v-- {
+		if st, err := db.MigrateDown(); err != nil || st.Version != v {
+			t.Fatalf("migrate down = %+v, %v; want version %d", st, err, v)
+		}
+	}
+	list := append(append([]migration{}, migrations[0]),
+		migration{
+			version: 2, name: "widgets",
+			up:   sqlStep(`CREATE TABLE widgets (id INTEGER PRIMARY KEY)`, `CREATE TABLE widgets (id BIGSERIAL PRIMARY KEY)`),
+			down: sqlStep(`DROP TABLE widgets`, `DROP TABLE widgets`),
+		},
+		migration{
+			version: 3, name: "broken",
+			up: sqlStep(`CREATE TABLE gadgets (id INTEGER); ALTER TABLE nope ADD COLUMN x INTEGER`, ``),
+		},
+	)
+
+	n, err := db.migrateUp(list)
+	if n != 1 || err == nil || !strings.Contains(err.Error(), "schema left at version 2") {
+		t.Fatalf("migrate up = %d, %v; want 1 and a failure at version 3", n, err)
+	}
+	if _, err := db.Exec(`SELECT 1 FROM gadgets`); err == nil {
+		t.Error("failed migration left gadgets behind")
+	}
+	if _, err := db.Exec(`SELECT 1 FROM widgets`); err != nil {
+		t.Errorf("widgets missing: %v", err)
+	}
+
+	st, err := db.migrateDown(list)
+	if err != nil || st.Version != 2 {
+		t.Fatalf("migrate down = %+v, %v; want version 2", st, err)
+	}
+	if _, err := db.Exec(`SELECT 1 FROM widgets`); err == nil {
+		t.Error("widgets still present after down")
+	}
+	if _, err := db.migrateDown(list); err == nil || !strings.Contains(err.Error(), "cannot be reverted") {
+		t.Errorf("reverting baseline = %v, want an error", err)
+	}
+
+	status, err := db.Migrations()
+	if err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	if len(status) != len(migrations) || status[0].AppliedAt == nil {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+// TestBaselineFrozen fails when the baseline snapshot is edited. Databases
+// already at version 1 never see such an edit; put it in a new migration.
+func TestBaselineFrozen(t *testing.T) {
+	for name, tc := range map[string]struct{ schema, sum string }{
+		"sqlite":   {baselineSQLite, "900fff96215e5f6705d51d6cf11cd53992c51b67afdbda488b745843c6f26a3b"},
+		"postgres": {baselinePostgres, "e7232947d08e490fe5d2fc45792b81752f58f5237ba927d5a75cf864ae808f38"},
+	} {
+		if got := fmt.Sprintf("%x", sha256.Sum256([]byte(tc.schema))); got != tc.sum {
+			t.Errorf("%s baseline changed (sha256 %s); add a migration instead", name, got)
+		}
+	}
+}
+
+func TestMigrateNewerSchema(t *testing.T) {
+	db := testDB(t)
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (99, 'from the future')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := db.Migrate(); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Errorf("migrate = %v, want a newer-schema error", err)
+	}
+	status, err := db.Migrations()
+	if err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	if last := status[len(status)-1]; last.Version != 99 || last.Name != "from the future" {
+		t.Errorf("unknown migration not reported: %+v", last)
+	}
+}
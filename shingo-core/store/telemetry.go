@@ -0,0 +1,148 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RobotSnapshot is a downsampled robot status sample.
+type RobotSnapshot struct {
+	ID         int64     `json:"id"`
+	VehicleID  string    `json:"vehicle_id"`
+	Fleet      string    `json:"fleet"`
+	State      string    `json:"state"`
+	Battery    float64   `json:"battery"`
+	Charging   bool      `json:"charging"`
+	X          float64   `json:"x"`
+	Y          float64   `json:"y"`
+	Station    string    `json:"station"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// RobotInterval is a span of time a robot spent in one state. EndedAt is nil
+// for the robot's current state.
+type RobotInterval struct {
+	ID        int64      `json:"id"`
+	VehicleID string     `json:"vehicle_id"`
+	State     string     `json:"state"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// InsertRobotSnapshot records a robot status sample.
+func (db *DB) InsertRobotSnapshot(s *RobotSnapshot) error {
+	result, err := db.Exec(db.Q(`INSERT INTO robot_snapshots (vehicle_id, fleet, state, battery, charging, x, y, station, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		s.VehicleID, s.Fleet, s.State, s.Battery, s.Charging, s.X, s.Y, s.Station, db.timeArg(&s.RecordedAt))
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	s.ID = id
+	return nil
+}
+
+// ListRobotSnapshots returns a robot's samples recorded in [from, to), oldest
+// first.
+func (db *DB) ListRobotSnapshots(vehicleID string, from, to time.Time) ([]*RobotSnapshot, error) {
+	rows, err := db.Query(db.Q(`SELECT id, vehicle_id, fleet, state, battery, charging, x, y, station, recorded_at
+		FROM robot_snapshots WHERE vehicle_id=? AND recorded_at>=? AND recorded_at<? ORDER BY recorded_at, id`),
+		vehicleID, db.timeArg(&from), db.timeArg(&to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var snaps []*RobotSnapshot
+	for rows.Next() {
+		var s RobotSnapshot
+		var recordedAt any
+		if err := rows.Scan(&s.ID, &s.VehicleID, &s.Fleet, &s.State, &s.Battery, &s.Charging, &s.X, &s.Y, &s.Station, &recordedAt); err != nil {
+			return nil, err
+		}
+		s.RecordedAt = parseLocalTime(recordedAt)
+		snaps = append(snaps, &s)
+	}
+	return snaps, rows.Err()
+}
+
+// LastRobotSnapshotTime returns when the most recent sample was recorded, or
+// nil if there are none.
+func (db *DB) LastRobotSnapshotTime() (*time.Time, error) {
+	var at any
+	if err := db.QueryRow(`SELECT MAX(recorded_at) FROM robot_snapshots`).Scan(&at); err != nil {
+		return nil, err
+	}
+	return parseLocalTimePtr(at), nil
+}
+
+// StartRobotInterval closes a robot's open state interval at the given time
+// and opens a new one in state.
+func (db *DB) StartRobotInterval(vehicleID, state string, at time.Time) error {
+	return db.WithTx(func(tx *Tx) error {
+		if _, err := tx.Exec(tx.Q(`UPDATE robot_state_intervals SET ended_at=? WHERE vehicle_id=? AND ended_at IS NULL`),
+			db.timeArg(&at), vehicleID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(tx.Q(`INSERT INTO robot_state_intervals (vehicle_id, state, started_at) VALUES (?, ?, ?)`),
+			vehicleID, state, db.timeArg(&at))
+		return err
+	})
+}
+
+// CloseRobotIntervals ends every open state interval at the given time.
+func (db *DB) CloseRobotIntervals(at time.Time) error {
+	_, err := db.Exec(db.Q(`UPDATE robot_state_intervals SET ended_at=? WHERE ended_at IS NULL`), db.timeArg(&at))
+	return err
+}
+
+// ListRobotIntervals returns the state intervals that overlap [from, to),
+// ordered by robot and start time.
+func (db *DB) ListRobotIntervals(from, to time.Time) ([]*RobotInterval, error) {
+	rows, err := db.Query(db.Q(`SELECT id, vehicle_id, state, started_at, ended_at FROM robot_state_intervals
+		WHERE started_at<? AND (ended_at IS NULL OR ended_at>?) ORDER BY vehicle_id, started_at, id`),
+		db.timeArg(&to), db.timeArg(&from))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRobotIntervals(rows)
+}
+
+func scanRobotIntervals(rows *sql.Rows) ([]*RobotInterval, error) {
+	var intervals []*RobotInterval
+	for rows.Next() {
+		var iv RobotInterval
+		var startedAt, endedAt any
+		if err := rows.Scan(&iv.ID, &iv.VehicleID, &iv.State, &startedAt, &endedAt); err != nil {
+			return nil, err
+		}
+		iv.StartedAt = parseLocalTime(startedAt)
+		iv.EndedAt = parseLocalTimePtr(endedAt)
+		intervals = append(intervals, &iv)
+	}
+	return intervals, rows.Err()
+}
+
+// PruneRobotTelemetry deletes samples recorded before the cutoff and state
+// intervals that ended before it. It returns the number of rows deleted.
+func (db *DB) PruneRobotTelemetry(before time.Time) (int64, error) {
+	var n int64
+	err := db.WithTx(func(tx *Tx) error {
+		res, err := tx.Exec(tx.Q(`DELETE FROM robot_snapshots WHERE recorded_at<?`), db.timeArg(&before))
+		if err != nil {
+			return err
+		}
+		snaps, _ := res.RowsAffected()
+		res, err = tx.Exec(tx.Q(`DELETE FROM robot_state_intervals WHERE ended_at IS NOT NULL AND ended_at<?`), db.timeArg(&before))
+		if err != nil {
+			return err
+		}
+		intervals, _ := res.RowsAffected()
+		n = snaps + intervals
+		return nil
+	})
+	return n, err
+}
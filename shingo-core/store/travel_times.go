@@ -0,0 +1,90 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Travel time kinds: between two nodes, or between two zones as a fallback
+// for node pairs without history.
+const (
+	TravelNode = "node"
+	TravelZone = "zone"
+)
+
+// travelWindow is how many recent samples the learned average follows.
+// Older samples fade out, so the estimate tracks layout and traffic changes.
+const travelWindow = 20
+
+// TravelTime is the learned pickup-to-delivery duration between two nodes or
+// zones.
+type TravelTime struct {
+	ID         int64     `json:"id"`
+	Kind       string    `json:"kind"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Samples    int       `json:"samples"`
+	AvgSeconds float64   `json:"avg_seconds"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func scanTravelTime(row interface{ Scan(...any) error }) (*TravelTime, error) {
+	var tt TravelTime
+	var updatedAt any
+	if err := row.Scan(&tt.ID, &tt.Kind, &tt.From, &tt.To, &tt.Samples, &tt.AvgSeconds, &updatedAt); err != nil {
+		return nil, err
+	}
+	tt.UpdatedAt = parseTime(updatedAt)
+	return &tt, nil
+}
+
+// RecordTravelTime folds a measured duration into the average for a pair.
+func (db *DB) RecordTravelTime(kind, from, to string, seconds float64) error {
+	return db.WithTx(func(tx *Tx) error {
+		var samples int
+		var avg float64
+		err := tx.QueryRow(tx.Q(`SELECT samples, avg_seconds FROM travel_times WHERE kind=? AND from_key=? AND to_key=?`),
+			kind, from, to).Scan(&samples, &avg)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		samples++
+		avg += (seconds - avg) / float64(min(samples, travelWindow))
+		_, err = tx.Exec(tx.Q(`
+			INSERT INTO travel_times (kind, from_key, to_key, samples, avg_seconds)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(kind, from_key, to_key) DO UPDATE SET
+				samples = excluded.samples,
+				avg_seconds = excluded.avg_seconds,
+				updated_at = datetime('now','localtime')
+		`), kind, from, to, samples, avg)
+		return err
+	})
+}
+
+// GetTravelTime returns the learned duration for a pair, or sql.ErrNoRows if
+// there is no history.
+func (db *DB) GetTravelTime(kind, from, to string) (*TravelTime, error) {
+	row := db.QueryRow(db.Q(`SELECT id, kind, from_key, to_key, samples, avg_seconds, updated_at
+		FROM travel_times WHERE kind=? AND from_key=? AND to_key=?`), kind, from, to)
+	return scanTravelTime(row)
+}
+
+func (db *DB) ListTravelTimes() ([]*TravelTime, error) {
+	rows, err := db.Query(`SELECT id, kind, from_key, to_key, samples, avg_seconds, updated_at
+		FROM travel_times ORDER BY kind, from_key, to_key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*TravelTime
+	for rows.Next() {
+		tt, err := scanTravelTime(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, tt)
+	}
+	return out, rows.Err()
+}
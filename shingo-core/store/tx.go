@@ -0,0 +1,50 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Tx is a database transaction that rewrites queries for the open dialect.
+// Inside WithTx every statement must go through the Tx: SQLite runs on a
+// single connection, so using the DB directly would block on the open tx.
+type Tx struct {
+	*sql.Tx
+	db *DB
+}
+
+// Q rewrites a query for the dialect of the underlying DB.
+func (tx *Tx) Q(query string) string {
+	return tx.db.Q(query)
+}
+
+// execer is satisfied by both *DB and *Tx, so single statements can share
+// helpers with multi-statement transactions.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Q(query string) string
+}
+
+// WithTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise.
+func (db *DB) WithTx(fn func(tx *Tx) error) error {
+	sqlTx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	tx := &Tx{Tx: sqlTx, db: db}
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
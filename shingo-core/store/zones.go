@@ -0,0 +1,122 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// ExclusiveZone is a zone only one party may work in at a time, such as a
+// narrow aisle or a press area during a die change. While locked, core holds
+// the zone's fleet mutex groups and holds orders to or from the zone.
+type ExclusiveZone struct {
+	ID          int64      `json:"id"`
+	Zone        string     `json:"zone"`
+	MutexGroups string     `json:"mutex_groups"` // comma-separated fleet mutex groups; empty means one named after the zone
+	Locked      bool       `json:"locked"`
+	LockReason  string     `json:"lock_reason"`
+	LockedBy    string     `json:"locked_by"`
+	LockedAt    *time.Time `json:"locked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Groups returns the fleet mutex groups to occupy while the zone is locked.
+func (z *ExclusiveZone) Groups() []string {
+	var groups []string
+	for _, g := range strings.Split(z.MutexGroups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) == 0 {
+		groups = []string{z.Zone}
+	}
+	return groups
+}
+
+const exclusiveZoneSelectCols = `id, zone, mutex_groups, locked, lock_reason, locked_by, locked_at, created_at, updated_at`
+
+func scanExclusiveZone(row interface{ Scan(...any) error }) (*ExclusiveZone, error) {
+	var z ExclusiveZone
+	var lockedAt, createdAt, updatedAt any
+	if err := row.Scan(&z.ID, &z.Zone, &z.MutexGroups, &z.Locked, &z.LockReason, &z.LockedBy, &lockedAt, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	z.LockedAt = parseLocalTimePtr(lockedAt)
+	z.CreatedAt = parseTime(createdAt)
+	z.UpdatedAt = parseTime(updatedAt)
+	return &z, nil
+}
+
+// SaveExclusiveZone marks a zone exclusive, or updates its mutex groups if it
+// already is. The lock state is left as it is.
+func (db *DB) SaveExclusiveZone(zone, mutexGroups string) error {
+	_, err := db.Exec(db.Q(`
+		INSERT INTO exclusive_zones (zone, mutex_groups)
+		VALUES (?, ?)
+		ON CONFLICT(zone) DO UPDATE SET
+			mutex_groups = excluded.mutex_groups,
+			updated_at = datetime('now','localtime')
+	`), zone, mutexGroups)
+	return err
+}
+
+// DeleteExclusiveZone stops treating a zone as exclusive.
+func (db *DB) DeleteExclusiveZone(zone string) error {
+	_, err := db.Exec(db.Q(`DELETE FROM exclusive_zones WHERE zone=?`), zone)
+	return err
+}
+
+func (db *DB) GetExclusiveZone(zone string) (*ExclusiveZone, error) {
+	row := db.QueryRow(db.Q(`SELECT `+exclusiveZoneSelectCols+` FROM exclusive_zones WHERE zone=?`), zone)
+	return scanExclusiveZone(row)
+}
+
+func (db *DB) ListExclusiveZones() ([]*ExclusiveZone, error) {
+	rows, err := db.Query(`SELECT ` + exclusiveZoneSelectCols + ` FROM exclusive_zones ORDER BY zone`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var zones []*ExclusiveZone
+	for rows.Next() {
+		z, err := scanExclusiveZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+// SetZoneLock locks or unlocks an exclusive zone.
+func (db *DB) SetZoneLock(zone string, locked bool, reason, lockedBy string) error {
+	var lockedAt *time.Time
+	if locked {
+		now := time.Now()
+		lockedAt = &now
+	} else {
+		reason, lockedBy = "", ""
+	}
+	_, err := db.Exec(db.Q(`UPDATE exclusive_zones SET locked=?, lock_reason=?, locked_by=?, locked_at=?, updated_at=datetime('now','localtime') WHERE zone=?`),
+		locked, reason, lockedBy, db.timeArg(lockedAt), zone)
+	return err
+}
+
+// LockedZones returns the names of all locked zones.
+func (db *DB) LockedZones() (map[string]bool, error) {
+	rows, err := db.Query(db.Q(`SELECT zone FROM exclusive_zones WHERE locked=?`), true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	zones := make(map[string]bool)
+	for rows.Next() {
+		var zone string
+		if err := rows.Scan(&zone); err != nil {
+			return nil, err
+		}
+		zones[zone] = true
+	}
+	return zones, rows.Err()
+}
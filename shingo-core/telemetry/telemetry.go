@@ -0,0 +1,271 @@
+// Package telemetry records robot status history and reports how robots
+// spent their time: busy, idle, charging, in error or offline, per shift.
+package telemetry
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"shingocore/dispatch"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+// Robot states recorded in state intervals. Busy, paused, ready, error and
+// offline come from fleet.RobotStatus.State; charging is split out of them.
+const (
+	StateBusy     = "busy"
+	StateReady    = "ready"
+	StatePaused   = "paused"
+	StateCharging = "charging"
+	StateError    = "error"
+	StateOffline  = "offline"
+)
+
+// StateOf returns the state a robot status is recorded under.
+func StateOf(r fleet.RobotStatus) string {
+	switch {
+	case !r.Connected:
+		return StateOffline
+	case r.IsError:
+		return StateError
+	case r.Charging && !r.Busy:
+		return StateCharging
+	}
+	return r.State()
+}
+
+type last struct {
+	state   string
+	sampled time.Time
+}
+
+// Recorder stores robot status refreshes: a snapshot per robot at most once
+// per sample interval, and a new state interval whenever a robot's state
+// changes.
+type Recorder struct {
+	db          *store.DB
+	sampleEvery time.Duration
+	DebugLog    func(string, ...any)
+
+	mu   sync.Mutex
+	last map[string]*last // vehicle -> last recorded state and sample time
+}
+
+// NewRecorder creates a recorder that samples each robot at most once per
+// sampleEvery. State changes are always recorded.
+func NewRecorder(db *store.DB, sampleEvery time.Duration) *Recorder {
+	return &Recorder{db: db, sampleEvery: sampleEvery, last: make(map[string]*last)}
+}
+
+func (r *Recorder) dbg(format string, args ...any) {
+	if fn := r.DebugLog; fn != nil {
+		fn(format, args...)
+	}
+}
+
+// Resume ends the state intervals left open by the previous run at its last
+// sample, so the time core was down is reported as offline rather than as
+// whatever state each robot was last seen in.
+func (r *Recorder) Resume() error {
+	at, err := r.db.LastRobotSnapshotTime()
+	if err != nil || at == nil {
+		return err
+	}
+	return r.db.CloseRobotIntervals(*at)
+}
+
+// Prune deletes telemetry older than the retention period.
+func (r *Recorder) Prune(now time.Time, retention time.Duration) {
+	n, err := r.db.PruneRobotTelemetry(now.Add(-retention))
+	if err != nil {
+		log.Printf("telemetry: prune: %v", err)
+		return
+	}
+	if n > 0 {
+		r.dbg("telemetry: pruned %d rows older than %s", n, retention)
+	}
+}
+
+// Record stores a robot status refresh. Robots missing from the refresh are
+// recorded as offline.
+func (r *Recorder) Record(robots []fleet.RobotStatus, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]bool, len(robots))
+	for _, rb := range robots {
+		seen[rb.VehicleID] = true
+		state := StateOf(rb)
+		l := r.last[rb.VehicleID]
+		changed := l == nil || l.state != state
+		if changed {
+			if err := r.db.StartRobotInterval(rb.VehicleID, state, now); err != nil {
+				log.Printf("telemetry: record %s state %s: %v", rb.VehicleID, state, err)
+				continue
+			}
+			if l != nil {
+				r.dbg("telemetry: %s %s -> %s", rb.VehicleID, l.state, state)
+			}
+		}
+		if !changed && now.Sub(l.sampled) < r.sampleEvery {
+			continue
+		}
+		snap := &store.RobotSnapshot{
+			VehicleID:  rb.VehicleID,
+			Fleet:      rb.Fleet,
+			State:      state,
+			Battery:    rb.BatteryLevel,
+			Charging:   rb.Charging,
+			X:          rb.X,
+			Y:          rb.Y,
+			Station:    rb.CurrentStation,
+			RecordedAt: now,
+		}
+		if err := r.db.InsertRobotSnapshot(snap); err != nil {
+			log.Printf("telemetry: record %s snapshot: %v", rb.VehicleID, err)
+		}
+		r.last[rb.VehicleID] = &last{state: state, sampled: now}
+	}
+
+	for id, l := range r.last {
+		if seen[id] || l.state == StateOffline {
+			continue
+		}
+		if err := r.db.StartRobotInterval(id, StateOffline, now); err != nil {
+			log.Printf("telemetry: record %s state %s: %v", id, StateOffline, err)
+			continue
+		}
+		r.dbg("telemetry: %s no longer reported by the fleet", id)
+		l.state = StateOffline
+	}
+}
+
+// Shift is a named daily window utilization is reported for.
+type Shift struct {
+	Name   string
+	Window dispatch.DowntimeWindow
+}
+
+// Period is one occurrence of a shift.
+type Period struct {
+	Shift    string
+	From, To time.Time
+}
+
+// Periods returns the shifts that start on the given local day. Without
+// shifts the whole day is one period.
+func Periods(day time.Time, shifts []Shift) []Period {
+	day = day.Local()
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	if len(shifts) == 0 {
+		return []Period{{Shift: "day", From: midnight, To: midnight.AddDate(0, 0, 1)}}
+	}
+	var out []Period
+	for _, s := range shifts {
+		from := midnight.Add(s.Window.Start)
+		to := midnight.Add(s.Window.End)
+		if s.Window.End <= s.Window.Start {
+			to = to.AddDate(0, 0, 1)
+		}
+		if !s.Window.Contains(from) {
+			continue // not worked on this weekday
+		}
+		out = append(out, Period{Shift: s.Name, From: from, To: to})
+	}
+	return out
+}
+
+// Usage is how one robot spent one shift. Times are in seconds.
+type Usage struct {
+	VehicleID   string    `json:"vehicle_id"`
+	Shift       string    `json:"shift"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Busy        float64   `json:"busy_seconds"`
+	Idle        float64   `json:"idle_seconds"` // ready or paused
+	Charging    float64   `json:"charging_seconds"`
+	Error       float64   `json:"error_seconds"`
+	Offline     float64   `json:"offline_seconds"` // offline or not recorded
+	Errors      int       `json:"errors"`          // times the robot went into error
+	Utilization float64   `json:"utilization"`     // busy share of the elapsed shift, percent
+}
+
+// Utilization sums state intervals into per-robot usage for each period.
+// Open intervals run until now, and periods are cut off at now.
+func Utilization(intervals []*store.RobotInterval, periods []Period, now time.Time) []Usage {
+	byRobot := make(map[string][]*store.RobotInterval)
+	var robots []string
+	for _, iv := range intervals {
+		if byRobot[iv.VehicleID] == nil {
+			robots = append(robots, iv.VehicleID)
+		}
+		byRobot[iv.VehicleID] = append(byRobot[iv.VehicleID], iv)
+	}
+	sort.Strings(robots)
+
+	var out []Usage
+	for _, p := range periods {
+		end := p.To
+		if now.Before(end) {
+			end = now
+		}
+		if !end.After(p.From) {
+			continue
+		}
+		elapsed := end.Sub(p.From).Seconds()
+		for _, id := range robots {
+			u := Usage{VehicleID: id, Shift: p.Shift, From: p.From, To: p.To}
+			covered := 0.0
+			for _, iv := range byRobot[id] {
+				ivEnd := now
+				if iv.EndedAt != nil {
+					ivEnd = *iv.EndedAt
+				}
+				from, to := maxTime(iv.StartedAt, p.From), minTime(ivEnd, end)
+				if !to.After(from) {
+					continue
+				}
+				secs := to.Sub(from).Seconds()
+				covered += secs
+				switch iv.State {
+				case StateBusy:
+					u.Busy += secs
+				case StateReady, StatePaused:
+					u.Idle += secs
+				case StateCharging:
+					u.Charging += secs
+				case StateError:
+					u.Error += secs
+				default:
+					u.Offline += secs
+				}
+				if iv.State == StateError && !iv.StartedAt.Before(p.From) {
+					u.Errors++
+				}
+			}
+			if gap := elapsed - covered; gap > 0 {
+				u.Offline += gap
+			}
+			u.Utilization = u.Busy / elapsed * 100
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
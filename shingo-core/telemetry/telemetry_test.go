@@ -0,0 +1,84 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"shingocore/dispatch"
+	"shingocore/fleet"
+	"shingocore/store"
+)
+
+func interval(vehicle, state string, from, to time.Time) *store.RobotInterval {
+	iv := &store.RobotInterval{VehicleID: vehicle, State: state, StartedAt: from}
+	if !to.IsZero() {
+		iv.EndedAt = &to
+	}
+	return iv
+}
+
+func TestStateOf(t *testing.T) {
+	r := fleet.RobotStatus{Connected: true, Available: false, Charging: true}
+	if s := StateOf(r); s != StateCharging {
+		t.Errorf("charging robot = %s, want %s", s, StateCharging)
+	}
+	r.Busy = true
+	if s := StateOf(r); s != StateBusy {
+		t.Errorf("busy robot on charge = %s, want %s", s, StateBusy)
+	}
+	r.IsError = true
+	if s := StateOf(r); s != StateError {
+		t.Errorf("robot in error = %s, want %s", s, StateError)
+	}
+}
+
+func TestUtilizationPerShift(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local) // a Monday
+	first, _ := dispatch.ParseDowntimeWindow([]string{"mon", "tue"}, "06:00", "14:00")
+	night, _ := dispatch.ParseDowntimeWindow([]string{"sun"}, "22:00", "06:00")
+	periods := Periods(day, []Shift{{Name: "first", Window: first}, {Name: "night", Window: night}})
+	if len(periods) != 1 || periods[0].Shift != "first" {
+		t.Fatalf("periods = %+v, want only the first shift on a Monday", periods)
+	}
+
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	intervals := []*store.RobotInterval{
+		interval("AMB-01", StateReady, at(5, 0), at(7, 0)), // one hour before the shift is not counted
+		interval("AMB-01", StateBusy, at(7, 0), at(9, 0)),
+		interval("AMB-01", StateError, at(9, 0), at(9, 30)),
+		interval("AMB-01", StateBusy, at(9, 30), at(10, 0)),
+		interval("AMB-01", StateError, at(10, 0), at(10, 30)),
+		interval("AMB-01", StateCharging, at(10, 30), time.Time{}), // still charging
+	}
+	usage := Utilization(intervals, periods, at(12, 0))
+	if len(usage) != 1 {
+		t.Fatalf("usage = %+v, want one row", usage)
+	}
+	u := usage[0]
+	hours := func(s float64) float64 { return s / 3600 }
+	// 06:00-12:00 elapsed: 1h idle, 2.5h busy, 1h error, 1.5h charging
+	if hours(u.Idle) != 1 || hours(u.Busy) != 2.5 || hours(u.Error) != 1 || hours(u.Charging) != 1.5 || u.Offline != 0 {
+		t.Errorf("usage = %+v", u)
+	}
+	if u.Errors != 2 {
+		t.Errorf("errors = %d, want 2", u.Errors)
+	}
+	if u.Utilization < 41.6 || u.Utilization > 41.7 {
+		t.Errorf("utilization = %.2f, want 41.67", u.Utilization)
+	}
+}
+
+func TestUtilizationCountsGapsOffline(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+	periods := Periods(day, nil)
+	if len(periods) != 1 || !periods[0].To.Equal(day.AddDate(0, 0, 1)) {
+		t.Fatalf("periods = %+v, want the whole day", periods)
+	}
+	intervals := []*store.RobotInterval{
+		interval("AMB-02", StateBusy, day.Add(8*time.Hour), day.Add(20*time.Hour)),
+	}
+	u := Utilization(intervals, periods, day.AddDate(0, 0, 2))[0]
+	if u.Busy != 12*3600 || u.Offline != 12*3600 || u.Utilization != 50 {
+		t.Errorf("usage = %+v, want 12h busy and 12h offline", u)
+	}
+}
@@ -31,6 +31,8 @@ func (h *Handlers) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	msgOK := h.engine.MsgClient().IsConnected()
 	redisOK := h.engine.NodeState().Ping() == nil
 
+	inFlight, _ := h.engine.Dispatcher().InFlightUsage()
+
 	trackerCount := 0
 	if t := h.engine.Tracker(); t != nil {
 		trackerCount = t.ActiveCount()
@@ -40,6 +42,8 @@ func (h *Handlers) handleDashboard(w http.ResponseWriter, r *http.Request) {
 		"Page":          "dashboard",
 		"ActiveOrders":  activeOrders,
 		"StatusCounts":  statusCounts,
+		"QueuedOrders":  statusCounts["queued"],
+		"InFlight":      inFlight,
 		"TotalOrders":   len(activeOrders),
 		"TotalNodes":    len(nodes),
 		"EnabledNodes":  enabledNodes,
@@ -0,0 +1,32 @@
+package www
+
+import (
+	"net/http"
+
+	"shingocore/devices"
+)
+
+func (h *Handlers) handleDevices(w http.ResponseWriter, r *http.Request) {
+	var devs []devices.View
+	var alerts []devices.Alert
+	if m := h.engine.Devices(); m != nil {
+		devs = m.Devices()
+		alerts = m.Alerts()
+	}
+	data := map[string]any{
+		"Page":          "devices",
+		"Devices":       devs,
+		"Alerts":        alerts,
+		"Authenticated": h.isAuthenticated(r),
+	}
+	h.render(w, "devices.html", data)
+}
+
+func (h *Handlers) apiListDevices(w http.ResponseWriter, r *http.Request) {
+	m := h.engine.Devices()
+	if m == nil {
+		h.jsonOK(w, map[string]any{"devices": []devices.View{}, "alerts": []devices.Alert{}})
+		return
+	}
+	h.jsonOK(w, map[string]any{"devices": m.Devices(), "alerts": m.Alerts()})
+}
@@ -0,0 +1,204 @@
+package www
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+
+	"shingocore/fleet"
+	"shingocore/nodestate"
+	"shingocore/store"
+)
+
+// mapMargin pads the map's view box around the outermost scene point, in meters.
+const mapMargin = 2.0
+
+// mapArea is the bounding box of one scene area, in SVG coordinates.
+type mapArea struct {
+	Name       string
+	X, Y, W, H float64
+}
+
+// mapPoint is a scene point with no ShinGo node behind it: an advanced point,
+// or a bin location that has not been made a node.
+type mapPoint struct {
+	Instance string
+	Class    string
+	Label    string
+	Area     string
+	X, Y     float64
+}
+
+// mapNode is a ShinGo node placed on the map at its vendor location.
+type mapNode struct {
+	ID       int64   `json:"id"`
+	Name     string  `json:"name"`
+	Instance string  `json:"instance"`
+	Zone     string  `json:"zone"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Count    int     `json:"count"`
+	Capacity int     `json:"capacity"`
+	Enabled  bool    `json:"enabled"`
+	Fill     string  `json:"fill"`
+}
+
+// plantMap is the scene laid out for the map page. Scene coordinates are
+// meters with Y pointing up and SVG's Y points down, so every position on
+// the map is drawn at (x, -y).
+type plantMap struct {
+	ViewBox  string
+	Areas    []mapArea
+	Points   []mapPoint
+	Nodes    []mapNode
+	Unplaced []string // enabled nodes with no scene point
+}
+
+// buildPlantMap places the synced scene points and the nodes bound to them.
+func buildPlantMap(points []*store.ScenePoint, nodes []*store.Node, states map[int64]*nodestate.NodeState) *plantMap {
+	pm := &plantMap{}
+	byInstance := make(map[string]*store.ScenePoint, len(points))
+	for _, sp := range points {
+		byInstance[sp.InstanceName] = sp
+	}
+
+	placed := make(map[string]bool)
+	for _, n := range nodes {
+		sp := byInstance[n.VendorLocation]
+		if n.VendorLocation == "" || sp == nil {
+			if n.Enabled {
+				pm.Unplaced = append(pm.Unplaced, n.Name)
+			}
+			continue
+		}
+		placed[sp.InstanceName] = true
+		pm.Nodes = append(pm.Nodes, nodeOnMap(n, sp, states[n.ID]))
+	}
+
+	all := newBounds()
+	areas := make(map[string]*bounds)
+	var areaNames []string
+	for _, sp := range points {
+		x, y := sp.PosX, -sp.PosY
+		all.add(x, y)
+		a := areas[sp.AreaName]
+		if a == nil {
+			a = newBounds()
+			areas[sp.AreaName] = a
+			areaNames = append(areaNames, sp.AreaName)
+		}
+		a.add(x, y)
+
+		if !placed[sp.InstanceName] {
+			pm.Points = append(pm.Points, mapPoint{
+				Instance: sp.InstanceName,
+				Class:    sp.ClassName,
+				Label:    sp.Label,
+				Area:     sp.AreaName,
+				X:        x,
+				Y:        y,
+			})
+		}
+	}
+
+	sort.Strings(areaNames)
+	for _, name := range areaNames {
+		a := areas[name]
+		// Pad the box so points on its edge are not drawn on the outline.
+		pm.Areas = append(pm.Areas, mapArea{Name: name, X: a.minX - 1, Y: a.minY - 1, W: a.maxX - a.minX + 2, H: a.maxY - a.minY + 2})
+	}
+
+	if len(points) == 0 {
+		pm.ViewBox = "0 0 10 10"
+	} else {
+		pm.ViewBox = fmt.Sprintf("%.2f %.2f %.2f %.2f",
+			all.minX-mapMargin, all.minY-mapMargin, all.maxX-all.minX+2*mapMargin, all.maxY-all.minY+2*mapMargin)
+	}
+	return pm
+}
+
+type bounds struct{ minX, minY, maxX, maxY float64 }
+
+func newBounds() *bounds {
+	return &bounds{minX: math.Inf(1), minY: math.Inf(1), maxX: math.Inf(-1), maxY: math.Inf(-1)}
+}
+
+func (b *bounds) add(x, y float64) {
+	b.minX, b.maxX = math.Min(b.minX, x), math.Max(b.maxX, x)
+	b.minY, b.maxY = math.Min(b.minY, y), math.Max(b.maxY, y)
+}
+
+func nodeOnMap(n *store.Node, sp *store.ScenePoint, st *nodestate.NodeState) mapNode {
+	mn := mapNode{
+		ID:       n.ID,
+		Name:     n.Name,
+		Instance: sp.InstanceName,
+		Zone:     n.Zone,
+		X:        sp.PosX,
+		Y:        -sp.PosY,
+		Capacity: n.Capacity,
+		Enabled:  n.Enabled,
+	}
+	if st != nil {
+		mn.Count = st.ItemCount
+	}
+	mn.Fill = occupancyFill(mn.Count, mn.Capacity)
+	return mn
+}
+
+// loadPlantMap reads the scene, nodes and node states for the map.
+func (h *Handlers) loadPlantMap() (*plantMap, error) {
+	points, err := h.engine.DB().ListScenePoints()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := h.engine.DB().ListNodes()
+	if err != nil {
+		return nil, err
+	}
+	states, err := h.engine.NodeState().GetAllNodeStates()
+	if err != nil {
+		log.Printf("map: node states: %v", err)
+	}
+	return buildPlantMap(points, nodes, states), nil
+}
+
+func (h *Handlers) handleMap(w http.ResponseWriter, r *http.Request) {
+	pm, err := h.loadPlantMap()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var robots []fleet.RobotStatus
+	if rl, ok := h.engine.Fleet().(fleet.RobotLister); ok {
+		robots, err = rl.GetRobotsStatus()
+		if err != nil {
+			log.Printf("map: fleet error: %v", err)
+		}
+	}
+	nodes, _ := h.engine.DB().ListNodes()
+	data := map[string]any{
+		"Page":          "map",
+		"Map":           pm,
+		"Robots":        robots,
+		"AllNodes":      nodes,
+		"Authenticated": h.isAuthenticated(r),
+	}
+	h.render(w, "map.html", data)
+}
+
+// apiMapNodes returns the placed nodes with their current occupancy, for
+// recoloring the map when payloads move.
+func (h *Handlers) apiMapNodes(w http.ResponseWriter, r *http.Request) {
+	pm, err := h.loadPlantMap()
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if pm.Nodes == nil {
+		pm.Nodes = []mapNode{}
+	}
+	h.jsonOK(w, pm.Nodes)
+}
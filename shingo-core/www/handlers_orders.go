@@ -2,8 +2,14 @@ package www
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+
+	"shingo/protocol"
+	"shingocore/engine"
+	"shingocore/fleet"
+	"shingocore/fleet/seerrds"
 )
 
 func (h *Handlers) handleOrders(w http.ResponseWriter, r *http.Request) {
@@ -18,10 +24,11 @@ func (h *Handlers) handleOrders(w http.ResponseWriter, r *http.Request) {
 	orders, _ := h.engine.DB().ListOrders(status, limit)
 
 	data := map[string]any{
-		"Page":          "orders",
-		"Orders":        orders,
-		"FilterStatus":  status,
-		"Authenticated": h.isAuthenticated(r),
+		"Page":           "orders",
+		"Orders":         orders,
+		"FilterStatus":   status,
+		"FleetReconcile": h.engine.LastFleetReconcile(),
+		"Authenticated":  h.isAuthenticated(r),
 	}
 	h.render(w, "orders.html", data)
 }
@@ -110,3 +117,53 @@ func (h *Handlers) apiSetOrderPriority(w http.ResponseWriter, r *http.Request) {
 	}
 	h.jsonOK(w, map[string]string{"status": "ok"})
 }
+
+func (h *Handlers) apiFleetReconcile(w http.ResponseWriter, r *http.Request) {
+	report, err := h.engine.ReconcileFleet()
+	switch {
+	case errors.Is(err, engine.ErrReconcileUnsupported):
+		h.jsonError(w, err.Error(), http.StatusNotImplemented)
+		return
+	case errors.Is(err, engine.ErrReconcileBusy):
+		h.jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	h.jsonOK(w, report)
+}
+
+func (h *Handlers) apiLastFleetReconcile(w http.ResponseWriter, r *http.Request) {
+	h.jsonOK(w, h.engine.LastFleetReconcile())
+}
+
+// apiFleetPollStats reports RDS poller call counts and latency, for tuning
+// the poll intervals.
+func (h *Handlers) apiFleetPollStats(w http.ResponseWriter, r *http.Request) {
+	adapter, ok := fleet.As[*seerrds.Adapter](h.engine.Fleet())
+	if !ok {
+		h.jsonError(w, "fleet backend does not poll", http.StatusNotFound)
+		return
+	}
+	h.jsonOK(w, adapter.PollStats())
+}
+
+// apiPlanOrder dry-runs an order request: it reports the source and
+// destination core would choose, and why other candidates were passed over,
+// without creating the order or calling the fleet.
+func (h *Handlers) apiPlanOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		StationID string `json:"station_id"`
+		protocol.OrderRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.OrderType == "" {
+		h.jsonError(w, "order_type is required", http.StatusBadRequest)
+		return
+	}
+	if req.StationID == "" {
+		req.StationID = "core-plan"
+	}
+	h.jsonOK(w, h.engine.Dispatcher().PlanOrder(req.StationID, &req.OrderRequest))
+}
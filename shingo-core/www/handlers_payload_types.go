@@ -23,6 +23,7 @@ func (h *Handlers) handlePayloadTypeCreate(w http.ResponseWriter, r *http.Reques
 		Description:         r.FormValue("description"),
 		FormFactor:          r.FormValue("form_factor"),
 		DefaultManifestJSON: manifest,
+		SourceStrategy:      r.FormValue("source_strategy"),
 	}
 
 	if err := h.engine.DB().CreatePayloadType(pt); err != nil {
@@ -60,6 +61,7 @@ func (h *Handlers) handlePayloadTypeUpdate(w http.ResponseWriter, r *http.Reques
 	pt.Description = r.FormValue("description")
 	pt.FormFactor = r.FormValue("form_factor")
 	pt.DefaultManifestJSON = manifest
+	pt.SourceStrategy = r.FormValue("source_strategy")
 
 	if err := h.engine.DB().UpdatePayloadType(pt); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
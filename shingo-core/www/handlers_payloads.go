@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strconv"
 
+	"shingocore/dispatch"
+	"shingocore/engine"
 	"shingocore/store"
 )
 
@@ -17,6 +19,7 @@ func (h *Handlers) handlePayloads(w http.ResponseWriter, r *http.Request) {
 		"Page":          "payloads",
 		"Payloads":      payloads,
 		"PayloadTypes":  types,
+		"Strategies":    dispatch.SourceStrategies(),
 		"Nodes":         nodes,
 		"Authenticated": h.isAuthenticated(r),
 	}
@@ -55,6 +58,7 @@ func (h *Handlers) handlePayloadCreate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.emitPayloadChanged(p, "added")
 
 	http.Redirect(w, r, "/payloads", http.StatusSeeOther)
 }
@@ -98,6 +102,7 @@ func (h *Handlers) handlePayloadUpdate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.emitPayloadChanged(p, "updated")
 
 	http.Redirect(w, r, "/payloads", http.StatusSeeOther)
 }
@@ -117,6 +122,19 @@ func (h *Handlers) handlePayloadDelete(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/payloads", http.StatusSeeOther)
 }
 
+// emitPayloadChanged notifies listeners (SSE, sourcing backlog) of a manual payload edit.
+func (h *Handlers) emitPayloadChanged(p *store.Payload, action string) {
+	var nodeID int64
+	if p.NodeID != nil {
+		nodeID = *p.NodeID
+	}
+	h.engine.Events.Emit(engine.Event{Type: engine.EventPayloadChanged, Payload: engine.PayloadChangedEvent{
+		NodeID:    nodeID,
+		Action:    action,
+		PayloadID: p.ID,
+	}})
+}
+
 func (h *Handlers) apiListPayloads(w http.ResponseWriter, r *http.Request) {
 	payloads, err := h.engine.DB().ListPayloads()
 	if err != nil {
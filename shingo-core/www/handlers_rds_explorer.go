@@ -13,7 +13,7 @@ import (
 
 func (h *Handlers) handleFleetExplorer(w http.ResponseWriter, r *http.Request) {
 	baseURL := ""
-	if vp, ok := h.engine.Fleet().(fleet.VendorProxy); ok {
+	if vp, ok := fleet.As[fleet.VendorProxy](h.engine.Fleet()); ok {
 		baseURL = vp.BaseURL()
 	}
 	data := map[string]any{
@@ -31,7 +31,7 @@ func (h *Handlers) apiFleetProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vp, ok := h.engine.Fleet().(fleet.VendorProxy)
+	vp, ok := fleet.As[fleet.VendorProxy](h.engine.Fleet())
 	if !ok {
 		h.jsonError(w, "fleet backend does not support API proxy", http.StatusNotImplemented)
 		return
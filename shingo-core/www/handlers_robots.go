@@ -5,7 +5,10 @@ import (
 	"log"
 	"net/http"
 
+	"shingocore/charging"
 	"shingocore/fleet"
+	"shingocore/fleet/sim"
+	"shingocore/goods"
 )
 
 func (h *Handlers) handleRobots(w http.ResponseWriter, r *http.Request) {
@@ -17,14 +20,49 @@ func (h *Handlers) handleRobots(w http.ResponseWriter, r *http.Request) {
 			log.Printf("robots: fleet error: %v", err)
 		}
 	}
+	var charged []charging.View
+	m := h.engine.Charging()
+	if m != nil {
+		charged = m.Charging()
+	}
+	var bindings []goods.Binding
+	var mismatches []goods.Mismatch
+	g := h.engine.Goods()
+	if g != nil {
+		bindings = g.Bindings()
+		mismatches = g.Mismatches()
+	}
 	data := map[string]any{
-		"Page":          "robots",
-		"Robots":        robots,
-		"Authenticated": h.isAuthenticated(r),
+		"Page":            "robots",
+		"Robots":          robots,
+		"ChargingEnabled": m != nil,
+		"ChargingRobots":  charged,
+		"GoodsEnabled":    g != nil,
+		"GoodsBindings":   bindings,
+		"GoodsMismatches": mismatches,
+		"Authenticated":   h.isAuthenticated(r),
 	}
 	h.render(w, "robots.html", data)
 }
 
+func (h *Handlers) apiRobotsCharging(w http.ResponseWriter, r *http.Request) {
+	m := h.engine.Charging()
+	if m == nil {
+		h.jsonOK(w, []charging.View{})
+		return
+	}
+	h.jsonOK(w, m.Charging())
+}
+
+func (h *Handlers) apiRobotsGoods(w http.ResponseWriter, r *http.Request) {
+	g := h.engine.Goods()
+	if g == nil {
+		h.jsonOK(w, map[string]any{"bindings": []goods.Binding{}, "mismatches": []goods.Mismatch{}})
+		return
+	}
+	h.jsonOK(w, map[string]any{"bindings": g.Bindings(), "mismatches": g.Mismatches()})
+}
+
 func (h *Handlers) apiRobotSetAvailability(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		VehicleID string `json:"vehicle_id"`
@@ -85,3 +123,31 @@ func (h *Handlers) apiRobotForceComplete(w http.ResponseWriter, r *http.Request)
 	}
 	h.jsonOK(w, map[string]string{"status": "ok"})
 }
+
+// apiSimFail injects a failure into the simulated fleet: either a named
+// vendor order fails now, or the next n stops any robot works fail.
+func (h *Handlers) apiSimFail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		VendorOrderID string `json:"vendor_order_id"`
+		Next          int    `json:"next"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	s, ok := fleet.As[*sim.Backend](h.engine.Fleet())
+	if !ok {
+		h.jsonError(w, "fleet backend is not the simulator", http.StatusNotImplemented)
+		return
+	}
+	if req.VendorOrderID != "" {
+		if err := s.FailOrder(req.VendorOrderID); err != nil {
+			h.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Next > 0 {
+		s.FailNext(req.Next)
+	}
+	h.jsonOK(w, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,90 @@
+package www
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"shingocore/scenario"
+)
+
+// apiListScenarios returns the scripts in the scenario directory and the
+// recent runs with their logs.
+func (h *Handlers) apiListScenarios(w http.ResponseWriter, r *http.Request) {
+	runner := h.engine.Scenarios()
+	if runner == nil {
+		h.jsonOK(w, map[string]any{"enabled": false, "scripts": []string{}, "runs": []scenario.Run{}})
+		return
+	}
+	scripts := []string{}
+	if dir := h.engine.AppConfig().Scenarios.Dir; dir != "" {
+		names, err := scenario.Scripts(dir)
+		if err != nil {
+			log.Printf("scenarios: list %s: %v", dir, err)
+		}
+		scripts = append(scripts, names...)
+	}
+	h.jsonOK(w, map[string]any{"enabled": true, "scripts": scripts, "runs": runner.Runs()})
+}
+
+// apiRunScenario starts a script, either a file from the scenario directory
+// or YAML posted with the request.
+func (h *Handlers) apiRunScenario(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		File   string `json:"file"`
+		Script string `json:"script"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	runner := h.engine.Scenarios()
+	if runner == nil {
+		h.jsonError(w, "scenarios are disabled or the fleet has no simulated robots", http.StatusNotImplemented)
+		return
+	}
+
+	var s *scenario.Script
+	var err error
+	switch {
+	case req.File != "":
+		dir := h.engine.AppConfig().Scenarios.Dir
+		if dir == "" || req.File != filepath.Base(req.File) {
+			h.jsonError(w, "unknown scenario file", http.StatusBadRequest)
+			return
+		}
+		s, err = scenario.Load(filepath.Join(dir, req.File))
+	case req.Script != "":
+		s, err = scenario.Parse([]byte(req.Script))
+	default:
+		h.jsonError(w, "file or script is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.Name == "" {
+		s.Name = "ad hoc"
+	}
+
+	id, err := runner.Start(s)
+	if errors.Is(err, scenario.ErrBusy) {
+		h.jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.jsonOK(w, map[string]any{"id": id})
+}
+
+func (h *Handlers) apiStopScenario(w http.ResponseWriter, r *http.Request) {
+	if runner := h.engine.Scenarios(); runner != nil {
+		runner.Stop()
+	}
+	h.jsonOK(w, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,214 @@
+package www
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"shingocore/dispatch"
+	"shingocore/store"
+)
+
+// handleSchedules renders the scheduled orders page.
+func (h *Handlers) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, _ := h.engine.DB().ListOrderSchedules()
+	nodes, _ := h.engine.DB().ListNodes()
+	payloadTypes, _ := h.engine.DB().ListPayloadTypes()
+	data := map[string]any{
+		"Page":          "schedules",
+		"Schedules":     schedules,
+		"Nodes":         nodes,
+		"PayloadTypes":  payloadTypes,
+		"Downtime":      h.engine.AppConfig().Dispatch.Schedules.Downtime,
+		"Authenticated": h.isAuthenticated(r),
+	}
+	h.render(w, "schedules.html", data)
+}
+
+// --- Schedule API ---
+
+type scheduleRequest struct {
+	Name            string  `json:"name"`
+	OrderType       string  `json:"order_type"`
+	PayloadTypeCode string  `json:"payload_type_code"`
+	PickupNode      string  `json:"pickup_node"`
+	DeliveryNode    string  `json:"delivery_node"`
+	Quantity        float64 `json:"quantity"`
+	Priority        int     `json:"priority"`
+	StationID       string  `json:"station_id"`
+	RunAt           string  `json:"run_at"` // RFC 3339, or local "2006-01-02T15:04"
+	Cron            string  `json:"cron"`
+	Enabled         *bool   `json:"enabled"` // omitted: enabled on create, unchanged on update
+}
+
+// schedule validates a request and builds the schedule it describes, enabled
+// as given unless the request says otherwise.
+func (h *Handlers) schedule(req scheduleRequest, enabled bool) (*store.OrderSchedule, error) {
+	db := h.engine.DB()
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	switch req.OrderType {
+	case dispatch.OrderTypeRetrieve, dispatch.OrderTypeMove, dispatch.OrderTypeStore, dispatch.OrderTypeSwap:
+	default:
+		return nil, fmt.Errorf("invalid order_type %q", req.OrderType)
+	}
+	if _, err := db.GetPayloadTypeByName(req.PayloadTypeCode); err != nil {
+		return nil, fmt.Errorf("payload type %q not found", req.PayloadTypeCode)
+	}
+	for _, name := range []string{req.PickupNode, req.DeliveryNode} {
+		if name == "" {
+			continue
+		}
+		if _, err := db.GetNodeByName(name); err != nil {
+			return nil, fmt.Errorf("node %q not found", name)
+		}
+	}
+	if (req.RunAt == "") == (req.Cron == "") {
+		return nil, fmt.Errorf("set exactly one of run_at and cron")
+	}
+	if req.Quantity <= 0 {
+		req.Quantity = 1
+	}
+
+	s := &store.OrderSchedule{
+		Name:            req.Name,
+		OrderType:       req.OrderType,
+		PayloadTypeCode: req.PayloadTypeCode,
+		PickupNode:      req.PickupNode,
+		DeliveryNode:    req.DeliveryNode,
+		Quantity:        req.Quantity,
+		Priority:        req.Priority,
+		StationID:       req.StationID,
+		Cron:            req.Cron,
+		Enabled:         enabled,
+	}
+	if req.Enabled != nil {
+		s.Enabled = *req.Enabled
+	}
+	if req.RunAt != "" {
+		t, err := time.Parse(time.RFC3339, req.RunAt)
+		if err != nil {
+			if t, err = time.ParseInLocation("2006-01-02T15:04", req.RunAt, time.Local); err != nil {
+				return nil, fmt.Errorf("invalid run_at %q", req.RunAt)
+			}
+		}
+		s.RunAt = &t
+	}
+	next, err := dispatch.NextScheduleRun(s, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if s.Enabled {
+		s.NextRunAt = next
+	}
+	return s, nil
+}
+
+func (h *Handlers) apiListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.engine.DB().ListOrderSchedules()
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.jsonOK(w, schedules)
+}
+
+func (h *Handlers) apiCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s, err := h.schedule(req, true)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.engine.DB().CreateOrderSchedule(s); err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.jsonOK(w, s)
+}
+
+func (h *Handlers) apiUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.jsonError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing, err := h.engine.DB().GetOrderSchedule(id)
+	if err != nil {
+		h.jsonError(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+	s, err := h.schedule(req, existing.Enabled)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.ID = id
+	if err := h.engine.DB().UpdateOrderSchedule(s); err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.jsonOK(w, s)
+}
+
+// apiSetScheduleEnabled pauses or resumes a schedule. Resuming a recurring
+// schedule restarts it from now rather than catching up missed runs.
+func (h *Handlers) apiSetScheduleEnabled(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.jsonError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s, err := h.engine.DB().GetOrderSchedule(id)
+	if err != nil {
+		h.jsonError(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+	s.Enabled = req.Enabled
+	s.NextRunAt = nil
+	if s.Enabled {
+		if s.NextRunAt, err = dispatch.NextScheduleRun(s, time.Now()); err != nil {
+			h.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.engine.DB().UpdateOrderSchedule(s); err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.jsonOK(w, s)
+}
+
+func (h *Handlers) apiDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.jsonError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := h.engine.DB().DeleteOrderSchedule(id); err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.jsonOK(w, map[string]string{"status": "ok"})
+}
@@ -38,6 +38,7 @@ func (h *Handlers) apiTestOrderSubmit(w http.ResponseWriter, r *http.Request) {
 		OrderType       string  `json:"order_type"`
 		PickupNode      string  `json:"pickup_node"`
 		DeliveryNode    string  `json:"delivery_node"`
+		StagingNode     string  `json:"staging_node"`
 		PayloadTypeCode string  `json:"payload_type_code"`
 		Quantity        float64 `json:"quantity"`
 		Priority        int     `json:"priority"`
@@ -67,6 +68,7 @@ func (h *Handlers) apiTestOrderSubmit(w http.ResponseWriter, r *http.Request) {
 		Quantity:        req.Quantity,
 		DeliveryNode:    req.DeliveryNode,
 		PickupNode:      req.PickupNode,
+		StagingNode:     req.StagingNode,
 		Priority:        req.Priority,
 		PayloadDesc:     "test order from shingo core",
 	}
@@ -146,6 +148,50 @@ func (h *Handlers) apiTestOrderCancel(w http.ResponseWriter, r *http.Request) {
 	h.jsonOK(w, map[string]string{"status": "cancel sent", "order_uuid": req.OrderUUID})
 }
 
+func (h *Handlers) apiTestOrderRelease(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OrderUUID string `json:"order_uuid"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.OrderUUID == "" {
+		h.jsonError(w, "order_uuid is required", http.StatusBadRequest)
+		return
+	}
+
+	cfg := h.engine.AppConfig()
+	src := protocol.Address{Role: protocol.RoleEdge, Station: "core-test"}
+	dst := protocol.Address{Role: protocol.RoleCore, Station: cfg.Messaging.StationID}
+
+	env, err := protocol.NewEnvelope(protocol.TypeOrderRelease, src, dst, &protocol.OrderRelease{
+		OrderUUID: req.OrderUUID,
+		Reason:    req.Reason,
+	})
+	if err != nil {
+		h.jsonError(w, "build envelope: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := env.Encode()
+	if err != nil {
+		h.jsonError(w, "encode envelope: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	topic := cfg.Messaging.OrdersTopic
+	log.Printf("test-orders: publishing %s to %s: %s", env.Type, topic, string(data))
+
+	if err := h.engine.MsgClient().Publish(topic, data); err != nil {
+		h.jsonError(w, "publish failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.jsonOK(w, map[string]string{"status": "release sent", "order_uuid": req.OrderUUID})
+}
+
 func (h *Handlers) apiTestOrderReceipt(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		OrderUUID   string  `json:"order_uuid"`
@@ -321,7 +367,7 @@ func (h *Handlers) apiDirectOrdersList(w http.ResponseWriter, r *http.Request) {
 // --- Section C: Direct RDS Robot Command APIs ---
 
 func (h *Handlers) apiTestCommandSubmit(w http.ResponseWriter, r *http.Request) {
-	adapter, ok := h.engine.Fleet().(*seerrds.Adapter)
+	adapter, ok := fleet.As[*seerrds.Adapter](h.engine.Fleet())
 	if !ok {
 		h.jsonError(w, "fleet backend does not support direct RDS commands", http.StatusNotImplemented)
 		return
@@ -407,7 +453,7 @@ func (h *Handlers) apiTestCommandStatus(w http.ResponseWriter, r *http.Request)
 
 	var rdsDetail *rds.OrderDetail
 	if tc.CompletedAt == nil {
-		adapter, ok := h.engine.Fleet().(*seerrds.Adapter)
+		adapter, ok := fleet.As[*seerrds.Adapter](h.engine.Fleet())
 		if ok && tc.VendorOrderID != "" {
 			detail, err := adapter.RDSClient().GetOrderDetails(tc.VendorOrderID)
 			if err == nil {
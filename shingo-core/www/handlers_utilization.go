@@ -0,0 +1,79 @@
+package www
+
+import (
+	"net/http"
+	"time"
+
+	"shingocore/store"
+	"shingocore/telemetry"
+)
+
+// utilizationDay parses the ?date=YYYY-MM-DD parameter, defaulting to today.
+func utilizationDay(r *http.Request) (time.Time, error) {
+	if s := r.URL.Query().Get("date"); s != "" {
+		return time.ParseInLocation("2006-01-02", s, time.Local)
+	}
+	return time.Now(), nil
+}
+
+func (h *Handlers) handleUtilization(w http.ResponseWriter, r *http.Request) {
+	day, err := utilizationDay(r)
+	if err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	usage, err := h.engine.Utilization(day)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]any{
+		"Page":          "utilization",
+		"Date":          day.Format("2006-01-02"),
+		"Enabled":       h.engine.Telemetry() != nil,
+		"Usage":         usage,
+		"Authenticated": h.isAuthenticated(r),
+	}
+	h.render(w, "utilization.html", data)
+}
+
+func (h *Handlers) apiUtilization(w http.ResponseWriter, r *http.Request) {
+	day, err := utilizationDay(r)
+	if err != nil {
+		h.jsonError(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	usage, err := h.engine.Utilization(day)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if usage == nil {
+		usage = []telemetry.Usage{}
+	}
+	h.jsonOK(w, usage)
+}
+
+// apiRobotHistory returns one robot's status samples for a day.
+func (h *Handlers) apiRobotHistory(w http.ResponseWriter, r *http.Request) {
+	vehicle := r.URL.Query().Get("vehicle_id")
+	if vehicle == "" {
+		h.jsonError(w, "vehicle_id is required", http.StatusBadRequest)
+		return
+	}
+	day, err := utilizationDay(r)
+	if err != nil {
+		h.jsonError(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	snaps, err := h.engine.DB().ListRobotSnapshots(vehicle, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if snaps == nil {
+		snaps = []*store.RobotSnapshot{}
+	}
+	h.jsonOK(w, snaps)
+}
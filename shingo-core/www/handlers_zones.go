@@ -0,0 +1,114 @@
+package www
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"shingocore/store"
+)
+
+func (h *Handlers) handleZones(w http.ResponseWriter, r *http.Request) {
+	zones, _ := h.engine.DB().ListExclusiveZones()
+	held := make(map[string][]int64)
+	if orders, err := h.engine.DB().ListHeldOrders(); err == nil {
+		for _, o := range orders {
+			held[o.HeldZone] = append(held[o.HeldZone], o.ID)
+		}
+	}
+	data := map[string]any{
+		"Page":          "zones",
+		"Zones":         zones,
+		"Held":          held,
+		"Authenticated": h.isAuthenticated(r),
+		"Username":      h.getUsername(r),
+	}
+	h.render(w, "zones.html", data)
+}
+
+func (h *Handlers) apiListZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := h.engine.DB().ListExclusiveZones()
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if zones == nil {
+		zones = []*store.ExclusiveZone{}
+	}
+	h.jsonOK(w, zones)
+}
+
+func (h *Handlers) apiSaveZone(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Zone        string `json:"zone"`
+		MutexGroups string `json:"mutex_groups"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Zone == "" {
+		h.jsonError(w, "zone is required", http.StatusBadRequest)
+		return
+	}
+	if err := h.engine.DB().SaveExclusiveZone(req.Zone, req.MutexGroups); err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.jsonOK(w, map[string]string{"status": "ok"})
+}
+
+func (h *Handlers) apiDeleteZone(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Zone string `json:"zone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	z, err := h.engine.DB().GetExclusiveZone(req.Zone)
+	if err != nil {
+		h.jsonError(w, "zone not found", http.StatusNotFound)
+		return
+	}
+	if z.Locked {
+		h.jsonError(w, "unlock the zone before deleting it", http.StatusConflict)
+		return
+	}
+	if err := h.engine.DB().DeleteExclusiveZone(req.Zone); err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.jsonOK(w, map[string]string{"status": "ok"})
+}
+
+func (h *Handlers) apiLockZone(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Zone   string `json:"zone"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := h.engine.Dispatcher().LockZone(req.Zone, req.Reason, h.getUsername(r)); err != nil {
+		h.jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	h.jsonOK(w, map[string]string{"status": "ok"})
+}
+
+func (h *Handlers) apiUnlockZone(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Zone     string `json:"zone"`
+		Override bool   `json:"override"` // unlock a zone someone else locked
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.jsonError(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := h.engine.Dispatcher().UnlockZone(req.Zone, h.getUsername(r), req.Override); err != nil {
+		h.jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	h.jsonOK(w, map[string]string{"status": "ok"})
+}
@@ -59,6 +59,8 @@ func templateFuncs() template.FuncMap {
 				return "bg-blue-100 text-blue-800"
 			case "in_transit":
 				return "bg-indigo-100 text-indigo-800"
+			case "staged":
+				return "bg-purple-100 text-purple-800"
 			case "delivered", "confirmed", "completed":
 				return "bg-green-100 text-green-800"
 			case "failed":
@@ -103,6 +105,9 @@ func templateFuncs() template.FuncMap {
 		"f1": func(f float64) string {
 			return fmt.Sprintf("%.1f", f)
 		},
+		"hours": func(seconds float64) string {
+			return fmt.Sprintf("%.1f", seconds/3600)
+		},
 		"payloadStatusColor": func(status string) string {
 			switch status {
 			case "available":
@@ -127,22 +132,30 @@ func templateFuncs() template.FuncMap {
 			return [2]string{broker, "9093"}
 		},
 		"nodeColor": func(count, capacity int) string {
-			if capacity <= 0 || count <= 0 {
-				return "background:#e9ecef;color:#495057;"
-			}
-			f := float64(count) / float64(capacity)
-			if f > 1 {
-				f = 1
-			}
-			// Interpolate gray (#e9ecef) → purple (#7c3aed)
-			r := int(233 - 109*f)
-			g := int(236 - 178*f)
-			b := int(239 - 2*f)
+			f := occupancy(count, capacity)
 			tc := "#495057"
 			if f > 0.45 {
 				tc = "#fff"
 			}
-			return fmt.Sprintf("background:rgb(%d,%d,%d);color:%s;", r, g, b, tc)
+			return fmt.Sprintf("background:%s;color:%s;", occupancyFill(count, capacity), tc)
 		},
 	}
 }
+
+// occupancy returns how full a node is, from 0 to 1.
+func occupancy(count, capacity int) float64 {
+	if capacity <= 0 || count <= 0 {
+		return 0
+	}
+	return min(float64(count)/float64(capacity), 1)
+}
+
+// occupancyFill returns a node's color by occupancy: gray (#e9ecef) when
+// empty through purple (#7c3aed) when full.
+func occupancyFill(count, capacity int) string {
+	f := occupancy(count, capacity)
+	r := int(233 - 109*f)
+	g := int(236 - 178*f)
+	b := int(239 - 2*f)
+	return fmt.Sprintf("rgb(%d,%d,%d)", r, g, b)
+}
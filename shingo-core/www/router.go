@@ -41,14 +41,19 @@ func NewRouter(eng *engine.Engine, dbg *debuglog.Logger) (http.Handler, func())
 	pages := []string{
 		"templates/dashboard.html",
 		"templates/nodes.html",
+		"templates/map.html",
 		"templates/orders.html",
 		"templates/diagnostics.html",
 		"templates/login.html",
 		"templates/config.html",
 		"templates/rds_explorer.html",
 		"templates/robots.html",
+		"templates/devices.html",
+		"templates/utilization.html",
+		"templates/zones.html",
 		"templates/payloads.html",
 		"templates/demand.html",
+		"templates/schedules.html",
 		"templates/test-orders.html",
 	}
 	tmpls := make(map[string]*template.Template, len(pages))
@@ -87,10 +92,15 @@ func NewRouter(eng *engine.Engine, dbg *debuglog.Logger) (http.Handler, func())
 	r.Post("/login", h.handleLogin)
 	r.Get("/logout", h.handleLogout)
 	r.Get("/nodes", h.handleNodes)
+	r.Get("/map", h.handleMap)
 	r.Get("/orders", h.handleOrders)
 	r.Get("/orders/detail", h.handleOrderDetail)
 	r.Get("/robots", h.handleRobots)
+	r.Get("/devices", h.handleDevices)
+	r.Get("/utilization", h.handleUtilization)
+	r.Get("/zones", h.handleZones)
 	r.Get("/demand", h.handleDemand)
+	r.Get("/schedules", h.handleSchedules)
 
 	// API routes (no auth required for read)
 	r.Route("/api", func(r chi.Router) {
@@ -100,6 +110,12 @@ func NewRouter(eng *engine.Engine, dbg *debuglog.Logger) (http.Handler, func())
 		r.Get("/orders/detail", h.apiGetOrder)
 		r.Get("/nodestate", h.apiNodeState)
 		r.Get("/robots", h.apiRobotsStatus)
+		r.Get("/robots/charging", h.apiRobotsCharging)
+		r.Get("/robots/goods", h.apiRobotsGoods)
+		r.Get("/robots/history", h.apiRobotHistory)
+		r.Get("/robots/utilization", h.apiUtilization)
+		r.Get("/devices", h.apiListDevices)
+		r.Get("/zones", h.apiListZones)
 		r.Get("/health", h.apiHealthCheck)
 		r.Get("/payload-types", h.apiListPayloadTypes)
 		r.Get("/payloads", h.apiListPayloads)
@@ -108,8 +124,11 @@ func NewRouter(eng *engine.Engine, dbg *debuglog.Logger) (http.Handler, func())
 		r.Get("/nodes/occupancy", h.apiNodeOccupancy)
 		r.Get("/corrections", h.apiListNodeCorrections)
 		r.Get("/map/points", h.apiScenePoints)
+		r.Get("/map/nodes", h.apiMapNodes)
 		r.Get("/demands", h.apiListDemands)
 		r.Get("/demands/{id}/log", h.apiDemandLog)
+		r.Get("/schedules", h.apiListSchedules)
+		r.Post("/dispatch/plan", h.apiPlanOrder)
 	})
 
 	// Protected routes
@@ -129,6 +148,7 @@ func NewRouter(eng *engine.Engine, dbg *debuglog.Logger) (http.Handler, func())
 		r.Post("/api/test-orders/submit", h.apiTestOrderSubmit)
 		r.Post("/api/test-orders/cancel", h.apiTestOrderCancel)
 		r.Post("/api/test-orders/receipt", h.apiTestOrderReceipt)
+		r.Post("/api/test-orders/release", h.apiTestOrderRelease)
 		// Direct-to-RDS orders
 		r.Get("/api/test-orders/direct", h.apiDirectOrdersList)
 		r.Post("/api/test-orders/direct", h.apiDirectOrderSubmit)
@@ -139,6 +159,10 @@ func NewRouter(eng *engine.Engine, dbg *debuglog.Logger) (http.Handler, func())
 		// Shared helpers
 		r.Get("/api/test-orders/robots", h.apiTestRobots)
 		r.Get("/api/test-orders/scene-points", h.apiTestScenePoints)
+		// Scenario rehearsals against simulated robots
+		r.Get("/api/scenarios", h.apiListScenarios)
+		r.Post("/api/scenarios/run", h.apiRunScenario)
+		r.Post("/api/scenarios/stop", h.apiStopScenario)
 		r.Post("/payload-types/create", h.handlePayloadTypeCreate)
 		r.Post("/payload-types/update", h.handlePayloadTypeUpdate)
 		r.Post("/payload-types/delete", h.handlePayloadTypeDelete)
@@ -158,8 +182,16 @@ func NewRouter(eng *engine.Engine, dbg *debuglog.Logger) (http.Handler, func())
 		r.Post("/api/robots/availability", h.apiRobotSetAvailability)
 		r.Post("/api/robots/retry", h.apiRobotRetryFailed)
 		r.Post("/api/robots/force-complete", h.apiRobotForceComplete)
+		r.Post("/api/sim/fail", h.apiSimFail)
 		r.Post("/api/orders/terminate", h.apiTerminateOrder)
 		r.Post("/api/orders/priority", h.apiSetOrderPriority)
+		r.Get("/api/fleet/reconcile", h.apiLastFleetReconcile)
+		r.Get("/api/fleet/poll-stats", h.apiFleetPollStats)
+		r.Post("/api/fleet/reconcile", h.apiFleetReconcile)
+		r.Post("/api/zones", h.apiSaveZone)
+		r.Delete("/api/zones", h.apiDeleteZone)
+		r.Post("/api/zones/lock", h.apiLockZone)
+		r.Post("/api/zones/unlock", h.apiUnlockZone)
 		r.Post("/api/demands", h.apiCreateDemand)
 		r.Put("/api/demands/{id}", h.apiUpdateDemand)
 		r.Put("/api/demands/{id}/apply", h.apiApplyDemand)
@@ -168,6 +200,10 @@ func NewRouter(eng *engine.Engine, dbg *debuglog.Logger) (http.Handler, func())
 		r.Put("/api/demands/{id}/produced", h.apiSetDemandProduced)
 		r.Post("/api/demands/{id}/clear", h.apiClearDemandProduced)
 		r.Post("/api/demands/clear-all", h.apiClearAllProduced)
+		r.Post("/api/schedules", h.apiCreateSchedule)
+		r.Put("/api/schedules/{id}", h.apiUpdateSchedule)
+		r.Put("/api/schedules/{id}/enabled", h.apiSetScheduleEnabled)
+		r.Delete("/api/schedules/{id}", h.apiDeleteSchedule)
 	})
 
 	stopFn := func() {
@@ -144,6 +144,31 @@ func (h *EventHub) SetupEngineListeners(eng *engine.Engine) {
 		h.Broadcast("order-update", sseJSON(map[string]any{"type": "cancelled", "order_id": ev.OrderID, "reason": ev.Reason}))
 	}, engine.EventOrderCancelled)
 
+	eng.Events.SubscribeTypes(func(evt engine.Event) {
+		ev := evt.Payload.(engine.SLABreachedEvent)
+		h.Broadcast("order-update", sseJSON(map[string]any{"type": "sla_breached", "order_id": ev.OrderID, "station_id": ev.StationID}))
+	}, engine.EventSLABreached)
+
+	eng.Events.SubscribeTypes(func(evt engine.Event) {
+		ev := evt.Payload.(engine.DeviceAlertEvent)
+		h.Broadcast("device-alert", sseJSON(map[string]any{"device": ev.Device, "kind": ev.Kind, "order_id": ev.OrderID, "reason": ev.Reason}))
+	}, engine.EventDeviceAlert)
+
+	eng.Events.SubscribeTypes(func(evt engine.Event) {
+		ev := evt.Payload.(engine.RobotChargingEvent)
+		h.Broadcast("robot-charging", sseJSON(map[string]any{"vehicle_id": ev.VehicleID, "action": ev.Action, "reason": ev.Reason, "station": ev.Station, "battery": ev.Battery}))
+	}, engine.EventRobotCharging)
+
+	eng.Events.SubscribeTypes(func(evt engine.Event) {
+		ev := evt.Payload.(engine.GoodsMismatchEvent)
+		h.Broadcast("goods-mismatch", sseJSON(map[string]any{"vehicle_id": ev.VehicleID, "goods_id": ev.GoodsID, "reason": ev.Reason}))
+	}, engine.EventGoodsMismatch)
+
+	eng.Events.SubscribeTypes(func(evt engine.Event) {
+		ev := evt.Payload.(engine.ZoneLockEvent)
+		h.Broadcast("zone-lock", sseJSON(map[string]any{"zone": ev.Zone, "locked": ev.Locked, "reason": ev.Reason, "actor": ev.Actor}))
+	}, engine.EventZoneLock)
+
 	eng.Events.SubscribeTypes(func(evt engine.Event) {
 		ev := evt.Payload.(engine.PayloadChangedEvent)
 		h.Broadcast("payload-update", sseJSON(map[string]any{"node_id": ev.NodeID, "action": ev.Action, "payload_id": ev.PayloadID}))
@@ -17,6 +17,11 @@
     es.addEventListener('inventory-update', function(e) {
       const el = document.querySelector('[data-sse="nodestate"]');
       if (el) htmx.trigger(el, 'sse-refresh');
+      if (typeof window.mapRefreshNodes === 'function') window.mapRefreshNodes();
+    });
+
+    es.addEventListener('payload-update', function(e) {
+      if (typeof window.mapRefreshNodes === 'function') window.mapRefreshNodes();
     });
 
     es.addEventListener('node-update', function(e) {
@@ -48,6 +53,9 @@
 
     es.addEventListener('robot-update', function(e) {
       var robots = JSON.parse(e.data);
+      if (typeof window.mapUpdateRobots === 'function') {
+        window.mapUpdateRobots(robots);
+      }
       var grid = document.getElementById('robot-grid');
       if (!grid) return;
 
@@ -148,6 +156,23 @@
       }
     });
 
+    es.addEventListener('device-alert', function(e) {
+      // The devices page lists alerts; reload it to show the new one
+      if (document.getElementById('device-alerts')) location.reload();
+    });
+
+    es.addEventListener('robot-charging', function(e) {
+      if (document.getElementById('charging-robots')) location.reload();
+    });
+
+    es.addEventListener('goods-mismatch', function(e) {
+      if (document.getElementById('robot-goods')) location.reload();
+    });
+
+    es.addEventListener('zone-lock', function(e) {
+      if (document.getElementById('zones-table')) location.reload();
+    });
+
     es.addEventListener('debug-log', function(e) {
       if (typeof window.debugAppendRow === 'function') {
         var entry = JSON.parse(e.data);
@@ -20,6 +20,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	configPath := flag.String("config", "shingoedge.yaml", "path to config file")
 	debug := flag.Bool("debug", false, "enable debug logging")
 	port := flag.Int("port", 0, "HTTP port (overrides config)")
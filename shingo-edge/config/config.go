@@ -25,6 +25,10 @@ type Config struct {
 	Web       WebConfig       `yaml:"web"`
 	Messaging MessagingConfig `yaml:"messaging"`
 	Counter   CounterConfig   `yaml:"counter"`
+
+	// ChangeoverZones maps production line names to the exclusive core zone
+	// locked for the length of the line's changeovers.
+	ChangeoverZones map[string]string `yaml:"changeover_zones"`
 }
 
 // WarLinkConfig defines the WarLink connection.
@@ -1,10 +1,18 @@
 package engine
 
-import "log"
+import (
+	"fmt"
+	"log"
+
+	"shingo/protocol"
+	"shingoedge/orders"
+)
 
 // wireEventHandlers sets up the full event chain:
 // CounterDelta → payload decrement → PayloadReorder → order creation
+// PayloadEmpty → staged order release
 // OrderCompleted → payload reset
+// Changeover started/ended → zone lock/unlock in core
 func (e *Engine) wireEventHandlers() {
 	// CounterDelta → payload consumption
 	e.Events.SubscribeTypes(func(evt Event) {
@@ -24,11 +32,29 @@ func (e *Engine) wireEventHandlers() {
 		e.handlePayloadReorder(reorder)
 	}, EventPayloadReorder)
 
+	// PayloadEmpty → release any order staged for the payload
+	e.Events.SubscribeTypes(func(evt Event) {
+		empty := evt.Payload.(PayloadEmptyEvent)
+		e.handlePayloadEmpty(empty)
+	}, EventPayloadEmpty)
+
 	// OrderCompleted → reset payload if linked
 	e.Events.SubscribeTypes(func(evt Event) {
 		completed := evt.Payload.(OrderCompletedEvent)
 		e.handleOrderCompleted(completed)
 	}, EventOrderCompleted)
+
+	// Changeover start/end → lock/unlock the line's exclusive zone in core
+	e.Events.SubscribeTypes(func(evt Event) {
+		switch ev := evt.Payload.(type) {
+		case ChangeoverStartedEvent:
+			e.sendZoneLock(ev.LineID, true, fmt.Sprintf("changeover %s -> %s", ev.FromJobStyle, ev.ToJobStyle))
+		case ChangeoverCompletedEvent:
+			e.sendZoneLock(ev.LineID, false, "changeover completed")
+		case ChangeoverCancelledEvent:
+			e.sendZoneLock(ev.LineID, false, "changeover cancelled")
+		}
+	}, EventChangeoverStarted, EventChangeoverCompleted, EventChangeoverCancelled)
 }
 
 func (e *Engine) handleCounterDelta(delta CounterDeltaEvent) {
@@ -94,6 +120,23 @@ func (e *Engine) handlePayloadReorder(reorder PayloadReorderEvent) {
 		reorder.PayloadID, reorder.Location, reorder.ReorderQty)
 
 	payloadID := reorder.PayloadID
+
+	// An empty left at the line goes back on the same robot trip, where the
+	// location node is set up for swaps.
+	if reorder.RetrieveEmpty && reorder.StagingNode == "" && e.swapsEmpties(reorder.Location) {
+		_, err := e.orderMgr.CreateSwapOrder(
+			&payloadID,
+			float64(reorder.ReorderQty),
+			reorder.Location,
+			"standard",
+			e.cfg.Web.AutoConfirm,
+		)
+		if err != nil {
+			log.Printf("create swap reorder for payload %d: %v", reorder.PayloadID, err)
+		}
+		return
+	}
+
 	_, err := e.orderMgr.CreateRetrieveOrder(
 		&payloadID,
 		reorder.RetrieveEmpty,
@@ -108,14 +151,37 @@ func (e *Engine) handlePayloadReorder(reorder PayloadReorderEvent) {
 	}
 }
 
+// swapsEmpties reports whether reorders at a location replenish by swap.
+// Locations without a location node keep plain retrieves.
+func (e *Engine) swapsEmpties(location string) bool {
+	node, err := e.db.GetLocationNodeByNodeID(location)
+	return err == nil && node.SwapEmpty
+}
+
+// handlePayloadEmpty releases orders staged for a payload once the line
+// consumes it, so the replacement moves from staging to the line.
+func (e *Engine) handlePayloadEmpty(empty PayloadEmptyEvent) {
+	orders, err := e.db.ListStagedOrdersByPayload(empty.PayloadID)
+	if err != nil {
+		log.Printf("list staged orders for payload %d: %v", empty.PayloadID, err)
+		return
+	}
+	for _, o := range orders {
+		e.debugFn("payload empty: releasing staged order %d for payload %d", o.ID, empty.PayloadID)
+		if err := e.orderMgr.ReleaseOrder(o.ID, "payload empty at "+empty.Location); err != nil {
+			log.Printf("release staged order %d: %v", o.ID, err)
+		}
+	}
+}
+
 func (e *Engine) handleOrderCompleted(completed OrderCompletedEvent) {
 	order, err := e.db.GetOrder(completed.OrderID)
 	if err != nil || order.PayloadID == nil {
 		return
 	}
 
-	// Only reset payload on retrieve order completion
-	if order.OrderType != "retrieve" {
+	// Only reset payload on retrieve or swap order completion
+	if order.OrderType != orders.TypeRetrieve && order.OrderType != orders.TypeSwap {
 		return
 	}
 
@@ -148,3 +214,39 @@ func (e *Engine) handleOrderCompleted(completed OrderCompletedEvent) {
 		Status: "active",
 	}})
 }
+
+// sendZoneLock asks core to lock or unlock the exclusive zone configured for
+// a production line's changeovers. Lines without a zone are ignored.
+func (e *Engine) sendZoneLock(lineID int64, locked bool, reason string) {
+	line, err := e.db.GetProductionLine(lineID)
+	if err != nil {
+		log.Printf("get production line %d for zone lock: %v", lineID, err)
+		return
+	}
+	zone := e.cfg.ChangeoverZones[line.Name]
+	if zone == "" {
+		return
+	}
+
+	stationID := e.cfg.StationID()
+	env, err := protocol.NewDataEnvelope(
+		protocol.SubjectZoneLock,
+		protocol.Address{Role: protocol.RoleEdge, Station: stationID},
+		protocol.Address{Role: protocol.RoleCore},
+		&protocol.ZoneLock{StationID: stationID, Zone: zone, Locked: locked, Reason: reason},
+	)
+	if err != nil {
+		log.Printf("build zone lock envelope: %v", err)
+		return
+	}
+	data, err := env.Encode()
+	if err != nil {
+		log.Printf("encode zone lock envelope: %v", err)
+		return
+	}
+	if _, err := e.db.EnqueueOutbox(data, protocol.SubjectZoneLock); err != nil {
+		log.Printf("enqueue zone lock for %s: %v", zone, err)
+		return
+	}
+	e.debugFn("zone lock: line=%s zone=%s locked=%v", line.Name, zone, locked)
+}
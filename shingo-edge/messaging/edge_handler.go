@@ -67,6 +67,17 @@ func (h *EdgeHandler) HandleData(env *protocol.Envelope, p *protocol.Data) {
 			return
 		}
 		log.Printf("edge_handler: WARNING: core marked this edge as stale: %s", stale.Message)
+	case protocol.SubjectZoneLockReply:
+		var zl protocol.ZoneLockReply
+		if err := json.Unmarshal(p.Body, &zl); err != nil {
+			log.Printf("edge_handler: decode zone lock reply: %v", err)
+			return
+		}
+		if !zl.Granted {
+			log.Printf("edge_handler: WARNING: zone %s lock=%v refused by core (held by %s): %s", zl.Zone, zl.Locked, zl.HeldBy, zl.Detail)
+			return
+		}
+		log.Printf("edge_handler: zone lock reply: zone=%s locked=%v", zl.Zone, zl.Locked)
 	default:
 		log.Printf("edge_handler: unhandled data subject: %s", p.Subject)
 	}
@@ -88,7 +99,11 @@ func (h *EdgeHandler) HandleOrderWaybill(env *protocol.Envelope, p *protocol.Ord
 
 func (h *EdgeHandler) HandleOrderUpdate(env *protocol.Envelope, p *protocol.OrderUpdate) {
 	log.Printf("edge_handler: order update: uuid=%s status=%s", p.OrderUUID, p.Status)
-	if err := h.orderMgr.HandleDispatchReply(p.OrderUUID, "update", "", p.ETA, p.Detail); err != nil {
+	replyType := "update"
+	if p.Status == protocol.StatusStaged {
+		replyType = "staged"
+	}
+	if err := h.orderMgr.HandleDispatchReply(p.OrderUUID, replyType, "", p.ETA, p.Detail); err != nil {
 		log.Printf("edge_handler: handle update for %s: %v", p.OrderUUID, err)
 	}
 }
@@ -94,6 +94,49 @@ func (m *Manager) CreateRetrieveOrder(payloadID *int64, retrieveEmpty bool, quan
 	return m.db.GetOrder(orderID)
 }
 
+// CreateSwapOrder creates a swap order: core delivers a full payload to the
+// delivery node and takes the empty there back to storage in the same trip.
+func (m *Manager) CreateSwapOrder(payloadID *int64, quantity float64, deliveryNode, loadType string, autoConfirm bool) (*store.Order, error) {
+	orderUUID := uuid.New().String()
+
+	orderID, err := m.db.CreateOrder(orderUUID, TypeSwap,
+		payloadID, true,
+		quantity, deliveryNode, "", "", loadType, autoConfirm)
+	if err != nil {
+		return nil, fmt.Errorf("create swap order: %w", err)
+	}
+
+	var payloadDesc string
+	if payloadID != nil {
+		if p, err := m.db.GetPayload(*payloadID); err == nil {
+			payloadDesc = p.Description
+		}
+	}
+
+	env, err := protocol.NewEnvelope(protocol.TypeOrderRequest, m.src(), m.dst(), &protocol.OrderRequest{
+		OrderUUID:     orderUUID,
+		OrderType:     TypeSwap,
+		PayloadDesc:   payloadDesc,
+		RetrieveEmpty: true,
+		Quantity:      quantity,
+		DeliveryNode:  deliveryNode,
+		LoadType:      loadType,
+	})
+	if err != nil {
+		log.Printf("build envelope for order %s: %v", orderUUID, err)
+	} else if err := m.enqueueEnvelope(env); err != nil {
+		log.Printf("enqueue order %s: %v", orderUUID, err)
+	}
+
+	if err := m.TransitionOrder(orderID, StatusSubmitted, "auto-submitted at creation"); err != nil {
+		log.Printf("auto-submit swap order %s: %v", orderUUID, err)
+	}
+
+	m.emitter.EmitOrderCreated(orderID, orderUUID, TypeSwap)
+
+	return m.db.GetOrder(orderID)
+}
+
 // CreateStoreOrder creates a new store order (for returning payloads to warehouse).
 func (m *Manager) CreateStoreOrder(payloadID *int64, quantity float64, pickupNode string) (*store.Order, error) {
 	orderUUID := uuid.New().String()
@@ -249,6 +292,32 @@ func (m *Manager) RedirectOrder(orderID int64, newDeliveryNode string) (*store.O
 	return m.db.GetOrder(orderID)
 }
 
+// ReleaseOrder sends a staged order on from its staging node to the delivery node.
+func (m *Manager) ReleaseOrder(orderID int64, reason string) error {
+	order, err := m.db.GetOrder(orderID)
+	if err != nil {
+		return fmt.Errorf("get order: %w", err)
+	}
+	if order.Status != StatusStaged {
+		return fmt.Errorf("order is not staged: %s", order.Status)
+	}
+
+	env, err := protocol.NewEnvelope(protocol.TypeOrderRelease, m.src(), m.dst(), &protocol.OrderRelease{
+		OrderUUID: order.UUID,
+		Reason:    reason,
+	})
+	if err != nil {
+		return fmt.Errorf("build release envelope: %w", err)
+	}
+	if err := m.enqueueEnvelope(env); err != nil {
+		return fmt.Errorf("enqueue release: %w", err)
+	}
+	if err := m.db.InsertOrderHistory(orderID, order.Status, order.Status, "release requested: "+reason); err != nil {
+		log.Printf("insert order history: %v", err)
+	}
+	return nil
+}
+
 // SubmitOrder transitions a pending order to submitted and enqueues it.
 func (m *Manager) SubmitOrder(orderID int64) error {
 	order, err := m.db.GetOrder(orderID)
@@ -337,6 +406,8 @@ func (m *Manager) HandleDispatchReply(orderUUID, replyType, waybillID, eta, stat
 			}
 		}
 		return nil
+	case "staged":
+		return m.TransitionOrder(order.ID, StatusStaged, statusDetail)
 	case "delivered":
 		if err := m.TransitionOrder(order.ID, StatusDelivered, statusDetail); err != nil {
 			return err
@@ -2,11 +2,12 @@ package orders
 
 import "shingo/protocol"
 
-// Order types
+// Order types aliased from protocol.
 const (
-	TypeRetrieve = "retrieve"
-	TypeStore    = "store"
-	TypeMove     = "move"
+	TypeRetrieve = protocol.OrderTypeRetrieve
+	TypeStore    = protocol.OrderTypeStore
+	TypeMove     = protocol.OrderTypeMove
+	TypeSwap     = protocol.OrderTypeSwap
 )
 
 // Order statuses aliased from protocol.
@@ -15,6 +16,7 @@ const (
 	StatusSubmitted    = protocol.StatusSubmitted
 	StatusAcknowledged = protocol.StatusAcknowledged
 	StatusInTransit    = protocol.StatusInTransit
+	StatusStaged       = protocol.StatusStaged
 	StatusDelivered    = protocol.StatusDelivered
 	StatusConfirmed    = protocol.StatusConfirmed
 	StatusCancelled    = protocol.StatusCancelled
@@ -24,8 +26,9 @@ const (
 var validTransitions = map[string][]string{
 	StatusPending:      {StatusSubmitted, StatusCancelled, StatusFailed},
 	StatusSubmitted:    {StatusAcknowledged, StatusCancelled, StatusFailed},
-	StatusAcknowledged: {StatusInTransit, StatusCancelled, StatusFailed},
-	StatusInTransit:    {StatusDelivered, StatusCancelled, StatusFailed},
+	StatusAcknowledged: {StatusInTransit, StatusStaged, StatusCancelled, StatusFailed},
+	StatusInTransit:    {StatusDelivered, StatusStaged, StatusCancelled, StatusFailed},
+	StatusStaged:       {StatusAcknowledged, StatusInTransit, StatusCancelled, StatusFailed},
 	StatusDelivered:    {StatusConfirmed, StatusCancelled, StatusFailed},
 }
 
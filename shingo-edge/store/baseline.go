@@ -1,13 +1,23 @@
 package store
 
-const schemaMigrations = `
+import (
+	"database/sql"
+	"fmt"
+)
+
+// legacyTables are tables from builds before payloads replaced materials.
+const legacyTables = `
 DROP TABLE IF EXISTS bom_entries;
 DROP TABLE IF EXISTS inventory;
 DROP TABLE IF EXISTS materials;
 DROP TABLE IF EXISTS kanban_templates;
 `
 
-const schema = `
+// baselineSchema is the schema as it stood at migration 1, the baseline. It
+// is frozen: a database at version 1 must look the same no matter which
+// build created it, so schema changes go in a new migration appended to
+// migrations, never in this snapshot.
+const baselineSchema = `
 CREATE TABLE IF NOT EXISTS admin_users (
     id            INTEGER PRIMARY KEY AUTOINCREMENT,
     username      TEXT NOT NULL UNIQUE,
@@ -155,53 +165,98 @@ CREATE TABLE IF NOT EXISTS hourly_counts (
 );
 `
 
-func (db *DB) migrate() error {
-	// Run cleanup migrations first (drop old tables)
-	if _, err := db.Exec(schemaMigrations); err != nil {
+// migrateBaseline brings a database to the schema as it stood when
+// versioned migrations were introduced. A fresh database gets the frozen
+// snapshot; one created by an older build gets the cleanups, renames and
+// column adds those builds used to apply on every start. Like the snapshot,
+// this step never changes: new columns are migrations of their own.
+func migrateBaseline(tx *sql.Tx) error {
+	if _, err := tx.Exec(legacyTables); err != nil {
+		return fmt.Errorf("drop legacy tables: %w", err)
+	}
+	// SQLite refuses to drop a column that carries a foreign key; the
+	// unused material_id stays in that case.
+	if ok, err := columnExists(tx, "orders", "material_id"); err != nil {
 		return err
+	} else if ok {
+		fk, err := foreignKeyColumn(tx, "orders", "material_id")
+		if err != nil {
+			return err
+		}
+		if !fk {
+			if _, err := tx.Exec(`ALTER TABLE orders DROP COLUMN material_id`); err != nil {
+				return fmt.Errorf("drop orders.material_id: %w", err)
+			}
+		}
 	}
-	// Also drop the old material_id column from orders if it exists
-	// SQLite doesn't support DROP COLUMN before 3.35, so we handle this gracefully
-	db.Exec("ALTER TABLE orders DROP COLUMN material_id")
-	_, err := db.Exec(schema)
-	if err != nil {
+	if ok, err := columnExists(tx, "location_nodes", "node_type"); err != nil {
 		return err
+	} else if ok {
+		if _, err := tx.Exec(`ALTER TABLE location_nodes RENAME COLUMN node_type TO process`); err != nil {
+			return fmt.Errorf("rename location_nodes.node_type: %w", err)
+		}
 	}
-	// Graceful migrations for existing DBs
-	db.Exec("ALTER TABLE payloads ADD COLUMN has_description TEXT NOT NULL DEFAULT ''")
-	db.Exec("ALTER TABLE payloads ADD COLUMN auto_reorder INTEGER NOT NULL DEFAULT 1")
-	db.Exec("ALTER TABLE location_nodes RENAME COLUMN node_type TO process")
-
-	// Production lines migrations
-	db.Exec("ALTER TABLE job_styles ADD COLUMN line_id INTEGER REFERENCES production_lines(id) ON DELETE CASCADE")
-	db.Exec("ALTER TABLE reporting_points ADD COLUMN line_id INTEGER REFERENCES production_lines(id) ON DELETE CASCADE")
-	db.Exec("ALTER TABLE changeover_log ADD COLUMN line_id INTEGER")
-	db.Exec("ALTER TABLE job_styles ADD COLUMN cat_id TEXT NOT NULL DEFAULT ''")
-
-	// Auto-create default line if job_styles exist but no lines do
-	var lineCount int
-	db.QueryRow("SELECT COUNT(*) FROM production_lines").Scan(&lineCount)
-	if lineCount == 0 {
-		var jsCount int
-		db.QueryRow("SELECT COUNT(*) FROM job_styles").Scan(&jsCount)
-		if jsCount > 0 {
-			db.Exec("INSERT INTO production_lines (name, description) VALUES ('Line 1', 'Default production line')")
-			// Assign orphaned job styles to the default line
-			db.Exec("UPDATE job_styles SET line_id = (SELECT id FROM production_lines WHERE name = 'Line 1') WHERE line_id IS NULL")
-			// Assign orphaned reporting points to the default line
-			db.Exec("UPDATE reporting_points SET line_id = (SELECT id FROM production_lines WHERE name = 'Line 1') WHERE line_id IS NULL")
+
+	if _, err := tx.Exec(baselineSchema); err != nil {
+		return fmt.Errorf("create schema: %w", err)
+	}
+
+	adds := []struct{ table, column, ddl string }{
+		{"payloads", "has_description", "TEXT NOT NULL DEFAULT ''"},
+		{"payloads", "auto_reorder", "INTEGER NOT NULL DEFAULT 1"},
+		{"job_styles", "line_id", "INTEGER REFERENCES production_lines(id) ON DELETE CASCADE"},
+		{"job_styles", "cat_id", "TEXT NOT NULL DEFAULT ''"},
+		{"reporting_points", "line_id", "INTEGER REFERENCES production_lines(id) ON DELETE CASCADE"},
+		{"reporting_points", "warlink_managed", "INTEGER NOT NULL DEFAULT 0"},
+		{"changeover_log", "line_id", "INTEGER"},
+		{"location_nodes", "line_id", "INTEGER REFERENCES production_lines(id) ON DELETE CASCADE"},
+	}
+	for _, a := range adds {
+		ok, err := columnExists(tx, a.table, a.column)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, a.table, a.column, a.ddl)); err != nil {
+			return fmt.Errorf("add %s.%s: %w", a.table, a.column, err)
 		}
 	}
 
-	// WarLink tag management tracking
-	db.Exec("ALTER TABLE reporting_points ADD COLUMN warlink_managed INTEGER NOT NULL DEFAULT 0")
+	// Create a default line if job styles exist but no lines do, and
+	// assign the orphaned job styles and reporting points to it.
+	var lineCount, jsCount int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM production_lines`).Scan(&lineCount); err != nil {
+		return fmt.Errorf("count production lines: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM job_styles`).Scan(&jsCount); err != nil {
+		return fmt.Errorf("count job styles: %w", err)
+	}
+	if lineCount == 0 && jsCount > 0 {
+		for _, q := range []string{
+			`INSERT INTO production_lines (name, description) VALUES ('Line 1', 'Default production line')`,
+			`UPDATE job_styles SET line_id = (SELECT id FROM production_lines WHERE name = 'Line 1') WHERE line_id IS NULL`,
+			`UPDATE reporting_points SET line_id = (SELECT id FROM production_lines WHERE name = 'Line 1') WHERE line_id IS NULL`,
+		} {
+			if _, err := tx.Exec(q); err != nil {
+				return fmt.Errorf("default production line: %w", err)
+			}
+		}
+	}
 
 	// Location nodes: migrate process text → line_id FK
-	db.Exec("ALTER TABLE location_nodes ADD COLUMN line_id INTEGER REFERENCES production_lines(id) ON DELETE CASCADE")
-	db.Exec("UPDATE location_nodes SET line_id = (SELECT id FROM production_lines WHERE name = location_nodes.process) WHERE line_id IS NULL AND process != ''")
+	if ok, err := columnExists(tx, "location_nodes", "process"); err != nil {
+		return err
+	} else if ok {
+		if _, err := tx.Exec(`UPDATE location_nodes SET line_id = (SELECT id FROM production_lines WHERE name = location_nodes.process) WHERE line_id IS NULL AND process != ''`); err != nil {
+			return fmt.Errorf("assign location node lines: %w", err)
+		}
+	}
 
 	// Migrate queued -> pending status
-	db.Exec("UPDATE orders SET status='pending' WHERE status='queued'")
-
+	if _, err := tx.Exec(`UPDATE orders SET status='pending' WHERE status='queued'`); err != nil {
+		return fmt.Errorf("requeue orders: %w", err)
+	}
 	return nil
 }
@@ -6,10 +6,17 @@ type LocationNode struct {
 	NodeID      string `json:"node_id"`
 	LineID      int64  `json:"line_id"`
 	Description string `json:"description"`
+	SwapEmpty   bool   `json:"swap_empty"` // replenish by swap: the robot delivering full takes the empty back
+}
+
+const locationNodeCols = "id, node_id, COALESCE(line_id, 0), description, swap_empty"
+
+func scanLocationNode(n *LocationNode, scanner interface{ Scan(...interface{}) error }) error {
+	return scanner.Scan(&n.ID, &n.NodeID, &n.LineID, &n.Description, &n.SwapEmpty)
 }
 
 func (db *DB) ListLocationNodes() ([]LocationNode, error) {
-	rows, err := db.Query("SELECT id, node_id, COALESCE(line_id, 0), description FROM location_nodes ORDER BY node_id")
+	rows, err := db.Query("SELECT " + locationNodeCols + " FROM location_nodes ORDER BY node_id")
 	if err != nil {
 		return nil, err
 	}
@@ -17,7 +24,7 @@ func (db *DB) ListLocationNodes() ([]LocationNode, error) {
 	var nodes []LocationNode
 	for rows.Next() {
 		var n LocationNode
-		if err := rows.Scan(&n.ID, &n.NodeID, &n.LineID, &n.Description); err != nil {
+		if err := scanLocationNode(&n, rows); err != nil {
 			return nil, err
 		}
 		nodes = append(nodes, n)
@@ -26,7 +33,7 @@ func (db *DB) ListLocationNodes() ([]LocationNode, error) {
 }
 
 func (db *DB) ListLocationNodesByLine(lineID int64) ([]LocationNode, error) {
-	rows, err := db.Query("SELECT id, node_id, COALESCE(line_id, 0), description FROM location_nodes WHERE line_id = ? ORDER BY node_id", lineID)
+	rows, err := db.Query("SELECT "+locationNodeCols+" FROM location_nodes WHERE line_id = ? ORDER BY node_id", lineID)
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +41,7 @@ func (db *DB) ListLocationNodesByLine(lineID int64) ([]LocationNode, error) {
 	var nodes []LocationNode
 	for rows.Next() {
 		var n LocationNode
-		if err := rows.Scan(&n.ID, &n.NodeID, &n.LineID, &n.Description); err != nil {
+		if err := scanLocationNode(&n, rows); err != nil {
 			return nil, err
 		}
 		nodes = append(nodes, n)
@@ -42,6 +49,15 @@ func (db *DB) ListLocationNodesByLine(lineID int64) ([]LocationNode, error) {
 	return nodes, rows.Err()
 }
 
+// GetLocationNodeByNodeID returns the location node for a core node name.
+func (db *DB) GetLocationNodeByNodeID(nodeID string) (*LocationNode, error) {
+	var n LocationNode
+	if err := scanLocationNode(&n, db.QueryRow("SELECT "+locationNodeCols+" FROM location_nodes WHERE node_id = ?", nodeID)); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
 // ListKnownNodes returns distinct location and staging_node values from payloads.
 func (db *DB) ListKnownNodes() ([]string, error) {
 	rows, err := db.Query(`SELECT DISTINCT location FROM payloads UNION SELECT DISTINCT staging_node FROM payloads WHERE staging_node != '' ORDER BY 1`)
@@ -60,16 +76,16 @@ func (db *DB) ListKnownNodes() ([]string, error) {
 	return nodes, rows.Err()
 }
 
-func (db *DB) CreateLocationNode(nodeID string, lineID int64, description string) (int64, error) {
-	res, err := db.Exec("INSERT INTO location_nodes (node_id, line_id, description) VALUES (?, ?, ?)", nodeID, lineID, description)
+func (db *DB) CreateLocationNode(nodeID string, lineID int64, description string, swapEmpty bool) (int64, error) {
+	res, err := db.Exec("INSERT INTO location_nodes (node_id, line_id, description, swap_empty) VALUES (?, ?, ?, ?)", nodeID, lineID, description, swapEmpty)
 	if err != nil {
 		return 0, err
 	}
 	return res.LastInsertId()
 }
 
-func (db *DB) UpdateLocationNode(id int64, nodeID string, lineID int64, description string) error {
-	_, err := db.Exec("UPDATE location_nodes SET node_id = ?, line_id = ?, description = ? WHERE id = ?", nodeID, lineID, description, id)
+func (db *DB) UpdateLocationNode(id int64, nodeID string, lineID int64, description string, swapEmpty bool) error {
+	_, err := db.Exec("UPDATE location_nodes SET node_id = ?, line_id = ?, description = ?, swap_empty = ? WHERE id = ?", nodeID, lineID, description, swapEmpty, id)
 	return err
 }
 
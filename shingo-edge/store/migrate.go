@@ -0,0 +1,253 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is one numbered schema change. Each migration runs in its own
+// transaction together with its schema_migrations row, so a failure leaves
+// the database at the previous version instead of half upgraded.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+	down    func(tx *sql.Tx) error // nil if the migration cannot be reverted
+}
+
+// migrations lists every schema change in version order. Append new
+// migrations at the end, usually built with execStep; never renumber or
+// edit one that has shipped.
+var migrations = []migration{
+	{version: 1, name: "baseline", up: migrateBaseline},
+	{
+		version: 2, name: "location_nodes_swap_empty",
+		up:   execStep(`ALTER TABLE location_nodes ADD COLUMN swap_empty INTEGER NOT NULL DEFAULT 0`),
+		down: execStep(`ALTER TABLE location_nodes DROP COLUMN swap_empty`),
+	},
+}
+
+// execStep returns a migration step that runs the given statements.
+func execStep(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+const migrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    name       TEXT NOT NULL,
+    applied_at TEXT NOT NULL DEFAULT (datetime('now','localtime'))
+)`
+
+// MigrationStatus is one migration and when it was applied. AppliedAt is
+// nil for a pending migration.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrations reports the known migrations followed by any applied versions
+// this build does not know about.
+func (db *DB) Migrations() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]MigrationStatus, len(applied))
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+	var out []MigrationStatus
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.version, Name: m.name}
+		if a, ok := byVersion[m.version]; ok {
+			st.AppliedAt = a.AppliedAt
+			delete(byVersion, m.version)
+		}
+		out = append(out, st)
+	}
+	for _, a := range applied {
+		if _, ok := byVersion[a.Version]; ok {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// Migrate applies every pending migration and returns how many it applied.
+// It refuses to touch a database whose applied versions do not match this
+// build, e.g. one already upgraded by a newer release.
+func (db *DB) Migrate() (int, error) {
+	return db.migrateUp(migrations)
+}
+
+// MigrateDown reverts the most recently applied migration and returns it.
+func (db *DB) MigrateDown() (*MigrationStatus, error) {
+	return db.migrateDown(migrations)
+}
+
+func (db *DB) migrateUp(list []migration) (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkApplied(applied, list); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, m := range list[len(applied):] {
+		err := db.inTx(func(tx *sql.Tx) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name)
+			return err
+		})
+		if err != nil {
+			return n, fmt.Errorf("migration %d (%s) failed, schema left at version %d: %w",
+				m.version, m.name, schemaVersion(list, len(applied)+n), err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (db *DB) migrateDown(list []migration) (*MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApplied(applied, list); err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		return nil, fmt.Errorf("no migrations applied")
+	}
+	m := list[len(applied)-1]
+	if m.down == nil {
+		return nil, fmt.Errorf("migration %d (%s) cannot be reverted", m.version, m.name)
+	}
+	err = db.inTx(func(tx *sql.Tx) error {
+		if err := m.down(tx); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version=?`, m.version)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("revert migration %d (%s): %w", m.version, m.name, err)
+	}
+	st := applied[len(applied)-1]
+	return &st, nil
+}
+
+// inTx runs fn in a transaction, committing if it returns nil. The
+// connection pool holds a single connection, so fn must only use tx.
+func (db *DB) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkApplied verifies the applied migrations are exactly the first ones
+// in list. Anything else means the schema is in a state this build cannot
+// reason about, so it stops rather than guess.
+func checkApplied(applied []MigrationStatus, list []migration) error {
+	for i, a := range applied {
+		if i < len(list) && list[i].version == a.Version {
+			continue
+		}
+		latest := schemaVersion(list, len(list))
+		if a.Version > latest {
+			return fmt.Errorf("database schema is at version %d but this build only knows up to %d; run a newer release or migrate down with one",
+				applied[len(applied)-1].Version, latest)
+		}
+		return fmt.Errorf("database has migration %d (%s) applied out of sequence; the schema needs manual repair",
+			a.Version, a.Name)
+	}
+	return nil
+}
+
+// schemaVersion is the version reached after applying the first n migrations.
+func schemaVersion(list []migration, n int) int {
+	if n == 0 {
+		return 0
+	}
+	return list[n-1].version
+}
+
+// appliedMigrations creates schema_migrations if needed and returns its
+// rows in version order.
+func (db *DB) appliedMigrations() ([]MigrationStatus, error) {
+	if _, err := db.Exec(migrationsTable); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	rows, err := db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	var out []MigrationStatus
+	for rows.Next() {
+		var st MigrationStatus
+		var appliedAt string
+		if err := rows.Scan(&st.Version, &st.Name, &appliedAt); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		t := scanTime(appliedAt)
+		st.AppliedAt = &t
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+
+// columnExists checks if a column exists in a table. A missing table has
+// no columns.
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("check %s.%s: %w", table, column, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notnull, &dflt, &pk); err != nil {
+			return false, fmt.Errorf("check %s.%s: %w", table, column, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// foreignKeyColumn reports whether a column references another table.
+func foreignKeyColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT \"from\" FROM pragma_foreign_key_list('%s')", table))
+	if err != nil {
+		return false, fmt.Errorf("check %s.%s foreign key: %w", table, column, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var from string
+		if err := rows.Scan(&from); err != nil {
+			return false, fmt.Errorf("check %s.%s foreign key: %w", table, column, err)
+		}
+		if from == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
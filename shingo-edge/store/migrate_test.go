@@ -0,0 +1,140 @@
+package store
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testDB opens a migrated database in a temporary directory.
+func testDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "edge.db"))
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	db := testDB(t)
+
+	// Roll the database back to what a pre-migration build left behind.
+	for _, q := range []string{
+		`DROP TABLE schema_migrations`,
+		`ALTER TABLE location_nodes DROP COLUMN swap_empty`,
+		`ALTER TABLE payloads DROP COLUMN auto_reorder`,
+		`CREATE TABLE materials (id INTEGER PRIMARY KEY)`,
+		`INSERT INTO orders (uuid, order_type, status) VALUES ('legacy-1', 'retrieve', 'queued')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	n, err := db.Migrate()
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if n != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", n, len(migrations))
+	}
+	if _, err := db.Exec(`SELECT auto_reorder FROM payloads`); err != nil {
+		t.Errorf("auto_reorder not added: %v", err)
+	}
+	if _, err := db.Exec(`SELECT swap_empty FROM location_nodes`); err != nil {
+		t.Errorf("swap_empty not added: %v", err)
+	}
+	if _, err := db.Exec(`SELECT 1 FROM materials`); err == nil {
+		t.Error("legacy materials table not dropped")
+	}
+	var status string
+	db.QueryRow(`SELECT status FROM orders WHERE uuid='legacy-1'`).Scan(&status)
+	if status != "pending" {
+		t.Errorf("status = %q, want pending", status)
+	}
+
+	if n, err := db.Migrate(); err != nil || n != 0 {
+		t.Errorf("second migrate = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db := testDB(t)
+	// Back down to the baseline, then run a list with test migrations after it
+	for v := len(migrations); v > 1; v-- {
+		if st, err := db.MigrateDown(); err != nil || st.Version != v {
+			t.Fatalf("migrate down = %+v, %v; want version %d", st, err, v)
+		}
+	}
+	list := append(append([]migration{}, migrations[0]),
+		migration{
+			version: 2, name: "widgets",
+			up:   execStep(`CREATE TABLE widgets (id INTEGER PRIMARY KEY)`),
+			down: execStep(`DROP TABLE widgets`),
+		},
+		migration{
+			version: 3, name: "broken",
+			up: execStep(`CREATE TABLE gadgets (id INTEGER); ALTER TABLE nope ADD COLUMN x INTEGER`),
+		},
+	)
+
+	n, err := db.migrateUp(list)
+	if n != 1 || err == nil || !strings.Contains(err.Error(), "schema left at version 2") {
+		t.Fatalf("migrate up = %d, %v; want 1 and a failure at version 3", n, err)
+	}
+	if _, err := db.Exec(`SELECT 1 FROM gadgets`); err == nil {
+		t.Error("failed migration left gadgets behind")
+	}
+	if _, err := db.Exec(`SELECT 1 FROM widgets`); err != nil {
+		t.Errorf("widgets missing: %v", err)
+	}
+
+	st, err := db.migrateDown(list)
+	if err != nil || st.Version != 2 {
+		t.Fatalf("migrate down = %+v, %v; want version 2", st, err)
+	}
+	if _, err := db.Exec(`SELECT 1 FROM widgets`); err == nil {
+		t.Error("widgets still present after down")
+	}
+	if _, err := db.migrateDown(list); err == nil || !strings.Contains(err.Error(), "cannot be reverted") {
+		t.Errorf("reverting baseline = %v, want an error", err)
+	}
+
+	status, err := db.Migrations()
+	if err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	if len(status) != len(migrations) || status[0].AppliedAt == nil {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestMigrateNewerSchema(t *testing.T) {
+	db := testDB(t)
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (99, 'from the future')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := db.Migrate(); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Errorf("migrate = %v, want a newer-schema error", err)
+	}
+	status, err := db.Migrations()
+	if err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	if last := status[len(status)-1]; last.Version != 99 || last.Name != "from the future" {
+		t.Errorf("unknown migration not reported: %+v", last)
+	}
+}
+
+// TestBaselineFrozen fails when the baseline snapshot is edited. Databases
+// already at version 1 never see such an edit; put it in a new migration.
+func TestBaselineFrozen(t *testing.T) {
+	const sum = "8f7cfcf257f02de1d43885016cdee3f883c2369b2a37d34075a19d4fb722867a"
+	if got := fmt.Sprintf("%x", sha256.Sum256([]byte(baselineSchema))); got != sum {
+		t.Errorf("baseline changed (sha256 %s); add a migration instead", got)
+	}
+}
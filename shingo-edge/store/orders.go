@@ -92,6 +92,20 @@ func (db *DB) ListActiveOrdersByLine(lineID int64) ([]Order, error) {
 	return scanOrders(rows)
 }
 
+// ListStagedOrdersByPayload returns orders for a payload that are held at their
+// staging node awaiting release, oldest first.
+func (db *DB) ListStagedOrdersByPayload(payloadID int64) ([]Order, error) {
+	rows, err := db.Query(`SELECT `+orderSelectCols+` `+orderJoin+`
+		WHERE o.status = 'staged'
+		AND o.payload_id = ?
+		ORDER BY o.created_at`, payloadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanOrders(rows)
+}
+
 func scanOrders(rows *sql.Rows) ([]Order, error) {
 	var orders []Order
 	for rows.Next() {
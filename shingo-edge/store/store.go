@@ -12,8 +12,23 @@ type DB struct {
 	*sql.DB
 }
 
-// Open opens (or creates) a SQLite database and runs migrations.
+// Open opens (or creates) a SQLite database and applies any pending
+// schema migrations.
 func Open(path string) (*DB, error) {
+	db, err := Connect(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return db, nil
+}
+
+// Connect opens (or creates) a SQLite database without touching its schema.
+// It is meant for the migrate command; everything else should use Open.
+func Connect(path string) (*DB, error) {
 	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=on", path)
 	sqlDB, err := sql.Open("sqlite", dsn)
 	if err != nil {
@@ -21,10 +36,5 @@ func Open(path string) (*DB, error) {
 	}
 	sqlDB.SetMaxOpenConns(1)
 
-	db := &DB{sqlDB}
-	if err := db.migrate(); err != nil {
-		sqlDB.Close()
-		return nil, fmt.Errorf("migrate: %w", err)
-	}
-	return db, nil
+	return &DB{sqlDB}, nil
 }
@@ -96,6 +96,39 @@ func (h *Handlers) apiCreateRetrieveOrder(w http.ResponseWriter, r *http.Request
 	writeJSON(w, order)
 }
 
+func (h *Handlers) apiCreateSwapOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PayloadID    int64   `json:"payload_id"`
+		Quantity     float64 `json:"quantity"`
+		DeliveryNode string  `json:"delivery_node"`
+		LoadType     string  `json:"load_type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var payloadID *int64
+	if req.PayloadID > 0 {
+		payloadID = &req.PayloadID
+		if p, err := h.engine.DB().GetPayload(req.PayloadID); err == nil {
+			if req.DeliveryNode == "" {
+				req.DeliveryNode = p.Location
+			}
+		}
+	}
+
+	order, err := h.engine.OrderManager().CreateSwapOrder(
+		payloadID, req.Quantity, req.DeliveryNode, req.LoadType,
+		h.engine.AppConfig().Web.AutoConfirm,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, order)
+}
+
 func (h *Handlers) apiCreateStoreOrder(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		PayloadID  int64   `json:"payload_id"`
@@ -302,6 +335,19 @@ func (h *Handlers) apiRedirectOrder(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, order)
 }
 
+func (h *Handlers) apiReleaseOrder(w http.ResponseWriter, r *http.Request) {
+	orderID, err := parseID(r, "orderID")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order ID")
+		return
+	}
+	if err := h.engine.OrderManager().ReleaseOrder(orderID, "released by operator"); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
 // --- Counter Anomalies ---
 
 func (h *Handlers) apiConfirmAnomaly(w http.ResponseWriter, r *http.Request) {
@@ -1030,6 +1076,7 @@ func (h *Handlers) apiCreateLocationNode(w http.ResponseWriter, r *http.Request)
 		NodeID      string `json:"node_id"`
 		LineID      int64  `json:"line_id"`
 		Description string `json:"description"`
+		SwapEmpty   bool   `json:"swap_empty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
@@ -1043,7 +1090,7 @@ func (h *Handlers) apiCreateLocationNode(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "line_id is required")
 		return
 	}
-	id, err := h.engine.DB().CreateLocationNode(req.NodeID, req.LineID, req.Description)
+	id, err := h.engine.DB().CreateLocationNode(req.NodeID, req.LineID, req.Description, req.SwapEmpty)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -1061,6 +1108,7 @@ func (h *Handlers) apiUpdateLocationNode(w http.ResponseWriter, r *http.Request)
 		NodeID      string `json:"node_id"`
 		LineID      int64  `json:"line_id"`
 		Description string `json:"description"`
+		SwapEmpty   bool   `json:"swap_empty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
@@ -1070,7 +1118,7 @@ func (h *Handlers) apiUpdateLocationNode(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "line_id is required")
 		return
 	}
-	if err := h.engine.DB().UpdateLocationNode(id, req.NodeID, req.LineID, req.Description); err != nil {
+	if err := h.engine.DB().UpdateLocationNode(id, req.NodeID, req.LineID, req.Description, req.SwapEmpty); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
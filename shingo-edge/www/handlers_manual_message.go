@@ -130,6 +130,14 @@ func (h *Handlers) apiSendManualMessage(w http.ResponseWriter, r *http.Request)
 		}
 		env, err = protocol.NewEnvelope(protocol.TypeOrderRedirect, src, dst, &p)
 
+	case "order.release":
+		var p protocol.OrderRelease
+		if e := json.Unmarshal(req.Payload, &p); e != nil {
+			writeError(w, http.StatusBadRequest, "invalid payload: "+e.Error())
+			return
+		}
+		env, err = protocol.NewEnvelope(protocol.TypeOrderRelease, src, dst, &p)
+
 	case "order.storage_waybill":
 		var p protocol.OrderStorageWaybill
 		if e := json.Unmarshal(req.Payload, &p); e != nil {
@@ -79,6 +79,25 @@ func NewRouter(eng *engine.Engine) (http.Handler, func()) {
 			}
 			return t.Format("2006-01-02 15:04:05")
 		},
+		// etaIn shows an RFC 3339 ETA from core as minutes from now, and any
+		// other ETA as sent.
+		"etaIn": func(eta *string) string {
+			if eta == nil || *eta == "" {
+				return "--"
+			}
+			t, err := time.Parse(time.RFC3339, *eta)
+			if err != nil {
+				return *eta
+			}
+			switch m := int(time.Until(t).Round(time.Minute).Minutes()); {
+			case m <= 0:
+				return "arriving"
+			case m == 1:
+				return "in 1 min"
+			default:
+				return fmt.Sprintf("in %d min", m)
+			}
+		},
 	}
 	h.tmpl = template.Must(template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/*.html", "templates/partials/*.html"))
 
@@ -139,10 +158,12 @@ func NewRouter(eng *engine.Engine) (http.Handler, func()) {
 		r.Post("/orders/retrieve", h.apiCreateRetrieveOrder)
 		r.Post("/orders/store", h.apiCreateStoreOrder)
 		r.Post("/orders/move", h.apiCreateMoveOrder)
+		r.Post("/orders/swap", h.apiCreateSwapOrder)
 		r.Post("/orders/{orderID}/submit", h.apiSubmitOrder)
 		r.Post("/orders/{orderID}/cancel", h.apiCancelOrder)
 		r.Post("/orders/{orderID}/abort", h.apiAbortOrder)
 		r.Post("/orders/{orderID}/redirect", h.apiRedirectOrder)
+		r.Post("/orders/{orderID}/release", h.apiReleaseOrder)
 		r.Post("/orders/{orderID}/count", h.apiSetOrderCount)
 		r.Put("/payloads/{id}/count", h.apiPayloadCount)
 		r.Put("/payloads/{id}/reorder-point", h.apiUpdateReorderPoint)